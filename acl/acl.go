@@ -0,0 +1,266 @@
+package acl
+
+import (
+	"crypto/x509"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// DNSPrefix marks entries that match a DNS name of the certificate's subject alternative names
+	DNSPrefix = "dns:"
+	// URIPrefix marks entries that match a URI of the certificate's subject alternative names
+	URIPrefix = "uri:"
+	// EmailPrefix marks entries that match an email address of the certificate's subject alternative names
+	EmailPrefix = "email:"
+	// RegexPrefix marks entries holding a regular expression that is matched against the certificate's DN
+	RegexPrefix = "regex:"
+)
+
+// Role represents the level of access that an identity has to the service
+type Role int
+
+const (
+	// NoRole identities can't access the service at all
+	NoRole Role = iota
+	// ReadOnlyRole identities can only inspect the service and its subscriptions
+	ReadOnlyRole
+	// AdminRole identities have full access to the service
+	AdminRole
+)
+
+// names of the roles as they appear in the configuration and in token claims
+const (
+	AdminRoleName    = "admin"
+	ReadOnlyRoleName = "read_only"
+)
+
+// String returns the name of the role
+func (r Role) String() string {
+	switch r {
+	case AdminRole:
+		return AdminRoleName
+	case ReadOnlyRole:
+		return ReadOnlyRoleName
+	}
+	return "none"
+}
+
+// ParseRole returns the role with the provided name
+func ParseRole(name string) (Role, error) {
+	switch name {
+	case AdminRoleName:
+		return AdminRole, nil
+	case ReadOnlyRoleName:
+		return ReadOnlyRole, nil
+	}
+	return NoRole, errors.Errorf("Invalid role %v", name)
+}
+
+// List holds the ACL entries of each role
+type List struct {
+	Admin    []string
+	ReadOnly []string
+}
+
+// compiled caches the compiled regular expressions of the regex entries
+var compiled sync.Map
+
+// Role returns the highest role that any of the ACL entries grants to the certificate
+func (l List) Role(cert *x509.Certificate) Role {
+
+	for _, entry := range l.Admin {
+		if Match(entry, cert) {
+			return AdminRole
+		}
+	}
+
+	for _, entry := range l.ReadOnly {
+		if Match(entry, cert) {
+			return ReadOnlyRole
+		}
+	}
+
+	return NoRole
+}
+
+// Validate checks whether or not the provided ACL entry is well formed
+func Validate(entry string) error {
+
+	if pattern, ok := strings.CutPrefix(entry, RegexPrefix); ok {
+		_, err := compile(pattern)
+		if err != nil {
+			return errors.Errorf("Invalid ACL entry %v, %v", entry, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Match checks whether or not the provided ACL entry matches the certificate.
+// Entries prefixed with dns:, uri: or email: match the respective subject alternative names,
+// entries prefixed with regex: hold a regular expression that should match the whole of the certificate's RFC 4514 DN,
+// entries containing a = are compared to the certificate's DN, in either order of its attributes,
+// while any other entry is compared to the certificate's common name
+func Match(entry string, cert *x509.Certificate) bool {
+
+	if cert == nil {
+		return false
+	}
+
+	switch {
+
+	case strings.HasPrefix(entry, DNSPrefix):
+
+		name := strings.TrimPrefix(entry, DNSPrefix)
+		for _, dns := range cert.DNSNames {
+			if strings.EqualFold(dns, name) {
+				return true
+			}
+		}
+
+	case strings.HasPrefix(entry, URIPrefix):
+
+		uri := strings.TrimPrefix(entry, URIPrefix)
+		for _, u := range cert.URIs {
+			if u.String() == uri {
+				return true
+			}
+		}
+
+	case strings.HasPrefix(entry, EmailPrefix):
+
+		email := strings.TrimPrefix(entry, EmailPrefix)
+		for _, e := range cert.EmailAddresses {
+			if strings.EqualFold(e, email) {
+				return true
+			}
+		}
+
+	case strings.HasPrefix(entry, RegexPrefix):
+
+		re, err := regex(strings.TrimPrefix(entry, RegexPrefix))
+		if err != nil {
+			return false
+		}
+		return re.MatchString(cert.Subject.String())
+
+	case strings.Contains(entry, "="):
+
+		return matchDN(entry, cert.Subject.String())
+
+	default:
+
+		return entry == cert.Subject.CommonName
+	}
+
+	return false
+}
+
+// regex returns the compiled regular expression of the pattern, compiling it only once
+func regex(pattern string) (*regexp.Regexp, error) {
+
+	if re, ok := compiled.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled.Store(pattern, re)
+
+	return re, nil
+}
+
+// compile compiles the pattern of a regex entry anchored at both ends, so that it has to match the whole DN
+// and not just a part of it, e.g. an attribute value chosen by whoever requested the certificate
+func compile(pattern string) (*regexp.Regexp, error) {
+
+	// report the errors of the pattern as it was configured
+	_, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// matchDN compares two DNs attribute by attribute, the entry may list the attributes
+// either in RFC 4514 order(CN first) or in the reverse order that tools like openssl print them
+func matchDN(entry string, dn string) bool {
+
+	e := splitDN(entry)
+	d := splitDN(dn)
+
+	if len(e) != len(d) {
+		return false
+	}
+
+	inOrder, reversed := true, true
+
+	for idx := range e {
+		if !strings.EqualFold(e[idx], d[idx]) {
+			inOrder = false
+		}
+		if !strings.EqualFold(e[idx], d[len(d)-1-idx]) {
+			reversed = false
+		}
+	}
+
+	return inOrder || reversed
+}
+
+// splitDN splits a DN to its attributes on unescaped commas, or slashes for openssl style DNs,
+// and normalises the spacing around each attribute
+func splitDN(dn string) []string {
+
+	dn = strings.TrimSpace(dn)
+
+	sep := byte(',')
+	if strings.HasPrefix(dn, "/") {
+		sep = '/'
+		dn = dn[1:]
+	}
+
+	var rdns []string
+	current := strings.Builder{}
+
+	for idx := 0; idx < len(dn); idx++ {
+
+		c := dn[idx]
+
+		if c == '\\' && idx+1 < len(dn) {
+			current.WriteByte(c)
+			current.WriteByte(dn[idx+1])
+			idx++
+			continue
+		}
+
+		if c == sep {
+			rdns = append(rdns, normaliseRDN(current.String()))
+			current.Reset()
+			continue
+		}
+
+		current.WriteByte(c)
+	}
+
+	rdns = append(rdns, normaliseRDN(current.String()))
+
+	return rdns
+}
+
+// normaliseRDN trims the spaces around the type and the value of an attribute
+func normaliseRDN(rdn string) string {
+
+	attrType, value, found := strings.Cut(rdn, "=")
+	if !found {
+		return strings.TrimSpace(rdn)
+	}
+
+	return strings.ToUpper(strings.TrimSpace(attrType)) + "=" + strings.TrimSpace(value)
+}
@@ -0,0 +1,118 @@
+package acl
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/suite"
+	"net/url"
+	"testing"
+)
+
+type ACLTestSuite struct {
+	suite.Suite
+}
+
+func testCertificate() *x509.Certificate {
+
+	spiffe, _ := url.Parse("spiffe://example.com/monitoring")
+
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "local.example.com",
+			OrganizationalUnit: []string{"my.local"},
+			Organization:       []string{"mkcert development certificate"},
+		},
+		DNSNames:       []string{"local.example.com", "alt.example.com"},
+		URIs:           []*url.URL{spiffe},
+		EmailAddresses: []string{"ops@example.com"},
+	}
+}
+
+// TestMatch tests the matching of each kind of ACL entry
+func (suite *ACLTestSuite) TestMatch() {
+
+	cert := testCertificate()
+
+	suite.Equal("CN=local.example.com,OU=my.local,O=mkcert development certificate", cert.Subject.String())
+
+	// common name
+	suite.True(Match("local.example.com", cert))
+	suite.False(Match("alt.example.com", cert))
+
+	// full DN
+	suite.True(Match("CN=local.example.com,OU=my.local,O=mkcert development certificate", cert))
+	suite.True(Match("cn=local.example.com, ou=my.local, o=mkcert development certificate", cert))
+	suite.True(Match("O=mkcert development certificate,OU=my.local,CN=local.example.com", cert))
+	suite.True(Match("/O=mkcert development certificate/OU=my.local/CN=local.example.com", cert))
+	suite.False(Match("OU=my.local,O=mkcert development certificate", cert))
+	suite.False(Match("CN=other.example.com,OU=my.local,O=mkcert development certificate", cert))
+
+	// subject alternative names
+	suite.True(Match("dns:alt.example.com", cert))
+	suite.False(Match("dns:other.example.com", cert))
+	suite.True(Match("uri:spiffe://example.com/monitoring", cert))
+	suite.False(Match("uri:spiffe://example.com/admin", cert))
+	suite.True(Match("email:OPS@example.com", cert))
+	suite.False(Match("email:dev@example.com", cert))
+
+	// regular expressions
+	suite.True(Match("regex:^CN=[a-z]+\\.example\\.com,.*", cert))
+	suite.True(Match("regex:CN=local\\.example\\.com,OU=my\\.local,O=.+", cert))
+	suite.False(Match("regex:^CN=admin", cert))
+	suite.False(Match("regex:(", cert))
+
+	// the patterns have to match the whole DN, not just a part of it
+	suite.False(Match("regex:OU=my\\.local", cert))
+	suite.False(Match("regex:local", cert))
+	suite.False(Match("regex:^CN=[a-z]+\\.example\\.com,", cert))
+
+	suite.False(Match("local.example.com", nil))
+}
+
+// TestRole tests the mapping of a certificate to a role
+func (suite *ACLTestSuite) TestRole() {
+
+	cert := testCertificate()
+
+	suite.Equal(AdminRole, List{Admin: []string{"local.example.com"}}.Role(cert))
+	suite.Equal(AdminRole, List{Admin: []string{"dns:alt.example.com"}, ReadOnly: []string{"local.example.com"}}.Role(cert))
+	suite.Equal(ReadOnlyRole, List{Admin: []string{"admin.example.com"}, ReadOnly: []string{"uri:spiffe://example.com/monitoring"}}.Role(cert))
+	suite.Equal(NoRole, List{Admin: []string{"admin.example.com"}}.Role(cert))
+	suite.Equal(NoRole, List{}.Role(cert))
+}
+
+// TestValidate tests the validation of ACL entries
+// TestParseRole tests the mapping between the roles and their names
+func (suite *ACLTestSuite) TestParseRole() {
+
+	r1, e1 := ParseRole("admin")
+	suite.Nil(e1)
+	suite.Equal(AdminRole, r1)
+	suite.Equal("admin", r1.String())
+
+	r2, e2 := ParseRole("read_only")
+	suite.Nil(e2)
+	suite.Equal(ReadOnlyRole, r2)
+	suite.Equal("read_only", r2.String())
+
+	r3, e3 := ParseRole("root")
+	suite.Equal("Invalid role root", e3.Error())
+	suite.Equal(NoRole, r3)
+	suite.Equal("none", r3.String())
+}
+
+func (suite *ACLTestSuite) TestValidate() {
+	suite.Nil(Validate("local.example.com"))
+	suite.Nil(Validate("regex:^CN=.*"))
+	suite.Equal("Invalid ACL entry regex:(, error parsing regexp: missing closing ): `(`", Validate("regex:(").Error())
+}
+
+// TestSplitDN tests the splitting of DNs with escaped separators
+func (suite *ACLTestSuite) TestSplitDN() {
+	suite.Equal([]string{"CN=a\\,b", "O=org"}, splitDN("cn = a\\,b , O=org"))
+	suite.Equal([]string{"O=org", "CN=a"}, splitDN("/O=org/CN=a"))
+}
+
+func TestACLTestSuite(t *testing.T) {
+	suite.Run(t, new(ACLTestSuite))
+}
@@ -27,17 +27,23 @@ export GOPATH=$PWD
 export PATH=$PATH:$GOPATH/bin
 
 cd src/github.com/ARGOeu/ams-push-server/
-go install
+go install -ldflags "-X main.version=%{version}"
+go install ./cmd/ams-push-ctl
 
 %install
 %{__rm} -rf %{buildroot}
 install --directory %{buildroot}/var/www/ams-push-server
 install --mode 755 bin/ams-push-server %{buildroot}/var/www/ams-push-server/ams-push-server
 
+install --directory %{buildroot}/usr/bin
+install --mode 755 bin/ams-push-ctl %{buildroot}/usr/bin/ams-push-ctl
+
 install --directory %{buildroot}/etc/ams-push-server
 install --directory %{buildroot}/etc/ams-push-server/conf.d
 install --mode 644 src/github.com/ARGOeu/ams-push-server/conf/ams-push-server-config.template %{buildroot}/etc/ams-push-server/conf.d/ams-push-server-config.json
 
+install --directory %{buildroot}/var/lib/ams-push-server
+
 install --directory %{buildroot}/usr/lib/systemd/system
 install --mode 644 src/github.com/ARGOeu/ams-push-server/ams-push-server.service %{buildroot}/usr/lib/systemd/system/
 
@@ -52,7 +58,9 @@ go clean
 %attr(0750,ams-push-server,ams-push-server) /var/www/ams-push-server
 %attr(0755,ams-push-server,ams-push-server) /var/www/ams-push-server/ams-push-server
 %caps(cap_net_bind_service=+ep) /var/www/ams-push-server/ams-push-server
+%attr(0755,root,root) /usr/bin/ams-push-ctl
 %config(noreplace) %attr(0644,ams-push-server,ams-push-server) /etc/ams-push-server/conf.d/ams-push-server-config.json
+%dir %attr(0700,ams-push-server,ams-push-server) /var/lib/ams-push-server
 %attr(0644,root,root) /usr/lib/systemd/system/ams-push-server.service
 
 %changelog
@@ -0,0 +1,171 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ARGOeu/ams-push-server/acl"
+	"github.com/ARGOeu/ams-push-server/config"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"strings"
+)
+
+// ErrNoCredentials is returned by an auth provider when the call carries no credentials that the provider understands
+var ErrNoCredentials = errors.New("no credentials provided")
+
+// Identity is an authenticated caller of the service
+type Identity struct {
+	// Name of the caller, the DN of its certificate, the name of its static token or the subject of its jwt
+	Name string
+	// Provider that authenticated the caller
+	Provider string
+	// Role of the caller
+	Role acl.Role
+}
+
+// AuthProvider authenticates the callers of the service
+type AuthProvider interface {
+	// Authenticate returns the identity of the caller based on the credentials of the call
+	Authenticate(ctx context.Context) (Identity, error)
+}
+
+// identityKey is the context key under which the identity of the caller is stored
+type identityKey struct{}
+
+// NewIdentityContext returns a new context that carries the identity of the caller
+func NewIdentityContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity of the caller stored in the context, if any
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// NewAuthProvider builds the auth provider described by the configuration, chaining the configured providers in order.
+// It returns nil if no provider is configured, meaning that the service is open to everyone
+func NewAuthProvider(cfg *config.Config) (AuthProvider, error) {
+
+	var chain ChainAuthProvider
+
+	for _, name := range cfg.GetAuthProviders() {
+
+		switch name {
+
+		case config.MTLSAuthProvider:
+			chain = append(chain, NewMTLSAuthProvider(cfg.GetACLList))
+
+		case config.TokenAuthProvider:
+			chain = append(chain, NewTokenAuthProvider(cfg.GetAuthTokens))
+
+		case config.JWTAuthProvider:
+			p, err := NewJWTAuthProvider(cfg.JWTAuth.JWKSFile, cfg.JWTAuth.Issuer, cfg.JWTAuth.Audience, cfg.GetJWTRoleClaim())
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, p)
+
+		default:
+			return nil, fmt.Errorf("auth provider %v not yet implemented", name)
+		}
+	}
+
+	if len(chain) == 0 {
+		return nil, nil
+	}
+
+	return chain, nil
+}
+
+// ChainAuthProvider tries its providers in order and returns the identity from the first one that authenticates the caller
+type ChainAuthProvider []AuthProvider
+
+// Authenticate returns the identity of the caller from the first provider that succeeds.
+// If all providers fail, the errors of those that found credentials in the call are returned
+func (c ChainAuthProvider) Authenticate(ctx context.Context) (Identity, error) {
+
+	var errs []error
+
+	for _, p := range c {
+
+		id, err := p.Authenticate(ctx)
+		if err == nil {
+			return id, nil
+		}
+
+		if !errors.Is(err, ErrNoCredentials) {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return Identity{}, ErrNoCredentials
+	}
+
+	return Identity{}, errors.Join(errs...)
+}
+
+// MTLSAuthProvider authenticates callers through their client certificates and the ACL
+type MTLSAuthProvider struct {
+	getACL func() acl.List
+}
+
+// NewMTLSAuthProvider initialises and returns a new mtls auth provider,
+// the ACL is retrieved on every call so that it can change while the service is running
+func NewMTLSAuthProvider(getACL func() acl.List) *MTLSAuthProvider {
+	return &MTLSAuthProvider{
+		getACL: getACL,
+	}
+}
+
+// Authenticate returns the identity of the caller that its certificate grants through the ACL
+func (p *MTLSAuthProvider) Authenticate(ctx context.Context) (Identity, error) {
+
+	pr, ok := peer.FromContext(ctx)
+	if !ok || pr == nil {
+		return Identity{}, fmt.Errorf("%w, no peer information found in the context", ErrNoCredentials)
+	}
+
+	if pr.AuthInfo == nil || pr.AuthInfo.AuthType() != "tls" {
+		return Identity{}, fmt.Errorf("%w, the connection doesn't use tls", ErrNoCredentials)
+	}
+
+	tlsInfo := pr.AuthInfo.(credentials.TLSInfo)
+	if len(tlsInfo.State.PeerCertificates) == 0 {
+		return Identity{}, fmt.Errorf("%w, no certificate provided", ErrNoCredentials)
+	}
+
+	cert := tlsInfo.State.PeerCertificates[0]
+
+	role := p.getACL().Role(cert)
+	if role == acl.NoRole {
+		return Identity{}, fmt.Errorf("provided certificate %v didn't match any ACL entry", cert.Subject.String())
+	}
+
+	return Identity{
+		Name:     cert.Subject.String(),
+		Provider: config.MTLSAuthProvider,
+		Role:     role,
+	}, nil
+}
+
+// bearerToken returns the bearer token found in the authorization metadata of the call
+func bearerToken(ctx context.Context) string {
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	for _, value := range md.Get("authorization") {
+		scheme, token, found := strings.Cut(value, " ")
+		if found && strings.EqualFold(scheme, "bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	return ""
+}
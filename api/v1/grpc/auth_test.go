@@ -0,0 +1,148 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/ARGOeu/ams-push-server/acl"
+	"github.com/ARGOeu/ams-push-server/config"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"testing"
+)
+
+type AuthTestSuite struct {
+	suite.Suite
+}
+
+// MockAuthProvider returns the same identity and error on every call
+type MockAuthProvider struct {
+	id  Identity
+	err error
+}
+
+func (p *MockAuthProvider) Authenticate(ctx context.Context) (Identity, error) {
+	return p.id, p.err
+}
+
+// TestChainAuthProvider tests that the chain returns the identity of the first provider that authenticates the caller
+func (suite *AuthTestSuite) TestChainAuthProvider() {
+
+	noCreds := &MockAuthProvider{err: ErrNoCredentials}
+	failed := &MockAuthProvider{err: errors.New("invalid credentials")}
+	admin := &MockAuthProvider{id: Identity{Name: "admin", Provider: "token", Role: acl.AdminRole}}
+
+	// the first provider that succeeds wins
+	id1, e1 := ChainAuthProvider{noCreds, failed, admin}.Authenticate(context.Background())
+	suite.Nil(e1)
+	suite.Equal(Identity{Name: "admin", Provider: "token", Role: acl.AdminRole}, id1)
+
+	// providers that found no credentials are not reported
+	_, e2 := ChainAuthProvider{noCreds, failed}.Authenticate(context.Background())
+	suite.Equal("invalid credentials", e2.Error())
+
+	_, e3 := ChainAuthProvider{noCreds, noCreds}.Authenticate(context.Background())
+	suite.True(errors.Is(e3, ErrNoCredentials))
+}
+
+// TestMTLSAuthProvider tests the authentication of callers through their certificates
+func (suite *AuthTestSuite) TestMTLSAuthProvider() {
+
+	p := NewMTLSAuthProvider(func() acl.List {
+		return acl.List{Admin: []string{"local.example.com"}}
+	})
+
+	certCtx := func(cert *x509.Certificate) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{
+				State: tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{cert},
+				},
+			},
+		})
+	}
+
+	id1, e1 := p.Authenticate(certCtx(&x509.Certificate{Subject: pkix.Name{CommonName: "local.example.com"}}))
+	suite.Nil(e1)
+	suite.Equal(Identity{Name: "CN=local.example.com", Provider: "mtls", Role: acl.AdminRole}, id1)
+
+	_, e2 := p.Authenticate(certCtx(&x509.Certificate{Subject: pkix.Name{CommonName: "other.example.com"}}))
+	suite.Equal("provided certificate CN=other.example.com didn't match any ACL entry", e2.Error())
+
+	// calls without a certificate carry no credentials for the provider
+	_, e3 := p.Authenticate(context.Background())
+	suite.True(errors.Is(e3, ErrNoCredentials))
+
+	_, e4 := p.Authenticate(peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{},
+	}))
+	suite.True(errors.Is(e4, ErrNoCredentials))
+}
+
+// TestNewAuthProvider tests that the configured providers are chained in order
+func (suite *AuthTestSuite) TestNewAuthProvider() {
+
+	// no providers and no tls, the service is open
+	cfg1 := config.NewMockConfig()
+	p1, e1 := NewAuthProvider(cfg1)
+	suite.Nil(e1)
+	suite.Nil(p1)
+
+	// mtls is the default when tls is enabled
+	cfg2 := config.NewMockConfig()
+	cfg2.TLSEnabled = true
+	p2, e2 := NewAuthProvider(cfg2)
+	suite.Nil(e2)
+	suite.Equal(1, len(p2.(ChainAuthProvider)))
+	suite.IsType(&MTLSAuthProvider{}, p2.(ChainAuthProvider)[0])
+
+	cfg3 := config.NewMockConfig()
+	cfg3.AuthProviders = []string{"mtls", "token"}
+	p3, e3 := NewAuthProvider(cfg3)
+	suite.Nil(e3)
+	suite.Equal(2, len(p3.(ChainAuthProvider)))
+	suite.IsType(&MTLSAuthProvider{}, p3.(ChainAuthProvider)[0])
+	suite.IsType(&TokenAuthProvider{}, p3.(ChainAuthProvider)[1])
+
+	// the jwks file can't be loaded
+	cfg4 := config.NewMockConfig()
+	cfg4.AuthProviders = []string{"jwt"}
+	cfg4.JWTAuth.JWKSFile = "/missing/jwks.json"
+	_, e4 := NewAuthProvider(cfg4)
+	suite.Equal("Could not load JWKS file /missing/jwks.json, open /missing/jwks.json: no such file or directory", e4.Error())
+}
+
+// TestBearerToken tests the extraction of bearer tokens from the metadata of a call
+func (suite *AuthTestSuite) TestBearerToken() {
+
+	suite.Equal("", bearerToken(context.Background()))
+
+	ctx1 := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer abc"))
+	suite.Equal("abc", bearerToken(ctx1))
+
+	ctx2 := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer abc"))
+	suite.Equal("abc", bearerToken(ctx2))
+
+	ctx3 := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Basic abc"))
+	suite.Equal("", bearerToken(ctx3))
+}
+
+// TestIdentityContext tests that the identity of the caller can be stored and retrieved from a context
+func (suite *AuthTestSuite) TestIdentityContext() {
+
+	_, ok := IdentityFromContext(context.Background())
+	suite.False(ok)
+
+	id := Identity{Name: "admin", Provider: "token", Role: acl.AdminRole}
+	id1, ok1 := IdentityFromContext(NewIdentityContext(context.Background(), id))
+	suite.True(ok1)
+	suite.Equal(id, id1)
+}
+
+func TestAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
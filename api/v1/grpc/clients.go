@@ -0,0 +1,134 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/ARGOeu/ams-push-server/config"
+	"github.com/ARGOeu/ams-push-server/netpolicy"
+	"golang.org/x/net/http/httpproxy"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// IdleConnTimeout is how long an idle connection of an http client is kept open
+const IdleConnTimeout = 90 * time.Second
+
+// newHTTPClient builds an http client out of its configuration, connecting through the provided dialer
+func newHTTPClient(c config.HTTPClient, verifySSL bool, dialer *net.Dialer) *http.Client {
+
+	dialer.Timeout = c.GetDialTimeout()
+
+	transCfg := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: !verifySSL,
+		},
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   c.GetTLSHandshakeTimeout(),
+		ResponseHeaderTimeout: c.GetResponseHeaderTimeout(),
+		MaxIdleConnsPerHost:   c.GetMaxIdleConnsPerHost(),
+		IdleConnTimeout:       IdleConnTimeout,
+		ForceAttemptHTTP2:     c.HTTP2,
+		Proxy:                 proxyFunc(c.Proxy),
+	}
+
+	return &http.Client{
+		Transport: transCfg,
+		Timeout:   c.GetTimeout(),
+	}
+}
+
+// newPushClient builds the client that pushes to the destinations of the subscriptions, refusing to reach any of them
+// that the destination policy denies. Direct requests are checked by the dialer, right before it connects. Proxied
+// requests only connect to the proxy, which may well be on a denied network, so the dialer lets the proxies through
+// and the destination of every proxied request is checked against the policy before it is handed to its proxy
+func newPushClient(c config.HTTPClient, verifySSL bool, policy *netpolicy.Policy) *http.Client {
+
+	dialer := policy.Dialer()
+	client := newHTTPClient(c, verifySSL, dialer)
+
+	transport := client.Transport.(*http.Transport)
+	if transport.Proxy == nil {
+		return client
+	}
+
+	proxy := transport.Proxy
+	transport.Proxy = func(r *http.Request) (*url.URL, error) {
+
+		u, err := proxy(r)
+		if err != nil || u == nil {
+			return u, err
+		}
+
+		err = policy.CheckURL(r.Context(), r.URL.String())
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	}
+
+	proxies := proxyAddresses(c.Proxy)
+	proxyDialer := &net.Dialer{Timeout: dialer.Timeout, KeepAlive: dialer.KeepAlive}
+
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		if proxies[address] {
+			return proxyDialer.DialContext(ctx, network, address)
+		}
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	return client
+}
+
+// proxyAddresses returns the host:port addresses of the configured proxies, as the transport dials them
+func proxyAddresses(p config.Proxy) map[string]bool {
+
+	defaultPorts := map[string]string{
+		"http":    "80",
+		"https":   "443",
+		"socks5":  "1080",
+		"socks5h": "1080",
+	}
+
+	addresses := make(map[string]bool)
+
+	for _, proxy := range []string{p.HTTPProxy, p.HTTPSProxy} {
+
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			continue
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = defaultPorts[u.Scheme]
+		}
+
+		addresses[net.JoinHostPort(u.Hostname(), port)] = true
+	}
+
+	return addresses
+}
+
+// proxyFunc returns the function that picks the proxy of every request, or nil if no proxy is configured
+func proxyFunc(p config.Proxy) func(*http.Request) (*url.URL, error) {
+
+	if p.HTTPProxy == "" && p.HTTPSProxy == "" {
+		return nil
+	}
+
+	proxyCfg := &httpproxy.Config{
+		HTTPProxy:  p.HTTPProxy,
+		HTTPSProxy: p.HTTPSProxy,
+		NoProxy:    strings.Join(p.NoProxy, ","),
+	}
+
+	f := proxyCfg.ProxyFunc()
+
+	return func(r *http.Request) (*url.URL, error) {
+		return f(r.URL)
+	}
+}
@@ -0,0 +1,124 @@
+package grpc
+
+import (
+	"github.com/ARGOeu/ams-push-server/config"
+	"github.com/ARGOeu/ams-push-server/netpolicy"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type ClientsTestSuite struct {
+	suite.Suite
+}
+
+// TestNewHTTPClient tests that the defaults apply to any unset field of the client configuration
+func (suite *ClientsTestSuite) TestNewHTTPClient() {
+
+	c1 := newHTTPClient(config.HTTPClient{}, true, new(net.Dialer))
+	t1 := c1.Transport.(*http.Transport)
+
+	suite.Equal(30*time.Second, c1.Timeout)
+	suite.Equal(10*time.Second, t1.TLSHandshakeTimeout)
+	suite.Equal(time.Duration(0), t1.ResponseHeaderTimeout)
+	suite.Equal(2, t1.MaxIdleConnsPerHost)
+	suite.Equal(IdleConnTimeout, t1.IdleConnTimeout)
+	suite.False(t1.ForceAttemptHTTP2)
+	suite.False(t1.TLSClientConfig.InsecureSkipVerify)
+	suite.Nil(t1.Proxy)
+
+	dialer := new(net.Dialer)
+	c2 := newHTTPClient(config.HTTPClient{
+		DialTimeout:           1,
+		TLSHandshakeTimeout:   2,
+		ResponseHeaderTimeout: 3,
+		Timeout:               4,
+		MaxIdleConnsPerHost:   5,
+		HTTP2:                 true,
+	}, false, dialer)
+	t2 := c2.Transport.(*http.Transport)
+
+	suite.Equal(time.Second, dialer.Timeout)
+	suite.Equal(2*time.Second, t2.TLSHandshakeTimeout)
+	suite.Equal(3*time.Second, t2.ResponseHeaderTimeout)
+	suite.Equal(4*time.Second, c2.Timeout)
+	suite.Equal(5, t2.MaxIdleConnsPerHost)
+	suite.True(t2.ForceAttemptHTTP2)
+	suite.True(t2.TLSClientConfig.InsecureSkipVerify)
+}
+
+// TestProxyFunc tests that requests are routed through the proxy of their scheme, unless their host is excluded
+func (suite *ClientsTestSuite) TestProxyFunc() {
+
+	f := proxyFunc(config.Proxy{
+		HTTPProxy:  "http://proxy.example.com:3128",
+		HTTPSProxy: "socks5://socks.example.com:1080",
+		NoProxy:    []string{"internal.example.com", ".local.example.com", "10.0.0.0/8"},
+	})
+
+	proxy := func(rawURL string) string {
+		r, _ := http.NewRequest(http.MethodPost, rawURL, nil)
+		u, err := f(r)
+		suite.Nil(err)
+		if u == nil {
+			return ""
+		}
+		return u.String()
+	}
+
+	suite.Equal("http://proxy.example.com:3128", proxy("http://receiver.example.com/push"))
+	suite.Equal("socks5://socks.example.com:1080", proxy("https://receiver.example.com/push"))
+	suite.Equal("", proxy("https://internal.example.com/push"))
+	suite.Equal("", proxy("https://a.local.example.com/push"))
+	suite.Equal("", proxy("https://10.1.2.3/push"))
+
+	suite.Nil(proxyFunc(config.Proxy{NoProxy: []string{"example.com"}}))
+}
+
+// TestNewPushClient tests that the destination policy applies to the destinations of the proxied requests,
+// rather than to the proxy that they connect to
+func (suite *ClientsTestSuite) TestNewPushClient() {
+
+	proxied := make([]string, 0)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+
+	// the default policy denies the loopback network of the proxy
+	policy, err := netpolicy.New(nil, nil, nil)
+	suite.Nil(err)
+
+	client := newPushClient(config.HTTPClient{
+		Proxy: config.Proxy{HTTPProxy: proxy.URL},
+	}, true, policy)
+
+	// an allowed destination is reached through the proxy
+	resp, err := client.Post("http://203.0.113.10/push", "application/json", nil)
+	suite.Nil(err)
+	resp.Body.Close()
+	suite.Equal([]string{"http://203.0.113.10/push"}, proxied)
+
+	// a denied destination is refused before it reaches the proxy
+	_, err = client.Post("http://10.1.2.3/push", "application/json", nil)
+	suite.ErrorContains(err, "address 10.1.2.3 is not allowed")
+	suite.Equal(1, len(proxied))
+
+	// the destinations that are reached directly are still checked by the dialer
+	_, err = newPushClient(config.HTTPClient{}, true, policy).Post(proxy.URL, "application/json", nil)
+	suite.ErrorContains(err, "address 127.0.0.1 is not allowed")
+	suite.Equal(1, len(proxied))
+
+	suite.Equal(map[string]bool{"127.0.0.1:3128": true, "proxy.example.com:1080": true},
+		proxyAddresses(config.Proxy{HTTPProxy: "http://127.0.0.1:3128", HTTPSProxy: "socks5://proxy.example.com"}))
+}
+
+func TestClientsTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(ClientsTestSuite))
+}
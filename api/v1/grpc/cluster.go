@@ -0,0 +1,475 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/audit"
+	"github.com/ARGOeu/ams-push-server/cluster"
+	"github.com/ARGOeu/ams-push-server/config"
+	"github.com/ARGOeu/ams-push-server/push"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	gRPCHealth "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"sync"
+	"time"
+)
+
+// forwardedHeader marks a call that another instance of the cluster forwarded to the owner of its subscription.
+// A forwarded call is handled locally, so that instances that briefly disagree on the owner don't forward it back and forth
+const forwardedHeader = "x-ams-push-forwarded-by"
+
+// peerConns holds the connections to the other instances of the cluster, dialed the first time they are needed
+type peerConns struct {
+	mutex sync.Mutex
+	conns map[string]*grpc.ClientConn
+	// address every connection was dialed to, a peer that moves to another address is dialed again
+	addresses map[string]string
+}
+
+// toPeers maps the configured peers to the peers of the cluster
+func toPeers(peers []config.Peer) []cluster.Peer {
+
+	cp := make([]cluster.Peer, 0, len(peers))
+	for _, p := range peers {
+		cp = append(cp, cluster.Peer{Name: p.Name, Address: p.Address})
+	}
+
+	return cp
+}
+
+// initCluster joins the cluster of the configured peers. The peers are probed once before any subscription
+// is restored or loaded, so that the instance starts with the subscriptions it owns among the live instances
+func (ps *PushService) initCluster() {
+
+	c := ps.Cfg.GetCluster()
+
+	ps.Cluster = cluster.New(c.Self, toPeers(c.Peers), c.GetVirtualNodes())
+	ps.peerConns.conns = make(map[string]*grpc.ClientConn)
+	ps.peerConns.addresses = make(map[string]string)
+
+	ps.Cluster.ProbeAll(context.Background(), ps.probePeer)
+	live, down := ps.Cluster.Live()
+
+	log.WithFields(
+		log.Fields{
+			"type": "system_log",
+			"self": c.Self,
+			"live": live,
+			"down": down,
+		},
+	).Info("Joined the cluster")
+
+	// hand offs wait for workers to drain, they shouldn't hold up the probes or the reloads
+	ps.Cluster.OnChange(func() {
+		go ps.rebalance()
+	})
+	ps.Cfg.OnReload(ps.reloadCluster)
+
+	go ps.clusterLoop()
+}
+
+// clusterLoop probes the other instances of the cluster every health interval, until the service shuts down
+func (ps *PushService) clusterLoop() {
+
+	for {
+
+		time.Sleep(ps.Cfg.GetCluster().GetHealthInterval())
+
+		if ps.shuttingDown.Load() {
+			return
+		}
+
+		ps.Cluster.ProbeAll(context.Background(), ps.probePeer)
+	}
+}
+
+// reloadCluster applies the reloaded peers of the cluster, cluster mode itself can't be enabled or disabled live
+func (ps *PushService) reloadCluster(cfg *config.Config) {
+
+	c := cfg.GetCluster()
+
+	if ps.Cluster == nil || !c.Enabled() {
+		if (ps.Cluster == nil) == c.Enabled() {
+			log.WithFields(
+				log.Fields{
+					"type": "system_log",
+				},
+			).Warning("Cluster mode can't be enabled or disabled without a restart")
+		}
+		return
+	}
+
+	ps.closeStalePeerConns(c.Peers)
+	ps.Cluster.Update(c.Self, toPeers(c.Peers), c.GetVirtualNodes())
+}
+
+// owner returns the instance that owns the provided subscription and whether or not it is the current one.
+// Without a cluster the current instance owns every subscription
+func (ps *PushService) owner(name string) (cluster.Peer, bool) {
+
+	if ps.Cluster == nil {
+		return cluster.Peer{}, true
+	}
+
+	p := ps.Cluster.Owner(name)
+
+	return p, p.Name == ps.Cluster.Self()
+}
+
+// owns returns whether or not the current instance owns the provided subscription
+func (ps *PushService) owns(name string) bool {
+
+	_, local := ps.owner(name)
+
+	return local
+}
+
+// pushedByPreviousOwner returns whether or not the instance that owned the provided subscription, before the current one
+// joined the cluster, still pushes it. The rest of the instances keep pushing the subscriptions of a joining instance
+// until they find it live and hand them over, so the joining instance leaves those subscriptions to the hand offs
+func (ps *PushService) pushedByPreviousOwner(name string) bool {
+
+	if ps.Cluster == nil {
+		return false
+	}
+
+	prev, found := ps.Cluster.PreviousOwner(name)
+	if !found {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ps.Cfg.GetCluster().GetHealthInterval())
+	defer cancel()
+
+	// the previous owner only reports the status of the subscriptions that are active on it
+	_, err := ps.forwardSubscriptionStatus(ctx, prev, &amsPb.SubscriptionStatusRequest{FullName: name})
+
+	return err == nil
+}
+
+// forwardTo returns the owner of the provided subscription and whether or not a call for it should be forwarded there.
+// Calls for subscriptions that another instance owns are forwarded, unless another instance of the cluster already did.
+// The forwarded header of any other caller is rejected, instead of ignored, since routing such a call again could
+// bounce it between instances that disagree on the owner
+func (ps *PushService) forwardTo(ctx context.Context, name string) (cluster.Peer, bool, error) {
+
+	owner, local := ps.owner(name)
+	if local {
+		return owner, false, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md.Get(forwardedHeader)) == 0 {
+		return owner, true, nil
+	}
+
+	if !ps.isPeerCall(ctx) {
+		return owner, false, status.Error(codes.PermissionDenied, "Only the instances of the cluster can forward calls")
+	}
+
+	return owner, false, nil
+}
+
+// isPeerCall returns whether or not the call comes from another instance of the cluster. An instance is recognised
+// either by the cluster token or by a verified client certificate that is valid for the host of its address,
+// the same certificate that the instances already verify against that host when they dial each other
+func (ps *PushService) isPeerCall(ctx context.Context) bool {
+
+	if ps.Cluster == nil {
+		return false
+	}
+
+	if token := ps.Cfg.GetClusterToken(); token != "" && subtle.ConstantTimeCompare([]byte(bearerToken(ctx)), []byte(token)) == 1 {
+		return true
+	}
+
+	pr, ok := peer.FromContext(ctx)
+	if !ok || pr == nil {
+		return false
+	}
+
+	tlsInfo, ok := pr.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return false
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+
+	for _, p := range ps.Cluster.Peers() {
+		host, _, err := net.SplitHostPort(p.Address)
+		if err == nil && cert.VerifyHostname(host) == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// peerConn returns the connection to the provided peer, dialing it if there is no connection to it yet
+func (ps *PushService) peerConn(p cluster.Peer) (*grpc.ClientConn, error) {
+
+	ps.peerConns.mutex.Lock()
+	defer ps.peerConns.mutex.Unlock()
+
+	if conn, found := ps.peerConns.conns[p.Name]; found && ps.peerConns.addresses[p.Name] == p.Address {
+		return conn, nil
+	}
+
+	if conn, found := ps.peerConns.conns[p.Name]; found {
+		conn.Close()
+	}
+
+	creds := insecure.NewCredentials()
+	if ps.Cfg.TLSEnabled {
+		creds = credentials.NewTLS(ps.Cfg.GetClientTLSConfig())
+	}
+
+	conn, err := grpc.NewClient(p.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, errors.Errorf("Could not connect to cluster peer %v, %v", p.Name, err.Error())
+	}
+
+	ps.peerConns.conns[p.Name] = conn
+	ps.peerConns.addresses[p.Name] = p.Address
+
+	return conn, nil
+}
+
+// closeStalePeerConns closes the connections to the peers that are no longer part of the cluster
+func (ps *PushService) closeStalePeerConns(peers []config.Peer) {
+
+	ps.peerConns.mutex.Lock()
+	defer ps.peerConns.mutex.Unlock()
+
+	current := make(map[string]bool)
+	for _, p := range peers {
+		current[p.Name] = true
+	}
+
+	for name, conn := range ps.peerConns.conns {
+		if !current[name] {
+			conn.Close()
+			delete(ps.peerConns.conns, name)
+			delete(ps.peerConns.addresses, name)
+		}
+	}
+}
+
+// closePeerConns closes the connections to every peer
+func (ps *PushService) closePeerConns() {
+	ps.closeStalePeerConns(nil)
+}
+
+// peerContext returns the context of a call to another instance of the cluster,
+// marked as forwarded and carrying the cluster token, if one is configured
+func (ps *PushService) peerContext(ctx context.Context) context.Context {
+
+	ctx = metadata.AppendToOutgoingContext(ctx, forwardedHeader, ps.Cluster.Self())
+
+	if token := ps.Cfg.GetClusterToken(); token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+
+	return ctx
+}
+
+// probePeer checks that a peer is reachable and serving through its health service
+func (ps *PushService) probePeer(ctx context.Context, p cluster.Peer) error {
+
+	conn, err := ps.peerConn(p)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ps.Cfg.GetCluster().GetHealthInterval())
+	defer cancel()
+
+	resp, err := gRPCHealth.NewHealthClient(conn).Check(ps.peerContext(ctx), &gRPCHealth.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+
+	if resp.Status != gRPCHealth.HealthCheckResponse_SERVING {
+		return errors.Errorf("Cluster peer %v is %v", p.Name, resp.Status.String())
+	}
+
+	return nil
+}
+
+// unreachable is the error of a call that couldn't be forwarded to the owner of its subscription
+func unreachable(owner cluster.Peer, name string, err error) error {
+	return status.Errorf(codes.Unavailable, "Could not reach %v, the owner of subscription %v, %v", owner.Name, name, err.Error())
+}
+
+// forwardActivation forwards the activation of a subscription to the instance that owns it
+func (ps *PushService) forwardActivation(ctx context.Context, owner cluster.Peer, r *amsPb.ActivateSubscriptionRequest) (*amsPb.ActivateSubscriptionResponse, error) {
+
+	conn, err := ps.peerConn(owner)
+	if err != nil {
+		return nil, unreachable(owner, r.Subscription.FullName, err)
+	}
+
+	return amsPb.NewPushServiceClient(conn).ActivateSubscription(ps.peerContext(ctx), r)
+}
+
+// forwardDeactivation forwards the deactivation of a subscription to the instance that owns it
+func (ps *PushService) forwardDeactivation(ctx context.Context, owner cluster.Peer, r *amsPb.DeactivateSubscriptionRequest) (*amsPb.DeactivateSubscriptionResponse, error) {
+
+	conn, err := ps.peerConn(owner)
+	if err != nil {
+		return nil, unreachable(owner, r.FullName, err)
+	}
+
+	return amsPb.NewPushServiceClient(conn).DeactivateSubscription(ps.peerContext(ctx), r)
+}
+
+// forwardSubscriptionStatus forwards the status request of a subscription to the instance that owns it
+func (ps *PushService) forwardSubscriptionStatus(ctx context.Context, owner cluster.Peer, r *amsPb.SubscriptionStatusRequest) (*amsPb.SubscriptionStatusResponse, error) {
+
+	conn, err := ps.peerConn(owner)
+	if err != nil {
+		return nil, unreachable(owner, r.FullName, err)
+	}
+
+	return amsPb.NewPushServiceClient(conn).SubscriptionStatus(ps.peerContext(ctx), r)
+}
+
+// rebalance follows a change of the owners, handing the subscriptions that other instances now own over to them
+// and picking up the subscriptions that the current instance now owns through a reconciliation
+func (ps *PushService) rebalance() {
+
+	if ps.shuttingDown.Load() {
+		return
+	}
+
+	ps.handOff()
+
+	if ps.Cfg.SkipSubsLoad {
+		return
+	}
+
+	go func() {
+		_, err := ps.Reconcile(context.Background())
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":  "system_log",
+					"error": err.Error(),
+				},
+			).Error("Could not reconcile subscriptions")
+		}
+	}()
+}
+
+// handOff hands every active subscription that another instance owns over to it
+func (ps *PushService) handOff() {
+
+	var wg sync.WaitGroup
+
+	ps.PushWorkers.Range(func(name string, w push.Worker) {
+
+		owner, local := ps.owner(name)
+		if local {
+			return
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			ps.handOffWorker(name, w, owner)
+		}()
+	})
+
+	wg.Wait()
+}
+
+// handOffWorker drains the worker of a subscription and activates the subscription on its owner.
+// The worker stops before the owner starts, so that no message is pushed by both of them.
+// If the owner can't activate the subscription, the worker resumes locally from where it stopped
+func (ps *PushService) handOffWorker(name string, w push.Worker, owner cluster.Peer) {
+
+	if _, found := ps.PushWorkers.Remove(name); !found {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ps.Cfg.GetDrainTimeout())
+	w.Drain(ctx)
+	cancel()
+
+	sysCtx := NewIdentityContext(context.Background(), systemIdentity)
+	sub := w.Subscription()
+
+	conn, err := ps.peerConn(owner)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(sysCtx, ps.Cfg.GetDrainTimeout())
+		_, err = amsPb.NewPushServiceClient(conn).ActivateSubscription(ps.peerContext(ctx), &amsPb.ActivateSubscriptionRequest{
+			Subscription: sub,
+		})
+		cancel()
+	}
+
+	// the owner already picked the subscription up on its own
+	if err != nil && status.Code(err) != codes.AlreadyExists {
+
+		log.WithFields(
+			log.Fields{
+				"type":         "system_log",
+				"subscription": name,
+				"peer":         owner.Name,
+				"error":        err.Error(),
+			},
+		).Error("Could not hand off subscription, resuming it locally")
+
+		err = ps.restoreWorker(w.State())
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":         "system_log",
+					"subscription": name,
+					"error":        err.Error(),
+				},
+			).Error("Could not resume push worker")
+			ps.deleteState(name)
+		}
+		return
+	}
+
+	ps.deleteState(name)
+	ps.audit(sysCtx, audit.HandOff, name, sub.PushConfig, nil, nil, fmt.Sprintf("Owned by %v", owner.Name))
+
+	log.WithFields(
+		log.Fields{
+			"type":         "system_log",
+			"subscription": name,
+			"peer":         owner.Name,
+		},
+	).Info("Subscription handed off")
+}
+
+// clusterStatus returns the membership of the cluster as it is reported by the status of the service,
+// or nil if cluster mode is disabled
+func (ps *PushService) clusterStatus() *amsPb.ClusterStatus {
+
+	if ps.Cluster == nil {
+		return nil
+	}
+
+	live, down := ps.Cluster.Live()
+
+	return &amsPb.ClusterStatus{
+		Self:      ps.Cluster.Self(),
+		LivePeers: live,
+		DownPeers: down,
+	}
+}
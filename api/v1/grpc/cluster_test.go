@@ -0,0 +1,539 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/cluster"
+	"github.com/ARGOeu/ams-push-server/config"
+	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type ClusterTestSuite struct {
+	suite.Suite
+}
+
+// testInstance is an instance of a cluster that runs inside the test
+type testInstance struct {
+	ps  *PushService
+	srv *grpc.Server
+}
+
+// stop stops the grpc server and the push service of the instance
+func (i *testInstance) stop() {
+	i.srv.Stop()
+	i.ps.Shutdown(context.Background())
+}
+
+// listen returns a listener on a random local port
+func (suite *ClusterTestSuite) listen() net.Listener {
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().Nil(err)
+
+	return lis
+}
+
+// testAms serves, as ams does, a push worker user that is assigned the provided push enabled subscriptions
+type testAms struct {
+	host string
+	port int
+	subs []string
+	// while unavailable, every request fails, e.g. to keep an instance loading its subscriptions
+	unavailable atomic.Bool
+}
+
+// startAms starts serving the provided subscriptions over tls, until the test ends
+func (suite *ClusterTestSuite) startAms(subs []string) *testAms {
+
+	a := &testAms{subs: subs}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if a.unavailable.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/v1/users:byToken/") {
+			user := ams.UserInfo{Name: "worker", Projects: []ams.Project{{Project: "push1"}}}
+			for _, name := range a.subs {
+				user.Projects[0].Subscriptions = append(user.Projects[0].Subscriptions, strings.TrimPrefix(name, "/projects/push1/subscriptions/"))
+			}
+			json.NewEncoder(w).Encode(user)
+			return
+		}
+
+		for _, name := range a.subs {
+			if r.URL.Path == "/v1"+name {
+				sub := clusterSubscription(name)
+				json.NewEncoder(w).Encode(ams.Subscription{
+					FullName:  name,
+					FullTopic: sub.FullTopic,
+					PushCfg: ams.PushConfig{
+						Type:   ams.HttpEndpointPushConfig,
+						Pend:   sub.PushConfig.PushEndpoint,
+						RetPol: ams.RetryPolicy{PolicyType: sub.PushConfig.RetryPolicy.Type, Period: sub.PushConfig.RetryPolicy.Period},
+					},
+				})
+				return
+			}
+		}
+
+		// e.g. the pulls of the workers
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	suite.T().Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	a.host = host
+	a.port, _ = strconv.Atoi(port)
+
+	return a
+}
+
+// startInstance starts the instance of the cluster with the provided name, serving its api on the listener
+// and loading its subscriptions from the provided ams
+func (suite *ClusterTestSuite) startInstance(name string, peers []config.Peer, lis net.Listener, a *testAms) *testInstance {
+
+	cfg := config.NewMockConfig()
+	cfg.AmsHost = a.host
+	cfg.AmsPort = a.port
+	cfg.VerifySSL = false
+	cfg.SkipSubsLoad = false
+	cfg.DrainTimeout = 1
+	cfg.ClusterToken = "cluster-secret"
+	cfg.Cluster = config.Cluster{
+		Self:           name,
+		Peers:          peers,
+		HealthInterval: 1,
+	}
+
+	ps := NewPushService(cfg)
+
+	srv := NewGRPCServer(cfg, ps)
+	go srv.Serve(lis)
+
+	return &testInstance{ps: ps, srv: srv}
+}
+
+// ready waits until every instance has loaded its subscriptions
+func (suite *ClusterTestSuite) ready(instances []*testInstance) {
+
+	suite.Eventually(func() bool {
+		for _, i := range instances {
+			ls := i.ps.loadStats.status(1)
+			if i.ps.getStatus() != "ok" || ls == nil || !ls.Done {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+// client returns a client of the api of the instance listening on the provided address
+func (suite *ClusterTestSuite) client(address string) amsPb.PushServiceClient {
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	suite.Require().Nil(err)
+	suite.T().Cleanup(func() {
+		conn.Close()
+	})
+
+	return amsPb.NewPushServiceClient(conn)
+}
+
+// clusterSubscription returns a subscription with the provided name
+func clusterSubscription(name string) *amsPb.Subscription {
+	return &amsPb.Subscription{
+		FullName:  name,
+		FullTopic: "/projects/push1/topics/t1",
+		PushConfig: &amsPb.PushConfig{
+			Type:         amsPb.PushType_HTTP_ENDPOINT,
+			PushEndpoint: "https://example.com:5000/receive_here",
+			RetryPolicy:  &amsPb.RetryPolicy{Type: "linear", Period: 300},
+		},
+	}
+}
+
+// clusterSubscriptions returns the names of n subscriptions
+func clusterSubscriptions(n int) []string {
+
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("/projects/push1/subscriptions/cluster%v", i)
+	}
+
+	return names
+}
+
+// allLive waits until every instance sees all of the provided peers as live
+func (suite *ClusterTestSuite) allLive(instances []*testInstance, peers []config.Peer) {
+
+	suite.Eventually(func() bool {
+		for _, i := range instances {
+			live, _ := i.ps.Cluster.Live()
+			if len(live) != len(peers) {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+// owners returns the instance that each of the subscriptions is active on, or an empty string if it isn't active on any
+// and fails if a subscription is active on more than one instance
+func (suite *ClusterTestSuite) owners(instances []*testInstance, names []string) map[string]string {
+
+	owners := make(map[string]string)
+
+	for _, name := range names {
+		owners[name] = ""
+		for _, i := range instances {
+			if i.ps.IsSubActive(name) {
+				suite.Equal("", owners[name], "subscription %v is active on more than one instance", name)
+				owners[name] = i.ps.Cluster.Self()
+			}
+		}
+	}
+
+	return owners
+}
+
+// settled waits until every subscription is active on its owner only
+func (suite *ClusterTestSuite) settled(instances []*testInstance, names []string) {
+
+	suite.Eventually(func() bool {
+		for name, owner := range suite.owners(instances, names) {
+			if owner != instances[0].ps.Cluster.Owner(name).Name {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+// TestForwarding tests that the calls on a subscription reach the instance that owns it, whichever instance receives them
+func (suite *ClusterTestSuite) TestForwarding() {
+
+	listeners := []net.Listener{suite.listen(), suite.listen(), suite.listen()}
+
+	peers := make([]config.Peer, 0)
+	for i, lis := range listeners {
+		peers = append(peers, config.Peer{Name: fmt.Sprintf("push-%v", i+1), Address: lis.Addr().String()})
+	}
+
+	names := clusterSubscriptions(12)
+	a := suite.startAms(names)
+
+	instances := make([]*testInstance, 0)
+	for i, lis := range listeners {
+		instances = append(instances, suite.startInstance(peers[i].Name, peers, lis, a))
+	}
+	defer func() {
+		for _, i := range instances {
+			i.stop()
+		}
+	}()
+
+	suite.allLive(instances, peers)
+	suite.ready(instances)
+
+	// every subscription is active on its owner only, and the subscriptions are spread over the instances
+	suite.settled(instances, names)
+
+	counts := make(map[string]int)
+	for _, owner := range suite.owners(instances, names) {
+		counts[owner]++
+	}
+	suite.Equal(3, len(counts))
+
+	client := suite.client(peers[0].Address)
+
+	// the status, the deactivation and the activation of a subscription owned by another instance are forwarded to it
+	var remote string
+	for _, name := range names {
+		if !instances[0].ps.owns(name) {
+			remote = name
+			break
+		}
+	}
+
+	resp, err := client.SubscriptionStatus(context.Background(), &amsPb.SubscriptionStatusRequest{FullName: remote})
+	suite.Nil(err)
+	suite.NotEmpty(resp.Status)
+
+	_, err = client.DeactivateSubscription(context.Background(), &amsPb.DeactivateSubscriptionRequest{FullName: remote})
+	suite.Nil(err)
+	suite.Equal("", suite.owners(instances, []string{remote})[remote])
+
+	_, err = client.SubscriptionStatus(context.Background(), &amsPb.SubscriptionStatusRequest{FullName: remote})
+	suite.Equal(codes.NotFound, status.Code(err))
+
+	_, err = client.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: clusterSubscription(remote),
+	})
+	suite.Nil(err)
+	suite.Equal(instances[0].ps.Cluster.Owner(remote).Name, suite.owners(instances, []string{remote})[remote])
+
+	_, err = client.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: clusterSubscription(remote),
+	})
+	suite.Equal(codes.AlreadyExists, status.Code(err))
+
+	_, err = client.DeactivateSubscription(context.Background(), &amsPb.DeactivateSubscriptionRequest{FullName: remote})
+	suite.Nil(err)
+
+	// an ordinary caller can't make an instance push a subscription that it doesn't own by setting the forwarded header
+	spoofed := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(forwardedHeader, "push-2"))
+	_, err = client.ActivateSubscription(spoofed, &amsPb.ActivateSubscriptionRequest{
+		Subscription: clusterSubscription(remote),
+	})
+	suite.Equal(status.Error(codes.PermissionDenied, "Only the instances of the cluster can forward calls"), err)
+	suite.Equal("", suite.owners(instances, []string{remote})[remote])
+
+	_, err = client.SubscriptionStatus(spoofed, &amsPb.SubscriptionStatusRequest{FullName: remote})
+	suite.Equal(codes.PermissionDenied, status.Code(err))
+
+	_, err = client.DeactivateSubscription(spoofed, &amsPb.DeactivateSubscriptionRequest{FullName: remote})
+	suite.Equal(codes.PermissionDenied, status.Code(err))
+
+	// a call forwarded by another instance is handled by the instance that receives it, even if it doesn't own the subscription
+	forwarded := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(forwardedHeader, "push-2", "authorization", "Bearer cluster-secret"))
+	_, err = instances[0].ps.ActivateSubscription(forwarded, &amsPb.ActivateSubscriptionRequest{
+		Subscription: clusterSubscription(remote),
+	})
+	suite.Nil(err)
+	suite.True(instances[0].ps.IsSubActive(remote))
+
+	// the status of the service reports the membership of the cluster
+	st, err := client.Status(context.Background(), &amsPb.StatusRequest{})
+	suite.Nil(err)
+	suite.Equal("push-1", st.Cluster.Self)
+	suite.Equal([]string{"push-1", "push-2", "push-3"}, st.Cluster.LivePeers)
+	suite.Empty(st.Cluster.DownPeers)
+}
+
+// TestHandOff tests that the subscriptions move to an instance that joins the cluster and back once it leaves
+func (suite *ClusterTestSuite) TestHandOff() {
+
+	listeners := []net.Listener{suite.listen(), suite.listen(), suite.listen()}
+
+	peers := make([]config.Peer, 0)
+	for i, lis := range listeners {
+		peers = append(peers, config.Peer{Name: fmt.Sprintf("push-%v", i+1), Address: lis.Addr().String()})
+	}
+
+	names := clusterSubscriptions(12)
+	a := suite.startAms(names)
+
+	// the third instance isn't running yet
+	instances := []*testInstance{
+		suite.startInstance("push-1", peers, listeners[0], a),
+		suite.startInstance("push-2", peers, listeners[1], a),
+	}
+
+	suite.Eventually(func() bool {
+		_, down := instances[0].ps.Cluster.Live()
+		return len(down) == 1 && down[0] == "push-3"
+	}, 10*time.Second, 50*time.Millisecond)
+
+	suite.ready(instances)
+	suite.settled(instances, names)
+
+	// once the third instance joins, the subscriptions it owns are handed over to it
+	third := suite.startInstance("push-3", peers, listeners[2], a)
+	instances = append(instances, third)
+	suite.allLive(instances, peers)
+
+	suite.settled(instances, names)
+	suite.True(third.ps.PushWorkers.Len() > 0)
+
+	// once it leaves, the rest of the instances pick its subscriptions up again
+	third.stop()
+
+	suite.Eventually(func() bool {
+		_, down := instances[0].ps.Cluster.Live()
+		return len(down) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	suite.settled(instances[:2], names)
+
+	instances[0].stop()
+	instances[1].stop()
+}
+
+// TestHandOffFailure tests that a subscription that couldn't be handed over keeps being pushed locally
+func (suite *ClusterTestSuite) TestHandOffFailure() {
+
+	lis := suite.listen()
+
+	// an address that nothing listens on
+	closed := suite.listen()
+	closed.Close()
+
+	peers := []config.Peer{
+		{Name: "push-1", Address: lis.Addr().String()},
+		{Name: "push-2", Address: closed.Addr().String()},
+	}
+
+	names := clusterSubscriptions(8)
+
+	i1 := suite.startInstance("push-1", peers, lis, suite.startAms(names))
+	defer i1.stop()
+
+	_, down := i1.ps.Cluster.Live()
+	suite.Equal([]string{"push-2"}, down)
+
+	// with the other instance down, the instance loads every subscription
+	suite.ready([]*testInstance{i1})
+	suite.Equal(len(names), i1.ps.PushWorkers.Len())
+
+	// the unreachable instance is considered live, the hand offs to it fail
+	i1.ps.Cluster.SetLive("push-2", true)
+	i1.ps.handOff()
+
+	suite.Eventually(func() bool {
+		for _, name := range names {
+			if !i1.ps.IsSubActive(name) {
+				return false
+			}
+		}
+		return i1.ps.PushWorkers.Len() == len(names)
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// the activations of the subscriptions it owns can't reach it either
+	i1.ps.Cluster.SetLive("push-2", true)
+	for _, name := range clusterSubscriptions(20) {
+		name += "-new"
+		if !i1.ps.owns(name) {
+			_, err := i1.ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+				Subscription: clusterSubscription(name),
+			})
+			suite.Equal(codes.Unavailable, status.Code(err))
+			break
+		}
+	}
+}
+
+// TestLoadingPeer tests that an instance stays live while it loads its subscriptions,
+// so that the rest of the instances don't pick up the subscriptions it is about to push
+func (suite *ClusterTestSuite) TestLoadingPeer() {
+
+	listeners := []net.Listener{suite.listen(), suite.listen()}
+
+	peers := []config.Peer{
+		{Name: "push-1", Address: listeners[0].Addr().String()},
+		{Name: "push-2", Address: listeners[1].Addr().String()},
+	}
+
+	names := clusterSubscriptions(12)
+	a1 := suite.startAms(names)
+	a2 := suite.startAms(names)
+	a2.unavailable.Store(true)
+
+	// the second instance starts first and can't load its subscriptions
+	i2 := suite.startInstance("push-2", peers, listeners[1], a2)
+	defer i2.stop()
+	i1 := suite.startInstance("push-1", peers, listeners[0], a1)
+	defer i1.stop()
+
+	instances := []*testInstance{i1, i2}
+
+	suite.allLive(instances, peers)
+	suite.ready(instances[:1])
+	suite.NotEqual("ok", i2.ps.getStatus())
+
+	// the loading instance stays live and the first one only pushes the subscriptions it owns
+	suite.Never(func() bool {
+		if _, down := i1.ps.Cluster.Live(); len(down) > 0 {
+			return true
+		}
+		for _, name := range names {
+			if i1.ps.IsSubActive(name) && !i1.ps.owns(name) {
+				return true
+			}
+		}
+		return false
+	}, 3*time.Second, 50*time.Millisecond)
+
+	// once it loads them, every subscription is pushed by its owner
+	a2.unavailable.Store(false)
+
+	suite.ready(instances)
+	suite.settled(instances, names)
+}
+
+// TestIsPeerCall tests how the calls of the other instances of the cluster are recognised
+func (suite *ClusterTestSuite) TestIsPeerCall() {
+
+	cfg := config.NewMockConfig()
+	cfg.ClusterToken = "cluster-secret"
+
+	ps := &PushService{Cfg: cfg}
+	suite.False(ps.isPeerCall(context.Background()))
+
+	ps.Cluster = cluster.New("push-1", []cluster.Peer{
+		{Name: "push-1", Address: "push-1.example.com:5555"},
+		{Name: "push-2", Address: "push-2.example.com:5555"},
+	}, 8)
+
+	token := func(t string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+t))
+	}
+
+	cert := func(verified bool, dnsNames ...string) context.Context {
+		state := tls.ConnectionState{}
+		c := &x509.Certificate{Subject: pkix.Name{CommonName: "push"}, DNSNames: dnsNames}
+		state.PeerCertificates = []*x509.Certificate{c}
+		if verified {
+			state.VerifiedChains = [][]*x509.Certificate{{c}}
+		}
+		return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+	}
+
+	suite.False(ps.isPeerCall(context.Background()))
+	suite.True(ps.isPeerCall(token("cluster-secret")))
+	suite.False(ps.isPeerCall(token("other")))
+
+	// a verified certificate of one of the peers
+	suite.True(ps.isPeerCall(cert(true, "push-2.example.com")))
+	// the certificate of a host that isn't one of the peers
+	suite.False(ps.isPeerCall(cert(true, "monitoring.example.com")))
+	// a certificate that wasn't verified, e.g. with trust_unknown_cas
+	suite.False(ps.isPeerCall(cert(false, "push-2.example.com")))
+
+	// without a cluster token only the certificates are recognised
+	cfg.ClusterToken = ""
+	suite.False(ps.isPeerCall(token("")))
+}
+
+func TestClusterTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(ClusterTestSuite))
+}
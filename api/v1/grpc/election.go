@@ -0,0 +1,147 @@
+package grpc
+
+import (
+	"context"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/election"
+	"github.com/ARGOeu/ams-push-server/push"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// initElection starts competing for the lease, the instance stands by until it acquires it
+func (ps *PushService) initElection() {
+
+	e := ps.Cfg.Election
+
+	lease, err := election.NewLease(e.Backend, e.LeaseFile)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Fatal("Could not initialise the lease")
+	}
+
+	ps.standby.Store(true)
+	ps.Elector = election.NewElector(lease, e.GetIdentity(), e.GetLeaseDuration(), e.GetRenewInterval())
+
+	log.WithFields(
+		log.Fields{
+			"type":     "system_log",
+			"identity": ps.Elector.Identity(),
+			"backend":  e.Backend,
+		},
+	).Info("Standing by until the lease is acquired")
+
+	ps.Elector.Start(ps.lead, ps.standDown)
+}
+
+// lead starts pushing once the instance acquires the lease, it restores the persisted workers and loads the subscriptions
+func (ps *PushService) lead() {
+
+	if ps.shuttingDown.Load() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ps.leaderMutex.Lock()
+	ps.leaderCancel = cancel
+	ps.leaderMutex.Unlock()
+
+	ps.setStatus("")
+	ps.standby.Store(false)
+
+	ps.restoreWorkers()
+	ps.startLoading(ctx)
+}
+
+// standDown stops pushing once the instance loses the lease. The workers are stopped right away, without draining,
+// since the instance that acquired the lease might already be pushing their subscriptions
+func (ps *PushService) standDown() {
+
+	// the activations in progress either register their workers before the instance stands by, and get stopped below,
+	// or find it on standby
+	ps.standbyMutex.Lock()
+	ps.standby.Store(true)
+	ps.standbyMutex.Unlock()
+
+	ps.leaderMutex.Lock()
+	if ps.leaderCancel != nil {
+		ps.leaderCancel()
+		ps.leaderCancel = nil
+	}
+	ps.leaderMutex.Unlock()
+
+	ps.PushWorkers.Range(func(name string, w push.Worker) {
+		ps.deactivateSubscription(name)
+	})
+
+	log.WithFields(
+		log.Fields{
+			"type":     "system_log",
+			"identity": ps.Elector.Identity(),
+			"holder":   ps.Elector.Holder(),
+		},
+	).Warning("Push workers stopped, standing by")
+}
+
+// addWorker registers the worker of a subscription, unless the instance is on standby
+// or the subscription already has a worker
+func (ps *PushService) addWorker(name string, w push.Worker) error {
+
+	ps.standbyMutex.RLock()
+	defer ps.standbyMutex.RUnlock()
+
+	if ps.standby.Load() {
+		return status.Error(codes.FailedPrecondition, OnStandby)
+	}
+
+	if !ps.PushWorkers.Add(name, w) {
+		return status.Errorf(codes.AlreadyExists, "Subscription %v is already activated", name)
+	}
+
+	return nil
+}
+
+// stopElection stops competing for the lease and releases it, if the instance holds it
+func (ps *PushService) stopElection() {
+
+	if ps.Elector == nil {
+		return
+	}
+
+	ps.leaderMutex.Lock()
+	if ps.leaderCancel != nil {
+		ps.leaderCancel()
+	}
+	ps.leaderMutex.Unlock()
+
+	err := ps.Elector.Stop()
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Error("Could not release the lease")
+	}
+}
+
+// electionStatus returns the role of the instance as it is reported by the status of the service,
+// or nil if leader election is disabled
+func (ps *PushService) electionStatus() *amsPb.ElectionStatus {
+
+	if ps.Elector == nil {
+		return nil
+	}
+
+	return &amsPb.ElectionStatus{
+		Identity: ps.Elector.Identity(),
+		Role:     ps.Elector.Role(),
+		Holder:   ps.Elector.Holder(),
+	}
+}
@@ -0,0 +1,181 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/config"
+	"github.com/ARGOeu/ams-push-server/election"
+	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	"github.com/ARGOeu/ams-push-server/push"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type ElectionTestSuite struct {
+	suite.Suite
+}
+
+// amsServer serves the responses of the mock ams round tripper, so that a push service talks to it from the start
+func (suite *ElectionTestSuite) amsServer() (string, int) {
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, _ := new(ams.MockAmsRoundTripper).RoundTrip(r)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	suite.T().Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	p, _ := strconv.Atoi(port)
+
+	return host, p
+}
+
+// electionConfig returns the config of an instance that competes for the lease file under the provided identity
+func (suite *ElectionTestSuite) electionConfig(identity string, leaseFile string, amsHost string, amsPort int) *config.Config {
+
+	cfg := config.NewMockConfig()
+	cfg.AmsHost = amsHost
+	cfg.AmsPort = amsPort
+	cfg.VerifySSL = false
+	cfg.SkipSubsLoad = false
+	cfg.DrainTimeout = 1
+	// errorsub can never be retrieved, the service is ready with the rest of them
+	cfg.SubscriptionLoading.ReadyFraction = 0.8
+	cfg.Election = config.Election{
+		Backend:       election.FileBackend,
+		LeaseFile:     leaseFile,
+		Identity:      identity,
+		LeaseDuration: 3,
+		RenewInterval: 1,
+	}
+
+	return cfg
+}
+
+// TestFailover tests that only the leader pushes, that the standby takes over once the leader stops
+// and that the workers stop as soon as the lease is lost
+func (suite *ElectionTestSuite) TestFailover() {
+
+	host, port := suite.amsServer()
+	leaseFile := filepath.Join(suite.T().TempDir(), "lease")
+
+	ps1 := NewPushService(suite.electionConfig("push-1", leaseFile, host, port))
+
+	suite.Eventually(func() bool {
+		return ps1.IsSubActive("/projects/push1/subscriptions/sub1") && ps1.getStatus() == "ok"
+	}, 10*time.Second, 10*time.Millisecond)
+
+	st, err := ps1.Status(context.Background(), &amsPb.StatusRequest{})
+	suite.Nil(err)
+	suite.Equal("leader", st.Election.Role)
+	suite.Equal("push-1", st.Election.Holder)
+
+	// the second instance stands by, it keeps serving its api but doesn't push
+	ps2 := NewPushService(suite.electionConfig("push-2", leaseFile, host, port))
+	defer ps2.Shutdown(context.Background())
+
+	suite.Eventually(func() bool {
+		return ps2.Elector.Holder() == "push-1"
+	}, 10*time.Second, 10*time.Millisecond)
+
+	st, err = ps2.Status(context.Background(), &amsPb.StatusRequest{})
+	suite.Nil(err)
+	suite.Equal(&amsPb.ElectionStatus{Identity: "push-2", Role: "standby", Holder: "push-1"}, st.Election)
+	suite.Equal(0, ps2.PushWorkers.Len())
+
+	_, err = ps2.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: clusterSubscription("/projects/push1/subscriptions/sub9"),
+	})
+	suite.Equal(status.Error(codes.FailedPrecondition, OnStandby), err)
+
+	// the standby takes over once the leader releases the lease on shutdown
+	suite.Nil(ps1.Shutdown(context.Background()))
+
+	suite.Eventually(func() bool {
+		return ps2.IsSubActive("/projects/push1/subscriptions/sub1") && ps2.getStatus() == "ok"
+	}, 10*time.Second, 10*time.Millisecond)
+
+	st, err = ps2.Status(context.Background(), &amsPb.StatusRequest{})
+	suite.Nil(err)
+	suite.Equal("leader", st.Election.Role)
+
+	// another instance takes the lease over, e.g. after the leader was paused for longer than the lease,
+	// and the workers stop at the next renewal
+	b, _ := json.Marshal(map[string]interface{}{"holder": "push-3", "expires": time.Now().Add(time.Minute)})
+	suite.Nil(os.WriteFile(leaseFile, b, 0600))
+
+	suite.Eventually(func() bool {
+		return ps2.PushWorkers.Len() == 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	st, err = ps2.Status(context.Background(), &amsPb.StatusRequest{})
+	suite.Nil(err)
+	suite.Equal(&amsPb.ElectionStatus{Identity: "push-2", Role: "standby", Holder: "push-3"}, st.Election)
+}
+
+// TestStandDownActivations tests that no worker is left running when the instance stands by while subscriptions get activated
+func (suite *ElectionTestSuite) TestStandDownActivations() {
+
+	for round := 0; round < 20; round++ {
+
+		ps := NewPushService(config.NewMockConfig())
+		ps.Elector = election.NewElector(new(election.MockLease), "push-1", time.Second, time.Second)
+
+		workers := make([]*push.MockWorker, 10)
+		registered := make([]bool, len(workers))
+
+		wg := new(sync.WaitGroup)
+		for i := range workers {
+			workers[i] = &push.MockWorker{Sub: amsPb.Subscription{FullName: "/projects/p1/subscriptions/sub" + strconv.Itoa(i)}, SubStatus: "ok"}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				err := ps.addWorker(workers[i].Sub.FullName, workers[i])
+				if err != nil {
+					suite.Equal(status.Error(codes.FailedPrecondition, OnStandby), err)
+					return
+				}
+				registered[i] = true
+			}(i)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ps.standDown()
+		}()
+
+		wg.Wait()
+
+		suite.Equal(0, ps.PushWorkers.Len())
+		for i, w := range workers {
+			if registered[i] {
+				suite.Equal("stopped", w.Status())
+			}
+		}
+
+		// once on standby, no worker gets registered
+		suite.Equal(status.Error(codes.FailedPrecondition, OnStandby), ps.addWorker(workers[0].Sub.FullName, workers[0]))
+	}
+}
+
+func TestElectionTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(ElectionTestSuite))
+}
@@ -3,14 +3,17 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"github.com/ARGOeu/ams-push-server/acl"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"strings"
 )
 
+// healthMethodPrefix is the prefix of the calls of the grpc health service
+const healthMethodPrefix = "/grpc.health.v1.Health/"
+
 // StatusInterceptor is used in order to check, depending on the service's status if the call should be continued or not
 func StatusInterceptor(srv *PushService) grpc.UnaryServerInterceptor {
 	return func(
@@ -20,8 +23,11 @@ func StatusInterceptor(srv *PushService) grpc.UnaryServerInterceptor {
 		handler grpc.UnaryHandler) (resp interface{}, err error) {
 
 		// if a request tries to access any other api call rather than the Status call
-		// while the service's status is not ok, block the request
-		if info.FullMethod != "/PushService/Status" && srv.status != "ok" {
+		// while the service's status is not ok, block the request. A standby instance keeps serving the api.
+		// While the subscriptions load, the health service keeps reporting liveness to the other instances of a cluster,
+		// and their calls, e.g. the hand offs of the subscriptions that the loading instance owns, are served
+		if info.FullMethod != "/PushService/Status" && !strings.HasPrefix(info.FullMethod, healthMethodPrefix) &&
+			srv.getStatus() != "ok" && !srv.standby.Load() && !srv.isPeerCall(ctx) {
 			return nil, status.Error(codes.Internal, ServiceUnavailable)
 		}
 
@@ -29,78 +35,119 @@ func StatusInterceptor(srv *PushService) grpc.UnaryServerInterceptor {
 	}
 }
 
-// AuthInterceptor provides ACL based access to the service using certificate DNs
-func AuthInterceptor(acl []string, tlsEnabled bool) grpc.UnaryServerInterceptor {
+// readOnlyMethods are the calls that identities with the read-only role are allowed to perform
+var readOnlyMethods = map[string]bool{
+	"/PushService/Status":             true,
+	"/PushService/SubscriptionStatus": true,
+	"/PushService/ListSubscriptions":  true,
+	"/grpc.health.v1.Health/Check":    true,
+	"/grpc.health.v1.Health/Watch":    true,
+}
+
+// AuthInterceptor authenticates every call through the provided auth provider and authorises it based on the role of the caller.
+// Admin callers can perform any call while read-only callers can only perform the read-only calls.
+// The identity of the caller is stored in the context of the call. When no provider is given, the service is open to everyone
+func AuthInterceptor(provider AuthProvider) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req interface{},
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler) (resp interface{}, err error) {
 
-		// if tls is not enabled skip the authorisation process
-		if !tlsEnabled {
+		// if no auth provider is in use skip the authorisation process
+		if provider == nil {
 			return handler(ctx, req)
 		}
 
-		p, ok := peer.FromContext(ctx)
-		if ok {
-			if p != nil {
-				if p.AuthInfo != nil {
-					if p.AuthInfo.AuthType() == "tls" {
-						tls := p.AuthInfo.(credentials.TLSInfo)
-						if len(tls.State.PeerCertificates) > 0 {
-							for _, c := range acl {
-								if c == tls.State.PeerCertificates[0].Subject.CommonName {
-									return handler(ctx, req)
-								}
-							}
-							log.WithFields(
-								log.Fields{
-									"type":  "error_log",
-									"acl":   acl,
-									"error": fmt.Sprintf("Provided certificate's cn: %v didn't match any ACL entry", tls.State.PeerCertificates[0].Subject.ToRDNSequence().String()),
-								},
-							).Error("unauthorised access to the service")
-						} else {
-							log.WithFields(
-								log.Fields{
-									"type":  "error_log",
-									"error": "No certificate provided",
-								},
-							).Error("")
-						}
-					} else {
-						log.WithFields(
-							log.Fields{
-								"type":  "error_log",
-								"error": fmt.Sprintf("Peer information AuthInfo is of type %v instead of tls", p.AuthInfo.AuthType()),
-							},
-						).Error("")
-					}
-				} else {
-					log.WithFields(
-						log.Fields{
-							"type":  "error_log",
-							"error": "Peer information found in the context contains no AuthInfo",
-						},
-					).Error("")
-				}
-			} else {
-				log.WithFields(
-					log.Fields{
-						"type":  "error_log",
-						"error": "Peer information found in the context is nil",
-					},
-				).Error("")
-			}
-		} else {
-			log.WithFields(
-				log.Fields{
-					"type":  "error_log",
-					"error": "No peer information found in the context",
-				},
-			).Error("")
+		ctx, err = authorise(ctx, info.FullMethod, provider)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor is the streaming counterpart of the AuthInterceptor
+func AuthStreamInterceptor(provider AuthProvider) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		// if no auth provider is in use skip the authorisation process
+		if provider == nil {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authorise(ss.Context(), info.FullMethod, provider)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &identityServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// identityServerStream is a server stream whose context carries the identity of the caller
+type identityServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the stream
+func (s *identityServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorise authenticates the caller and checks whether or not its role grants it access to the provided method.
+// It returns a context that carries the identity of the caller
+func authorise(ctx context.Context, method string, provider AuthProvider) (context.Context, error) {
+
+	id, err := provider.Authenticate(ctx)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":   "error_log",
+				"method": method,
+				"error":  err.Error(),
+			},
+		).Error("unauthorised access to the service")
+		return ctx, status.Error(codes.Unauthenticated, "UNAUTHORISED")
+	}
+
+	switch id.Role {
+
+	case acl.AdminRole:
+		return NewIdentityContext(ctx, id), nil
+
+	case acl.ReadOnlyRole:
+		if readOnlyMethods[method] {
+			return NewIdentityContext(ctx, id), nil
 		}
-		return nil, status.Error(codes.Unauthenticated, "UNAUTHORISED")
+
+		log.WithFields(
+			log.Fields{
+				"type":     "error_log",
+				"method":   method,
+				"identity": id.Name,
+				"provider": id.Provider,
+				"error":    fmt.Sprintf("Identity %v only has read-only access", id.Name),
+			},
+		).Error("forbidden access to the service")
+		return ctx, status.Error(codes.PermissionDenied, "FORBIDDEN")
 	}
+
+	log.WithFields(
+		log.Fields{
+			"type":     "error_log",
+			"method":   method,
+			"identity": id.Name,
+			"provider": id.Provider,
+			"error":    fmt.Sprintf("Identity %v has no role", id.Name),
+		},
+	).Error("unauthorised access to the service")
+
+	return ctx, status.Error(codes.Unauthenticated, "UNAUTHORISED")
 }
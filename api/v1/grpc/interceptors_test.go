@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"github.com/ARGOeu/ams-push-server/acl"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -63,14 +64,24 @@ func (suite *InterceptorsTestSuite) TestStatusInterceptor() {
 	suite.Equal("i3", r3.(string))
 	suite.Nil(err3)
 
+	// status not ok but the request is a health check, e.g. the probe of another instance of the cluster
+	r4, err4 := interceptor3(
+		context.Background(),
+		"i4",
+		&grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+		MockUnaryHandler)
+
+	suite.Equal("i4", r4.(string))
+	suite.Nil(err4)
+
 }
 
 func (suite *InterceptorsTestSuite) TestAuthInterceptor() {
 
 	acl1 := []string{"local.example.com"}
 
-	// since tlsEnabled is false, no ACL will take place
-	interceptor1 := AuthInterceptor(acl1, false)
+	// since no auth provider is in use, no ACL will take place
+	interceptor1 := AuthInterceptor(nil)
 
 	r1, err1 := interceptor1(
 		context.Background(),
@@ -83,7 +94,7 @@ func (suite *InterceptorsTestSuite) TestAuthInterceptor() {
 
 	// normal case where the Certificate in the incoming request, exists in the ACL aswell
 
-	interceptor2 := AuthInterceptor(acl1, true)
+	interceptor2 := AuthInterceptor(NewMTLSAuthProvider(func() acl.List { return acl.List{Admin: acl1} }))
 
 	cert1 := x509.Certificate{
 		Subject: pkix.Name{
@@ -110,7 +121,7 @@ func (suite *InterceptorsTestSuite) TestAuthInterceptor() {
 
 	// error case
 	acl2 := []string{"notlocal.example.com"}
-	interceptor3 := AuthInterceptor(acl2, true)
+	interceptor3 := AuthInterceptor(NewMTLSAuthProvider(func() acl.List { return acl.List{Admin: acl2} }))
 
 	r3, err3 := interceptor3(
 		peer.NewContext(ctx1, &p1),
@@ -120,6 +131,133 @@ func (suite *InterceptorsTestSuite) TestAuthInterceptor() {
 
 	suite.Nil(r3)
 	suite.Equal(status.Error(codes.Unauthenticated, "UNAUTHORISED"), err3)
+
+	// the ACL is swapped while the interceptor is in use
+	acl2 = []string{"local.example.com"}
+
+	r4, err4 := interceptor3(
+		peer.NewContext(ctx1, &p1),
+		"i1",
+		&grpc.UnaryServerInfo{FullMethod: "/PushService/Random"},
+		MockUnaryHandler)
+
+	suite.Nil(err4)
+	suite.Equal("i1", r4)
+}
+
+// TestAuthInterceptorRoles tests that read-only identities can only perform the read-only calls
+func (suite *InterceptorsTestSuite) TestAuthInterceptorRoles() {
+
+	aclList := acl.List{
+		Admin:    []string{"CN=admin.example.com,O=example"},
+		ReadOnly: []string{"dns:monitoring.example.com"},
+	}
+
+	interceptor := AuthInterceptor(NewMTLSAuthProvider(func() acl.List { return aclList }))
+
+	peerCtx := func(cert *x509.Certificate) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{
+				State: tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{cert},
+				},
+			},
+		})
+	}
+
+	admin := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "admin.example.com", Organization: []string{"example"}},
+	}
+
+	monitoring := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "monitoring"},
+		DNSNames: []string{"monitoring.example.com"},
+	}
+
+	// admin identities can perform any call
+	r1, e1 := interceptor(peerCtx(admin), "i1",
+		&grpc.UnaryServerInfo{FullMethod: "/PushService/DeactivateSubscription"}, MockUnaryHandler)
+	suite.Nil(e1)
+	suite.Equal("i1", r1)
+
+	// read-only identities can query the service
+	r2, e2 := interceptor(peerCtx(monitoring), "i2",
+		&grpc.UnaryServerInfo{FullMethod: "/PushService/SubscriptionStatus"}, MockUnaryHandler)
+	suite.Nil(e2)
+	suite.Equal("i2", r2)
+
+	r3, e3 := interceptor(peerCtx(monitoring), "i3",
+		&grpc.UnaryServerInfo{FullMethod: "/PushService/ListSubscriptions"}, MockUnaryHandler)
+	suite.Nil(e3)
+	suite.Equal("i3", r3)
+
+	// read-only identities can't change the service
+	r4, e4 := interceptor(peerCtx(monitoring), "i4",
+		&grpc.UnaryServerInfo{FullMethod: "/PushService/DeactivateSubscription"}, MockUnaryHandler)
+	suite.Nil(r4)
+	suite.Equal(status.Error(codes.PermissionDenied, "FORBIDDEN"), e4)
+
+	// the identity of the caller is passed on to the handler
+	r6, e6 := interceptor(peerCtx(monitoring), "i6",
+		&grpc.UnaryServerInfo{FullMethod: "/PushService/Status"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			id, _ := IdentityFromContext(ctx)
+			return id, nil
+		})
+	suite.Nil(e6)
+	suite.Equal(Identity{Name: "CN=monitoring", Provider: "mtls", Role: acl.ReadOnlyRole}, r6)
+
+	// unknown identities
+	r5, e5 := interceptor(peerCtx(&x509.Certificate{Subject: pkix.Name{CommonName: "admin.example.com"}}), "i5",
+		&grpc.UnaryServerInfo{FullMethod: "/PushService/Status"}, MockUnaryHandler)
+	suite.Nil(r5)
+	suite.Equal(status.Error(codes.Unauthenticated, "UNAUTHORISED"), e5)
+}
+
+// TestAuthStreamInterceptor tests the authorisation of streaming calls
+func (suite *InterceptorsTestSuite) TestAuthStreamInterceptor() {
+
+	aclList := acl.List{ReadOnly: []string{"local.example.com"}}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "local.example.com"}}},
+			},
+		},
+	})
+
+	handled := 0
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		handled++
+		return nil
+	}
+
+	// since no auth provider is in use, no ACL will take place
+	i1 := AuthStreamInterceptor(nil)
+	suite.Nil(i1(nil, &MockServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/PushService/Random"}, handler))
+	suite.Equal(1, handled)
+
+	i2 := AuthStreamInterceptor(NewMTLSAuthProvider(func() acl.List { return aclList }))
+	suite.Nil(i2(nil, &MockServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}, handler))
+	suite.Equal(2, handled)
+
+	e3 := i2(nil, &MockServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/PushService/Random"}, handler)
+	suite.Equal(status.Error(codes.PermissionDenied, "FORBIDDEN"), e3)
+
+	e4 := i2(nil, &MockServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}, handler)
+	suite.Equal(status.Error(codes.Unauthenticated, "UNAUTHORISED"), e4)
+	suite.Equal(2, handled)
+}
+
+// MockServerStream is a server stream that only carries a context
+type MockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (m *MockServerStream) Context() context.Context {
+	return m.ctx
 }
 
 func MockUnaryHandler(ctx context.Context, req interface{}) (interface{}, error) {
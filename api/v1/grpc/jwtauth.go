@@ -0,0 +1,291 @@
+package grpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ARGOeu/ams-push-server/acl"
+	"github.com/ARGOeu/ams-push-server/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// JWTLeeway is the clock skew tolerated when validating the time based claims of a jwt
+const JWTLeeway = 30 * time.Second
+
+// jwtSigningMethods are the asymmetric signing methods that jwts are accepted with
+var jwtSigningMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// JWTAuthProvider authenticates callers through jwt bearer tokens, signed by any key of a local JWKS file
+type JWTAuthProvider struct {
+	jwksFile  string
+	issuer    string
+	audience  string
+	roleClaim string
+	mutex     sync.RWMutex
+	keys      map[string]crypto.PublicKey
+}
+
+// NewJWTAuthProvider initialises and returns a new jwt auth provider, loading the keys of the JWKS file
+func NewJWTAuthProvider(jwksFile, issuer, audience, roleClaim string) (*JWTAuthProvider, error) {
+
+	p := &JWTAuthProvider{
+		jwksFile:  jwksFile,
+		issuer:    issuer,
+		audience:  audience,
+		roleClaim: roleClaim,
+	}
+
+	err := p.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Reload loads the keys of the JWKS file again, so that rotated keys are picked up
+func (p *JWTAuthProvider) Reload() error {
+
+	keys, err := LoadJWKS(p.jwksFile)
+	if err != nil {
+		return errors.Errorf("Could not load JWKS file %v, %v", p.jwksFile, err.Error())
+	}
+
+	p.mutex.Lock()
+	p.keys = keys
+	p.mutex.Unlock()
+
+	return nil
+}
+
+// Authenticate returns the identity that the jwt bearer token of the call grants,
+// the token should be signed by a known key, be issued by the expected issuer for the expected audience and carry a role
+func (p *JWTAuthProvider) Authenticate(ctx context.Context) (Identity, error) {
+
+	token := bearerToken(ctx)
+	if token == "" {
+		return Identity{}, fmt.Errorf("%w, no bearer token provided", ErrNoCredentials)
+	}
+
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(token, claims, p.key,
+		jwt.WithValidMethods(jwtSigningMethods),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(JWTLeeway),
+	)
+	if err != nil {
+		return Identity{}, errors.Errorf("invalid jwt, %v", err.Error())
+	}
+
+	roleName, _ := claims[p.roleClaim].(string)
+	role, err := acl.ParseRole(roleName)
+	if err != nil {
+		return Identity{}, errors.Errorf("invalid jwt, claim %v: %v", p.roleClaim, err.Error())
+	}
+
+	subject, _ := claims.GetSubject()
+
+	return Identity{
+		Name:     subject,
+		Provider: config.JWTAuthProvider,
+		Role:     role,
+	}, nil
+}
+
+// key returns the key that the token should be verified with, based on its key id.
+// Tokens without a key id are accepted only when the key set holds a single key
+func (p *JWTAuthProvider) key(token *jwt.Token) (interface{}, error) {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, nil
+		}
+	}
+
+	key, found := p.keys[kid]
+	if !found {
+		return nil, errors.Errorf("unknown key id %v", kid)
+	}
+
+	return key, nil
+}
+
+// jwk is a single key of a JSON Web Key Set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadJWKS reads the JSON Web Key Set of the provided file and returns its signing keys by key id.
+// RSA, EC(P-256,P-384,P-521) and OKP(Ed25519) keys are supported
+func LoadJWKS(path string) (map[string]crypto.PublicKey, error) {
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	err = json.Unmarshal(b, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+
+	for _, k := range set.Keys {
+
+		// skip keys meant for encryption
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, errors.Errorf("key %v, %v", k.Kid, err.Error())
+		}
+
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found")
+	}
+
+	return keys, nil
+}
+
+// publicKey decodes the public key that the jwk describes
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+
+	switch k.Kty {
+
+	case "RSA":
+
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %v", k.Crv)
+		}
+
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		// make sure that the point lies on the curve, using its uncompressed form
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x.Bytes()) > size || len(y.Bytes()) > size {
+			return nil, errors.New("invalid point")
+		}
+
+		point := append([]byte{4}, x.FillBytes(make([]byte, size))...)
+		point = append(point, y.FillBytes(make([]byte, size))...)
+
+		_, err = ecdhCurve.NewPublicKey(point)
+		if err != nil {
+			return nil, errors.New("invalid point")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+
+		if k.Crv != "Ed25519" {
+			return nil, errors.Errorf("unsupported curve %v", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid key size")
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errors.Errorf("unsupported key type %v", k.Kty)
+}
+
+// decodeJWKInt decodes a base64url encoded big endian integer of a jwk
+func decodeJWKInt(s string) (*big.Int, error) {
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,241 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/ARGOeu/ams-push-server/acl"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/metadata"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type JWTAuthTestSuite struct {
+	suite.Suite
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	edKey  ed25519.PrivateKey
+}
+
+func (suite *JWTAuthTestSuite) SetupSuite() {
+	suite.rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	suite.ecKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, suite.edKey, _ = ed25519.GenerateKey(rand.Reader)
+}
+
+// writeJWKS writes the public keys of the suite as a JSON Web Key Set and returns the path of the file
+func (suite *JWTAuthTestSuite) writeJWKS(dir string) string {
+
+	b64 := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa-1",
+				"use": "sig",
+				"n":   b64(suite.rsaKey.N.Bytes()),
+				"e":   b64(big.NewInt(int64(suite.rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec-1",
+				"crv": "P-256",
+				"x":   b64(suite.ecKey.X.FillBytes(make([]byte, 32))),
+				"y":   b64(suite.ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+			{
+				"kty": "OKP",
+				"kid": "ed-1",
+				"crv": "Ed25519",
+				"x":   b64(suite.edKey.Public().(ed25519.PublicKey)),
+			},
+			{
+				"kty": "RSA",
+				"kid": "enc-1",
+				"use": "enc",
+			},
+		},
+	}
+
+	b, _ := json.Marshal(set)
+	path := filepath.Join(dir, "jwks.json")
+	suite.Nil(os.WriteFile(path, b, 0600))
+
+	return path
+}
+
+// sign returns a signed jwt carrying the provided claims
+func (suite *JWTAuthTestSuite) sign(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	suite.Nil(err)
+	return signed
+}
+
+func tokenContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// TestAuthenticate tests the validation of jwt bearer tokens
+func (suite *JWTAuthTestSuite) TestAuthenticate() {
+
+	p, err := NewJWTAuthProvider(suite.writeJWKS(suite.T().TempDir()), "https://ams.example.com", "ams-push-server", "role")
+	suite.Nil(err)
+	suite.Equal(3, len(p.keys))
+
+	claims := func(role string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":  "ams-api",
+			"iss":  "https://ams.example.com",
+			"aud":  "ams-push-server",
+			"exp":  time.Now().Add(time.Hour).Unix(),
+			"role": role,
+		}
+	}
+
+	// every supported key type
+	id1, e1 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", suite.rsaKey, claims("admin"))))
+	suite.Nil(e1)
+	suite.Equal(Identity{Name: "ams-api", Provider: "jwt", Role: acl.AdminRole}, id1)
+
+	id2, e2 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodES256, "ec-1", suite.ecKey, claims("read_only"))))
+	suite.Nil(e2)
+	suite.Equal(Identity{Name: "ams-api", Provider: "jwt", Role: acl.ReadOnlyRole}, id2)
+
+	_, e3 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodEdDSA, "ed-1", suite.edKey, claims("admin"))))
+	suite.Nil(e3)
+
+	// signed by a key that is not part of the set
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, e4 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", otherKey, claims("admin"))))
+	suite.Contains(e4.Error(), "invalid jwt, token signature is invalid")
+
+	_, e5 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-2", suite.rsaKey, claims("admin"))))
+	suite.Contains(e5.Error(), "unknown key id rsa-2")
+
+	// symmetric signatures are not accepted
+	_, e6 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodHS256, "rsa-1", []byte("secret"), claims("admin"))))
+	suite.Contains(e6.Error(), "signing method HS256 is invalid")
+
+	// wrong issuer
+	c7 := claims("admin")
+	c7["iss"] = "https://other.example.com"
+	_, e7 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", suite.rsaKey, c7)))
+	suite.Contains(e7.Error(), "token has invalid issuer")
+
+	// wrong audience
+	c8 := claims("admin")
+	c8["aud"] = []string{"other"}
+	_, e8 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", suite.rsaKey, c8)))
+	suite.Contains(e8.Error(), "token has invalid audience")
+
+	// expired
+	c9 := claims("admin")
+	c9["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, e9 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", suite.rsaKey, c9)))
+	suite.Contains(e9.Error(), "token is expired")
+
+	// no expiry
+	c10 := claims("admin")
+	delete(c10, "exp")
+	_, e10 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", suite.rsaKey, c10)))
+	suite.Contains(e10.Error(), "token is missing required claim: exp claim is required")
+
+	// unknown role
+	_, e11 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", suite.rsaKey, claims("root"))))
+	suite.Equal("invalid jwt, claim role: Invalid role root", e11.Error())
+
+	// no bearer token in the call
+	_, e12 := p.Authenticate(context.Background())
+	suite.True(errors.Is(e12, ErrNoCredentials))
+}
+
+// TestReload tests that the keys are picked up again from the JWKS file
+func (suite *JWTAuthTestSuite) TestReload() {
+
+	dir := suite.T().TempDir()
+	path := filepath.Join(dir, "jwks.json")
+
+	// a single key is used for tokens without a key id
+	b, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(suite.edKey.Public().(ed25519.PublicKey)),
+		}},
+	})
+	suite.Nil(os.WriteFile(path, b, 0600))
+
+	p, err := NewJWTAuthProvider(path, "iss", "aud", "role")
+	suite.Nil(err)
+
+	claims := jwt.MapClaims{"sub": "s", "iss": "iss", "aud": "aud", "role": "admin", "exp": time.Now().Add(time.Hour).Unix()}
+
+	_, e1 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodEdDSA, "", suite.edKey, claims)))
+	suite.Nil(e1)
+
+	_, e2 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", suite.rsaKey, claims)))
+	suite.NotNil(e2)
+
+	// the keys are rotated
+	suite.writeJWKS(dir)
+	suite.Nil(p.Reload())
+
+	_, e3 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", suite.rsaKey, claims)))
+	suite.Nil(e3)
+
+	// an invalid file keeps the current keys
+	suite.Nil(os.WriteFile(path, []byte("{"), 0600))
+	suite.Equal("Could not load JWKS file "+path+", unexpected end of JSON input", p.Reload().Error())
+
+	_, e4 := p.Authenticate(tokenContext(suite.sign(jwt.SigningMethodRS256, "rsa-1", suite.rsaKey, claims)))
+	suite.Nil(e4)
+}
+
+// TestLoadJWKS tests the parsing of JSON Web Key Sets
+func (suite *JWTAuthTestSuite) TestLoadJWKS() {
+
+	dir := suite.T().TempDir()
+
+	write := func(content string) string {
+		path := filepath.Join(dir, "jwks.json")
+		suite.Nil(os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	_, e1 := LoadJWKS(write(`{"keys": []}`))
+	suite.Equal("no signing keys found", e1.Error())
+
+	_, e2 := LoadJWKS(write(`{"keys": [{"kty": "oct", "kid": "k1"}]}`))
+	suite.Equal("key k1, unsupported key type oct", e2.Error())
+
+	_, e3 := LoadJWKS(write(`{"keys": [{"kty": "EC", "kid": "k1", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`))
+	suite.Equal("key k1, invalid point", e3.Error())
+
+	_, e4 := LoadJWKS(write(`{"keys": [{"kty": "OKP", "kid": "k1", "crv": "X25519", "x": "AQ"}]}`))
+	suite.Equal("key k1, unsupported curve X25519", e4.Error())
+
+	_, e5 := LoadJWKS(write(`{"keys": [{"kty": "RSA", "kid": "k1", "n": "", "e": "AQAB"}]}`))
+	suite.Equal("key k1, empty key parameter", e5.Error())
+}
+
+func TestJWTAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(JWTAuthTestSuite))
+}
@@ -0,0 +1,405 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	log "github.com/sirupsen/logrus"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// states of a subscription while it is being loaded at startup
+const (
+	loadPending  = "pending"
+	loadRetrying = "retrying"
+	loadLoaded   = "loaded"
+	loadFailed   = "failed"
+)
+
+// backoff is the exponentially growing wait between the attempts of an operation
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+	next    time.Duration
+}
+
+// wait returns how long to wait before the next attempt, with up to 10% jitter, and doubles the wait that follows
+func (b *backoff) wait() time.Duration {
+
+	if b.next == 0 {
+		b.next = b.initial
+	}
+
+	d := b.next
+
+	b.next *= 2
+	if b.next > b.max {
+		b.next = b.max
+	}
+
+	return d + time.Duration(rand.Int63n(int64(d)/10+1))
+}
+
+// loadStats holds the progress of the loading of the push worker user's subscriptions at startup
+type loadStats struct {
+	mutex sync.Mutex
+	// state of every subscription of the push worker user, keyed by its full name
+	subs map[string]string
+	// whether the loading has completed, including its retries
+	done bool
+}
+
+// start records the subscriptions that are about to be loaded
+func (s *loadStats) start(names []string) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.subs = make(map[string]string)
+	for _, name := range names {
+		s.subs[name] = loadPending
+	}
+}
+
+// set changes the state of the provided subscriptions, ignoring the ones that weren't assigned at startup
+func (s *loadStats) set(state string, names ...string) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, name := range names {
+		if _, found := s.subs[name]; found {
+			s.subs[name] = state
+		}
+	}
+}
+
+// finish marks the loading as completed
+func (s *loadStats) finish() {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.done = true
+}
+
+// status returns the progress as it is reported by the status of the service, or nil if the loading hasn't started.
+// The service is ready once the loaded subscriptions reach the provided fraction of all of them
+func (s *loadStats) status(readyFraction float64) *amsPb.LoadStatus {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.subs == nil {
+		return nil
+	}
+
+	ls := &amsPb.LoadStatus{
+		Total: int64(len(s.subs)),
+		Done:  s.done,
+	}
+
+	for _, state := range s.subs {
+		switch state {
+		case loadLoaded:
+			ls.Loaded++
+		case loadRetrying:
+			ls.Retrying++
+		case loadFailed:
+			ls.Failed++
+		}
+	}
+
+	ls.Ready = ls.Total == 0 || float64(ls.Loaded) >= readyFraction*float64(ls.Total)
+
+	return ls
+}
+
+// loadSubscriptions activates all the ams subscriptions that are push enabled and assigned to the current push worker.
+// The subscriptions are retrieved in parallel and the ones that couldn't be retrieved are retried with a backoff,
+// the service becomes ok once the configured fraction of them has been loaded. The loading stops once the context is done
+func (ps *PushService) loadSubscriptions(ctx context.Context) {
+
+	t1 := time.Now()
+
+	userInfo, ok := ps.retrieveUser(ctx)
+	if !ok {
+		return
+	}
+
+	names := make([]string, 0)
+	for _, project := range userInfo.Projects {
+		for _, subName := range project.Subscriptions {
+			names = append(names, fmt.Sprintf("/projects/%v/subscriptions/%v", project.Project, subName))
+		}
+	}
+
+	ps.loadStats.start(names)
+	ps.updateReadiness()
+
+	loading := ps.Cfg.SubscriptionLoading
+	b := ps.loadBackoff
+
+	pending := names
+	for retry := 0; len(pending) > 0; retry++ {
+
+		failed := ps.loadBatch(ctx, pending, loading.GetConcurrency())
+		if len(failed) == 0 {
+			break
+		}
+
+		if retry == loading.GetRetries() {
+			ps.loadStats.set(loadFailed, failed...)
+			log.WithFields(
+				log.Fields{
+					"type":          "system_log",
+					"subscriptions": failed,
+				},
+			).Error("Could not load subscriptions, they will be picked up by the next reconciliation")
+			break
+		}
+
+		ps.loadStats.set(loadRetrying, failed...)
+
+		wait := b.wait()
+		log.WithFields(
+			log.Fields{
+				"type":          "system_log",
+				"subscriptions": failed,
+				"retry_in":      wait.String(),
+			},
+		).Warning("Could not load subscriptions, retrying")
+
+		if !ps.pause(ctx, wait) {
+			return
+		}
+
+		pending = failed
+	}
+
+	ps.loadStats.finish()
+	ps.updateReadiness()
+
+	ls := ps.loadStats.status(loading.GetReadyFraction())
+	if !ls.Ready {
+		log.WithFields(
+			log.Fields{
+				"type":           "system_log",
+				"loaded":         ls.Loaded,
+				"total":          ls.Total,
+				"ready_fraction": loading.GetReadyFraction(),
+			},
+		).Warning("Loaded fewer subscriptions than the ready fraction, serving anyway")
+	}
+
+	log.WithFields(
+		log.Fields{
+			"type":            "performance_log",
+			"total":           ls.Total,
+			"loaded":          ls.Loaded,
+			"failed":          ls.Failed,
+			"active":          ps.PushWorkers.Len(),
+			"processing_time": time.Since(t1).String(),
+		},
+	).Info("Subscriptions loaded")
+}
+
+// retrieveUser retrieves the push worker user, backing off between the attempts for as long as ams can't be reached.
+// It returns false if the service started shutting down or the context is done in the meantime
+func (ps *PushService) retrieveUser(ctx context.Context) (ams.UserInfo, bool) {
+
+	b := ps.loadBackoff
+
+	for {
+
+		t1 := time.Now()
+		userInfo, err := ps.AmsClient.GetUserByToken(ctx, ps.Cfg.AmsToken)
+		if err == nil {
+			log.WithFields(
+				log.Fields{
+					"type":            "performance_log",
+					"user":            userInfo.Name,
+					"processing_time": time.Since(t1).String(),
+				},
+			).Info("Push worker user retrieved successfully")
+			return userInfo, true
+		}
+
+		ps.setStatus("Could not retrieve push worker user")
+
+		wait := b.wait()
+		log.WithFields(
+			log.Fields{
+				"type":     "system_log",
+				"error":    err.Error(),
+				"retry_in": wait.String(),
+			},
+		).Error("Could not retrieve push worker user")
+
+		if !ps.pause(ctx, wait) {
+			return ams.UserInfo{}, false
+		}
+	}
+}
+
+// pause waits for the provided duration, it returns false if the service started shutting down or the context is done
+func (ps *PushService) pause(ctx context.Context, d time.Duration) bool {
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+	}
+
+	return !ps.shuttingDown.Load()
+}
+
+// loadBatch retrieves the provided subscriptions, at most concurrency of them at the same time, and activates the
+// push enabled ones as they arrive. It returns the subscriptions that couldn't be retrieved
+func (ps *PushService) loadBatch(ctx context.Context, names []string, concurrency int) []string {
+
+	type retrieval struct {
+		name string
+		sub  ams.Subscription
+		err  error
+	}
+
+	retrievals := make(chan retrieval)
+	sem := make(chan struct{}, concurrency)
+
+	go func() {
+
+		wg := sync.WaitGroup{}
+
+		for _, name := range names {
+
+			sem <- struct{}{}
+			wg.Add(1)
+
+			go func(name string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				sub, err := ps.AmsClient.GetSubscription(ctx, name)
+				retrievals <- retrieval{name: name, sub: sub, err: err}
+			}(name)
+		}
+
+		wg.Wait()
+		close(retrievals)
+	}()
+
+	failed := make([]string, 0)
+
+	// the activations happen one at a time, in the goroutine of the loader
+	for r := range retrievals {
+
+		if r.err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":         "system_log",
+					"subscription": r.name,
+					"error":        r.err.Error(),
+				},
+			).Error("Could not retrieve subscription")
+			failed = append(failed, r.name)
+			continue
+		}
+
+		ps.markLoaded(r.name)
+		ps.activateLoaded(ctx, r.sub)
+	}
+
+	sort.Strings(failed)
+
+	return failed
+}
+
+// activateLoaded activates a subscription retrieved from ams, if it is push enabled and not already active
+func (ps *PushService) activateLoaded(ctx context.Context, sub ams.Subscription) {
+
+	if !sub.IsPushEnabled() {
+		log.WithFields(
+			log.Fields{
+				"type":         "system_log",
+				"subscription": sub.FullName,
+			},
+		).Debug("Subscription is not push enabled")
+		return
+	}
+
+	if !ps.owns(sub.FullName) {
+		log.WithFields(
+			log.Fields{
+				"type":         "system_log",
+				"subscription": sub.FullName,
+			},
+		).Debug("Subscription is owned by another instance of the cluster")
+		return
+	}
+
+	if ps.IsSubActive(sub.FullName) {
+		return
+	}
+
+	if ps.pushedByPreviousOwner(sub.FullName) {
+		log.WithFields(
+			log.Fields{
+				"type":         "system_log",
+				"subscription": sub.FullName,
+			},
+		).Debug("Subscription is still pushed by its previous owner, waiting for the hand off")
+		return
+	}
+
+	_, err := ps.ActivateSubscription(NewIdentityContext(ctx, systemIdentity), &amsPb.ActivateSubscriptionRequest{
+		Subscription: ToSubscription(sub),
+	})
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":         "system_log",
+				"subscription": sub.FullName,
+				"error":        err.Error(),
+			},
+		).Error("Could not activate subscription")
+		return
+	}
+
+	log.WithFields(
+		log.Fields{
+			"type":         "system_log",
+			"subscription": sub.FullName,
+		},
+	).Info("Subscription activated successfully")
+}
+
+// markLoaded records that a subscription has been retrieved from ams and updates the readiness of the service
+func (ps *PushService) markLoaded(name string) {
+	ps.loadStats.set(loadLoaded, name)
+	ps.updateReadiness()
+}
+
+// updateReadiness sets the status of the service to ok once enough subscriptions have been loaded, or once the loading
+// is done even if they haven't, since the rest are left to the reconciliation. From then on readiness is only reported
+// through the load status, it doesn't hold back the api
+func (ps *PushService) updateReadiness() {
+
+	ls := ps.loadStats.status(ps.Cfg.SubscriptionLoading.GetReadyFraction())
+	if ls == nil {
+		return
+	}
+
+	if ls.Ready || ls.Done {
+		ps.setStatus("ok")
+		return
+	}
+
+	ps.setStatus(fmt.Sprintf("Loaded %v of %v subscriptions", ls.Loaded, ls.Total))
+}
@@ -0,0 +1,206 @@
+package grpc
+
+import (
+	"context"
+	"github.com/ARGOeu/ams-push-server/config"
+	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type LoaderTestSuite struct {
+	suite.Suite
+}
+
+// flakyRoundTripper fails the first requests to the provided paths and keeps track of the concurrent requests,
+// before handing them over to the mock ams
+type flakyRoundTripper struct {
+	mutex sync.Mutex
+	// how many more times each path fails
+	failures map[string]int
+	// how many requests are in flight and the most there have been at the same time
+	inFlight    int
+	maxInFlight int
+}
+
+func (f *flakyRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	// only the retrievals of the subscriptions count, not the consumption of the activated ones
+	retrieval := strings.Contains(r.URL.Path, "/subscriptions/") && !strings.Contains(r.URL.Path, ":")
+
+	f.mutex.Lock()
+	if retrieval {
+		f.inFlight++
+		if f.inFlight > f.maxInFlight {
+			f.maxInFlight = f.inFlight
+		}
+	}
+	fail := f.failures[r.URL.Path] > 0
+	if fail {
+		f.failures[r.URL.Path]--
+	}
+	f.mutex.Unlock()
+
+	defer func() {
+		f.mutex.Lock()
+		if retrieval {
+			f.inFlight--
+		}
+		f.mutex.Unlock()
+	}()
+
+	// give the rest of the requests the chance to be in flight at the same time
+	time.Sleep(10 * time.Millisecond)
+
+	if fail {
+		return &http.Response{
+			StatusCode: 503,
+			Body:       io.NopCloser(strings.NewReader("service unavailable")),
+			Header:     make(http.Header),
+		}, nil
+	}
+
+	return new(ams.MockAmsRoundTripper).RoundTrip(r)
+}
+
+// newLoaderPushService returns a push service that talks to the provided round tripper and backs off for milliseconds
+func newLoaderPushService(cfg *config.Config, rt http.RoundTripper) *PushService {
+
+	ps := NewPushService(cfg)
+	client := &http.Client{
+		Transport: rt,
+	}
+	ps.Client = client
+	ps.AmsClient = ams.NewClient("", "", "", 443, client)
+	ps.loadBackoff = backoff{initial: time.Millisecond, max: 4 * time.Millisecond}
+
+	return ps
+}
+
+// TestBackoff tests that the wait doubles with every attempt, up to the max
+func (suite *LoaderTestSuite) TestBackoff() {
+
+	b := backoff{initial: 100 * time.Millisecond, max: 300 * time.Millisecond}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for _, e := range expected {
+		wait := b.wait()
+		suite.GreaterOrEqual(wait, e)
+		suite.LessOrEqual(wait, e+e/10)
+	}
+}
+
+// TestLoadSubscriptionsRetries tests that the user and the subscriptions that couldn't be retrieved are retried
+func (suite *LoaderTestSuite) TestLoadSubscriptionsRetries() {
+
+	rt := &flakyRoundTripper{
+		failures: map[string]int{
+			"/v1/users:byToken/sometoken":           2,
+			"/v1/projects/push1/subscriptions/sub1": 3,
+		},
+	}
+
+	ps := newLoaderPushService(config.NewMockConfig(), rt)
+
+	ps.loadSubscriptions(context.Background())
+
+	suite.True(ps.IsSubActive("/projects/push1/subscriptions/sub1"))
+	suite.True(ps.IsSubActive("/projects/push2/subscriptions/sub4"))
+	suite.True(ps.IsSubActive("/projects/push2/subscriptions/sub5"))
+	suite.False(ps.IsSubActive("/projects/push2/subscriptions/sub3"))
+
+	// errorsub never loads, so the service isn't ready with the default ready fraction,
+	// yet once the loading is done it serves anyway
+	suite.Equal("ok", ps.getStatus())
+
+	ls := ps.loadStats.status(1)
+	suite.Equal(int64(5), ls.Total)
+	suite.Equal(int64(4), ls.Loaded)
+	suite.Equal(int64(0), ls.Retrying)
+	suite.Equal(int64(1), ls.Failed)
+	suite.False(ls.Ready)
+	suite.True(ls.Done)
+
+	// a reconciliation that retrieves errorsub makes the service ready
+	ps.markLoaded("/projects/push1/subscriptions/errorsub")
+	suite.Equal("ok", ps.getStatus())
+	suite.True(ps.loadStats.status(1).Ready)
+}
+
+// TestUpdateReadiness tests that the api is held back while the loading is in progress, until the ready fraction is reached
+func (suite *LoaderTestSuite) TestUpdateReadiness() {
+
+	ps := &PushService{Cfg: config.NewMockConfig()}
+
+	ps.loadStats.start([]string{"s1", "s2"})
+	ps.updateReadiness()
+	suite.Equal("Loaded 0 of 2 subscriptions", ps.getStatus())
+
+	ps.markLoaded("s1")
+	suite.Equal("Loaded 1 of 2 subscriptions", ps.getStatus())
+
+	// the loading is done without reaching the ready fraction, the service reports it but serves
+	ps.loadStats.set(loadFailed, "s2")
+	ps.loadStats.finish()
+	ps.updateReadiness()
+	suite.Equal("ok", ps.getStatus())
+	suite.False(ps.loadStats.status(1).Ready)
+}
+
+// TestLoadSubscriptionsConcurrency tests that no more than the configured subscriptions are retrieved at the same time
+func (suite *LoaderTestSuite) TestLoadSubscriptionsConcurrency() {
+
+	cfg := config.NewMockConfig()
+	cfg.SubscriptionLoading.Concurrency = 2
+	cfg.SubscriptionLoading.Retries = 1
+
+	rt := &flakyRoundTripper{}
+	ps := newLoaderPushService(cfg, rt)
+
+	ps.loadSubscriptions(context.Background())
+
+	rt.mutex.Lock()
+	suite.Equal(2, rt.maxInFlight)
+	rt.mutex.Unlock()
+
+	// errorsub is attempted twice and then given up
+	suite.Equal(int64(1), ps.loadStats.status(1).Failed)
+}
+
+// TestLoadStatsReadiness tests that the service is ready once the loaded subscriptions reach the ready fraction
+func (suite *LoaderTestSuite) TestLoadStatsReadiness() {
+
+	ls := new(loadStats)
+	suite.Nil(ls.status(1))
+
+	ls.start([]string{"s1", "s2", "s3", "s4"})
+	suite.False(ls.status(0.5).Ready)
+
+	ls.set(loadLoaded, "s1", "s2")
+	ls.set(loadRetrying, "s3")
+	// subscriptions that weren't assigned at startup are ignored
+	ls.set(loadLoaded, "s5")
+
+	st := ls.status(0.5)
+	suite.True(st.Ready)
+	suite.Equal(int64(4), st.Total)
+	suite.Equal(int64(2), st.Loaded)
+	suite.Equal(int64(1), st.Retrying)
+	suite.False(st.Done)
+	suite.False(ls.status(0.75).Ready)
+
+	// a user without subscriptions is ready right away
+	ls.start(nil)
+	suite.True(ls.status(1).Ready)
+}
+
+func TestLoaderTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(LoaderTestSuite))
+}
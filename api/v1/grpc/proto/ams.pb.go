@@ -50,6 +50,38 @@ func (PushType) EnumDescriptor() ([]byte, []int) {
 	return fileDescriptor_85e4db6795b5b1aa, []int{0}
 }
 
+// DeliveryPolicy declares when messages delivered to multiple destinations are considered delivered
+type DeliveryPolicy int32
+
+const (
+	// ALL_MUST_SUCCEED acknowledges the messages only when every destination has received them
+	DeliveryPolicy_ALL_MUST_SUCCEED DeliveryPolicy = 0
+	// ANY_SUCCEEDS acknowledges the messages when at least one destination has received them
+	DeliveryPolicy_ANY_SUCCEEDS DeliveryPolicy = 1
+	// BEST_EFFORT acknowledges the messages regardless of the outcome of the deliveries
+	DeliveryPolicy_BEST_EFFORT DeliveryPolicy = 2
+)
+
+var DeliveryPolicy_name = map[int32]string{
+	0: "ALL_MUST_SUCCEED",
+	1: "ANY_SUCCEEDS",
+	2: "BEST_EFFORT",
+}
+
+var DeliveryPolicy_value = map[string]int32{
+	"ALL_MUST_SUCCEED": 0,
+	"ANY_SUCCEEDS":     1,
+	"BEST_EFFORT":      2,
+}
+
+func (x DeliveryPolicy) String() string {
+	return proto.EnumName(DeliveryPolicy_name, int32(x))
+}
+
+func (DeliveryPolicy) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{1}
+}
+
 // Empty wrapper for status request call
 type SubscriptionStatusRequest struct {
 	// Required. The full resource name of the subscrption.
@@ -94,10 +126,12 @@ func (m *SubscriptionStatusRequest) GetFullName() string {
 // Empty wrapper for status response call
 type SubscriptionStatusResponse struct {
 	// Required. The full resource name of the subscrption.
-	Status               string   `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Status string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	// Delivery status of each destination, when the subscription fans out to multiple destinations
+	Destinations         []*DestinationStatus `protobuf:"bytes,2,rep,name=destinations,proto3" json:"destinations,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
 }
 
 func (m *SubscriptionStatusResponse) Reset()         { *m = SubscriptionStatusResponse{} }
@@ -132,6 +166,81 @@ func (m *SubscriptionStatusResponse) GetStatus() string {
 	return ""
 }
 
+func (m *SubscriptionStatusResponse) GetDestinations() []*DestinationStatus {
+	if m != nil {
+		return m.Destinations
+	}
+	return nil
+}
+
+// DestinationStatus holds delivery information for a single destination of a subscription
+type DestinationStatus struct {
+	// The destination the messages are delivered to
+	Destination string `protobuf:"bytes,1,opt,name=destination,proto3" json:"destination,omitempty"`
+	// Number of messages that have been delivered successfully
+	DeliveredMessages uint64 `protobuf:"varint,2,opt,name=delivered_messages,json=deliveredMessages,proto3" json:"delivered_messages,omitempty"`
+	// Number of push requests that have failed
+	FailedDeliveries uint64 `protobuf:"varint,3,opt,name=failed_deliveries,json=failedDeliveries,proto3" json:"failed_deliveries,omitempty"`
+	// The last error that occurred while delivering to the destination
+	LastError            string   `protobuf:"bytes,4,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DestinationStatus) Reset()         { *m = DestinationStatus{} }
+func (m *DestinationStatus) String() string { return proto.CompactTextString(m) }
+func (*DestinationStatus) ProtoMessage()    {}
+func (*DestinationStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{2}
+}
+
+func (m *DestinationStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DestinationStatus.Unmarshal(m, b)
+}
+func (m *DestinationStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DestinationStatus.Marshal(b, m, deterministic)
+}
+func (m *DestinationStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DestinationStatus.Merge(m, src)
+}
+func (m *DestinationStatus) XXX_Size() int {
+	return xxx_messageInfo_DestinationStatus.Size(m)
+}
+func (m *DestinationStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_DestinationStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DestinationStatus proto.InternalMessageInfo
+
+func (m *DestinationStatus) GetDestination() string {
+	if m != nil {
+		return m.Destination
+	}
+	return ""
+}
+
+func (m *DestinationStatus) GetDeliveredMessages() uint64 {
+	if m != nil {
+		return m.DeliveredMessages
+	}
+	return 0
+}
+
+func (m *DestinationStatus) GetFailedDeliveries() uint64 {
+	if m != nil {
+		return m.FailedDeliveries
+	}
+	return 0
+}
+
+func (m *DestinationStatus) GetLastError() string {
+	if m != nil {
+		return m.LastError
+	}
+	return ""
+}
+
 // Empty wrapper for status request call
 type StatusRequest struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -143,7 +252,7 @@ func (m *StatusRequest) Reset()         { *m = StatusRequest{} }
 func (m *StatusRequest) String() string { return proto.CompactTextString(m) }
 func (*StatusRequest) ProtoMessage()    {}
 func (*StatusRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_85e4db6795b5b1aa, []int{2}
+	return fileDescriptor_85e4db6795b5b1aa, []int{3}
 }
 
 func (m *StatusRequest) XXX_Unmarshal(b []byte) error {
@@ -164,18 +273,160 @@ func (m *StatusRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_StatusRequest proto.InternalMessageInfo
 
-// Empty wrapper for status response call
-type StatusResponse struct {
+// Empty wrapper for list subscriptions request call
+type ListSubscriptionsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListSubscriptionsRequest) Reset()         { *m = ListSubscriptionsRequest{} }
+func (m *ListSubscriptionsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListSubscriptionsRequest) ProtoMessage()    {}
+func (*ListSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{4}
+}
+
+func (m *ListSubscriptionsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSubscriptionsRequest.Unmarshal(m, b)
+}
+func (m *ListSubscriptionsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSubscriptionsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListSubscriptionsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSubscriptionsRequest.Merge(m, src)
+}
+func (m *ListSubscriptionsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListSubscriptionsRequest.Size(m)
+}
+func (m *ListSubscriptionsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSubscriptionsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSubscriptionsRequest proto.InternalMessageInfo
+
+// Wrapper for list subscriptions response call
+type ListSubscriptionsResponse struct {
+	// The currently active subscriptions
+	Subscriptions        []*ActiveSubscription `protobuf:"bytes,1,rep,name=subscriptions,proto3" json:"subscriptions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *ListSubscriptionsResponse) Reset()         { *m = ListSubscriptionsResponse{} }
+func (m *ListSubscriptionsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListSubscriptionsResponse) ProtoMessage()    {}
+func (*ListSubscriptionsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{5}
+}
+
+func (m *ListSubscriptionsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSubscriptionsResponse.Unmarshal(m, b)
+}
+func (m *ListSubscriptionsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSubscriptionsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListSubscriptionsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSubscriptionsResponse.Merge(m, src)
+}
+func (m *ListSubscriptionsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListSubscriptionsResponse.Size(m)
+}
+func (m *ListSubscriptionsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSubscriptionsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSubscriptionsResponse proto.InternalMessageInfo
+
+func (m *ListSubscriptionsResponse) GetSubscriptions() []*ActiveSubscription {
+	if m != nil {
+		return m.Subscriptions
+	}
+	return nil
+}
+
+// Summary of an active subscription
+type ActiveSubscription struct {
+	// The full resource name of the subscription
+	FullName string `protobuf:"bytes,1,opt,name=full_name,json=fullName,proto3" json:"full_name,omitempty"`
+	// The full resource name of the subscription's topic
+	FullTopic string `protobuf:"bytes,2,opt,name=full_topic,json=fullTopic,proto3" json:"full_topic,omitempty"`
+	// The status of the worker that handles the subscription
+	Status               string   `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
+func (m *ActiveSubscription) Reset()         { *m = ActiveSubscription{} }
+func (m *ActiveSubscription) String() string { return proto.CompactTextString(m) }
+func (*ActiveSubscription) ProtoMessage()    {}
+func (*ActiveSubscription) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{6}
+}
+
+func (m *ActiveSubscription) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ActiveSubscription.Unmarshal(m, b)
+}
+func (m *ActiveSubscription) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ActiveSubscription.Marshal(b, m, deterministic)
+}
+func (m *ActiveSubscription) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ActiveSubscription.Merge(m, src)
+}
+func (m *ActiveSubscription) XXX_Size() int {
+	return xxx_messageInfo_ActiveSubscription.Size(m)
+}
+func (m *ActiveSubscription) XXX_DiscardUnknown() {
+	xxx_messageInfo_ActiveSubscription.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ActiveSubscription proto.InternalMessageInfo
+
+func (m *ActiveSubscription) GetFullName() string {
+	if m != nil {
+		return m.FullName
+	}
+	return ""
+}
+
+func (m *ActiveSubscription) GetFullTopic() string {
+	if m != nil {
+		return m.FullTopic
+	}
+	return ""
+}
+
+func (m *ActiveSubscription) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+// Wrapper for status response call
+type StatusResponse struct {
+	// Expiration time(RFC3339) of the certificate the service is currently serving, when tls is enabled
+	CertificateExpiry string `protobuf:"bytes,1,opt,name=certificate_expiry,json=certificateExpiry,proto3" json:"certificate_expiry,omitempty"`
+	// Outcome of the reconciliations of the active subscriptions against ams
+	Reconcile *ReconcileStatus `protobuf:"bytes,2,opt,name=reconcile,proto3" json:"reconcile,omitempty"`
+	// Progress of the loading of the subscriptions at startup
+	Load *LoadStatus `protobuf:"bytes,3,opt,name=load,proto3" json:"load,omitempty"`
+	// Membership of the cluster the instance is part of, when cluster mode is enabled
+	Cluster *ClusterStatus `protobuf:"bytes,4,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	// Role of the instance, when leader election is enabled
+	Election             *ElectionStatus `protobuf:"bytes,5,opt,name=election,proto3" json:"election,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
 func (m *StatusResponse) Reset()         { *m = StatusResponse{} }
 func (m *StatusResponse) String() string { return proto.CompactTextString(m) }
 func (*StatusResponse) ProtoMessage()    {}
 func (*StatusResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_85e4db6795b5b1aa, []int{3}
+	return fileDescriptor_85e4db6795b5b1aa, []int{7}
 }
 
 func (m *StatusResponse) XXX_Unmarshal(b []byte) error {
@@ -196,6 +447,328 @@ func (m *StatusResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_StatusResponse proto.InternalMessageInfo
 
+func (m *StatusResponse) GetCertificateExpiry() string {
+	if m != nil {
+		return m.CertificateExpiry
+	}
+	return ""
+}
+
+func (m *StatusResponse) GetReconcile() *ReconcileStatus {
+	if m != nil {
+		return m.Reconcile
+	}
+	return nil
+}
+
+func (m *StatusResponse) GetLoad() *LoadStatus {
+	if m != nil {
+		return m.Load
+	}
+	return nil
+}
+
+func (m *StatusResponse) GetCluster() *ClusterStatus {
+	if m != nil {
+		return m.Cluster
+	}
+	return nil
+}
+
+func (m *StatusResponse) GetElection() *ElectionStatus {
+	if m != nil {
+		return m.Election
+	}
+	return nil
+}
+
+// ElectionStatus holds the role of the instance in the leader election
+type ElectionStatus struct {
+	// Identity under which the instance competes for the lease
+	Identity string `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	// Role of the instance, leader or standby
+	Role string `protobuf:"bytes,2,opt,name=role,proto3" json:"role,omitempty"`
+	// Identity that held the lease the last time the instance tried to acquire it
+	Holder               string   `protobuf:"bytes,3,opt,name=holder,proto3" json:"holder,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ElectionStatus) Reset()         { *m = ElectionStatus{} }
+func (m *ElectionStatus) String() string { return proto.CompactTextString(m) }
+func (*ElectionStatus) ProtoMessage()    {}
+func (*ElectionStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{8}
+}
+
+func (m *ElectionStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ElectionStatus.Unmarshal(m, b)
+}
+func (m *ElectionStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ElectionStatus.Marshal(b, m, deterministic)
+}
+func (m *ElectionStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ElectionStatus.Merge(m, src)
+}
+func (m *ElectionStatus) XXX_Size() int {
+	return xxx_messageInfo_ElectionStatus.Size(m)
+}
+func (m *ElectionStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_ElectionStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ElectionStatus proto.InternalMessageInfo
+
+func (m *ElectionStatus) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *ElectionStatus) GetRole() string {
+	if m != nil {
+		return m.Role
+	}
+	return ""
+}
+
+func (m *ElectionStatus) GetHolder() string {
+	if m != nil {
+		return m.Holder
+	}
+	return ""
+}
+
+// ClusterStatus holds the membership of the cluster as the instance currently sees it
+type ClusterStatus struct {
+	// Name of the instance
+	Self string `protobuf:"bytes,1,opt,name=self,proto3" json:"self,omitempty"`
+	// Instances that are live and share the subscriptions, sorted by name
+	LivePeers []string `protobuf:"bytes,2,rep,name=live_peers,json=livePeers,proto3" json:"live_peers,omitempty"`
+	// Instances that didn't respond to their last probe, sorted by name
+	DownPeers            []string `protobuf:"bytes,3,rep,name=down_peers,json=downPeers,proto3" json:"down_peers,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ClusterStatus) Reset()         { *m = ClusterStatus{} }
+func (m *ClusterStatus) String() string { return proto.CompactTextString(m) }
+func (*ClusterStatus) ProtoMessage()    {}
+func (*ClusterStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{9}
+}
+
+func (m *ClusterStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ClusterStatus.Unmarshal(m, b)
+}
+func (m *ClusterStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ClusterStatus.Marshal(b, m, deterministic)
+}
+func (m *ClusterStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ClusterStatus.Merge(m, src)
+}
+func (m *ClusterStatus) XXX_Size() int {
+	return xxx_messageInfo_ClusterStatus.Size(m)
+}
+func (m *ClusterStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_ClusterStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ClusterStatus proto.InternalMessageInfo
+
+func (m *ClusterStatus) GetSelf() string {
+	if m != nil {
+		return m.Self
+	}
+	return ""
+}
+
+func (m *ClusterStatus) GetLivePeers() []string {
+	if m != nil {
+		return m.LivePeers
+	}
+	return nil
+}
+
+func (m *ClusterStatus) GetDownPeers() []string {
+	if m != nil {
+		return m.DownPeers
+	}
+	return nil
+}
+
+// LoadStatus holds the progress of the loading of the push worker user's subscriptions at startup
+type LoadStatus struct {
+	// How many subscriptions are assigned to the push worker user
+	Total int64 `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	// How many subscriptions have been retrieved from ams
+	Loaded int64 `protobuf:"varint,2,opt,name=loaded,proto3" json:"loaded,omitempty"`
+	// How many subscriptions are waiting to be retrieved again
+	Retrying int64 `protobuf:"varint,3,opt,name=retrying,proto3" json:"retrying,omitempty"`
+	// How many subscriptions couldn't be retrieved after all their retries
+	Failed int64 `protobuf:"varint,4,opt,name=failed,proto3" json:"failed,omitempty"`
+	// Whether enough subscriptions have been loaded for the service to be ready
+	Ready bool `protobuf:"varint,5,opt,name=ready,proto3" json:"ready,omitempty"`
+	// Whether the loading has completed, including its retries
+	Done                 bool     `protobuf:"varint,6,opt,name=done,proto3" json:"done,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LoadStatus) Reset()         { *m = LoadStatus{} }
+func (m *LoadStatus) String() string { return proto.CompactTextString(m) }
+func (*LoadStatus) ProtoMessage()    {}
+func (*LoadStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{10}
+}
+
+func (m *LoadStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LoadStatus.Unmarshal(m, b)
+}
+func (m *LoadStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LoadStatus.Marshal(b, m, deterministic)
+}
+func (m *LoadStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LoadStatus.Merge(m, src)
+}
+func (m *LoadStatus) XXX_Size() int {
+	return xxx_messageInfo_LoadStatus.Size(m)
+}
+func (m *LoadStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_LoadStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LoadStatus proto.InternalMessageInfo
+
+func (m *LoadStatus) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *LoadStatus) GetLoaded() int64 {
+	if m != nil {
+		return m.Loaded
+	}
+	return 0
+}
+
+func (m *LoadStatus) GetRetrying() int64 {
+	if m != nil {
+		return m.Retrying
+	}
+	return 0
+}
+
+func (m *LoadStatus) GetFailed() int64 {
+	if m != nil {
+		return m.Failed
+	}
+	return 0
+}
+
+func (m *LoadStatus) GetReady() bool {
+	if m != nil {
+		return m.Ready
+	}
+	return false
+}
+
+func (m *LoadStatus) GetDone() bool {
+	if m != nil {
+		return m.Done
+	}
+	return false
+}
+
+// ReconcileStatus holds the counters of the reconciliations of the active subscriptions against ams
+type ReconcileStatus struct {
+	// Time(RFC3339) of the last reconciliation
+	LastRun string `protobuf:"bytes,1,opt,name=last_run,json=lastRun,proto3" json:"last_run,omitempty"`
+	// How many reconciliations have run
+	Runs int64 `protobuf:"varint,2,opt,name=runs,proto3" json:"runs,omitempty"`
+	// How many subscriptions have been started, stopped, updated or failed to reconcile, across all reconciliations
+	Started              int64    `protobuf:"varint,3,opt,name=started,proto3" json:"started,omitempty"`
+	Stopped              int64    `protobuf:"varint,4,opt,name=stopped,proto3" json:"stopped,omitempty"`
+	Updated              int64    `protobuf:"varint,5,opt,name=updated,proto3" json:"updated,omitempty"`
+	Failed               int64    `protobuf:"varint,6,opt,name=failed,proto3" json:"failed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReconcileStatus) Reset()         { *m = ReconcileStatus{} }
+func (m *ReconcileStatus) String() string { return proto.CompactTextString(m) }
+func (*ReconcileStatus) ProtoMessage()    {}
+func (*ReconcileStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{11}
+}
+
+func (m *ReconcileStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReconcileStatus.Unmarshal(m, b)
+}
+func (m *ReconcileStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReconcileStatus.Marshal(b, m, deterministic)
+}
+func (m *ReconcileStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReconcileStatus.Merge(m, src)
+}
+func (m *ReconcileStatus) XXX_Size() int {
+	return xxx_messageInfo_ReconcileStatus.Size(m)
+}
+func (m *ReconcileStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReconcileStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReconcileStatus proto.InternalMessageInfo
+
+func (m *ReconcileStatus) GetLastRun() string {
+	if m != nil {
+		return m.LastRun
+	}
+	return ""
+}
+
+func (m *ReconcileStatus) GetRuns() int64 {
+	if m != nil {
+		return m.Runs
+	}
+	return 0
+}
+
+func (m *ReconcileStatus) GetStarted() int64 {
+	if m != nil {
+		return m.Started
+	}
+	return 0
+}
+
+func (m *ReconcileStatus) GetStopped() int64 {
+	if m != nil {
+		return m.Stopped
+	}
+	return 0
+}
+
+func (m *ReconcileStatus) GetUpdated() int64 {
+	if m != nil {
+		return m.Updated
+	}
+	return 0
+}
+
+func (m *ReconcileStatus) GetFailed() int64 {
+	if m != nil {
+		return m.Failed
+	}
+	return 0
+}
+
 // Wrapper for subscription
 type DeactivateSubscriptionResponse struct {
 	// Message response
@@ -209,7 +782,7 @@ func (m *DeactivateSubscriptionResponse) Reset()         { *m = DeactivateSubscr
 func (m *DeactivateSubscriptionResponse) String() string { return proto.CompactTextString(m) }
 func (*DeactivateSubscriptionResponse) ProtoMessage()    {}
 func (*DeactivateSubscriptionResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_85e4db6795b5b1aa, []int{4}
+	return fileDescriptor_85e4db6795b5b1aa, []int{12}
 }
 
 func (m *DeactivateSubscriptionResponse) XXX_Unmarshal(b []byte) error {
@@ -250,7 +823,7 @@ func (m *DeactivateSubscriptionRequest) Reset()         { *m = DeactivateSubscri
 func (m *DeactivateSubscriptionRequest) String() string { return proto.CompactTextString(m) }
 func (*DeactivateSubscriptionRequest) ProtoMessage()    {}
 func (*DeactivateSubscriptionRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_85e4db6795b5b1aa, []int{5}
+	return fileDescriptor_85e4db6795b5b1aa, []int{13}
 }
 
 func (m *DeactivateSubscriptionRequest) XXX_Unmarshal(b []byte) error {
@@ -291,7 +864,7 @@ func (m *ActivateSubscriptionResponse) Reset()         { *m = ActivateSubscripti
 func (m *ActivateSubscriptionResponse) String() string { return proto.CompactTextString(m) }
 func (*ActivateSubscriptionResponse) ProtoMessage()    {}
 func (*ActivateSubscriptionResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_85e4db6795b5b1aa, []int{6}
+	return fileDescriptor_85e4db6795b5b1aa, []int{14}
 }
 
 func (m *ActivateSubscriptionResponse) XXX_Unmarshal(b []byte) error {
@@ -332,7 +905,7 @@ func (m *ActivateSubscriptionRequest) Reset()         { *m = ActivateSubscriptio
 func (m *ActivateSubscriptionRequest) String() string { return proto.CompactTextString(m) }
 func (*ActivateSubscriptionRequest) ProtoMessage()    {}
 func (*ActivateSubscriptionRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_85e4db6795b5b1aa, []int{7}
+	return fileDescriptor_85e4db6795b5b1aa, []int{15}
 }
 
 func (m *ActivateSubscriptionRequest) XXX_Unmarshal(b []byte) error {
@@ -377,7 +950,7 @@ func (m *Subscription) Reset()         { *m = Subscription{} }
 func (m *Subscription) String() string { return proto.CompactTextString(m) }
 func (*Subscription) ProtoMessage()    {}
 func (*Subscription) Descriptor() ([]byte, []int) {
-	return fileDescriptor_85e4db6795b5b1aa, []int{8}
+	return fileDescriptor_85e4db6795b5b1aa, []int{16}
 }
 
 func (m *Subscription) XXX_Unmarshal(b []byte) error {
@@ -439,7 +1012,23 @@ type PushConfig struct {
 	// Mattermost channel that the messages will be delivered to
 	MattermostChannel string `protobuf:"bytes,8,opt,name=mattermost_channel,json=mattermostChannel,proto3" json:"mattermost_channel,omitempty"`
 	// Indicates whether or not the payload should be decoded before being pushed to any remote destination
-	Base_64Decode        bool     `protobuf:"varint,9,opt,name=base_64_decode,json=base64Decode,proto3" json:"base_64_decode,omitempty"`
+	Base_64Decode bool `protobuf:"varint,9,opt,name=base_64_decode,json=base64Decode,proto3" json:"base_64_decode,omitempty"`
+	// Format of the pushed payload. Empty for the native ams format,
+	// cloudevents-binary or cloudevents-structured for CloudEvents 1.0
+	MessageFormat string `protobuf:"bytes,10,opt,name=message_format,json=messageFormat,proto3" json:"message_format,omitempty"`
+	// Compression applied to the pushed payload, can be none, gzip or zstd
+	Compression string `protobuf:"bytes,11,opt,name=compression,proto3" json:"compression,omitempty"`
+	// Minimum payload size in bytes for the compression to be applied
+	CompressionThreshold int64 `protobuf:"varint,12,opt,name=compression_threshold,json=compressionThreshold,proto3" json:"compression_threshold,omitempty"`
+	// Maximum size in bytes of the payload of a single push request, 0 means no limit.
+	// Larger batches of consumed messages are split into multiple push requests
+	MaxBatchBytes int64 `protobuf:"varint,13,opt,name=max_batch_bytes,json=maxBatchBytes,proto3" json:"max_batch_bytes,omitempty"`
+	// Additional destinations that the messages will be delivered to, alongside the one described above
+	Destinations []*Destination `protobuf:"bytes,14,rep,name=destinations,proto3" json:"destinations,omitempty"`
+	// Decides when messages delivered to multiple destinations are acknowledged
+	DeliveryPolicy DeliveryPolicy `protobuf:"varint,15,opt,name=delivery_policy,json=deliveryPolicy,proto3,enum=DeliveryPolicy" json:"delivery_policy,omitempty"`
+	// Timeout in milliseconds of a single push request, 0 uses the timeout of the push client
+	TimeoutMs            int64    `protobuf:"varint,16,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -449,7 +1038,7 @@ func (m *PushConfig) Reset()         { *m = PushConfig{} }
 func (m *PushConfig) String() string { return proto.CompactTextString(m) }
 func (*PushConfig) ProtoMessage()    {}
 func (*PushConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_85e4db6795b5b1aa, []int{9}
+	return fileDescriptor_85e4db6795b5b1aa, []int{17}
 }
 
 func (m *PushConfig) XXX_Unmarshal(b []byte) error {
@@ -533,6 +1122,141 @@ func (m *PushConfig) GetBase_64Decode() bool {
 	return false
 }
 
+func (m *PushConfig) GetMessageFormat() string {
+	if m != nil {
+		return m.MessageFormat
+	}
+	return ""
+}
+
+func (m *PushConfig) GetCompression() string {
+	if m != nil {
+		return m.Compression
+	}
+	return ""
+}
+
+func (m *PushConfig) GetCompressionThreshold() int64 {
+	if m != nil {
+		return m.CompressionThreshold
+	}
+	return 0
+}
+
+func (m *PushConfig) GetMaxBatchBytes() int64 {
+	if m != nil {
+		return m.MaxBatchBytes
+	}
+	return 0
+}
+
+func (m *PushConfig) GetDestinations() []*Destination {
+	if m != nil {
+		return m.Destinations
+	}
+	return nil
+}
+
+func (m *PushConfig) GetDeliveryPolicy() DeliveryPolicy {
+	if m != nil {
+		return m.DeliveryPolicy
+	}
+	return DeliveryPolicy_ALL_MUST_SUCCEED
+}
+
+func (m *PushConfig) GetTimeoutMs() int64 {
+	if m != nil {
+		return m.TimeoutMs
+	}
+	return 0
+}
+
+// Destination holds information on an additional destination of a subscription
+type Destination struct {
+	// Required. Defines the type of the destination the data will be sent to.
+	Type PushType `protobuf:"varint,1,opt,name=type,proto3,enum=PushType" json:"type,omitempty"`
+	// An https endpoint to where the messages will be pushed.
+	PushEndpoint string `protobuf:"bytes,2,opt,name=push_endpoint,json=pushEndpoint,proto3" json:"push_endpoint,omitempty"`
+	// Authorization header that the sent messages should include into the request
+	AuthorizationHeader string `protobuf:"bytes,3,opt,name=authorization_header,json=authorizationHeader,proto3" json:"authorization_header,omitempty"`
+	// Mattermost webhook url
+	MattermostUrl string `protobuf:"bytes,4,opt,name=mattermost_url,json=mattermostUrl,proto3" json:"mattermost_url,omitempty"`
+	// Mattermost username that the messages will be displayed under
+	MattermostUsername string `protobuf:"bytes,5,opt,name=mattermost_username,json=mattermostUsername,proto3" json:"mattermost_username,omitempty"`
+	// Mattermost channel that the messages will be delivered to
+	MattermostChannel    string   `protobuf:"bytes,6,opt,name=mattermost_channel,json=mattermostChannel,proto3" json:"mattermost_channel,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Destination) Reset()         { *m = Destination{} }
+func (m *Destination) String() string { return proto.CompactTextString(m) }
+func (*Destination) ProtoMessage()    {}
+func (*Destination) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{18}
+}
+
+func (m *Destination) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Destination.Unmarshal(m, b)
+}
+func (m *Destination) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Destination.Marshal(b, m, deterministic)
+}
+func (m *Destination) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Destination.Merge(m, src)
+}
+func (m *Destination) XXX_Size() int {
+	return xxx_messageInfo_Destination.Size(m)
+}
+func (m *Destination) XXX_DiscardUnknown() {
+	xxx_messageInfo_Destination.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Destination proto.InternalMessageInfo
+
+func (m *Destination) GetType() PushType {
+	if m != nil {
+		return m.Type
+	}
+	return PushType_HTTP_ENDPOINT
+}
+
+func (m *Destination) GetPushEndpoint() string {
+	if m != nil {
+		return m.PushEndpoint
+	}
+	return ""
+}
+
+func (m *Destination) GetAuthorizationHeader() string {
+	if m != nil {
+		return m.AuthorizationHeader
+	}
+	return ""
+}
+
+func (m *Destination) GetMattermostUrl() string {
+	if m != nil {
+		return m.MattermostUrl
+	}
+	return ""
+}
+
+func (m *Destination) GetMattermostUsername() string {
+	if m != nil {
+		return m.MattermostUsername
+	}
+	return ""
+}
+
+func (m *Destination) GetMattermostChannel() string {
+	if m != nil {
+		return m.MattermostChannel
+	}
+	return ""
+}
+
 // RetryPolicy holds information regarding the retry policy.
 type RetryPolicy struct {
 	// Required. Type of the retry policy used (Only linear policy supported).
@@ -548,7 +1272,7 @@ func (m *RetryPolicy) Reset()         { *m = RetryPolicy{} }
 func (m *RetryPolicy) String() string { return proto.CompactTextString(m) }
 func (*RetryPolicy) ProtoMessage()    {}
 func (*RetryPolicy) Descriptor() ([]byte, []int) {
-	return fileDescriptor_85e4db6795b5b1aa, []int{10}
+	return fileDescriptor_85e4db6795b5b1aa, []int{19}
 }
 
 func (m *RetryPolicy) XXX_Unmarshal(b []byte) error {
@@ -585,62 +1309,115 @@ func (m *RetryPolicy) GetPeriod() uint32 {
 
 func init() {
 	proto.RegisterEnum("PushType", PushType_name, PushType_value)
+	proto.RegisterEnum("DeliveryPolicy", DeliveryPolicy_name, DeliveryPolicy_value)
 	proto.RegisterType((*SubscriptionStatusRequest)(nil), "SubscriptionStatusRequest")
 	proto.RegisterType((*SubscriptionStatusResponse)(nil), "SubscriptionStatusResponse")
+	proto.RegisterType((*DestinationStatus)(nil), "DestinationStatus")
 	proto.RegisterType((*StatusRequest)(nil), "StatusRequest")
+	proto.RegisterType((*ListSubscriptionsRequest)(nil), "ListSubscriptionsRequest")
+	proto.RegisterType((*ListSubscriptionsResponse)(nil), "ListSubscriptionsResponse")
+	proto.RegisterType((*ActiveSubscription)(nil), "ActiveSubscription")
 	proto.RegisterType((*StatusResponse)(nil), "StatusResponse")
+	proto.RegisterType((*ElectionStatus)(nil), "ElectionStatus")
+	proto.RegisterType((*ClusterStatus)(nil), "ClusterStatus")
+	proto.RegisterType((*LoadStatus)(nil), "LoadStatus")
+	proto.RegisterType((*ReconcileStatus)(nil), "ReconcileStatus")
 	proto.RegisterType((*DeactivateSubscriptionResponse)(nil), "DeactivateSubscriptionResponse")
 	proto.RegisterType((*DeactivateSubscriptionRequest)(nil), "DeactivateSubscriptionRequest")
 	proto.RegisterType((*ActivateSubscriptionResponse)(nil), "ActivateSubscriptionResponse")
 	proto.RegisterType((*ActivateSubscriptionRequest)(nil), "ActivateSubscriptionRequest")
 	proto.RegisterType((*Subscription)(nil), "Subscription")
 	proto.RegisterType((*PushConfig)(nil), "PushConfig")
+	proto.RegisterType((*Destination)(nil), "Destination")
 	proto.RegisterType((*RetryPolicy)(nil), "RetryPolicy")
 }
 
 func init() { proto.RegisterFile("ams.proto", fileDescriptor_85e4db6795b5b1aa) }
 
 var fileDescriptor_85e4db6795b5b1aa = []byte{
-	// 614 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x94, 0x5f, 0x4f, 0xdb, 0x3c,
-	0x14, 0xc6, 0x5b, 0xe0, 0x2d, 0xed, 0x49, 0x5a, 0xe0, 0x80, 0x50, 0xde, 0x42, 0x59, 0x97, 0x6d,
-	0x52, 0xb5, 0x0d, 0x23, 0x3a, 0x84, 0xd8, 0xb4, 0x1b, 0x04, 0x48, 0xec, 0x02, 0xa8, 0xd2, 0x70,
-	0xb5, 0x8b, 0xc8, 0xa4, 0x86, 0x46, 0x6a, 0xe2, 0xcc, 0x76, 0x10, 0xdd, 0xf5, 0xbe, 0xd7, 0xbe,
-	0xda, 0x14, 0x37, 0xd0, 0x74, 0xa3, 0xd1, 0xb4, 0xbb, 0xf8, 0xf7, 0x9c, 0x93, 0xf3, 0x47, 0xf6,
-	0x03, 0x35, 0x1a, 0x4a, 0x12, 0x0b, 0xae, 0xb8, 0x7d, 0x04, 0xff, 0xf7, 0x93, 0x1b, 0xe9, 0x8b,
-	0x20, 0x56, 0x01, 0x8f, 0xfa, 0x8a, 0xaa, 0x44, 0x3a, 0xec, 0x5b, 0xc2, 0xa4, 0xc2, 0x2d, 0xa8,
-	0xdd, 0x26, 0xa3, 0x91, 0x17, 0xd1, 0x90, 0x59, 0xe5, 0x76, 0xb9, 0x53, 0x73, 0xaa, 0x29, 0xb8,
-	0xa4, 0x21, 0xb3, 0x0f, 0xa0, 0xf9, 0x5c, 0xa6, 0x8c, 0x79, 0x24, 0x19, 0x6e, 0x42, 0x45, 0x6a,
-	0x92, 0xe5, 0x65, 0x27, 0x7b, 0x05, 0xea, 0x33, 0x35, 0xec, 0x55, 0x68, 0xcc, 0xa6, 0xda, 0x9f,
-	0x60, 0xe7, 0x94, 0x51, 0x5f, 0x05, 0xf7, 0x54, 0xb1, 0x7c, 0x89, 0xa7, 0x9f, 0x5b, 0xb0, 0x1c,
-	0x32, 0x29, 0xe9, 0xdd, 0x63, 0x57, 0x8f, 0x47, 0xfb, 0x33, 0xb4, 0xe6, 0xe5, 0xfe, 0xc5, 0x48,
-	0x47, 0xb0, 0x7d, 0xfc, 0x6f, 0x75, 0x7b, 0xb0, 0x75, 0x5c, 0x50, 0x75, 0x1f, 0x4c, 0x99, 0xc3,
-	0x3a, 0xdb, 0xe8, 0xd6, 0xc9, 0x4c, 0xec, 0x4c, 0x88, 0xfd, 0x00, 0x66, 0x5e, 0x2d, 0x6c, 0x1c,
-	0x5b, 0x00, 0x5a, 0x54, 0x3c, 0x0e, 0x7c, 0x6b, 0x41, 0xab, 0x3a, 0xdc, 0x4d, 0x01, 0xbe, 0x07,
-	0x23, 0x4e, 0xe4, 0xd0, 0xf3, 0x79, 0x74, 0x1b, 0xdc, 0x59, 0x4b, 0xba, 0xba, 0x41, 0x7a, 0x89,
-	0x1c, 0x9e, 0x68, 0xe4, 0x40, 0xfc, 0xf4, 0x6d, 0xff, 0x58, 0x04, 0x98, 0x4a, 0xf8, 0x0a, 0xea,
-	0x3a, 0x99, 0x45, 0x83, 0x98, 0x07, 0x91, 0xca, 0x8a, 0x9b, 0x29, 0x3c, 0xcb, 0x18, 0xbe, 0x04,
-	0x33, 0xa4, 0x0f, 0x5e, 0xb6, 0x0e, 0x69, 0x2d, 0xb6, 0xcb, 0x9d, 0x45, 0xc7, 0x08, 0xe9, 0xc3,
-	0x45, 0x86, 0x70, 0x0f, 0x4c, 0xc1, 0x94, 0x18, 0x7b, 0x31, 0x1f, 0x05, 0xfe, 0x58, 0x77, 0x69,
-	0x74, 0x4d, 0xe2, 0xa4, 0xb0, 0xa7, 0x99, 0x63, 0x88, 0xe9, 0x01, 0xf7, 0x61, 0x83, 0x26, 0x6a,
-	0xc8, 0x45, 0xf0, 0x9d, 0xa6, 0x2b, 0xf0, 0x86, 0x8c, 0x0e, 0x98, 0xd0, 0xed, 0xd7, 0x9c, 0xf5,
-	0x19, 0xed, 0x5c, 0x4b, 0xd8, 0x82, 0x25, 0x35, 0x8e, 0x99, 0xf5, 0x5f, 0xbb, 0xdc, 0x69, 0x74,
-	0x6b, 0x7a, 0x42, 0x77, 0x1c, 0x33, 0x47, 0x63, 0x7c, 0x03, 0x8d, 0x90, 0x2a, 0xc5, 0x44, 0xc8,
-	0xa5, 0xf2, 0x12, 0x31, 0xb2, 0x2a, 0xfa, 0x5f, 0xf5, 0x29, 0xbd, 0x16, 0x23, 0xdc, 0x83, 0xf5,
-	0x7c, 0x98, 0x64, 0x42, 0x2f, 0x7d, 0x59, 0xc7, 0x62, 0x2e, 0x36, 0x53, 0x70, 0x17, 0x72, 0xd4,
-	0xf3, 0x87, 0x34, 0x8a, 0xd8, 0xc8, 0xaa, 0xea, 0xf8, 0xb5, 0xa9, 0x72, 0x32, 0x11, 0xf0, 0x35,
-	0x34, 0x6e, 0xa8, 0x64, 0xde, 0xe1, 0x81, 0x37, 0x60, 0x3e, 0x1f, 0x30, 0xab, 0xd6, 0x2e, 0x77,
-	0xaa, 0x8e, 0x99, 0xd2, 0xc3, 0x83, 0x53, 0xcd, 0xec, 0x8f, 0x60, 0xe4, 0x56, 0x83, 0x98, 0x8d,
-	0x36, 0xd9, 0xfe, 0x64, 0x9e, 0x4d, 0xa8, 0xc4, 0x4c, 0x04, 0x7c, 0xa0, 0x97, 0x59, 0x77, 0xb2,
-	0xd3, 0xdb, 0x5d, 0xa8, 0x3e, 0x4e, 0x8e, 0x6b, 0x50, 0x3f, 0x77, 0xdd, 0x9e, 0x77, 0x76, 0x79,
-	0xda, 0xbb, 0xfa, 0x72, 0xe9, 0xae, 0x96, 0xb0, 0x01, 0x70, 0x71, 0xec, 0xba, 0x67, 0xce, 0xc5,
-	0x55, 0xdf, 0x5d, 0x2d, 0x77, 0x7f, 0x2e, 0x80, 0x91, 0xc6, 0xf7, 0x99, 0xb8, 0x0f, 0x7c, 0x86,
-	0xd7, 0xb0, 0xf1, 0xdc, 0x65, 0xc6, 0x6d, 0x52, 0x70, 0xc7, 0x9b, 0x2d, 0x52, 0xf4, 0x76, 0xec,
-	0x12, 0x7e, 0x85, 0xcd, 0xe7, 0xdf, 0x26, 0xee, 0x90, 0xc2, 0x47, 0xdb, 0x7c, 0x41, 0x8a, 0x0d,
-	0xc1, 0x2e, 0xe1, 0x3b, 0xa8, 0x4c, 0x6c, 0x04, 0x1b, 0x64, 0xc6, 0x60, 0x9a, 0x2b, 0xe4, 0x37,
-	0x7f, 0x29, 0xe1, 0x15, 0xe0, 0x9f, 0xd6, 0x85, 0x4d, 0x32, 0xd7, 0x09, 0x9b, 0x5b, 0x64, 0xbe,
-	0xd7, 0xd9, 0xa5, 0x9b, 0x8a, 0x36, 0xd3, 0x0f, 0xbf, 0x02, 0x00, 0x00, 0xff, 0xff, 0xef, 0x1d,
-	0xcf, 0xc3, 0x59, 0x05, 0x00, 0x00,
+	// 1304 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0x96, 0x2c, 0x5b, 0x96, 0x86, 0xfa, 0xf3, 0xc6, 0x0d, 0x68, 0x25, 0x4e, 0x52, 0xf6, 0x07,
+	0x46, 0xd2, 0x30, 0x8d, 0x12, 0x04, 0x49, 0xd1, 0x8b, 0x63, 0x2b, 0x48, 0x00, 0xff, 0x81, 0x92,
+	0x8b, 0x16, 0x3d, 0x10, 0x6b, 0x72, 0x1c, 0x11, 0xa0, 0x48, 0x76, 0x77, 0xe9, 0x5a, 0x7d, 0x85,
+	0xa2, 0xf7, 0x3e, 0x41, 0xcf, 0xbd, 0xf4, 0x99, 0xfa, 0x1a, 0xc5, 0x2e, 0x97, 0x12, 0x19, 0x5b,
+	0x46, 0x53, 0xf4, 0xc6, 0xf9, 0xbe, 0x59, 0xcd, 0xec, 0xec, 0xb7, 0x33, 0x2b, 0x68, 0xd2, 0x29,
+	0xb7, 0x13, 0x16, 0x8b, 0xd8, 0x7a, 0x09, 0x5b, 0xa3, 0xf4, 0x8c, 0x7b, 0x2c, 0x48, 0x44, 0x10,
+	0x47, 0x23, 0x41, 0x45, 0xca, 0x1d, 0xfc, 0x29, 0x45, 0x2e, 0xc8, 0x1d, 0x68, 0x9e, 0xa7, 0x61,
+	0xe8, 0x46, 0x74, 0x8a, 0x66, 0xf5, 0x41, 0x75, 0xa7, 0xe9, 0x34, 0x24, 0x70, 0x44, 0xa7, 0x68,
+	0x85, 0xd0, 0xbf, 0x6e, 0x25, 0x4f, 0xe2, 0x88, 0x23, 0xb9, 0x0d, 0x75, 0xae, 0x10, 0xbd, 0x4e,
+	0x5b, 0xe4, 0x05, 0xb4, 0x7c, 0xe4, 0x22, 0x88, 0xa8, 0x5c, 0xc4, 0xcd, 0x95, 0x07, 0xb5, 0x1d,
+	0x63, 0x40, 0xec, 0xfd, 0x05, 0xa8, 0x7f, 0xa9, 0xe4, 0x67, 0xfd, 0x59, 0x85, 0x8d, 0x2b, 0x3e,
+	0xe4, 0x01, 0x18, 0x05, 0x2f, 0x1d, 0xaa, 0x08, 0x91, 0xc7, 0x40, 0x7c, 0x0c, 0x83, 0x0b, 0x64,
+	0xe8, 0xbb, 0x53, 0xe4, 0x9c, 0xbe, 0x47, 0x19, 0xb5, 0xba, 0xb3, 0xea, 0x6c, 0xcc, 0x99, 0x43,
+	0x4d, 0x90, 0x47, 0xb0, 0x71, 0x4e, 0x83, 0x10, 0x7d, 0x57, 0x73, 0x01, 0x72, 0xb3, 0xa6, 0xbc,
+	0x7b, 0x19, 0xb1, 0x3f, 0xc7, 0xc9, 0x36, 0x40, 0x48, 0xb9, 0x70, 0x91, 0xb1, 0x98, 0x99, 0xab,
+	0x2a, 0x78, 0x53, 0x22, 0x43, 0x09, 0x58, 0x5d, 0x68, 0x97, 0xca, 0x69, 0xf5, 0xc1, 0x3c, 0x08,
+	0xb8, 0x28, 0x56, 0x6d, 0xce, 0x7d, 0x07, 0x5b, 0xd7, 0x70, 0xba, 0x98, 0xaf, 0xa0, 0xcd, 0x8b,
+	0x84, 0x59, 0x55, 0x55, 0xbb, 0x65, 0xef, 0x7a, 0x22, 0xb8, 0xc0, 0xe2, 0x22, 0xa7, 0xec, 0x69,
+	0x4d, 0x80, 0x5c, 0x75, 0xba, 0xf1, 0x60, 0xe5, 0xb6, 0x14, 0x29, 0xe2, 0x24, 0xf0, 0x54, 0xa9,
+	0x9a, 0x8e, 0x72, 0x1f, 0x4b, 0xa0, 0x70, 0xb2, 0xb5, 0xe2, 0xc9, 0x5a, 0x7f, 0x57, 0xa1, 0xf3,
+	0x81, 0x08, 0x1e, 0x03, 0xf1, 0x90, 0x89, 0xe0, 0x3c, 0xf0, 0xa8, 0x40, 0x17, 0x2f, 0x93, 0x80,
+	0xcd, 0x74, 0xbc, 0x8d, 0x02, 0x33, 0x54, 0x04, 0xb1, 0xa1, 0xc9, 0xd0, 0x8b, 0x23, 0x2f, 0x08,
+	0x51, 0xc5, 0x35, 0x06, 0x3d, 0xdb, 0xc9, 0x11, 0xfd, 0xdb, 0x0b, 0x17, 0x72, 0x1f, 0x56, 0xc3,
+	0x98, 0xfa, 0x2a, 0x0f, 0x63, 0x60, 0xd8, 0x07, 0x31, 0xf5, 0xb5, 0x97, 0x22, 0xc8, 0x0e, 0xac,
+	0x7b, 0x61, 0xca, 0x05, 0x66, 0xa7, 0x63, 0x0c, 0x3a, 0xf6, 0x5e, 0x66, 0x6b, 0xb7, 0x9c, 0x26,
+	0x8f, 0xa0, 0x81, 0x21, 0x7a, 0x4a, 0x45, 0x6b, 0xca, 0xb5, 0x6b, 0x0f, 0x35, 0xa0, 0x7d, 0xe7,
+	0x0e, 0xd6, 0xf7, 0xd0, 0x29, 0x73, 0xa4, 0x0f, 0x8d, 0xc0, 0xc7, 0x48, 0x04, 0x22, 0xdf, 0xde,
+	0xdc, 0x26, 0x04, 0x56, 0x59, 0xac, 0x37, 0xd4, 0x74, 0xd4, 0xb7, 0xac, 0xe1, 0x24, 0x0e, 0x7d,
+	0x64, 0x79, 0x0d, 0x33, 0xcb, 0xa2, 0xd0, 0x2e, 0x25, 0x28, 0x17, 0x73, 0x0c, 0xcf, 0xf5, 0x8f,
+	0xaa, 0x6f, 0x25, 0xbb, 0xe0, 0x02, 0xdd, 0x04, 0x91, 0x65, 0x17, 0x48, 0xca, 0x2e, 0xb8, 0xc0,
+	0x13, 0x09, 0x48, 0xda, 0x8f, 0x7f, 0x8e, 0x34, 0x5d, 0xcb, 0x68, 0x89, 0x28, 0xda, 0xfa, 0xbd,
+	0x0a, 0xb0, 0x28, 0x14, 0xd9, 0x84, 0x35, 0x11, 0x0b, 0x1a, 0xaa, 0x08, 0x35, 0x27, 0x33, 0x64,
+	0x7e, 0xb2, 0x80, 0xe8, 0xab, 0xac, 0x6b, 0x8e, 0xb6, 0xe4, 0x3e, 0x19, 0x0a, 0x36, 0x0b, 0xa2,
+	0xf7, 0x2a, 0xf3, 0x9a, 0x33, 0xb7, 0xe5, 0x9a, 0xec, 0x86, 0xa8, 0x5a, 0xd7, 0x1c, 0x6d, 0xc9,
+	0x08, 0x0c, 0xa9, 0x3f, 0x53, 0x75, 0x6d, 0x38, 0x99, 0x21, 0x37, 0xe6, 0xc7, 0x11, 0x9a, 0x75,
+	0x05, 0xaa, 0x6f, 0xeb, 0x8f, 0x2a, 0x74, 0x3f, 0x38, 0x6e, 0xb2, 0x05, 0x0d, 0x75, 0xc7, 0x58,
+	0x9a, 0x5f, 0xef, 0x75, 0x69, 0x3b, 0x69, 0xa4, 0x0a, 0x9b, 0x46, 0x5c, 0xa7, 0xa8, 0xbe, 0x89,
+	0x09, 0xeb, 0x5c, 0x50, 0x26, 0xd0, 0xd7, 0xf9, 0xe5, 0x66, 0xc6, 0xc4, 0x49, 0x32, 0xcf, 0x2f,
+	0x37, 0x25, 0x93, 0x26, 0x3e, 0x95, 0x6b, 0xd6, 0x32, 0x46, 0x9b, 0x85, 0x2d, 0xd5, 0x8b, 0x5b,
+	0xb2, 0xbe, 0x81, 0x7b, 0xfb, 0x48, 0xe5, 0xb5, 0xa2, 0xa2, 0x7c, 0xfb, 0x72, 0xe5, 0x9b, 0xb0,
+	0xae, 0x9b, 0x4d, 0x9e, 0xb5, 0x36, 0xad, 0x6f, 0x61, 0x7b, 0xd9, 0xda, 0x7f, 0xd1, 0x74, 0x5f,
+	0xc2, 0xdd, 0xdd, 0xff, 0x16, 0xf7, 0x04, 0xee, 0xec, 0xde, 0x10, 0xf5, 0x29, 0xb4, 0x8a, 0x8d,
+	0x43, 0xad, 0x36, 0x06, 0x6d, 0xbb, 0xe4, 0x5b, 0x72, 0xb1, 0x2e, 0xa1, 0xf5, 0xbf, 0x35, 0x95,
+	0xaf, 0xc0, 0x48, 0x52, 0x3e, 0x71, 0xbd, 0x38, 0x3a, 0x0f, 0xde, 0xeb, 0xdb, 0x6a, 0xd8, 0x27,
+	0x29, 0x9f, 0xec, 0x29, 0xc8, 0x81, 0x64, 0xfe, 0x6d, 0xfd, 0xb5, 0x06, 0xb0, 0xa0, 0xc8, 0x67,
+	0xd0, 0x56, 0x8b, 0x31, 0xf2, 0x93, 0x38, 0x88, 0x84, 0x0e, 0xde, 0x92, 0xe0, 0x50, 0x63, 0xe4,
+	0x53, 0x68, 0x4d, 0xe9, 0xe5, 0x62, 0x04, 0x64, 0xf2, 0x30, 0xa6, 0xf4, 0x72, 0xde, 0xfc, 0x9f,
+	0x40, 0x4b, 0xa9, 0xd9, 0x4d, 0xe2, 0x30, 0xf0, 0x66, 0xba, 0x05, 0xb5, 0x6c, 0x47, 0x82, 0x27,
+	0x0a, 0x73, 0x0c, 0xb6, 0x30, 0xc8, 0x53, 0xd8, 0xa4, 0xa9, 0x98, 0xc4, 0x2c, 0xf8, 0x45, 0x4d,
+	0x1b, 0x77, 0x82, 0xd4, 0xc7, 0x7c, 0x14, 0xdc, 0x2a, 0x71, 0x6f, 0x15, 0x45, 0xb6, 0x61, 0x55,
+	0xcc, 0x12, 0x54, 0x4a, 0xeb, 0x0c, 0x9a, 0x6a, 0x87, 0xe3, 0x59, 0x82, 0x8e, 0x82, 0xc9, 0x17,
+	0xd0, 0x99, 0x52, 0x21, 0x90, 0x4d, 0x63, 0x2e, 0xdc, 0x94, 0x85, 0x4a, 0x79, 0x4d, 0xa7, 0xbd,
+	0x40, 0x4f, 0x59, 0x48, 0x9e, 0xc0, 0xad, 0xa2, 0x1b, 0x47, 0xa6, 0x8a, 0xbe, 0xae, 0x7c, 0x49,
+	0xc1, 0x57, 0x33, 0xb2, 0x13, 0x17, 0x16, 0x78, 0x13, 0x1a, 0x45, 0x18, 0x9a, 0x8d, 0xac, 0x13,
+	0x2f, 0x98, 0xbd, 0x8c, 0x20, 0x9f, 0x43, 0xe7, 0x8c, 0x72, 0x74, 0x5f, 0x3c, 0x77, 0x7d, 0xf4,
+	0x62, 0x1f, 0xcd, 0xa6, 0xba, 0xa7, 0x2d, 0x89, 0xbe, 0x78, 0xbe, 0xaf, 0x30, 0x95, 0x6c, 0x56,
+	0x3b, 0xf7, 0x3c, 0x66, 0x53, 0x2a, 0x4c, 0xd0, 0xc9, 0x66, 0xe8, 0x1b, 0x05, 0xca, 0x21, 0xed,
+	0xc5, 0xd3, 0x84, 0x21, 0xe7, 0x52, 0x59, 0x46, 0x36, 0xa4, 0x0b, 0x10, 0x79, 0x06, 0x9f, 0x14,
+	0x4c, 0x57, 0x4c, 0x18, 0x72, 0xd9, 0x11, 0xcd, 0x96, 0x3a, 0xa4, 0xcd, 0x02, 0x39, 0xce, 0x39,
+	0xf2, 0x25, 0x74, 0xe5, 0x81, 0x9e, 0x51, 0xe1, 0x4d, 0xdc, 0xb3, 0x99, 0x40, 0x6e, 0xb6, 0x95,
+	0x7b, 0x7b, 0x4a, 0x2f, 0x5f, 0x4b, 0xf4, 0xb5, 0x04, 0xc9, 0xd7, 0x1f, 0xbc, 0x38, 0x3a, 0x6a,
+	0x76, 0xb6, 0x8a, 0x2f, 0x8e, 0xf2, 0x5b, 0x83, 0xbc, 0x84, 0xae, 0x9e, 0xfe, 0x73, 0x29, 0x74,
+	0xd5, 0x71, 0x75, 0x6d, 0x3d, 0xfd, 0x73, 0x35, 0x74, 0xfc, 0x92, 0x2d, 0x55, 0x2e, 0x82, 0x29,
+	0xc6, 0xa9, 0x70, 0xa7, 0xdc, 0xec, 0xa9, 0x74, 0x9a, 0x1a, 0x39, 0xe4, 0xd6, 0xaf, 0x2b, 0x60,
+	0x14, 0xc2, 0xce, 0xc5, 0x50, 0xbd, 0x5e, 0x0c, 0x57, 0x74, 0xbd, 0x72, 0x8d, 0xae, 0x97, 0x69,
+	0xb0, 0xb6, 0x5c, 0x83, 0x57, 0x45, 0xb6, 0xfa, 0x11, 0x22, 0x5b, 0xfb, 0x48, 0x91, 0xd5, 0x97,
+	0x88, 0xcc, 0x7a, 0x05, 0x46, 0xe1, 0x66, 0xc9, 0x76, 0x3e, 0x2f, 0x46, 0x53, 0x57, 0xe0, 0x36,
+	0xd4, 0x13, 0x64, 0x41, 0x9c, 0xcd, 0xa1, 0xb6, 0xa3, 0xad, 0x87, 0x8f, 0xa1, 0x91, 0xd7, 0x8a,
+	0x6c, 0x40, 0xfb, 0xed, 0x78, 0x7c, 0xe2, 0x0e, 0x8f, 0xf6, 0x4f, 0x8e, 0xdf, 0x1d, 0x8d, 0x7b,
+	0x15, 0xd2, 0x01, 0x38, 0xdc, 0x1d, 0x8f, 0x87, 0xce, 0xe1, 0xf1, 0x68, 0xdc, 0xab, 0x3e, 0x7c,
+	0x07, 0x9d, 0xf2, 0xc1, 0x91, 0x4d, 0xe8, 0xed, 0x1e, 0x1c, 0xb8, 0x87, 0xa7, 0xa3, 0xb1, 0x3b,
+	0x3a, 0xdd, 0xdb, 0x1b, 0x0e, 0xf7, 0x7b, 0x15, 0xd2, 0x83, 0xd6, 0xee, 0xd1, 0x0f, 0x39, 0x30,
+	0xea, 0x55, 0x49, 0x17, 0x8c, 0xd7, 0xc3, 0xd1, 0xd8, 0x1d, 0xbe, 0x79, 0x73, 0xec, 0x8c, 0x7b,
+	0x2b, 0x83, 0xdf, 0x6a, 0x60, 0xc8, 0xd0, 0x23, 0x64, 0x17, 0x81, 0x87, 0xe4, 0x14, 0x36, 0xaf,
+	0x6b, 0xab, 0xe4, 0xae, 0x7d, 0x43, 0xb7, 0xed, 0x6f, 0xdb, 0x37, 0x75, 0x71, 0xab, 0x42, 0x7e,
+	0x84, 0xdb, 0xd7, 0x4f, 0x09, 0x72, 0xcf, 0xbe, 0x71, 0x7c, 0xf4, 0xef, 0xdb, 0x37, 0x8f, 0x26,
+	0xab, 0x42, 0x1e, 0x41, 0x5d, 0x4f, 0xd7, 0x8e, 0x5d, 0x7a, 0xa1, 0xf6, 0xbb, 0x76, 0xf9, 0x05,
+	0x67, 0x55, 0xc8, 0x31, 0x90, 0xab, 0xcf, 0x7c, 0xd2, 0xb7, 0x97, 0xfe, 0x6b, 0xe8, 0xdf, 0xb1,
+	0x97, 0xff, 0x2f, 0xb0, 0x2a, 0xe4, 0x00, 0x36, 0xae, 0xbc, 0x74, 0xc9, 0x96, 0xbd, 0xec, 0x65,
+	0xdc, 0xef, 0xdb, 0x4b, 0x1f, 0xc6, 0x56, 0xe5, 0xac, 0xae, 0xfe, 0xc6, 0x3c, 0xfb, 0x67, 0x00,
+	0xa4, 0x4a, 0x16, 0xfe, 0xd3, 0x0c, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -663,6 +1440,8 @@ type PushServiceClient interface {
 	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
 	// SubscriptionStatus returns the status of the worker that handles the respective subscription
 	SubscriptionStatus(ctx context.Context, in *SubscriptionStatusRequest, opts ...grpc.CallOption) (*SubscriptionStatusResponse, error)
+	// ListSubscriptions returns all the subscriptions that are currently active
+	ListSubscriptions(ctx context.Context, in *ListSubscriptionsRequest, opts ...grpc.CallOption) (*ListSubscriptionsResponse, error)
 }
 
 type pushServiceClient struct {
@@ -709,6 +1488,15 @@ func (c *pushServiceClient) SubscriptionStatus(ctx context.Context, in *Subscrip
 	return out, nil
 }
 
+func (c *pushServiceClient) ListSubscriptions(ctx context.Context, in *ListSubscriptionsRequest, opts ...grpc.CallOption) (*ListSubscriptionsResponse, error) {
+	out := new(ListSubscriptionsResponse)
+	err := c.cc.Invoke(ctx, "/PushService/ListSubscriptions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // PushServiceServer is the server API for PushService service.
 type PushServiceServer interface {
 	// Activates a subscription in order for the service to start handling the push functionality
@@ -719,6 +1507,8 @@ type PushServiceServer interface {
 	Status(context.Context, *StatusRequest) (*StatusResponse, error)
 	// SubscriptionStatus returns the status of the worker that handles the respective subscription
 	SubscriptionStatus(context.Context, *SubscriptionStatusRequest) (*SubscriptionStatusResponse, error)
+	// ListSubscriptions returns all the subscriptions that are currently active
+	ListSubscriptions(context.Context, *ListSubscriptionsRequest) (*ListSubscriptionsResponse, error)
 }
 
 func RegisterPushServiceServer(s *grpc.Server, srv PushServiceServer) {
@@ -797,6 +1587,24 @@ func _PushService_SubscriptionStatus_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PushService_ListSubscriptions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSubscriptionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServiceServer).ListSubscriptions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/PushService/ListSubscriptions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServiceServer).ListSubscriptions(ctx, req.(*ListSubscriptionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _PushService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "PushService",
 	HandlerType: (*PushServiceServer)(nil),
@@ -817,6 +1625,10 @@ var _PushService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "SubscriptionStatus",
 			Handler:    _PushService_SubscriptionStatus_Handler,
 		},
+		{
+			MethodName: "ListSubscriptions",
+			Handler:    _PushService_ListSubscriptions_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "ams.proto",
@@ -0,0 +1,337 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	"github.com/ARGOeu/ams-push-server/push"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReconcileResult summarises the changes that a reconciliation applied to the active subscriptions
+type ReconcileResult struct {
+	// subscriptions that were push enabled in ams but not active
+	Started []string
+	// active subscriptions that no longer exist or are no longer push enabled in ams
+	Stopped []string
+	// active subscriptions whose push configuration changed in ams
+	Updated []string
+	// subscriptions that couldn't be retrieved, activated or deactivated
+	Failed []string
+}
+
+// reconcileStats holds the counters of all the reconciliations so far
+type reconcileStats struct {
+	mutex   sync.Mutex
+	lastRun time.Time
+	runs    int64
+	started int64
+	stopped int64
+	updated int64
+	failed  int64
+}
+
+// record adds the changes of a reconciliation to the counters
+func (s *reconcileStats) record(r ReconcileResult) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastRun = time.Now()
+	s.runs++
+	s.started += int64(len(r.Started))
+	s.stopped += int64(len(r.Stopped))
+	s.updated += int64(len(r.Updated))
+	s.failed += int64(len(r.Failed))
+}
+
+// status returns the counters as they are reported by the status of the service, or nil if no reconciliation has run
+func (s *reconcileStats) status() *amsPb.ReconcileStatus {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.runs == 0 {
+		return nil
+	}
+
+	return &amsPb.ReconcileStatus{
+		LastRun: s.lastRun.UTC().Format(time.RFC3339),
+		Runs:    s.runs,
+		Started: s.started,
+		Stopped: s.stopped,
+		Updated: s.updated,
+		Failed:  s.failed,
+	}
+}
+
+// reconcileLoop reconciles the active subscriptions against ams every interval, until the service shuts down
+// or the context is done
+func (ps *PushService) reconcileLoop(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if ps.shuttingDown.Load() {
+			return
+		}
+
+		_, err := ps.Reconcile(ctx)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":  "system_log",
+					"error": err.Error(),
+				},
+			).Error("Could not reconcile subscriptions")
+		}
+	}
+}
+
+// Reconcile retrieves the subscriptions assigned to the push worker user and brings the active subscriptions in line
+// with them, starting the missing push enabled ones, stopping the ones that are no longer assigned or push enabled
+// and restarting the ones whose push configuration has changed
+func (ps *PushService) Reconcile(ctx context.Context) (ReconcileResult, error) {
+
+	userInfo, err := ps.AmsClient.GetUserByToken(ctx, ps.Cfg.AmsToken)
+	if err != nil {
+		return ReconcileResult{}, err
+	}
+
+	return ps.reconcile(ctx, userInfo), nil
+}
+
+// reconcile brings the active subscriptions in line with the subscriptions of the provided push worker user.
+// In cluster mode only the subscriptions that the current instance owns are considered,
+// the active ones owned by other instances are handed over to them
+func (ps *PushService) reconcile(ctx context.Context, userInfo ams.UserInfo) ReconcileResult {
+
+	ps.reconcileMutex.Lock()
+	defer ps.reconcileMutex.Unlock()
+
+	// retry the hand offs that failed
+	if ps.Cluster != nil {
+		ps.handOff()
+	}
+
+	t1 := time.Now()
+	result := ReconcileResult{}
+
+	// the service itself performs the changes, so that they are recorded in the audit log
+	sysCtx := NewIdentityContext(ctx, systemIdentity)
+
+	// subscriptions that should be active, and subscriptions whose state is unknown since they couldn't be retrieved
+	desired := make(map[string]*amsPb.Subscription)
+	unknown := make(map[string]bool)
+
+	for _, project := range userInfo.Projects {
+
+		for _, subName := range project.Subscriptions {
+
+			fullSubName := fmt.Sprintf("/projects/%v/subscriptions/%v", project.Project, subName)
+
+			t2 := time.Now()
+			sub, err := ps.AmsClient.GetSubscription(ctx, fullSubName)
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":         "system_log",
+						"subscription": fullSubName,
+						"error":        err.Error(),
+					},
+				).Error("Could not retrieve subscription")
+				unknown[fullSubName] = true
+				result.Failed = append(result.Failed, fullSubName)
+				continue
+			}
+
+			// subscriptions that couldn't be retrieved at startup count towards the readiness of the service
+			ps.markLoaded(fullSubName)
+
+			if !sub.IsPushEnabled() {
+				log.WithFields(
+					log.Fields{
+						"type":         "system_log",
+						"subscription": fullSubName,
+					},
+				).Debug("Subscription is not push enabled")
+				continue
+			}
+
+			if !ps.owns(fullSubName) {
+				continue
+			}
+
+			log.WithFields(
+				log.Fields{
+					"type":            "performance_log",
+					"subscription":    sub.FullName,
+					"processing_time": time.Since(t2).String(),
+				},
+			).Debug("Subscription retrieved successfully")
+
+			desired[sub.FullName] = ToSubscription(sub)
+		}
+	}
+
+	// subscriptions that are stopped in order to be started again with their new push configuration
+	restarting := make(map[string]bool)
+
+	// stop the subscriptions that are no longer assigned or push enabled, and those whose push configuration changed
+	ps.PushWorkers.Range(func(name string, w push.Worker) {
+
+		if unknown[name] || !ps.owns(name) {
+			return
+		}
+
+		sub, found := desired[name]
+		if found && sub.FullTopic == w.Subscription().FullTopic && proto.Equal(sub.PushConfig, w.Subscription().PushConfig) {
+			return
+		}
+
+		// a subscription whose push configuration changed completes its current push cycle before it restarts
+		var err error
+		if found {
+			err = ps.drainSubscription(sysCtx, name, w)
+		} else {
+			_, err = ps.DeactivateSubscription(sysCtx, &amsPb.DeactivateSubscriptionRequest{FullName: name})
+		}
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":         "system_log",
+					"subscription": name,
+					"error":        err.Error(),
+				},
+			).Error("Could not deactivate subscription")
+			result.Failed = append(result.Failed, name)
+			return
+		}
+
+		if !found {
+			result.Stopped = append(result.Stopped, name)
+			log.WithFields(
+				log.Fields{
+					"type":         "system_log",
+					"subscription": name,
+				},
+			).Info("Subscription deactivated successfully")
+			return
+		}
+
+		restarting[name] = true
+	})
+
+	// start the missing subscriptions, along with the ones being updated
+	for name, sub := range desired {
+
+		if ps.IsSubActive(name) {
+			continue
+		}
+
+		if !restarting[name] && ps.pushedByPreviousOwner(name) {
+			log.WithFields(
+				log.Fields{
+					"type":         "system_log",
+					"subscription": name,
+				},
+			).Debug("Subscription is still pushed by its previous owner, waiting for the hand off")
+			continue
+		}
+
+		_, err := ps.ActivateSubscription(sysCtx, &amsPb.ActivateSubscriptionRequest{Subscription: sub})
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":         "system_log",
+					"subscription": name,
+					"error":        err.Error(),
+				},
+			).Error("Could not activate subscription")
+			result.Failed = append(result.Failed, name)
+			// a subscription that couldn't be restarted has been stopped
+			if restarting[name] {
+				result.Stopped = append(result.Stopped, name)
+			}
+			continue
+		}
+
+		log.WithFields(
+			log.Fields{
+				"type":         "system_log",
+				"subscription": name,
+			},
+		).Info("Subscription activated successfully")
+
+		if restarting[name] {
+			result.Updated = append(result.Updated, name)
+			continue
+		}
+
+		result.Started = append(result.Started, name)
+	}
+
+	sort.Strings(result.Started)
+	sort.Strings(result.Stopped)
+	sort.Strings(result.Updated)
+	sort.Strings(result.Failed)
+
+	ps.reconcileStats.record(result)
+
+	log.WithFields(
+		log.Fields{
+			"type":            "system_log",
+			"started":         result.Started,
+			"stopped":         result.Stopped,
+			"updated":         result.Updated,
+			"failed":          result.Failed,
+			"active":          ps.PushWorkers.Len(),
+			"processing_time": time.Since(t1).String(),
+		},
+	).Info("Subscriptions reconciled")
+
+	return result
+}
+
+// drainSubscription removes the worker of a subscription from the registry and lets its current push cycle complete,
+// within the drain timeout, before the worker stops
+func (ps *PushService) drainSubscription(ctx context.Context, name string, w push.Worker) error {
+
+	// e.g. deactivated in the meantime
+	if _, found := ps.PushWorkers.Remove(name); !found {
+		return errors.Errorf("Subscription %v is not active", name)
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), ps.Cfg.GetDrainTimeout())
+	defer cancel()
+
+	err := w.Drain(drainCtx)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":         "system_log",
+				"subscription": name,
+				"error":        err.Error(),
+			},
+		).Warning("Push worker did not drain in time, its current push cycle has been canceled")
+	}
+
+	ps.deleteState(name)
+	ps.audit(ctx, "DeactivateSubscription", name, w.Subscription().PushConfig, nil, nil, "")
+
+	return nil
+}
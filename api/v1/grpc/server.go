@@ -2,42 +2,95 @@ package grpc
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
+	"github.com/ARGOeu/ams-push-server/acl"
 	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/audit"
+	"github.com/ARGOeu/ams-push-server/cluster"
 	"github.com/ARGOeu/ams-push-server/config"
 	"github.com/ARGOeu/ams-push-server/consumers"
+	"github.com/ARGOeu/ams-push-server/election"
+	"github.com/ARGOeu/ams-push-server/logging"
+	"github.com/ARGOeu/ams-push-server/netpolicy"
 	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
 	"github.com/ARGOeu/ams-push-server/push"
+	"github.com/ARGOeu/ams-push-server/redact"
 	"github.com/ARGOeu/ams-push-server/senders"
+	"github.com/ARGOeu/ams-push-server/state"
+	"github.com/ARGOeu/ams-push-server/verifiers"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
 	"github.com/grpc-ecosystem/go-grpc-middleware/tags"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
-	lSyslog "github.com/sirupsen/logrus/hooks/syslog"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	gRPCHealth "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
-	"log/syslog"
+	"net"
 	"net/http"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const ServiceUnavailable = "The push service is currently unable to handle any requests"
 
+// ShuttingDown is the reason activations are rejected while the service shuts down
+const ShuttingDown = "The push service is shutting down"
+
+// OnStandby is the reason activations are rejected by an instance that doesn't hold the lease
+const OnStandby = "The push service is on standby, only the leader activates subscriptions"
+
+// systemIdentity is the identity of the service itself, when it activates or deactivates subscriptions on its own
+var systemIdentity = Identity{
+	Name:     "ams-push-server",
+	Provider: "system",
+	Role:     acl.AdminRole,
+}
+
 // PushService holds all the the information and functionality regarding the push implementation
 type PushService struct {
-	Cfg            *config.Config
-	Client         *http.Client
-	AmsClient      *ams.Client
-	PushWorkers    map[string]push.Worker
-	deactivateChan chan consumers.CancelableError
-	status         string
+	Cfg               *config.Config
+	Client            *http.Client
+	AmsClient         *ams.Client
+	PushWorkers       *push.Registry
+	Verifier          verifiers.Verifier
+	DestinationPolicy *netpolicy.Policy
+	Auditor           audit.Logger
+	StateStore        state.Store
+	Cluster           *cluster.Cluster
+	Elector           *election.Elector
+	deactivateChan    chan consumers.CancelableError
+	status            string
+	// guards the status, which the loading of the subscriptions updates
+	statusMutex sync.RWMutex
+	// set once the service starts shutting down, no subscription can be activated after that
+	shuttingDown atomic.Bool
+	// set while another instance holds the lease, no subscription can be activated until this one acquires it
+	standby atomic.Bool
+	// serializes the transitions to standby with the registrations of new workers
+	standbyMutex sync.RWMutex
+	// serializes the writes of the persisted states with their removals
+	stateMutex sync.Mutex
+	// stops the loading and the reconciliation of the subscriptions when the instance loses the lease
+	leaderCancel context.CancelFunc
+	leaderMutex  sync.Mutex
+	// health service of the grpc server, reporting whether or not the service is serving
+	healthService *health.Server
+	// counters of the reconciliations of the active subscriptions against ams
+	reconcileStats reconcileStats
+	// progress of the loading of the subscriptions at startup
+	loadStats loadStats
+	// wait between the attempts to retrieve the push worker user and the subscriptions that couldn't be retrieved
+	loadBackoff backoff
+	// only one reconciliation runs at a time
+	reconcileMutex sync.Mutex
+	// connections to the other instances of the cluster
+	peerConns peerConns
 }
 
 // NewPushService returns a pointer to a PushService and initialises its fields
@@ -46,39 +99,115 @@ func NewPushService(cfg *config.Config) *PushService {
 	ps := new(PushService)
 
 	ps.Cfg = cfg
-	ps.PushWorkers = make(map[string]push.Worker)
+	ps.PushWorkers = push.NewRegistry()
 
-	// build the client
-	transCfg := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: !ps.Cfg.VerifySSL,
-		},
+	policy, err := cfg.GetDestinationPolicy()
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Fatal("Could not initialise the destination policy")
 	}
+	ps.DestinationPolicy = policy
 
-	client := &http.Client{
-		Transport: transCfg,
-		Timeout:   time.Duration(30 * time.Second),
+	// build the client that talks to ams
+	amsClient := newHTTPClient(cfg.AmsClient, cfg.VerifySSL, &net.Dialer{KeepAlive: 30 * time.Second})
+
+	ps.AmsClient = ams.NewClient("https", ps.Cfg.AmsHost, ps.Cfg.AmsToken, ps.Cfg.AmsPort, amsClient)
+
+	// build the client that pushes to the subscriptions' destinations,
+	// it refuses to reach any destination denied by the destination policy
+	ps.Client = newPushClient(cfg.PushClient, cfg.VerifySSL, policy)
+
+	verifier, err := verifiers.New(cfg.EndpointVerification, ps.Client, cfg.GetEndpointVerificationTTL())
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Fatal("Could not initialise the endpoint verifier")
 	}
+	ps.Verifier = verifier
 
-	ps.Client = client
-	ps.AmsClient = ams.NewClient("https", ps.Cfg.AmsHost, ps.Cfg.AmsToken, ps.Cfg.AmsPort, client)
+	auditor, err := audit.New(cfg.AuditLog)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Fatal("Could not initialise the audit log")
+	}
+	ps.Auditor = auditor
+
+	store, err := state.New(cfg.StateFile)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Fatal("Could not initialise the state store")
+	}
+	ps.StateStore = store
+
+	ps.loadBackoff = backoff{
+		initial: cfg.SubscriptionLoading.GetInitialBackoff(),
+		max:     cfg.SubscriptionLoading.GetMaxBackoff(),
+	}
 
 	ps.deactivateChan = make(chan consumers.CancelableError)
 	go ps.handleDeactivateChannel()
 
-	if !cfg.SkipSubsLoad {
-		go ps.loadSubscriptions()
+	// find out which subscriptions this instance owns, before any of them gets restored or loaded
+	if cfg.Cluster.Enabled() {
+		ps.initCluster()
+	}
+
+	go ps.checkpointLoop(cfg.GetStateCheckpointInterval())
+
+	// a standby instance only starts pushing once it acquires the lease
+	if cfg.Election.Enabled() {
+		ps.initElection()
+		return ps
 	}
 
+	// resume the workers that were active when the service last stopped, before any subscription gets loaded
+	ps.restoreWorkers()
+	ps.startLoading(context.Background())
+
 	return ps
 }
 
+// startLoading loads the subscriptions and keeps reconciling them against ams, unless the loading is skipped.
+// Both of them stop once the context is done
+func (ps *PushService) startLoading(ctx context.Context) {
+
+	if ps.Cfg.SkipSubsLoad {
+		return
+	}
+
+	go func() {
+		ps.loadSubscriptions(ctx)
+		if interval := ps.Cfg.GetReconcileInterval(); interval > 0 {
+			ps.reconcileLoop(ctx, interval)
+		}
+	}()
+}
+
 // handleDeactivateChannel listens on the deactivate channel in order to stop any subscription that caused a cancelable error
 func (ps *PushService) handleDeactivateChannel() {
 
 	for {
 		cancelErr, ok := <-ps.deactivateChan
 		if ok {
+			var prev *amsPb.PushConfig
+			if w, found := ps.PushWorkers.Get(cancelErr.Resource); found {
+				prev = w.Subscription().PushConfig
+			}
 			err := ps.deactivateSubscription(cancelErr.Resource)
 			if err != nil {
 				logrus.WithFields(
@@ -87,6 +216,9 @@ func (ps *PushService) handleDeactivateChannel() {
 						"subscription": cancelErr.Resource,
 					},
 				).Warning("Tried to deactivate malfunctioning subscription but was not active")
+			} else {
+				ps.audit(NewIdentityContext(context.Background(), systemIdentity), audit.AutoDeactivation,
+					cancelErr.Resource, prev, nil, nil, cancelErr.ErrMsg)
 			}
 			logrus.WithFields(
 				logrus.Fields{
@@ -103,35 +235,137 @@ func (ps *PushService) handleDeactivateChannel() {
 // Status returns the stat of the service, whether or not it is functioning properly
 func (ps *PushService) Status(context.Context, *amsPb.StatusRequest) (*amsPb.StatusResponse, error) {
 
-	if ps.status != "ok" {
-		return &amsPb.StatusResponse{}, status.Errorf(codes.Internal, "%v.%v", ServiceUnavailable, ps.status)
+	resp := &amsPb.StatusResponse{
+		Reconcile: ps.reconcileStats.status(),
+		Cluster:   ps.clusterStatus(),
+		Election:  ps.electionStatus(),
+	}
+
+	if ps.Cfg != nil {
+		resp.Load = ps.loadStats.status(ps.Cfg.SubscriptionLoading.GetReadyFraction())
+	}
+
+	if ps.Cfg != nil && ps.Cfg.TLSEnabled {
+		expiry, err := ps.Cfg.CertificateExpiry()
+		if err == nil {
+			resp.CertificateExpiry = expiry.UTC().Format(time.RFC3339)
+		}
 	}
 
-	return &amsPb.StatusResponse{}, nil
+	// a standby instance is functioning properly, even though it isn't pushing
+	if ps.standby.Load() {
+		return resp, nil
+	}
+
+	if st := ps.getStatus(); st != "ok" {
+		return resp, status.Errorf(codes.Internal, "%v.%v", ServiceUnavailable, st)
+	}
+
+	return resp, nil
+}
+
+// setStatus sets the status of the service, ok when it is functioning properly or the reason it isn't
+func (ps *PushService) setStatus(st string) {
+
+	ps.statusMutex.Lock()
+	defer ps.statusMutex.Unlock()
+
+	ps.status = st
+}
+
+// getStatus returns the status of the service
+func (ps *PushService) getStatus() string {
+
+	ps.statusMutex.RLock()
+	defer ps.statusMutex.RUnlock()
+
+	return ps.status
 }
 
 // SubscriptionStatus returns the status of the worker that handles the respective subscription
 func (ps *PushService) SubscriptionStatus(ctx context.Context, r *amsPb.SubscriptionStatusRequest) (*amsPb.SubscriptionStatusResponse, error) {
 
-	if !ps.IsSubActive(r.FullName) {
+	w, found := ps.PushWorkers.Get(r.FullName)
+	if !found {
+		owner, forward, err := ps.forwardTo(ctx, r.FullName)
+		if err != nil {
+			return nil, err
+		}
+		if forward {
+			return ps.forwardSubscriptionStatus(ctx, owner, r)
+		}
 		return nil, status.Errorf(codes.NotFound, "Subscription %v is not active", r.FullName)
 	}
 
-	w := ps.PushWorkers[r.FullName]
-
-	return &amsPb.SubscriptionStatusResponse{
+	resp := &amsPb.SubscriptionStatusResponse{
 		Status: w.Status(),
-	}, nil
+	}
+
+	if sr, ok := w.Sender().(senders.StatsReporter); ok {
+		for _, st := range sr.Stats() {
+			resp.Destinations = append(resp.Destinations, &amsPb.DestinationStatus{
+				Destination:       st.Destination,
+				DeliveredMessages: st.DeliveredMessages,
+				FailedDeliveries:  st.FailedDeliveries,
+				LastError:         st.LastError,
+			})
+		}
+	}
+
+	return resp, nil
+
+}
 
+// ListSubscriptions returns all the currently active subscriptions, sorted by name.
+// In cluster mode only the subscriptions of the current instance are listed
+func (ps *PushService) ListSubscriptions(ctx context.Context, r *amsPb.ListSubscriptionsRequest) (*amsPb.ListSubscriptionsResponse, error) {
+
+	resp := &amsPb.ListSubscriptionsResponse{}
+
+	ps.PushWorkers.Range(func(name string, w push.Worker) {
+		resp.Subscriptions = append(resp.Subscriptions, &amsPb.ActiveSubscription{
+			FullName:  w.Subscription().FullName,
+			FullTopic: w.Subscription().FullTopic,
+			Status:    w.Status(),
+		})
+	})
+
+	return resp, nil
 }
 
 // ActivateSubscription activates a subscription so the service can start handling the push functionality
-func (ps *PushService) ActivateSubscription(ctx context.Context, r *amsPb.ActivateSubscriptionRequest) (*amsPb.ActivateSubscriptionResponse, error) {
+func (ps *PushService) ActivateSubscription(ctx context.Context, r *amsPb.ActivateSubscriptionRequest) (resp *amsPb.ActivateSubscriptionResponse, err error) {
+
+	// the reason of the audit entry of an activation that was forwarded to the owner of the subscription
+	forwarded := ""
+
+	defer func() {
+		sub := r.GetSubscription()
+		ps.audit(ctx, "ActivateSubscription", sub.GetFullName(), nil, sub.GetPushConfig(), err, forwarded)
+	}()
 
 	if r.Subscription == nil || r.Subscription.PushConfig == nil || r.Subscription.PushConfig.RetryPolicy == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "Empty subscription")
 	}
 
+	if ps.shuttingDown.Load() {
+		return nil, status.Error(codes.Unavailable, ShuttingDown)
+	}
+
+	if ps.standby.Load() {
+		return nil, status.Error(codes.FailedPrecondition, OnStandby)
+	}
+
+	owner, forward, err := ps.forwardTo(ctx, r.Subscription.FullName)
+	if err != nil {
+		return nil, err
+	}
+
+	if forward {
+		forwarded = fmt.Sprintf("Forwarded to %v", owner.Name)
+		return ps.forwardActivation(ctx, owner, r)
+	}
+
 	if ps.IsSubActive(r.Subscription.FullName) {
 		return nil, status.Errorf(codes.AlreadyExists, "Subscription %v is already activated", r.Subscription.FullName)
 	}
@@ -143,31 +377,175 @@ func (ps *PushService) ActivateSubscription(ctx context.Context, r *amsPb.Activa
 		}
 	}
 
-	// choose a consumer
-	c, _ := consumers.New(consumers.AmsHttpConsumerType, r.Subscription.FullName, ps.AmsClient)
+	for _, d := range r.Subscription.PushConfig.Destinations {
+		if d == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Empty destination")
+		}
+		if d.Type == amsPb.PushType_HTTP_ENDPOINT {
+			_, err := url.ParseRequestURI(d.PushEndpoint)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "Invalid destination push endpoint, %v", err.Error())
+			}
+		}
+	}
 
-	// choose a sender
-	s, _ := senders.New(*r.Subscription.PushConfig, ps.Client)
+	if !senders.IsValidMessageFormat(r.Subscription.PushConfig.MessageFormat) {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid message format %v", r.Subscription.PushConfig.MessageFormat)
+	}
 
-	worker, err := push.New(r.Subscription, c, s, ps.deactivateChan)
+	if !senders.IsValidCompression(r.Subscription.PushConfig.Compression) {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid compression %v", r.Subscription.PushConfig.Compression)
+	}
+
+	if r.Subscription.PushConfig.TimeoutMs < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid timeout %v", r.Subscription.PushConfig.TimeoutMs)
+	}
+
+	err = ps.checkDestinations(ctx, r.Subscription)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = ps.verifyEndpoints(ctx, r.Subscription)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	worker, err := ps.newWorker(r.Subscription)
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "Invalid argument, %v", err.Error())
 	}
 
-	ps.PushWorkers[r.Subscription.FullName] = worker
+	// only one of the concurrent activations of the same subscription registers its worker
+	err = ps.addWorker(r.Subscription.FullName, worker)
+	if err != nil {
+		return nil, err
+	}
+
 	go worker.Start()
+	ps.saveState(worker)
+
+	log.WithFields(
+		log.Fields{
+			"type":         "system_log",
+			"subscription": r.Subscription.FullName,
+			"push_config":  redact.PushConfig(r.Subscription.PushConfig).String(),
+		},
+	).Debug("Push worker started")
 
 	return &amsPb.ActivateSubscriptionResponse{
 		Message: fmt.Sprintf("Subscription %v activated", r.Subscription.FullName),
 	}, nil
 }
 
-// DeactivateSubscription deactivates a subscription so the service can stop handling the push functionality for it
+// newWorker creates the worker of a subscription, along with its consumer and sender
+func (ps *PushService) newWorker(sub *amsPb.Subscription) (push.Worker, error) {
+
+	// choose a consumer
+	c, err := consumers.New(consumers.AmsHttpConsumerType, sub.FullName, ps.AmsClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// choose a sender
+	s, err := senders.New(*sub.PushConfig, ps.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return push.New(sub, c, s, ps.deactivateChan)
+}
+
+// checkDestinations checks every destination of the subscription against the destination policy
+func (ps *PushService) checkDestinations(ctx context.Context, sub *amsPb.Subscription) error {
+
+	destinations := []string{pushURL(sub.PushConfig.Type, sub.PushConfig.PushEndpoint, sub.PushConfig.MattermostUrl)}
+
+	for _, d := range sub.PushConfig.Destinations {
+		destinations = append(destinations, pushURL(d.Type, d.PushEndpoint, d.MattermostUrl))
+	}
+
+	for _, destination := range destinations {
+		err := ps.DestinationPolicy.CheckURL(ctx, destination)
+		if err != nil {
+			return errors.Errorf("Destination %v is not allowed, %v", redact.URL(destination), err.Error())
+		}
+	}
+
+	return nil
+}
+
+// pushURL returns the url that a destination of the provided type pushes to
+func pushURL(t amsPb.PushType, pushEndpoint string, mattermostUrl string) string {
+	if t == amsPb.PushType_MATTERMOST {
+		return mattermostUrl
+	}
+	return pushEndpoint
+}
+
+// verifyEndpoints verifies every http endpoint that the subscription is going to push to
+func (ps *PushService) verifyEndpoints(ctx context.Context, sub *amsPb.Subscription) error {
+
+	endpoints := make([]string, 0, len(sub.PushConfig.Destinations)+1)
+
+	if sub.PushConfig.Type == amsPb.PushType_HTTP_ENDPOINT {
+		endpoints = append(endpoints, sub.PushConfig.PushEndpoint)
+	}
+
+	for _, d := range sub.PushConfig.Destinations {
+		if d.Type == amsPb.PushType_HTTP_ENDPOINT {
+			endpoints = append(endpoints, d.PushEndpoint)
+		}
+	}
+
+	for _, endpoint := range endpoints {
+
+		err := ps.Verifier.Verify(ctx, sub.FullName, endpoint)
+		if err != nil {
+
+			log.WithFields(
+				log.Fields{
+					"type":         "system_log",
+					"subscription": sub.FullName,
+					"destination":  redact.URL(endpoint),
+					"error":        err.Error(),
+				},
+			).Error("Could not verify push endpoint")
+
+			return errors.Errorf("Could not verify push endpoint %v, %v", redact.URL(endpoint), err.Error())
+		}
+	}
+
+	return nil
+}
+
+// DeactivateSubscription deactivates a subscription so the service can stop handling the push functionality for it.
+// In cluster mode a subscription that isn't active locally is deactivated on the instance that owns it
 func (ps *PushService) DeactivateSubscription(ctx context.Context, r *amsPb.DeactivateSubscriptionRequest) (*amsPb.DeactivateSubscriptionResponse, error) {
 
+	var prev *amsPb.PushConfig
+	if w, found := ps.PushWorkers.Get(r.FullName); found {
+		prev = w.Subscription().PushConfig
+	} else if owner, forward, err := ps.forwardTo(ctx, r.FullName); err != nil || forward {
+		var resp *amsPb.DeactivateSubscriptionResponse
+		reason := ""
+		if err == nil {
+			resp, err = ps.forwardDeactivation(ctx, owner, r)
+			reason = fmt.Sprintf("Forwarded to %v", owner.Name)
+		}
+		ps.audit(ctx, "DeactivateSubscription", r.FullName, nil, nil, err, reason)
+		return resp, err
+	}
+
 	err := ps.deactivateSubscription(r.FullName)
 	if err != nil {
-		return nil, status.Error(codes.NotFound, err.Error())
+		err = status.Error(codes.NotFound, err.Error())
+	}
+
+	ps.audit(ctx, "DeactivateSubscription", r.FullName, prev, nil, err, "")
+
+	if err != nil {
+		return nil, err
 	}
 
 	return &amsPb.DeactivateSubscriptionResponse{
@@ -175,40 +553,149 @@ func (ps *PushService) DeactivateSubscription(ctx context.Context, r *amsPb.Deac
 	}, nil
 }
 
-// deactivateSubscription checks if the sub is active, then stops the respective worker and removes the sub from the map
+// deactivateSubscription removes the sub from the registry and stops the respective worker, if the sub is active
 func (ps *PushService) deactivateSubscription(sub string) error {
 
-	if !ps.IsSubActive(sub) {
+	w, found := ps.PushWorkers.Remove(sub)
+	if !found {
 		return errors.Errorf("Subscription %v is not active", sub)
 	}
 
-	w := ps.PushWorkers[sub]
 	w.Stop()
-
-	delete(ps.PushWorkers, sub)
+	ps.deleteState(sub)
 
 	return nil
 }
 
+// audit records an administrative action on a subscription in the audit log, along with the identity that performed it.
+// The reason explains why the service performed the action on its own
+func (ps *PushService) audit(ctx context.Context, action string, sub string, prev *amsPb.PushConfig, next *amsPb.PushConfig, err error, reason string) {
+
+	if ps.Auditor == nil {
+		return
+	}
+
+	entry := audit.Entry{
+		Identity:           "anonymous",
+		Action:             action,
+		Subscription:       sub,
+		PreviousPushConfig: audit.PushConfig(prev),
+		PushConfig:         audit.PushConfig(next),
+		Result:             audit.ResultOK,
+		Error:              reason,
+	}
+
+	if id, ok := IdentityFromContext(ctx); ok {
+		entry.Identity = id.Name
+		entry.Provider = id.Provider
+	}
+
+	if err != nil {
+		entry.Result = status.Code(err).String()
+		entry.Error = status.Convert(err).Message()
+	}
+
+	auditErr := ps.Auditor.Record(entry)
+	if auditErr != nil {
+		log.WithFields(
+			log.Fields{
+				"type":         "error_log",
+				"action":       action,
+				"subscription": sub,
+				"error":        auditErr.Error(),
+			},
+		).Error("Could not record audit entry")
+	}
+}
+
 // IsSubActive checks by subscription name, whether or not a subscription is already active
 func (ps *PushService) IsSubActive(name string) bool {
 
-	_, found := ps.PushWorkers[name]
-
-	return found
+	return ps.PushWorkers.Has(name)
 }
 
-// NewGRPCServer configures and returns a new *grpc.Server
-func NewGRPCServer(cfg *config.Config) *grpc.Server {
+// Shutdown stops accepting activations and drains every push worker, letting its current push cycle
+// consume, send and acknowledge its messages. Workers that don't drain before the context is done are canceled,
+// their unacknowledged messages are delivered again by ams after a restart
+func (ps *PushService) Shutdown(ctx context.Context) error {
 
-	grpcLogger := log.New()
-	fmter := &log.TextFormatter{FullTimestamp: true, DisableColors: true}
-	grpcLogger.SetFormatter(fmter)
-	hook, err := lSyslog.NewSyslogHook("", "", syslog.LOG_INFO, "")
-	if err == nil {
-		grpcLogger.AddHook(hook)
+	ps.shuttingDown.Store(true)
+
+	if ps.healthService != nil {
+		ps.healthService.Shutdown()
 	}
-	grpcLogger.SetLevel(cfg.GetLogLevel())
+
+	log.WithFields(
+		log.Fields{
+			"type":    "system_log",
+			"workers": ps.PushWorkers.Len(),
+		},
+	).Info("Draining push workers")
+
+	t1 := time.Now()
+
+	var wg sync.WaitGroup
+	var undrained atomic.Int32
+
+	ps.PushWorkers.Range(func(name string, w push.Worker) {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			err := w.Drain(ctx)
+			if err != nil {
+				undrained.Add(1)
+				log.WithFields(
+					log.Fields{
+						"type":         "system_log",
+						"subscription": name,
+						"error":        err.Error(),
+					},
+				).Warning("Push worker did not drain in time, its current push cycle has been canceled")
+				return
+			}
+
+			log.WithFields(
+				log.Fields{
+					"type":         "system_log",
+					"subscription": name,
+				},
+			).Debug("Push worker drained")
+		}()
+	})
+
+	wg.Wait()
+
+	// persist the state the workers stopped at, so that they resume from it after a restart
+	ps.checkpoint()
+	ps.closeStateStore()
+	ps.closePeerConns()
+
+	// the lease is released only once the workers have stopped, so that the standby doesn't push alongside them
+	ps.stopElection()
+
+	if n := undrained.Load(); n > 0 {
+		return errors.Errorf("%v push workers did not drain in time", n)
+	}
+
+	log.WithFields(
+		log.Fields{
+			"type":            "performance_log",
+			"workers":         ps.PushWorkers.Len(),
+			"processing_time": time.Since(t1).String(),
+		},
+	).Info("All push workers drained")
+
+	return nil
+}
+
+// NewGRPCServer configures and returns a new *grpc.Server that serves the provided push service
+func NewGRPCServer(cfg *config.Config, s *PushService) *grpc.Server {
+
+	grpcLogger := logging.Logger(logging.GRPC)
 
 	logOpts := []grpc_logrus.Option{
 		grpc_logrus.WithDurationField(func(duration time.Duration) (key string, value interface{}) {
@@ -216,15 +703,45 @@ func NewGRPCServer(cfg *config.Config) *grpc.Server {
 		}),
 	}
 
-	s := NewPushService(cfg)
+	authProvider, err := NewAuthProvider(cfg)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Fatal("Could not initialise the auth providers")
+	}
+
+	// pick up rotated jwt signing keys on every reload
+	if chain, ok := authProvider.(ChainAuthProvider); ok {
+		for _, p := range chain {
+			if jp, ok := p.(*JWTAuthProvider); ok {
+				cfg.OnReload(func(cfg *config.Config) {
+					err := jp.Reload()
+					if err != nil {
+						log.WithFields(
+							log.Fields{
+								"type":  "error_log",
+								"error": err.Error(),
+							},
+						).Error("Could not reload the jwt signing keys, keeping the current ones")
+					}
+				})
+			}
+		}
+	}
 
 	srvOptions := []grpc.ServerOption{
 		grpc.ChainUnaryInterceptor(
 			grpc_ctxtags.UnaryServerInterceptor(),
-			grpc_logrus.UnaryServerInterceptor(logrus.NewEntry(grpcLogger), logOpts...),
-			AuthInterceptor(cfg.ACL, cfg.TLSEnabled),
+			grpc_logrus.UnaryServerInterceptor(logrus.NewEntry(grpcLogger).WithField(logging.ComponentField, logging.GRPC), logOpts...),
+			AuthInterceptor(authProvider),
 			StatusInterceptor(s),
 		),
+		grpc.ChainStreamInterceptor(
+			AuthStreamInterceptor(authProvider),
+		),
 	}
 
 	if cfg.TLSEnabled {
@@ -236,130 +753,69 @@ func NewGRPCServer(cfg *config.Config) *grpc.Server {
 	healthService := health.NewServer()
 	healthService.SetServingStatus("", gRPCHealth.HealthCheckResponse_SERVING)
 	gRPCHealth.RegisterHealthServer(srv, healthService)
+	s.healthService = healthService
 
 	amsPb.RegisterPushServiceServer(srv, s)
 
 	return srv
 }
 
-// loadSubscriptions activates all the ams subscriptions that are push enabled and assigned to the current push worker
-func (ps *PushService) loadSubscriptions() {
-
-	var userInfo ams.UserInfo
-	var err error
-
-	userFound := false
-
-	// attempt to retrieve the push worker user
-	for !userFound {
-		t1 := time.Now()
-		userInfo, err = ps.AmsClient.GetUserByToken(context.Background(), ps.Cfg.AmsToken)
-		if err != nil {
-			ps.status = "Could not retrieve push worker user"
-			log.WithFields(
-				log.Fields{
-					"type":  "system_log",
-					"error": err.Error(),
-				},
-			).Error("Could not retrieve push worker user")
-			continue
-		}
-		userFound = true
-		log.WithFields(
-			log.Fields{
-				"type":            "performance_log",
-				"user":            userInfo.Name,
-				"processing_time": time.Since(t1).String(),
-			},
-		).Info("Push worker user retrieved successfully")
+// toPushType maps an ams push configuration type to the respective grpc push type
+func toPushType(t string) amsPb.PushType {
+	if t == ams.MattermostPushConfig {
+		return amsPb.PushType_MATTERMOST
 	}
+	return amsPb.PushType_HTTP_ENDPOINT
+}
 
-	ps.status = "ok"
-
-	for _, project := range userInfo.Projects {
-
-		for _, subName := range project.Subscriptions {
-
-			fullSubName := fmt.Sprintf("/projects/%v/subscriptions/%v", project.Project, subName)
-
-			t1 := time.Now()
-			sub, err := ps.AmsClient.GetSubscription(context.Background(), fullSubName)
-			if err != nil {
-				log.WithFields(
-					log.Fields{
-						"type":         "system_log",
-						"subscription": fullSubName,
-						"error":        err.Error(),
-					},
-				).Error("Could not retrieve subscription")
-				continue
-			}
-
-			if !sub.IsPushEnabled() {
-				log.WithFields(
-					log.Fields{
-						"type":         "system_log",
-						"subscription": fullSubName,
-					},
-				).Error("Subscription is not push enabled")
-				continue
-			}
-
-			log.WithFields(
-				log.Fields{
-					"type":            "performance_log",
-					"subscription":    sub.FullName,
-					"processing_time": time.Since(t1).String(),
-				},
-			).Info("Subscription retrieved successfully")
-
-			var pushType amsPb.PushType
-			if sub.PushCfg.Type == ams.HttpEndpointPushConfig {
-				pushType = amsPb.PushType_HTTP_ENDPOINT
-			} else {
-				pushType = amsPb.PushType_MATTERMOST
-			}
+// ToSubscription maps a subscription retrieved from ams to the respective grpc subscription
+func ToSubscription(sub ams.Subscription) *amsPb.Subscription {
+	return &amsPb.Subscription{
+		FullName:   sub.FullName,
+		FullTopic:  sub.FullTopic,
+		PushConfig: toPushConfig(sub.PushCfg),
+	}
+}
 
-			_, err = ps.ActivateSubscription(context.TODO(),
-				&amsPb.ActivateSubscriptionRequest{
-					Subscription: &amsPb.Subscription{
-						FullName:  sub.FullName,
-						FullTopic: sub.FullTopic,
-						PushConfig: &amsPb.PushConfig{
-							Type:                pushType,
-							PushEndpoint:        sub.PushCfg.Pend,
-							AuthorizationHeader: sub.PushCfg.AuthorizationHeader.Value,
-							MaxMessages:         sub.PushCfg.MaxMessages,
-							RetryPolicy: &amsPb.RetryPolicy{
-								Period: sub.PushCfg.RetPol.Period,
-								Type:   sub.PushCfg.RetPol.PolicyType,
-							},
-							MattermostUrl:      sub.PushCfg.MattermostUrl,
-							MattermostUsername: sub.PushCfg.MattermostUsername,
-							MattermostChannel:  sub.PushCfg.MattermostChannel,
-							Base_64Decode:      sub.PushCfg.Base64Decode,
-						},
-					},
-				},
-			)
+// toPushConfig maps the push configuration of an ams subscription to the respective grpc push configuration
+func toPushConfig(pc ams.PushConfig) *amsPb.PushConfig {
+
+	pushCfg := &amsPb.PushConfig{
+		Type:                 toPushType(pc.Type),
+		PushEndpoint:         pc.Pend,
+		AuthorizationHeader:  pc.AuthorizationHeader.Value,
+		MaxMessages:          pc.MaxMessages,
+		RetryPolicy:          &amsPb.RetryPolicy{Period: pc.RetPol.Period, Type: pc.RetPol.PolicyType},
+		MattermostUrl:        pc.MattermostUrl,
+		MattermostUsername:   pc.MattermostUsername,
+		MattermostChannel:    pc.MattermostChannel,
+		Base_64Decode:        pc.Base64Decode,
+		MessageFormat:        pc.MessageFormat,
+		Compression:          pc.Compression,
+		CompressionThreshold: pc.CompressionThreshold,
+		MaxBatchBytes:        pc.MaxBatchBytes,
+		TimeoutMs:            pc.TimeoutMs,
+	}
 
-			if err != nil {
-				log.WithFields(
-					log.Fields{
-						"type":         "system_log",
-						"subscription": fullSubName,
-						"error":        err.Error(),
-					},
-				).Error("Could not activate subscription")
-				continue
-			}
+	switch pc.DeliveryPolicy {
+	case ams.AnySucceedsPolicy:
+		pushCfg.DeliveryPolicy = amsPb.DeliveryPolicy_ANY_SUCCEEDS
+	case ams.BestEffortPolicy:
+		pushCfg.DeliveryPolicy = amsPb.DeliveryPolicy_BEST_EFFORT
+	default:
+		pushCfg.DeliveryPolicy = amsPb.DeliveryPolicy_ALL_MUST_SUCCEED
+	}
 
-			log.WithFields(
-				log.Fields{
-					"type":         "system_log",
-					"subscription": fullSubName,
-				},
-			).Info("Subscription activated successfully")
-		}
+	for _, d := range pc.Destinations {
+		pushCfg.Destinations = append(pushCfg.Destinations, &amsPb.Destination{
+			Type:                toPushType(d.Type),
+			PushEndpoint:        d.Pend,
+			AuthorizationHeader: d.AuthorizationHeader.Value,
+			MattermostUrl:       d.MattermostUrl,
+			MattermostUsername:  d.MattermostUsername,
+			MattermostChannel:   d.MattermostChannel,
+		})
 	}
+
+	return pushCfg
 }
@@ -2,19 +2,35 @@ package grpc
 
 import (
 	"context"
+	"fmt"
+	"github.com/ARGOeu/ams-push-server/acl"
 	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/audit"
 	"github.com/ARGOeu/ams-push-server/config"
 	"github.com/ARGOeu/ams-push-server/consumers"
+	"github.com/ARGOeu/ams-push-server/netpolicy"
 	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
 	"github.com/ARGOeu/ams-push-server/push"
+	"github.com/ARGOeu/ams-push-server/senders"
+	"github.com/ARGOeu/ams-push-server/state"
+	"github.com/ARGOeu/ams-push-server/verifiers"
+	"github.com/golang/protobuf/proto"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	gRPCHealth "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type ServerTestSuite struct {
@@ -34,12 +50,26 @@ func (suite *ServerTestSuite) TestStatus() {
 	_, e2 := ps.Status(context.Background(), &amsPb.StatusRequest{})
 	suite.Nil(e2)
 
+	// the expiry of the served certificate is reported
+	cfg, err := config.NewMockTLSConfig(suite.T().TempDir(), 24*time.Hour)
+	suite.Nil(err)
+	expiry, _ := cfg.CertificateExpiry()
+
+	ps.Cfg = cfg
+	r3, e3 := ps.Status(context.Background(), &amsPb.StatusRequest{})
+	suite.Nil(e3)
+	suite.Equal(expiry.UTC().Format(time.RFC3339), r3.CertificateExpiry)
+	suite.WithinDuration(time.Now().Add(24*time.Hour), expiry, time.Minute)
 }
 
 // TestActivateSubscriptionOK tests the normal case where a subscription is added successfully
 func (suite *ServerTestSuite) TestActivateSubscriptionOK() {
 
-	ps := NewPushService(config.NewMockConfig())
+	// allow the loopback destination
+	cfg := config.NewMockConfig()
+	cfg.DestinationPolicy.DenyCIDRs = []string{}
+
+	ps := NewPushService(cfg)
 
 	retry := amsPb.RetryPolicy{
 		Type:   "linear",
@@ -65,10 +95,103 @@ func (suite *ServerTestSuite) TestActivateSubscriptionOK() {
 	}, s)
 	suite.Nil(e)
 
-	lw, _ := ps.PushWorkers["/projects/p1/subscription/sub1"]
+	lw, _ := ps.PushWorkers.Get("/projects/p1/subscription/sub1")
 	suite.Equal(&sub, lw.Subscription())
 }
 
+// TestAuditLog tests that activations and deactivations are recorded in the audit log
+func (suite *ServerTestSuite) TestAuditLog() {
+
+	ps := NewPushService(config.NewMockConfig())
+	auditor := new(audit.MockLogger)
+	ps.Auditor = auditor
+
+	ctx := NewIdentityContext(context.Background(), Identity{Name: "CN=admin", Provider: "mtls", Role: acl.AdminRole})
+
+	pCfg := &amsPb.PushConfig{
+		Type:                amsPb.PushType_HTTP_ENDPOINT,
+		PushEndpoint:        "https://example.com:5000/receive_here",
+		AuthorizationHeader: "auth-header",
+		RetryPolicy:         &amsPb.RetryPolicy{Type: "linear", Period: 30},
+	}
+
+	sub := &amsPb.Subscription{FullName: "sub1", FullTopic: "topic1", PushConfig: pCfg}
+
+	// successful activation
+	_, e1 := ps.ActivateSubscription(ctx, &amsPb.ActivateSubscriptionRequest{Subscription: sub})
+	suite.Nil(e1)
+
+	// failed activation
+	_, e2 := ps.ActivateSubscription(ctx, &amsPb.ActivateSubscriptionRequest{Subscription: sub})
+	suite.NotNil(e2)
+
+	// successful deactivation
+	_, e3 := ps.DeactivateSubscription(ctx, &amsPb.DeactivateSubscriptionRequest{FullName: "sub1"})
+	suite.Nil(e3)
+
+	// failed deactivation by an unauthenticated caller
+	_, e4 := ps.DeactivateSubscription(context.Background(), &amsPb.DeactivateSubscriptionRequest{FullName: "sub1"})
+	suite.NotNil(e4)
+
+	entries := auditor.Recorded()
+	suite.Equal(4, len(entries))
+
+	suite.Equal(audit.Entry{
+		Identity:     "CN=admin",
+		Provider:     "mtls",
+		Action:       "ActivateSubscription",
+		Subscription: "sub1",
+		PushConfig:   audit.PushConfig(pCfg),
+		Result:       "OK",
+	}, entries[0])
+	suite.Contains(string(entries[0].PushConfig), `"authorization_header":"REDACTED"`)
+
+	suite.Equal(audit.Entry{
+		Identity:     "CN=admin",
+		Provider:     "mtls",
+		Action:       "ActivateSubscription",
+		Subscription: "sub1",
+		PushConfig:   audit.PushConfig(pCfg),
+		Result:       "AlreadyExists",
+		Error:        "Subscription sub1 is already activated",
+	}, entries[1])
+
+	suite.Equal(audit.Entry{
+		Identity:           "CN=admin",
+		Provider:           "mtls",
+		Action:             "DeactivateSubscription",
+		Subscription:       "sub1",
+		PreviousPushConfig: audit.PushConfig(pCfg),
+		Result:             "OK",
+	}, entries[2])
+
+	suite.Equal(audit.Entry{
+		Identity:     "anonymous",
+		Action:       "DeactivateSubscription",
+		Subscription: "sub1",
+		Result:       "NotFound",
+		Error:        "Subscription sub1 is not active",
+	}, entries[3])
+
+	// automatic deactivations are recorded as performed by the service
+	ps.PushWorkers.Add("sub2", &push.MockWorker{Sub: amsPb.Subscription{FullName: "sub2", PushConfig: pCfg}})
+	ps.deactivateChan <- consumers.CancelableError{ErrMsg: "Subscription sub2 no longer exists", Resource: "sub2"}
+	// send a second error so that the first one is surely handled
+	ps.deactivateChan <- consumers.CancelableError{ErrMsg: "not active", Resource: "unknown"}
+
+	entries = auditor.Recorded()
+	suite.Equal(5, len(entries))
+	suite.Equal(audit.Entry{
+		Identity:           "ams-push-server",
+		Provider:           "system",
+		Action:             "AutoDeactivation",
+		Subscription:       "sub2",
+		PreviousPushConfig: audit.PushConfig(pCfg),
+		Result:             "OK",
+		Error:              "Subscription sub2 no longer exists",
+	}, entries[4])
+}
+
 // TestActivateSubscriptionNIL tests the case where the provided subscription is invalid
 func (suite *ServerTestSuite) TestActivateSubscriptionInvalidArgument() {
 
@@ -95,13 +218,172 @@ func (suite *ServerTestSuite) TestActivateSubscriptionInvalidArgument() {
 	suite.Equal(status.Error(codes.InvalidArgument, "Invalid argument, worker unknown not yet implemented"), e1)
 
 	suite.Nil(s1)
+
+	// invalid argument through unknown message format
+	s2, e2 := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: &amsPb.Subscription{
+			PushConfig: &amsPb.PushConfig{
+				PushEndpoint:  "https://example.com",
+				MessageFormat: "unknown",
+				RetryPolicy: &amsPb.RetryPolicy{
+					Type: "linear",
+				},
+			},
+		}})
+
+	suite.Equal(status.Error(codes.InvalidArgument, "Invalid message format unknown"), e2)
+
+	suite.Nil(s2)
+
+	// invalid argument through a push endpoint denied by the destination policy
+	s3, e3 := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: &amsPb.Subscription{
+			PushConfig: &amsPb.PushConfig{
+				Type:         amsPb.PushType_HTTP_ENDPOINT,
+				PushEndpoint: "http://127.0.0.1:8080/",
+				RetryPolicy: &amsPb.RetryPolicy{
+					Type: "linear",
+				},
+			},
+		}})
+
+	suite.Equal(status.Error(codes.InvalidArgument,
+		"Destination http://127.0.0.1:8080/ is not allowed, address 127.0.0.1 is not allowed"), e3)
+
+	suite.Nil(s3)
+
+	// invalid argument through a mattermost destination denied by the destination policy
+	s4, e4 := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: &amsPb.Subscription{
+			PushConfig: &amsPb.PushConfig{
+				Type:         amsPb.PushType_HTTP_ENDPOINT,
+				PushEndpoint: "https://example.com",
+				RetryPolicy: &amsPb.RetryPolicy{
+					Type: "linear",
+				},
+				Destinations: []*amsPb.Destination{
+					{
+						Type:          amsPb.PushType_MATTERMOST,
+						MattermostUrl: "http://169.254.169.254/hooks/1",
+					},
+				},
+			},
+		}})
+
+	suite.Equal(status.Error(codes.InvalidArgument,
+		"Destination http://169.254.169.254/hooks/REDACTED is not allowed, address 169.254.169.254 is not allowed"), e4)
+
+	suite.Nil(s4)
+
+	// invalid argument through a negative timeout
+	s5, e5 := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: &amsPb.Subscription{
+			PushConfig: &amsPb.PushConfig{
+				Type:         amsPb.PushType_HTTP_ENDPOINT,
+				PushEndpoint: "https://example.com",
+				TimeoutMs:    -1,
+				RetryPolicy: &amsPb.RetryPolicy{
+					Type: "linear",
+				},
+			},
+		}})
+
+	suite.Equal(status.Error(codes.InvalidArgument, "Invalid timeout -1"), e5)
+
+	suite.Nil(s5)
+
+	// invalid argument through a push endpoint whose host can't be resolved
+	ps.DestinationPolicy.SetResolver(netpolicy.MockResolver{"unknown.example.com": {}})
+	s6, e6 := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: &amsPb.Subscription{
+			PushConfig: &amsPb.PushConfig{
+				Type:         amsPb.PushType_HTTP_ENDPOINT,
+				PushEndpoint: "https://unknown.example.com",
+				RetryPolicy: &amsPb.RetryPolicy{
+					Type: "linear",
+				},
+			},
+		}})
+
+	suite.Equal(status.Error(codes.InvalidArgument, "Destination https://unknown.example.com is not allowed, "+
+		"host unknown.example.com could not be resolved, lookup unknown.example.com: no such host"), e6)
+
+	suite.Nil(s6)
+
+	// invalid argument through a destination of an unsupported type, instead of a worker without a sender
+	s7, e7 := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: &amsPb.Subscription{
+			FullName: "/projects/p1/subscriptions/unsupported",
+			PushConfig: &amsPb.PushConfig{
+				Type:         amsPb.PushType_HTTP_ENDPOINT,
+				PushEndpoint: "https://example.com",
+				RetryPolicy: &amsPb.RetryPolicy{
+					Type: "linear",
+				},
+				Destinations: []*amsPb.Destination{
+					{
+						Type:         amsPb.PushType(99),
+						PushEndpoint: "https://other.example.com",
+					},
+				},
+			},
+		}})
+
+	suite.Equal(status.Error(codes.InvalidArgument, "Invalid argument, sender 99 not yet implemented"), e7)
+	suite.Nil(s7)
+	suite.False(ps.IsSubActive("/projects/p1/subscriptions/unsupported"))
+}
+
+// TestActivateSubscriptionFailedPrecondition tests the case where the push endpoints of the subscription can't be verified
+func (suite *ServerTestSuite) TestActivateSubscriptionFailedPrecondition() {
+
+	ps := NewPushService(config.NewMockConfig())
+	v := &verifiers.MockVerifier{Unverified: []string{"https://unverified.example.com"}}
+	ps.Verifier = v
+
+	sub := amsPb.Subscription{
+		FullName: "/projects/p1/subscription/sub1",
+		PushConfig: &amsPb.PushConfig{
+			Type:         amsPb.PushType_HTTP_ENDPOINT,
+			PushEndpoint: "https://example.com",
+			RetryPolicy:  &amsPb.RetryPolicy{Type: "linear", Period: 300},
+			Destinations: []*amsPb.Destination{
+				{
+					Type:          amsPb.PushType_MATTERMOST,
+					MattermostUrl: "https://mattermost.example.com/hooks/1",
+				},
+				{
+					Type:         amsPb.PushType_HTTP_ENDPOINT,
+					PushEndpoint: "https://unverified.example.com",
+				},
+			},
+		},
+	}
+
+	s, e := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{Subscription: &sub})
+
+	suite.Equal(status.Error(codes.FailedPrecondition,
+		"Could not verify push endpoint https://unverified.example.com, endpoint not verified"), e)
+	suite.Nil(s)
+	suite.False(ps.IsSubActive("/projects/p1/subscription/sub1"))
+
+	// mattermost destinations are not verified
+	suite.Equal([]string{"https://example.com"}, v.Verified)
+
+	// every endpoint is verified
+	sub.PushConfig.Destinations[1].PushEndpoint = "https://other.example.com"
+	_, e2 := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{Subscription: &sub})
+	suite.Nil(e2)
+	suite.Equal([]string{"https://example.com", "https://example.com", "https://other.example.com"}, v.Verified)
+	lw, _ := ps.PushWorkers.Get("/projects/p1/subscription/sub1")
+	lw.Stop()
 }
 
 // TestActivateSubscriptionCONFLICT tests the case where the subscription is already activated and a conflict is produced
 func (suite *ServerTestSuite) TestActivateSubscriptionCONFLICT() {
 
 	ps := NewPushService(config.NewMockConfig())
-	ps.PushWorkers["conflict_sub"] = new(push.MockWorker)
+	ps.PushWorkers.Add("conflict_sub", new(push.MockWorker))
 	sub := amsPb.Subscription{
 		FullName: "conflict_sub",
 		PushConfig: &amsPb.PushConfig{
@@ -130,10 +412,10 @@ func (suite *ServerTestSuite) TestSubscriptionStatus() {
 	suite.Nil(s)
 
 	// normal case
-	ps.PushWorkers["sub1"] = &push.MockWorker{
+	ps.PushWorkers.Add("sub1", &push.MockWorker{
 		Sub:       sub,
 		SubStatus: "ok",
-	}
+	})
 
 	s2, e2 := ps.SubscriptionStatus(context.Background(), &amsPb.SubscriptionStatusRequest{FullName: "sub1"})
 
@@ -142,6 +424,103 @@ func (suite *ServerTestSuite) TestSubscriptionStatus() {
 	}, s2)
 
 	suite.Nil(e2)
+
+	// subscription with multiple destinations
+	ms := senders.NewMultiSender([]senders.Sender{
+		new(senders.MockSender),
+		&senders.MockSender{SendStatus: "error_send"},
+	}, amsPb.DeliveryPolicy_ANY_SUCCEEDS)
+	ms.Send(context.Background(), senders.PushMsgs{Messages: []senders.PushMsg{{}}}, senders.MultipleMessageFormat)
+
+	ps.PushWorkers.Add("sub2", &push.MockWorker{
+		SubStatus: "ok",
+		MSender:   ms,
+	})
+
+	s3, e3 := ps.SubscriptionStatus(context.Background(), &amsPb.SubscriptionStatusRequest{FullName: "sub2"})
+
+	suite.Equal(&amsPb.SubscriptionStatusResponse{
+		Status: "ok",
+		Destinations: []*amsPb.DestinationStatus{
+			{
+				Destination:       "mock destination",
+				DeliveredMessages: 1,
+			},
+			{
+				Destination:      "mock destination",
+				FailedDeliveries: 1,
+				LastError:        "error while sending",
+			},
+		},
+	}, s3)
+
+	suite.Nil(e3)
+}
+
+// TestListSubscriptions tests the listing of the active subscriptions
+func (suite *ServerTestSuite) TestListSubscriptions() {
+
+	ps := NewPushService(config.NewMockConfig())
+
+	r1, e1 := ps.ListSubscriptions(context.Background(), &amsPb.ListSubscriptionsRequest{})
+	suite.Nil(e1)
+	suite.Equal(&amsPb.ListSubscriptionsResponse{}, r1)
+
+	ps.PushWorkers.Add("sub2", &push.MockWorker{
+		Sub:       amsPb.Subscription{FullName: "sub2", FullTopic: "topic2"},
+		SubStatus: "ok",
+	})
+	ps.PushWorkers.Add("sub1", &push.MockWorker{
+		Sub:       amsPb.Subscription{FullName: "sub1", FullTopic: "topic1"},
+		SubStatus: "not ok",
+	})
+
+	r2, e2 := ps.ListSubscriptions(context.Background(), &amsPb.ListSubscriptionsRequest{})
+	suite.Nil(e2)
+	suite.Equal(&amsPb.ListSubscriptionsResponse{
+		Subscriptions: []*amsPb.ActiveSubscription{
+			{FullName: "sub1", FullTopic: "topic1", Status: "not ok"},
+			{FullName: "sub2", FullTopic: "topic2", Status: "ok"},
+		},
+	}, r2)
+}
+
+// TestToPushConfig tests the mapping of an ams push configuration to a grpc push configuration
+func (suite *ServerTestSuite) TestToPushConfig() {
+
+	pc := ams.PushConfig{
+		Type:                ams.HttpEndpointPushConfig,
+		Pend:                "https://example.com",
+		AuthorizationHeader: ams.AuthorizationHeader{Value: "auth-header-1"},
+		MaxMessages:         3,
+		RetPol:              ams.RetryPolicy{PolicyType: "linear", Period: 300},
+		MessageFormat:       "cloudevents-structured",
+		DeliveryPolicy:      ams.AnySucceedsPolicy,
+		Destinations: []ams.Destination{
+			{
+				Type:              ams.MattermostPushConfig,
+				MattermostUrl:     "https://mattermost.example.com/hooks/1",
+				MattermostChannel: "ops",
+			},
+		},
+	}
+
+	suite.Equal(&amsPb.PushConfig{
+		Type:                amsPb.PushType_HTTP_ENDPOINT,
+		PushEndpoint:        "https://example.com",
+		AuthorizationHeader: "auth-header-1",
+		MaxMessages:         3,
+		RetryPolicy:         &amsPb.RetryPolicy{Type: "linear", Period: 300},
+		MessageFormat:       "cloudevents-structured",
+		DeliveryPolicy:      amsPb.DeliveryPolicy_ANY_SUCCEEDS,
+		Destinations: []*amsPb.Destination{
+			{
+				Type:              amsPb.PushType_MATTERMOST,
+				MattermostUrl:     "https://mattermost.example.com/hooks/1",
+				MattermostChannel: "ops",
+			},
+		},
+	}, toPushConfig(pc))
 }
 
 // TestIsSubActive tests the IsSubActive method of PushService for both true and false cases
@@ -149,7 +528,7 @@ func (suite *ServerTestSuite) TestIsSubActive() {
 
 	ps := NewPushService(config.NewMockConfig())
 
-	ps.PushWorkers["sub1"] = new(push.MockWorker)
+	ps.PushWorkers.Add("sub1", new(push.MockWorker))
 
 	suite.True(ps.IsSubActive("sub1"))
 
@@ -162,14 +541,14 @@ func (suite *ServerTestSuite) TestHandleDeactivateChannel() {
 
 	ps := NewPushService(config.NewMockConfig())
 
-	ps.PushWorkers["sub1"] = new(push.MockWorker)
+	ps.PushWorkers.Add("sub1", new(push.MockWorker))
 
 	ps.deactivateChan <- consumers.CancelableError{
 		ErrMsg:   "cancel",
 		Resource: "sub1",
 	}
 
-	_, found := ps.PushWorkers["sub1"]
+	found := ps.PushWorkers.Has("sub1")
 
 	suite.False(found)
 
@@ -179,10 +558,10 @@ func (suite *ServerTestSuite) TestDeactivateSubscription() {
 
 	ps := NewPushService(config.NewMockConfig())
 	mw := new(push.MockWorker)
-	ps.PushWorkers["sub1"] = mw
+	ps.PushWorkers.Add("sub1", mw)
 
 	e1 := ps.deactivateSubscription("sub1")
-	_, found := ps.PushWorkers["sub1"]
+	found := ps.PushWorkers.Has("sub1")
 
 	// test normal case(delete entry from map, deactivate worker)
 	suite.Equal("stopped", mw.Status())
@@ -204,17 +583,24 @@ func (suite *ServerTestSuite) TestNewPushService() {
 
 	// make sure the map containing the subscriptions is initialised
 	suite.NotNil(ps.PushWorkers)
+
+	// make sure the destination policy is initialised
+	suite.NotNil(ps.DestinationPolicy)
+
+	// the push client refuses to connect to denied addresses
+	_, err := ps.Client.Get("http://127.0.0.1:1/")
+	suite.Contains(err.Error(), "address 127.0.0.1 is not allowed")
 }
 
 func (suite *ServerTestSuite) TestDeactivateSubscriptionRequest() {
 
 	ps := NewPushService(config.NewMockConfig())
 
-	ps.PushWorkers["sub1"] = new(push.MockWorker)
+	ps.PushWorkers.Add("sub1", new(push.MockWorker))
 
 	s, e := ps.DeactivateSubscription(context.Background(), &amsPb.DeactivateSubscriptionRequest{FullName: "sub1"})
 
-	_, ok := ps.PushWorkers["sub1"]
+	ok := ps.PushWorkers.Has("sub1")
 	suite.Equal(&amsPb.DeactivateSubscriptionResponse{Message: "Subscription sub1 deactivated"}, s)
 
 	suite.False(ok)
@@ -234,14 +620,50 @@ func (suite *ServerTestSuite) TestDeactivateSubscriptionRequestNOTFOUND() {
 
 func (suite *ServerTestSuite) TestNewGRPCServer() {
 
-	srv := NewGRPCServer(config.NewMockConfig())
+	cfg := config.NewMockConfig()
+	srv := NewGRPCServer(cfg, NewPushService(cfg))
 
 	suite.IsType(&grpc.Server{}, srv)
 }
 
+// TestShutdown tests that the workers are drained and no subscription can be activated afterwards
+func (suite *ServerTestSuite) TestShutdown() {
+
+	cfg := config.NewMockConfig()
+	ps := NewPushService(cfg)
+	NewGRPCServer(cfg, ps)
+
+	w := &push.MockWorker{Sub: amsPb.Subscription{FullName: "/projects/p1/subscriptions/sub1"}}
+	ps.PushWorkers.Add("/projects/p1/subscriptions/sub1", w)
+
+	suite.Nil(ps.Shutdown(context.Background()))
+	suite.Equal("drained", w.Status())
+
+	hr, err := ps.healthService.Check(context.Background(), &gRPCHealth.HealthCheckRequest{})
+	suite.Nil(err)
+	suite.Equal(gRPCHealth.HealthCheckResponse_NOT_SERVING, hr.Status)
+
+	_, e1 := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+		Subscription: &amsPb.Subscription{
+			FullName: "/projects/p1/subscriptions/sub2",
+			PushConfig: &amsPb.PushConfig{
+				Type:         amsPb.PushType_HTTP_ENDPOINT,
+				PushEndpoint: "https://example.com",
+				RetryPolicy:  &amsPb.RetryPolicy{Type: "linear", Period: 300},
+			},
+		}})
+	suite.Equal(status.Error(codes.Unavailable, ShuttingDown), e1)
+	suite.False(ps.IsSubActive("/projects/p1/subscriptions/sub2"))
+}
+
 func (suite *ServerTestSuite) TestLoadSubscriptions() {
 
-	ps := NewPushService(config.NewMockConfig())
+	// errorsub can never be retrieved, the service is ready with the rest of them
+	cfg := config.NewMockConfig()
+	cfg.SubscriptionLoading.ReadyFraction = 0.8
+
+	ps := NewPushService(cfg)
+	ps.loadBackoff = backoff{initial: time.Millisecond, max: time.Millisecond}
 	client := &http.Client{
 		Transport: new(ams.MockAmsRoundTripper),
 	}
@@ -249,32 +671,480 @@ func (suite *ServerTestSuite) TestLoadSubscriptions() {
 	amsClient := ams.NewClient("", "", "", 443, client)
 	ps.AmsClient = amsClient
 
-	ps.loadSubscriptions()
+	ps.loadSubscriptions(context.Background())
 
 	// since there was no problem retrieving the ams user, status should be ok
 	suite.Equal("ok", ps.status)
 
 	// normal case, sub1 is push enabled and it should be activated successfully
-	_, sub1Found := ps.PushWorkers["/projects/push1/subscriptions/sub1"]
+	sub1Found := ps.PushWorkers.Has("/projects/push1/subscriptions/sub1")
 	suite.True(sub1Found)
 
 	// normal case, sub4 is push enabled and it should be activated successfully
-	_, sub4Found := ps.PushWorkers["/projects/push2/subscriptions/sub4"]
+	sub4Found := ps.PushWorkers.Has("/projects/push2/subscriptions/sub4")
 	suite.True(sub4Found)
 
 	// normal case, sub5 is mattermost push enabled and it should be activated successfully
-	_, sub5Found := ps.PushWorkers["/projects/push2/subscriptions/sub5"]
+	sub5Found := ps.PushWorkers.Has("/projects/push2/subscriptions/sub5")
 	suite.True(sub5Found)
 
 	// error case, the subscription should not have been activated
-	_, errorSubFound := ps.PushWorkers["/projects/push1/subscriptions/errorsub"]
+	errorSubFound := ps.PushWorkers.Has("/projects/push1/subscriptions/errorsub")
 	suite.False(errorSubFound)
 
 	// the subscription is not push enabled case, the subscription should not have been activated
-	_, sub3SubFound := ps.PushWorkers["/projects/push2/subscriptions/sub3"]
+	sub3SubFound := ps.PushWorkers.Has("/projects/push2/subscriptions/sub3")
 	suite.False(sub3SubFound)
 }
 
+// TestReconcile tests that the active subscriptions are brought in line with the subscriptions in ams
+func (suite *ServerTestSuite) TestReconcile() {
+
+	ps := NewPushService(config.NewMockConfig())
+	client := &http.Client{
+		Transport: new(ams.MockAmsRoundTripper),
+	}
+	ps.Client = client
+	ps.AmsClient = ams.NewClient("", "", "", 443, client)
+
+	// no reconciliation has run yet
+	suite.Nil(ps.reconcileStats.status())
+
+	// the push enabled subscriptions are started, errorsub can't be retrieved
+	r1, err := ps.Reconcile(context.Background())
+	suite.Nil(err)
+	suite.Equal(ReconcileResult{
+		Started: []string{
+			"/projects/push1/subscriptions/sub1",
+			"/projects/push2/subscriptions/sub4",
+			"/projects/push2/subscriptions/sub5",
+		},
+		Failed: []string{"/projects/push1/subscriptions/errorsub"},
+	}, r1)
+
+	// a subscription that no longer exists in ams, one whose push configuration changed
+	// and one whose state in ams is unknown
+	stale := &push.MockWorker{Sub: amsPb.Subscription{FullName: "/projects/push1/subscriptions/stale"}}
+	ps.PushWorkers.Add("/projects/push1/subscriptions/stale", stale)
+
+	sub1, _ := ps.PushWorkers.Remove("/projects/push1/subscriptions/sub1")
+	sub1.Stop()
+	changed := &push.MockWorker{Sub: amsPb.Subscription{
+		FullName:  "/projects/push1/subscriptions/sub1",
+		FullTopic: sub1.Subscription().FullTopic,
+		PushConfig: &amsPb.PushConfig{
+			Type:         amsPb.PushType_HTTP_ENDPOINT,
+			PushEndpoint: "https://old.example.com/receive_here",
+			RetryPolicy:  &amsPb.RetryPolicy{Type: "linear", Period: 300},
+		},
+	}}
+	ps.PushWorkers.Add("/projects/push1/subscriptions/sub1", changed)
+
+	unknown := &push.MockWorker{Sub: amsPb.Subscription{FullName: "/projects/push1/subscriptions/errorsub"}}
+	ps.PushWorkers.Add("/projects/push1/subscriptions/errorsub", unknown)
+
+	r2, err := ps.Reconcile(context.Background())
+	suite.Nil(err)
+	suite.Equal(ReconcileResult{
+		Stopped: []string{"/projects/push1/subscriptions/stale"},
+		Updated: []string{"/projects/push1/subscriptions/sub1"},
+		Failed:  []string{"/projects/push1/subscriptions/errorsub"},
+	}, r2)
+
+	suite.Equal("stopped", stale.Status())
+	suite.False(ps.IsSubActive("/projects/push1/subscriptions/stale"))
+
+	suite.Equal("drained", changed.Status())
+	sub1, _ = ps.PushWorkers.Get("/projects/push1/subscriptions/sub1")
+	suite.Equal("https://example.com:9999", sub1.Subscription().PushConfig.PushEndpoint)
+
+	// the worker of a subscription that couldn't be retrieved is left untouched
+	suite.Equal("", unknown.Status())
+	suite.True(ps.IsSubActive("/projects/push1/subscriptions/errorsub"))
+
+	// the counters add up all the reconciliations
+	rs := ps.reconcileStats.status()
+	suite.Equal(int64(2), rs.Runs)
+	suite.Equal(int64(3), rs.Started)
+	suite.Equal(int64(1), rs.Stopped)
+	suite.Equal(int64(1), rs.Updated)
+	suite.Equal(int64(2), rs.Failed)
+	suite.NotEmpty(rs.LastRun)
+
+	ps.status = "ok"
+	sr, err := ps.Status(context.Background(), &amsPb.StatusRequest{})
+	suite.Nil(err)
+	suite.Equal(rs, sr.Reconcile)
+}
+
+// TestReconcileDrain tests that the in-flight push cycle of a subscription whose push configuration changed
+// completes before the subscription restarts
+func (suite *ServerTestSuite) TestReconcileDrain() {
+
+	ps := NewPushService(config.NewMockConfig())
+	client := &http.Client{
+		Transport: new(ams.MockAmsRoundTripper),
+	}
+	ps.Client = client
+	ps.AmsClient = ams.NewClient("", "", "", 443, client)
+
+	c := &consumers.MockConsumer{SubStatus: "normal_sub", AckStatus: "normal_ack"}
+	w, err := push.New(&amsPb.Subscription{
+		FullName:  "/projects/push1/subscriptions/sub1",
+		FullTopic: "/projects/push1/topics/t1",
+		PushConfig: &amsPb.PushConfig{
+			Type:         amsPb.PushType_HTTP_ENDPOINT,
+			PushEndpoint: "https://old.example.com/receive_here",
+			MaxMessages:  1,
+			RetryPolicy:  &amsPb.RetryPolicy{Type: "linear", Period: 300},
+		},
+	}, c, &senders.MockSender{SendDelay: 300 * time.Millisecond}, nil)
+	suite.Nil(err)
+
+	ps.PushWorkers.Add("/projects/push1/subscriptions/sub1", w)
+	go w.Start()
+	time.Sleep(100 * time.Millisecond)
+
+	r, err := ps.Reconcile(context.Background())
+	suite.Nil(err)
+	suite.Equal([]string{"/projects/push1/subscriptions/sub1"}, r.Updated)
+
+	// the message of the in-flight cycle has been delivered and acknowledged, instead of being canceled
+	suite.Equal([]string{"ackid_0"}, c.AckMessages)
+
+	sub1, _ := ps.PushWorkers.Get("/projects/push1/subscriptions/sub1")
+	suite.Equal("https://example.com:9999", sub1.Subscription().PushConfig.PushEndpoint)
+
+	ps.PushWorkers.Range(func(name string, w push.Worker) {
+		w.Stop()
+	})
+}
+
+// TestConcurrentRPCs drives concurrent activations, deactivations and queries of the same subscriptions,
+// run it with -race to detect unsynchronized access to the push workers
+func (suite *ServerTestSuite) TestConcurrentRPCs() {
+
+	ps := NewPushService(config.NewMockConfig())
+	client := &http.Client{
+		Transport: new(ams.MockAmsRoundTripper),
+	}
+	ps.AmsClient = ams.NewClient("", "", "", 443, client)
+
+	const subs = 10
+	const callers = 8
+
+	activate := func(name string) error {
+		_, err := ps.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+			Subscription: &amsPb.Subscription{
+				FullName:  name,
+				FullTopic: "/projects/push1/topics/t1",
+				PushConfig: &amsPb.PushConfig{
+					Type:         amsPb.PushType_HTTP_ENDPOINT,
+					PushEndpoint: "https://example.com:5000/receive_here",
+					RetryPolicy:  &amsPb.RetryPolicy{Type: "linear", Period: 300},
+				},
+			},
+		})
+		return err
+	}
+
+	var activated, deactivated atomic.Int32
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < subs; i++ {
+
+		name := fmt.Sprintf("/projects/push1/subscriptions/stress%v", i)
+
+		for j := 0; j < callers; j++ {
+
+			wg.Add(3)
+
+			go func() {
+				defer wg.Done()
+				err := activate(name)
+				if err == nil {
+					activated.Add(1)
+					return
+				}
+				suite.Equal(codes.AlreadyExists, status.Code(err))
+			}()
+
+			go func() {
+				defer wg.Done()
+				_, err := ps.ListSubscriptions(context.Background(), &amsPb.ListSubscriptionsRequest{})
+				suite.Nil(err)
+				ps.SubscriptionStatus(context.Background(), &amsPb.SubscriptionStatusRequest{FullName: name})
+			}()
+
+			go func() {
+				defer wg.Done()
+				ps.Status(context.Background(), &amsPb.StatusRequest{})
+				ps.IsSubActive(name)
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	// only one of the concurrent activations of every subscription started a worker
+	suite.Equal(int32(subs), activated.Load())
+	suite.Equal(subs, ps.PushWorkers.Len())
+
+	for i := 0; i < subs; i++ {
+
+		name := fmt.Sprintf("/projects/push1/subscriptions/stress%v", i)
+
+		for j := 0; j < callers; j++ {
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				_, err := ps.DeactivateSubscription(context.Background(), &amsPb.DeactivateSubscriptionRequest{FullName: name})
+				if err == nil {
+					deactivated.Add(1)
+					return
+				}
+				suite.Equal(codes.NotFound, status.Code(err))
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	// only one of the concurrent deactivations of every subscription stopped its worker
+	suite.Equal(int32(subs), deactivated.Load())
+	suite.Equal(0, ps.PushWorkers.Len())
+}
+
+// TestConcurrentGRPCCalls drives interleaved activations, deactivations and queries of the same subscriptions
+// through the grpc api, run it with -race to detect unsynchronized access to the push workers
+func (suite *ServerTestSuite) TestConcurrentGRPCCalls() {
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	// allow pushing to the local receiver
+	cfg := config.NewMockConfig()
+	cfg.DestinationPolicy.DenyCIDRs = []string{}
+
+	ps := NewPushService(cfg)
+	ps.AmsClient = ams.NewClient("", "", "", 443, &http.Client{Transport: new(ams.MockAmsRoundTripper)})
+	// the subscriptions are not loaded
+	ps.setStatus("ok")
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().Nil(err)
+
+	srv := NewGRPCServer(cfg, ps)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	suite.Require().Nil(err)
+	defer conn.Close()
+
+	client := amsPb.NewPushServiceClient(conn)
+
+	const subs = 4
+	const callers = 8
+	const rounds = 20
+
+	var activated, deactivated atomic.Int32
+	wg := sync.WaitGroup{}
+
+	for c := 0; c < callers; c++ {
+
+		wg.Add(1)
+
+		go func(c int) {
+			defer wg.Done()
+
+			for r := 0; r < rounds; r++ {
+
+				name := fmt.Sprintf("/projects/push1/subscriptions/stress%v", (c+r)%subs)
+
+				switch (c + r) % 5 {
+
+				case 0:
+					_, err := client.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+						Subscription: &amsPb.Subscription{
+							FullName:  name,
+							FullTopic: "/projects/push1/topics/t1",
+							PushConfig: &amsPb.PushConfig{
+								Type:         amsPb.PushType_HTTP_ENDPOINT,
+								PushEndpoint: receiver.URL + "/receive_here",
+								RetryPolicy:  &amsPb.RetryPolicy{Type: "linear", Period: 300},
+							},
+						},
+					})
+					if err == nil {
+						activated.Add(1)
+						continue
+					}
+					suite.Equal(codes.AlreadyExists, status.Code(err))
+
+				case 1:
+					_, err := client.DeactivateSubscription(context.Background(), &amsPb.DeactivateSubscriptionRequest{FullName: name})
+					if err == nil {
+						deactivated.Add(1)
+						continue
+					}
+					suite.Equal(codes.NotFound, status.Code(err))
+
+				case 2:
+					_, err := client.ListSubscriptions(context.Background(), &amsPb.ListSubscriptionsRequest{})
+					suite.Nil(err)
+
+				case 3:
+					_, err := client.SubscriptionStatus(context.Background(), &amsPb.SubscriptionStatusRequest{FullName: name})
+					if err != nil {
+						suite.Equal(codes.NotFound, status.Code(err))
+					}
+
+				case 4:
+					_, err := client.Status(context.Background(), &amsPb.StatusRequest{})
+					suite.Nil(err)
+				}
+			}
+		}(c)
+	}
+
+	wg.Wait()
+
+	suite.NotZero(activated.Load())
+	suite.NotZero(deactivated.Load())
+
+	// every successful activation started a worker that is either still active or got deactivated once
+	suite.Equal(int(activated.Load()-deactivated.Load()), ps.PushWorkers.Len())
+
+	resp, err := client.ListSubscriptions(context.Background(), &amsPb.ListSubscriptionsRequest{})
+	suite.Nil(err)
+	suite.Equal(ps.PushWorkers.Len(), len(resp.Subscriptions))
+	for _, sub := range resp.Subscriptions {
+		suite.True(ps.IsSubActive(sub.FullName))
+	}
+}
+
+// TestSaveState tests that only the state of the registered worker of a subscription gets persisted
+func (suite *ServerTestSuite) TestSaveState() {
+
+	ps := NewPushService(config.NewMockConfig())
+	ps.StateStore = state.NewMemoryStore()
+
+	stored := func() map[string]string {
+		states, err := ps.StateStore.List()
+		suite.Nil(err)
+		m := make(map[string]string)
+		for _, s := range states {
+			m[s.Name()] = s.LastError
+		}
+		return m
+	}
+
+	name := "/projects/p1/subscriptions/sub1"
+	w1 := &push.MockWorker{Sub: amsPb.Subscription{FullName: name}, SubStatus: "w1"}
+
+	suite.Nil(ps.addWorker(name, w1))
+	ps.saveState(w1)
+	suite.Equal(map[string]string{name: "w1"}, stored())
+
+	// the state of a deactivated worker is not written back
+	suite.Nil(ps.deactivateSubscription(name))
+	ps.saveState(w1)
+	suite.Empty(stored())
+
+	// nor does it overwrite the state of the worker that replaced it
+	w2 := &push.MockWorker{Sub: amsPb.Subscription{FullName: name}, SubStatus: "w2"}
+	suite.Nil(ps.addWorker(name, w2))
+	ps.saveState(w2)
+	ps.saveState(w1)
+	suite.Equal(map[string]string{name: "w2"}, stored())
+	suite.Nil(ps.deactivateSubscription(name))
+
+	// activations racing with deactivations leave no stale state behind
+	for round := 0; round < 50; round++ {
+
+		w := &push.MockWorker{Sub: amsPb.Subscription{FullName: name}, SubStatus: "ok"}
+
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if ps.addWorker(name, w) == nil {
+				ps.saveState(w)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			ps.deactivateSubscription(name)
+		}()
+		wg.Wait()
+
+		_, found := stored()[name]
+		suite.Equal(ps.PushWorkers.Has(name), found)
+
+		ps.deactivateSubscription(name)
+	}
+}
+
+// TestRestoreWorkers tests that the workers active when the service stopped are restored along with their state
+func (suite *ServerTestSuite) TestRestoreWorkers() {
+
+	cfg := config.NewMockConfig()
+	cfg.StateFile = filepath.Join(suite.T().TempDir(), "state.db")
+
+	client := &http.Client{
+		Transport: new(ams.MockAmsRoundTripper),
+	}
+
+	subscription := func(name string) *amsPb.Subscription {
+		return &amsPb.Subscription{
+			FullName:  name,
+			FullTopic: "/projects/push1/topics/t1",
+			PushConfig: &amsPb.PushConfig{
+				Type:         amsPb.PushType_HTTP_ENDPOINT,
+				PushEndpoint: "https://example.com:9999",
+				RetryPolicy:  &amsPb.RetryPolicy{Type: "slowstart"},
+			},
+		}
+	}
+
+	ps1 := NewPushService(cfg)
+	ps1.AmsClient = ams.NewClient("", "", "", 443, client)
+
+	for _, name := range []string{"/projects/push1/subscriptions/sub1", "/projects/push1/subscriptions/sub2"} {
+		_, err := ps1.ActivateSubscription(context.Background(), &amsPb.ActivateSubscriptionRequest{
+			Subscription: subscription(name),
+		})
+		suite.Nil(err)
+	}
+
+	// a deactivated subscription is not restored
+	_, err := ps1.DeactivateSubscription(context.Background(), &amsPb.DeactivateSubscriptionRequest{
+		FullName: "/projects/push1/subscriptions/sub2",
+	})
+	suite.Nil(err)
+
+	suite.Nil(ps1.Shutdown(context.Background()))
+
+	w1, _ := ps1.PushWorkers.Get("/projects/push1/subscriptions/sub1")
+	s1 := w1.State()
+
+	ps2 := NewPushService(cfg)
+	defer ps2.Shutdown(context.Background())
+
+	suite.Equal([]string{"/projects/push1/subscriptions/sub1"}, ps2.PushWorkers.Names())
+
+	w2, _ := ps2.PushWorkers.Get("/projects/push1/subscriptions/sub1")
+	s2 := w2.State()
+	suite.True(proto.Equal(s1.Subscription, s2.Subscription))
+	suite.Equal(s1.RetryInterval, s2.RetryInterval)
+	suite.Equal(s1.Failing, s2.Failing)
+}
+
 func TestServerTestSuite(t *testing.T) {
 	logrus.SetOutput(io.Discard)
 	suite.Run(t, new(ServerTestSuite))
@@ -0,0 +1,175 @@
+package grpc
+
+import (
+	"context"
+	"github.com/ARGOeu/ams-push-server/push"
+	"github.com/ARGOeu/ams-push-server/state"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// restoreWorkers starts the workers whose state was persisted when the service last stopped, whether their
+// subscriptions were loaded from ams or activated through the api, and resumes them from that state
+func (ps *PushService) restoreWorkers() {
+
+	states, err := ps.StateStore.List()
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Error("Could not restore push workers")
+		return
+	}
+
+	for _, s := range states {
+
+		name := s.Name()
+
+		// another instance of the cluster picks the subscription up, or still pushes it and hands it over
+		if !ps.owns(name) || ps.pushedByPreviousOwner(name) {
+			ps.deleteState(name)
+			continue
+		}
+
+		err := ps.restoreWorker(s)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":         "system_log",
+					"subscription": name,
+					"error":        err.Error(),
+				},
+			).Error("Could not restore push worker")
+			ps.deleteState(name)
+			continue
+		}
+
+		log.WithFields(
+			log.Fields{
+				"type":           "system_log",
+				"subscription":   name,
+				"retry_interval": s.RetryInterval.String(),
+				"last_error":     s.LastError,
+			},
+		).Info("Push worker restored")
+	}
+}
+
+// restoreWorker starts the worker of a persisted state, unless its subscription is already active
+func (ps *PushService) restoreWorker(s state.WorkerState) error {
+
+	if s.Subscription.GetPushConfig().GetRetryPolicy() == nil {
+		return errors.Errorf("Invalid state, empty push configuration")
+	}
+
+	// the destination policy might have changed since the subscription was activated
+	err := ps.checkDestinations(context.Background(), s.Subscription)
+	if err != nil {
+		return err
+	}
+
+	w, err := ps.newWorker(s.Subscription)
+	if err != nil {
+		return err
+	}
+
+	w.Restore(s)
+
+	err = ps.addWorker(s.Name(), w)
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	go w.Start()
+
+	return nil
+}
+
+// checkpointLoop persists the state of the workers every interval, until the service shuts down
+func (ps *PushService) checkpointLoop(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+
+		if ps.shuttingDown.Load() {
+			return
+		}
+
+		ps.checkpoint()
+	}
+}
+
+// checkpoint persists the state of every worker
+func (ps *PushService) checkpoint() {
+	ps.PushWorkers.Range(func(name string, w push.Worker) {
+		ps.saveState(w)
+	})
+}
+
+// saveState persists the state of a worker, as long as it is still the registered worker of its subscription.
+// Workers are unregistered before their state is removed, so the state of a worker that got deactivated,
+// handed off or stopped on standby in the meantime is not written back
+func (ps *PushService) saveState(w push.Worker) {
+
+	s := w.State()
+
+	ps.stateMutex.Lock()
+	defer ps.stateMutex.Unlock()
+
+	if registered, found := ps.PushWorkers.Get(s.Name()); !found || registered != w {
+		return
+	}
+
+	err := ps.StateStore.Put(s)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":         "system_log",
+				"subscription": s.Name(),
+				"error":        err.Error(),
+			},
+		).Error("Could not persist the state of the push worker")
+	}
+}
+
+// deleteState removes the persisted state of the worker of a subscription, so that it isn't restored
+func (ps *PushService) deleteState(name string) {
+
+	ps.stateMutex.Lock()
+	defer ps.stateMutex.Unlock()
+
+	err := ps.StateStore.Delete(name)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":         "system_log",
+				"subscription": name,
+				"error":        err.Error(),
+			},
+		).Error("Could not remove the state of the push worker")
+	}
+}
+
+// closeStateStore closes the state store once the workers have stopped
+func (ps *PushService) closeStateStore() {
+
+	err := ps.StateStore.Close()
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Error("Could not close the state store")
+	}
+}
@@ -0,0 +1,57 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/ARGOeu/ams-push-server/acl"
+	"github.com/ARGOeu/ams-push-server/config"
+)
+
+// TokenAuthProvider authenticates callers through static bearer tokens
+type TokenAuthProvider struct {
+	getTokens func() []config.AuthToken
+}
+
+// NewTokenAuthProvider initialises and returns a new static token auth provider,
+// the tokens are retrieved on every call so that they can change while the service is running
+func NewTokenAuthProvider(getTokens func() []config.AuthToken) *TokenAuthProvider {
+	return &TokenAuthProvider{
+		getTokens: getTokens,
+	}
+}
+
+// Authenticate returns the identity that the bearer token of the call grants
+func (p *TokenAuthProvider) Authenticate(ctx context.Context) (Identity, error) {
+
+	token := bearerToken(ctx)
+	if token == "" {
+		return Identity{}, fmt.Errorf("%w, no bearer token provided", ErrNoCredentials)
+	}
+
+	// compare digests of equal length in constant time, so that the comparison reveals nothing about the tokens
+	digest := sha256.Sum256([]byte(token))
+
+	for _, t := range p.getTokens() {
+
+		expected := sha256.Sum256([]byte(t.Token))
+		if subtle.ConstantTimeCompare(digest[:], expected[:]) != 1 {
+			continue
+		}
+
+		role, err := acl.ParseRole(t.Role)
+		if err != nil {
+			return Identity{}, err
+		}
+
+		return Identity{
+			Name:     t.Name,
+			Provider: config.TokenAuthProvider,
+			Role:     role,
+		}, nil
+	}
+
+	return Identity{}, errors.New("bearer token didn't match any auth token")
+}
@@ -0,0 +1,59 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"github.com/ARGOeu/ams-push-server/acl"
+	"github.com/ARGOeu/ams-push-server/config"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+type TokenAuthTestSuite struct {
+	suite.Suite
+}
+
+// TestAuthenticate tests the authentication of callers through static bearer tokens
+func (suite *TokenAuthTestSuite) TestAuthenticate() {
+
+	tokens := []config.AuthToken{
+		{Name: "ams-api", Token: "admin-token", Role: "admin"},
+		{Name: "monitoring", Token: "monitoring-token", Role: "read_only"},
+	}
+
+	p := NewTokenAuthProvider(func() []config.AuthToken { return tokens })
+
+	tokenCtx := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	id1, e1 := p.Authenticate(tokenCtx("admin-token"))
+	suite.Nil(e1)
+	suite.Equal(Identity{Name: "ams-api", Provider: "token", Role: acl.AdminRole}, id1)
+
+	id2, e2 := p.Authenticate(tokenCtx("monitoring-token"))
+	suite.Nil(e2)
+	suite.Equal(Identity{Name: "monitoring", Provider: "token", Role: acl.ReadOnlyRole}, id2)
+
+	_, e3 := p.Authenticate(tokenCtx("admin"))
+	suite.Equal("bearer token didn't match any auth token", e3.Error())
+
+	// no bearer token in the call
+	_, e4 := p.Authenticate(context.Background())
+	suite.True(errors.Is(e4, ErrNoCredentials))
+
+	// the tokens are swapped while the provider is in use
+	tokens = []config.AuthToken{{Name: "ams-api", Token: "rotated-token", Role: "admin"}}
+
+	_, e5 := p.Authenticate(tokenCtx("admin-token"))
+	suite.NotNil(e5)
+
+	id6, e6 := p.Authenticate(tokenCtx("rotated-token"))
+	suite.Nil(e6)
+	suite.Equal("ams-api", id6.Name)
+}
+
+func TestTokenAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(TokenAuthTestSuite))
+}
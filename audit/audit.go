@@ -0,0 +1,259 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/redact"
+	"github.com/pkg/errors"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// NoTarget disables the audit log
+	NoTarget = ""
+	// SyslogTarget sends the audit log to the syslog facility
+	SyslogTarget = "syslog"
+)
+
+const (
+	// ResultOK is the result of a call that succeeded
+	ResultOK = "OK"
+	// AutoDeactivation is the action recorded when the service deactivates a malfunctioning subscription on its own
+	AutoDeactivation = "AutoDeactivation"
+	// HandOff is the action recorded when the service hands a subscription over to the instance of the cluster that owns it
+	HandOff = "HandOff"
+)
+
+// Entry is a single record of the audit log
+type Entry struct {
+	// when the action took place, in RFC3339 format
+	Time string `json:"time"`
+	// who performed the action, the DN of its certificate, the name of its static token or the subject of its jwt
+	Identity string `json:"identity"`
+	// how the identity was authenticated
+	Provider string `json:"provider,omitempty"`
+	// the rpc that was called, or AutoDeactivation
+	Action string `json:"action"`
+	// the subscription that the action refers to
+	Subscription string `json:"subscription"`
+	// the masked push configuration of the subscription before the action
+	PreviousPushConfig json.RawMessage `json:"previous_push_config,omitempty"`
+	// the masked push configuration of the subscription after the action
+	PushConfig json.RawMessage `json:"push_config,omitempty"`
+	// OK or the grpc code of the failed call
+	Result string `json:"result"`
+	// why the call failed or why the subscription got deactivated
+	Error string `json:"error,omitempty"`
+	// hash of the previous entry, empty for the first entry of the log
+	PrevHash string `json:"prev_hash"`
+	// hash of the entry itself, including the hash of the previous entry
+	Hash string `json:"hash"`
+}
+
+// Logger records administrative actions
+type Logger interface {
+	// Record appends the entry to the audit log
+	Record(entry Entry) error
+}
+
+// New acts as an audit logger factory, the target can be empty to disable the audit log,
+// syslog to send the entries to the syslog facility or the path of a file where the entries are appended
+func New(target string) (Logger, error) {
+
+	switch target {
+
+	case NoTarget:
+		return new(noLogger), nil
+
+	case SyslogTarget:
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTHPRIV, "ams-push-server-audit")
+		if err != nil {
+			return nil, errors.Errorf("Could not connect to syslog, %v", err.Error())
+		}
+		return NewChainedLogger(w, ""), nil
+	}
+
+	// continue the chain of the entries that are already in the file
+	lastHash := ""
+
+	f, err := os.Open(target)
+	if err == nil {
+		lastHash, err = LastHash(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Errorf("Could not read audit log %v, %v", target, err.Error())
+		}
+	}
+
+	f, err = os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Errorf("Could not open audit log %v, %v", target, err.Error())
+	}
+
+	return NewChainedLogger(f, lastHash), nil
+}
+
+// PushConfig returns the masked json representation of a push configuration that is stored in an entry
+func PushConfig(pc *amsPb.PushConfig) json.RawMessage {
+
+	if pc == nil {
+		return nil
+	}
+
+	b, _ := json.Marshal(redact.PushConfig(pc))
+
+	return b
+}
+
+// noLogger discards every entry
+type noLogger struct{}
+
+// Record discards the entry
+func (l *noLogger) Record(entry Entry) error {
+	return nil
+}
+
+// ChainedLogger writes every entry as a json line, chained to the previous one through its hash
+// so that any modification or removal of an entry can be detected
+type ChainedLogger struct {
+	w        io.Writer
+	mutex    sync.Mutex
+	lastHash string
+	now      func() time.Time
+}
+
+// NewChainedLogger initialises and returns a new chained logger, whose first entry is chained to the provided hash
+func NewChainedLogger(w io.Writer, lastHash string) *ChainedLogger {
+	return &ChainedLogger{
+		w:        w,
+		lastHash: lastHash,
+		now:      time.Now,
+	}
+}
+
+// Record chains the entry to the previous one and writes it
+func (l *ChainedLogger) Record(entry Entry) error {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if entry.Time == "" {
+		entry.Time = l.now().UTC().Format(time.RFC3339Nano)
+	}
+
+	entry.PrevHash = l.lastHash
+	entry.Hash = ""
+
+	hash, err := hashEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	entry.Hash = hash
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.w.Write(append(b, '\n'))
+	if err != nil {
+		return err
+	}
+
+	l.lastHash = hash
+
+	return nil
+}
+
+// hashEntry returns the hex encoded sha256 hash of the entry's json representation, without its own hash
+func hashEntry(entry Entry) (string, error) {
+
+	entry.Hash = ""
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Verify reads an audit log and checks that every entry is intact and chained to the one before it.
+// The first entry may be chained to any hash, so that a log that has been rotated can still be verified
+func Verify(r io.Reader) error {
+
+	prevHash := ""
+	line := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		line++
+
+		entry := Entry{}
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return errors.Errorf("entry %v is malformed, %v", line, err.Error())
+		}
+
+		if line > 1 && entry.PrevHash != prevHash {
+			return errors.Errorf("entry %v is not chained to the previous entry", line)
+		}
+
+		hash, err := hashEntry(entry)
+		if err != nil {
+			return err
+		}
+
+		if hash != entry.Hash {
+			return errors.Errorf("entry %v has been modified", line)
+		}
+
+		prevHash = entry.Hash
+	}
+
+	return scanner.Err()
+}
+
+// LastHash returns the hash of the last entry of an audit log, or an empty hash if the log is empty
+func LastHash(r io.Reader) (string, error) {
+
+	lastHash := ""
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		entry := Entry{}
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return "", err
+		}
+
+		lastHash = entry.Hash
+	}
+
+	return lastHash, scanner.Err()
+}
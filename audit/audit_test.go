@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+}
+
+// TestNew tests that the audit logger factory behaves properly
+func (suite *AuditTestSuite) TestNew() {
+
+	l1, e1 := New("")
+	suite.Nil(e1)
+	suite.IsType(&noLogger{}, l1)
+	suite.Nil(l1.Record(Entry{}))
+
+	path := filepath.Join(suite.T().TempDir(), "audit.log")
+
+	l2, e2 := New(path)
+	suite.Nil(e2)
+	suite.IsType(&ChainedLogger{}, l2)
+	suite.Nil(l2.Record(Entry{Action: "ActivateSubscription", Subscription: "sub1", Result: ResultOK}))
+
+	// the chain continues when the log is opened again
+	l3, e3 := New(path)
+	suite.Nil(e3)
+	suite.Nil(l3.Record(Entry{Action: "DeactivateSubscription", Subscription: "sub1", Result: ResultOK}))
+
+	b, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	suite.Equal(2, len(lines))
+
+	first, second := Entry{}, Entry{}
+	json.Unmarshal([]byte(lines[0]), &first)
+	json.Unmarshal([]byte(lines[1]), &second)
+	suite.Equal("", first.PrevHash)
+	suite.Equal(first.Hash, second.PrevHash)
+	suite.Nil(Verify(bytes.NewReader(b)))
+
+	// the log is not a valid audit log
+	suite.Nil(os.WriteFile(path, []byte("not json\n"), 0600))
+	_, e4 := New(path)
+	suite.Contains(e4.Error(), "Could not read audit log "+path)
+
+	_, e5 := New(filepath.Join(suite.T().TempDir(), "missing", "audit.log"))
+	suite.Contains(e5.Error(), "Could not open audit log")
+}
+
+// TestRecord tests that the entries are chained through their hashes
+func (suite *AuditTestSuite) TestRecord() {
+
+	buf := new(bytes.Buffer)
+	l := NewChainedLogger(buf, "previous")
+	l.now = func() time.Time {
+		return time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	}
+
+	suite.Nil(l.Record(Entry{
+		Identity:     "CN=admin",
+		Provider:     "mtls",
+		Action:       "ActivateSubscription",
+		Subscription: "sub1",
+		PushConfig: PushConfig(&amsPb.PushConfig{
+			PushEndpoint:        "https://example.com/receive_here?token=abc",
+			AuthorizationHeader: "auth-header",
+		}),
+		Result: ResultOK,
+	}))
+
+	entry := Entry{}
+	suite.Nil(json.Unmarshal(buf.Bytes(), &entry))
+
+	suite.Equal("2024-01-02T10:00:00Z", entry.Time)
+	suite.Equal("previous", entry.PrevHash)
+	suite.Equal(64, len(entry.Hash))
+	suite.Equal(entry.Hash, l.lastHash)
+
+	// secrets of the push configuration are masked
+	suite.Contains(string(entry.PushConfig), `"push_endpoint":"https://example.com/receive_here?token=REDACTED"`)
+	suite.Contains(string(entry.PushConfig), `"authorization_header":"REDACTED"`)
+	suite.NotContains(buf.String(), "auth-header")
+}
+
+// TestVerify tests the detection of modified, removed and reordered entries
+func (suite *AuditTestSuite) TestVerify() {
+
+	buf := new(bytes.Buffer)
+	l := NewChainedLogger(buf, "")
+
+	for _, sub := range []string{"sub1", "sub2", "sub3"} {
+		suite.Nil(l.Record(Entry{Action: "DeactivateSubscription", Subscription: sub, Result: ResultOK}))
+	}
+
+	suite.Nil(Verify(bytes.NewReader(buf.Bytes())))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	// modified entry
+	modified := strings.Replace(buf.String(), `"subscription":"sub2"`, `"subscription":"sub4"`, 1)
+	suite.Equal("entry 2 has been modified", Verify(strings.NewReader(modified)).Error())
+
+	// removed entry
+	removed := lines[0] + "\n" + lines[2] + "\n"
+	suite.Equal("entry 2 is not chained to the previous entry", Verify(strings.NewReader(removed)).Error())
+
+	// a rotated log can still be verified
+	rotated := lines[1] + "\n" + lines[2] + "\n"
+	suite.Nil(Verify(strings.NewReader(rotated)))
+
+	suite.Equal("entry 1 is malformed, invalid character 'x' looking for beginning of value", Verify(strings.NewReader("x\n")).Error())
+
+	h, err := LastHash(bytes.NewReader(buf.Bytes()))
+	suite.Nil(err)
+	suite.Equal(l.lastHash, h)
+}
+
+func TestAuditTestSuite(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"github.com/pkg/errors"
+	"sync"
+)
+
+type MockLogger struct {
+	// entries that have been recorded
+	Entries []Entry
+	// whether or not recording should fail
+	Fail  bool
+	mutex sync.Mutex
+}
+
+func (l *MockLogger) Record(entry Entry) error {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.Fail {
+		return errors.New("could not record entry")
+	}
+
+	l.Entries = append(l.Entries, entry)
+
+	return nil
+}
+
+// Recorded returns a copy of the entries that have been recorded
+func (l *MockLogger) Recorded() []Entry {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entries := make([]Entry, len(l.Entries))
+	copy(entries, l.Entries)
+
+	return entries
+}
@@ -0,0 +1,330 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+)
+
+// Peer is an instance of the cluster
+type Peer struct {
+	// name that identifies the instance on the hash ring
+	Name string
+	// address where the instance can be reached
+	Address string
+}
+
+// ProbeFunc checks whether or not a peer is live
+type ProbeFunc func(ctx context.Context, p Peer) error
+
+// Cluster keeps track of the live instances of the cluster and decides which one of them owns every key.
+// Only the live instances are placed on the hash ring, so when an instance goes down its keys move to the rest,
+// and they move back once it is live again. It is safe to be used by multiple goroutines at the same time
+type Cluster struct {
+	mutex        sync.RWMutex
+	self         string
+	peers        map[string]Peer
+	down         map[string]bool
+	virtualNodes int
+	ring         *Ring
+	// functions to be called after every change of the owners
+	listeners []func()
+}
+
+// New returns a cluster of the provided peers, all of them considered live until they are probed.
+// Self is the name of the current instance, which is always live
+func New(self string, peers []Peer, virtualNodes int) *Cluster {
+
+	c := &Cluster{
+		down: make(map[string]bool),
+	}
+
+	c.set(self, peers, virtualNodes)
+
+	return c
+}
+
+// set replaces the peers of the cluster and rebuilds the ring out of the live ones
+func (c *Cluster) set(self string, peers []Peer, virtualNodes int) {
+
+	c.self = self
+	c.virtualNodes = virtualNodes
+	c.peers = make(map[string]Peer)
+
+	for _, p := range peers {
+		c.peers[p.Name] = p
+	}
+
+	// forget the peers that are no longer part of the cluster
+	for name := range c.down {
+		if _, found := c.peers[name]; !found || name == self {
+			delete(c.down, name)
+		}
+	}
+
+	c.rebuild()
+}
+
+// rebuild places the live peers on the ring
+func (c *Cluster) rebuild() {
+
+	live := make([]string, 0, len(c.peers))
+	for name := range c.peers {
+		if !c.down[name] {
+			live = append(live, name)
+		}
+	}
+
+	c.ring = NewRing(live, c.virtualNodes)
+}
+
+// Self returns the name of the current instance
+func (c *Cluster) Self() string {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.self
+}
+
+// Owner returns the live peer that owns the provided key
+func (c *Cluster) Owner(key string) Peer {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.peers[c.ring.Owner(key)]
+}
+
+// Owns returns whether or not the current instance owns the provided key
+func (c *Cluster) Owns(key string) bool {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.ring.Owner(key) == c.self
+}
+
+// PreviousOwner returns the live peer that would own the provided key without the current instance,
+// and false if there is no other live peer
+func (c *Cluster) PreviousOwner(key string) (Peer, bool) {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	p, found := c.peers[c.ring.Successor(key, c.self)]
+
+	return p, found
+}
+
+// Peers returns every peer of the cluster, sorted by name
+func (c *Cluster) Peers() []Peer {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	peers := make([]Peer, 0, len(c.peers))
+	for _, p := range c.peers {
+		peers = append(peers, p)
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Name < peers[j].Name
+	})
+
+	return peers
+}
+
+// Live returns the names of the live peers and the names of the peers that are down, both sorted
+func (c *Cluster) Live() ([]string, []string) {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	live := make([]string, 0, len(c.peers))
+	down := make([]string, 0, len(c.down))
+
+	for name := range c.peers {
+		if c.down[name] {
+			down = append(down, name)
+			continue
+		}
+		live = append(live, name)
+	}
+
+	sort.Strings(live)
+	sort.Strings(down)
+
+	return live, down
+}
+
+// OnChange registers a function to be called after every change of the owners, either because the peers
+// of the cluster changed or because a peer went down or came back
+func (c *Cluster) OnChange(f func()) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.listeners = append(c.listeners, f)
+}
+
+// Update replaces the peers of the cluster, e.g. after the configuration has been reloaded.
+// It returns whether or not the owners changed
+func (c *Cluster) Update(self string, peers []Peer, virtualNodes int) bool {
+
+	c.mutex.Lock()
+
+	prev := c.snapshot()
+	c.set(self, peers, virtualNodes)
+	changed := prev != c.snapshot()
+
+	c.mutex.Unlock()
+
+	if changed {
+		c.notify()
+	}
+
+	return changed
+}
+
+// SetLive records whether or not a peer is live. The current instance, as well as peers that are not part
+// of the cluster, are ignored. It returns whether or not the owners changed
+func (c *Cluster) SetLive(name string, live bool) bool {
+	return c.setLive(map[string]bool{name: live})
+}
+
+// setLive records whether or not each of the provided peers is live and rebuilds the ring once for all of them
+func (c *Cluster) setLive(states map[string]bool) bool {
+
+	c.mutex.Lock()
+
+	changed := make([]string, 0)
+
+	for name, live := range states {
+
+		if _, found := c.peers[name]; !found || name == c.self || c.down[name] == !live {
+			continue
+		}
+
+		if live {
+			delete(c.down, name)
+		} else {
+			c.down[name] = true
+		}
+
+		changed = append(changed, name)
+	}
+
+	if len(changed) > 0 {
+		c.rebuild()
+	}
+
+	c.mutex.Unlock()
+
+	if len(changed) == 0 {
+		return false
+	}
+
+	sort.Strings(changed)
+
+	for _, name := range changed {
+		log.WithFields(
+			log.Fields{
+				"type": "system_log",
+				"peer": name,
+				"live": states[name],
+			},
+		).Info("Cluster membership changed")
+	}
+
+	c.notify()
+
+	return true
+}
+
+// snapshot summarises the ring, so that two rings with the same peers and virtual nodes compare as equal
+func (c *Cluster) snapshot() string {
+
+	live := make([]string, 0, len(c.peers))
+	for name, p := range c.peers {
+		if !c.down[name] {
+			live = append(live, name+"="+p.Address)
+		}
+	}
+
+	sort.Strings(live)
+
+	return fmt.Sprintf("%v %v %v", c.self, c.virtualNodes, live)
+}
+
+// notify calls the registered functions
+func (c *Cluster) notify() {
+
+	c.mutex.RLock()
+	listeners := make([]func(), len(c.listeners))
+	copy(listeners, c.listeners)
+	c.mutex.RUnlock()
+
+	for _, f := range listeners {
+		f()
+	}
+}
+
+// ProbeAll probes every other peer once, in parallel, and records whether or not it is live.
+// It returns whether or not the owners changed
+func (c *Cluster) ProbeAll(ctx context.Context, probe ProbeFunc) bool {
+
+	self := c.Self()
+
+	type result struct {
+		name string
+		err  error
+	}
+
+	peers := c.Peers()
+	results := make(chan result, len(peers))
+
+	for _, p := range peers {
+
+		if p.Name == self {
+			results <- result{name: p.Name}
+			continue
+		}
+
+		go func(p Peer) {
+			results <- result{name: p.Name, err: probe(ctx, p)}
+		}(p)
+	}
+
+	states := make(map[string]bool)
+
+	for range peers {
+
+		r := <-results
+
+		if r.err != nil && !c.isDown(r.name) {
+			log.WithFields(
+				log.Fields{
+					"type":  "system_log",
+					"peer":  r.name,
+					"error": r.err.Error(),
+				},
+			).Warning("Cluster peer is down")
+		}
+
+		states[r.name] = r.err == nil
+	}
+
+	return c.setLive(states)
+}
+
+// isDown returns whether or not a peer is currently considered down
+func (c *Cluster) isDown(name string) bool {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.down[name]
+}
@@ -0,0 +1,181 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+type ClusterTestSuite struct {
+	suite.Suite
+}
+
+var testPeers = []Peer{
+	{Name: "push-1", Address: "push-1.example.com:5555"},
+	{Name: "push-2", Address: "push-2.example.com:5555"},
+	{Name: "push-3", Address: "push-3.example.com:5555"},
+}
+
+// TestOwner tests that every instance of the cluster agrees on the owner of every key
+func (suite *ClusterTestSuite) TestOwner() {
+
+	c1 := New("push-1", testPeers, 128)
+	c2 := New("push-2", testPeers, 128)
+
+	suite.Equal("push-1", c1.Self())
+	suite.Equal(testPeers, c1.Peers())
+
+	owned := 0
+
+	for _, k := range keys(300) {
+
+		suite.Equal(c1.Owner(k), c2.Owner(k))
+		suite.Equal(c1.Owner(k).Name == "push-1", c1.Owns(k))
+		suite.Equal(c2.Owner(k).Name == "push-2", c2.Owns(k))
+		suite.False(c1.Owns(k) && c2.Owns(k))
+
+		if c1.Owns(k) {
+			owned++
+			suite.Equal("push-1.example.com:5555", c1.Owner(k).Address)
+		}
+	}
+
+	suite.True(owned > 0)
+}
+
+// TestPreviousOwner tests that the previous owner of a key is the live peer that would own it without the current instance
+func (suite *ClusterTestSuite) TestPreviousOwner() {
+
+	c1 := New("push-1", testPeers, 128)
+	c2 := New("push-2", testPeers[1:], 128)
+
+	for _, k := range keys(300) {
+		p, found := c1.PreviousOwner(k)
+		suite.True(found)
+		suite.Equal(c2.Owner(k), p)
+	}
+
+	// without any other live peer there is no previous owner
+	c3 := New("push-1", testPeers[:1], 128)
+	_, found := c3.PreviousOwner("/projects/p1/subscriptions/sub1")
+	suite.False(found)
+}
+
+// TestSetLive tests that the keys of a peer that goes down move to the live peers and move back once it is live again
+func (suite *ClusterTestSuite) TestSetLive() {
+
+	c := New("push-1", testPeers, 128)
+
+	changes := 0
+	c.OnChange(func() {
+		changes++
+	})
+
+	before := make(map[string]string)
+	for _, k := range keys(300) {
+		before[k] = c.Owner(k).Name
+	}
+
+	suite.True(c.SetLive("push-2", false))
+	// nothing changes when the peer is already down
+	suite.False(c.SetLive("push-2", false))
+	// the current instance and unknown peers can't go down
+	suite.False(c.SetLive("push-1", false))
+	suite.False(c.SetLive("push-4", false))
+	suite.Equal(1, changes)
+
+	live, down := c.Live()
+	suite.Equal([]string{"push-1", "push-3"}, live)
+	suite.Equal([]string{"push-2"}, down)
+
+	for _, k := range keys(300) {
+		suite.NotEqual("push-2", c.Owner(k).Name)
+		if before[k] != "push-2" {
+			suite.Equal(before[k], c.Owner(k).Name)
+		}
+	}
+
+	suite.True(c.SetLive("push-2", true))
+	suite.Equal(2, changes)
+
+	for _, k := range keys(300) {
+		suite.Equal(before[k], c.Owner(k).Name)
+	}
+}
+
+// TestUpdate tests replacing the peers of the cluster
+func (suite *ClusterTestSuite) TestUpdate() {
+
+	c := New("push-1", testPeers, 128)
+	c.SetLive("push-3", false)
+
+	changes := 0
+	c.OnChange(func() {
+		changes++
+	})
+
+	// the same peers don't change the owners
+	suite.False(c.Update("push-1", testPeers, 128))
+	suite.Equal(0, changes)
+
+	// a peer that was down and left the cluster doesn't change the owners, but it is forgotten
+	suite.False(c.Update("push-1", testPeers[:2], 128))
+	suite.Equal(0, changes)
+
+	live, down := c.Live()
+	suite.Equal([]string{"push-1", "push-2"}, live)
+	suite.Empty(down)
+
+	// a new address of a peer is a change
+	suite.True(c.Update("push-1", []Peer{testPeers[0], {Name: "push-2", Address: "push-2.example.com:6666"}}, 128))
+	suite.Equal(1, changes)
+	suite.Equal("push-2.example.com:6666", c.Peers()[1].Address)
+
+	// a live peer that left the cluster is a change
+	suite.True(c.Update("push-1", testPeers[:1], 128))
+	suite.Equal(2, changes)
+}
+
+// TestProbeAll tests that the probes of the peers decide whether or not they are live, the current instance is never probed
+func (suite *ClusterTestSuite) TestProbeAll() {
+
+	c := New("push-1", testPeers, 128)
+
+	changes := 0
+	c.OnChange(func() {
+		changes++
+	})
+
+	var probes atomic.Int32
+
+	probe := func(ctx context.Context, p Peer) error {
+		probes.Add(1)
+		if p.Name == "push-1" {
+			suite.Fail("the current instance should not be probed")
+		}
+		if p.Name == "push-3" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	suite.True(c.ProbeAll(context.Background(), probe))
+	suite.Equal(int32(2), probes.Load())
+	// a single notification for all the peers of a round
+	suite.Equal(1, changes)
+
+	_, down := c.Live()
+	suite.Equal([]string{"push-3"}, down)
+
+	suite.False(c.ProbeAll(context.Background(), probe))
+	suite.Equal(1, changes)
+}
+
+func TestClusterTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(ClusterTestSuite))
+}
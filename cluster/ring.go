@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Ring assigns keys to nodes through consistent hashing. Every node is placed on the ring at a number of points,
+// its virtual nodes, and a key belongs to the node of the first point that follows the hash of the key.
+// When a node joins or leaves, only the keys between its points and the points that precede them change owner
+type Ring struct {
+	points []uint32
+	owners map[uint32]string
+}
+
+// NewRing places the provided nodes on a ring, every one of them at virtualNodes points.
+// Nodes with the same names build the same ring, regardless of their order
+func NewRing(nodes []string, virtualNodes int) *Ring {
+
+	r := &Ring{
+		points: make([]uint32, 0, len(nodes)*virtualNodes),
+		owners: make(map[uint32]string),
+	}
+
+	sorted := append([]string(nil), nodes...)
+	sort.Strings(sorted)
+
+	for _, node := range sorted {
+		for i := 0; i < virtualNodes; i++ {
+			p := hash(fmt.Sprintf("%v#%v", node, i))
+			// on the rare collision the point stays with the node that sorts first, on every instance
+			if _, found := r.owners[p]; found {
+				continue
+			}
+			r.owners[p] = node
+			r.points = append(r.points, p)
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i] < r.points[j]
+	})
+
+	return r
+}
+
+// Owner returns the node that the provided key belongs to, or an empty string if the ring has no nodes
+func (r *Ring) Owner(key string) string {
+	return r.Successor(key, "")
+}
+
+// Successor returns the node that the provided key would belong to without the skipped node,
+// or an empty string if the ring has no other nodes
+func (r *Ring) Successor(key string, skip string) string {
+
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	h := hash(key)
+
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= h
+	})
+
+	// past the last point the ring wraps around to the first one
+	for n := 0; n < len(r.points); n++ {
+		if owner := r.owners[r.points[(i+n)%len(r.points)]]; owner != skip {
+			return owner
+		}
+	}
+
+	return ""
+}
+
+// hash places a key on the ring, md5 spreads keys that only differ slightly, like the virtual nodes, evenly
+func hash(key string) uint32 {
+	sum := md5.Sum([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"testing"
+)
+
+type RingTestSuite struct {
+	suite.Suite
+}
+
+// keys returns the full names of n subscriptions
+func keys(n int) []string {
+
+	k := make([]string, n)
+	for i := range k {
+		k[i] = fmt.Sprintf("/projects/p%v/subscriptions/sub%v", i%7, i)
+	}
+
+	return k
+}
+
+// TestOwner tests that the keys are spread over all the nodes and that every ring of the same nodes agrees on their owners
+func (suite *RingTestSuite) TestOwner() {
+
+	r1 := NewRing([]string{"push-1", "push-2", "push-3"}, 128)
+	r2 := NewRing([]string{"push-3", "push-1", "push-2"}, 128)
+
+	counts := make(map[string]int)
+
+	for _, k := range keys(3000) {
+		suite.Equal(r1.Owner(k), r2.Owner(k))
+		counts[r1.Owner(k)]++
+	}
+
+	suite.Equal(3, len(counts))
+	for node, n := range counts {
+		suite.True(n > 750, "node %v owns only %v of the keys", node, n)
+	}
+
+	// an empty ring has no owners
+	suite.Equal("", NewRing(nil, 128).Owner("/projects/p1/subscriptions/sub1"))
+
+	// a single node owns everything
+	suite.Equal("push-1", NewRing([]string{"push-1"}, 128).Owner("/projects/p1/subscriptions/sub1"))
+}
+
+// TestRemoveNode tests that only the keys of a node that leaves the ring change owner
+func (suite *RingTestSuite) TestRemoveNode() {
+
+	r1 := NewRing([]string{"push-1", "push-2", "push-3"}, 128)
+	r2 := NewRing([]string{"push-1", "push-2"}, 128)
+
+	for _, k := range keys(3000) {
+		if r1.Owner(k) != "push-3" {
+			suite.Equal(r1.Owner(k), r2.Owner(k))
+		}
+	}
+}
+
+// TestSuccessor tests that the successor of a key is its owner on the ring without the skipped node
+func (suite *RingTestSuite) TestSuccessor() {
+
+	r1 := NewRing([]string{"push-1", "push-2", "push-3"}, 128)
+	r2 := NewRing([]string{"push-1", "push-2"}, 128)
+
+	for _, k := range keys(3000) {
+		suite.Equal(r1.Owner(k), r1.Successor(k, ""))
+		suite.Equal(r2.Owner(k), r1.Successor(k, "push-3"))
+	}
+
+	// a ring of only the skipped node has no successors
+	suite.Equal("", NewRing([]string{"push-1"}, 128).Successor("/projects/p1/subscriptions/sub1", "push-1"))
+}
+
+func TestRingTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(RingTestSuite))
+}
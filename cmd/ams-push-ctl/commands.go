@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	amsgRPC "github.com/ARGOeu/ams-push-server/api/v1/grpc"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/config"
+	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// commandFlags returns the flag set of a command, with a usage message that describes its arguments
+func commandFlags(name string, arguments string) *flag.FlagSet {
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ams-push-ctl [flags] %v %v\n", name, arguments)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parseArgs parses the arguments of a command and checks that exactly n positional arguments are left
+func parseArgs(fs *flag.FlagSet, args []string, n int) bool {
+
+	if fs.Parse(args) != nil {
+		return false
+	}
+
+	if fs.NArg() != n {
+		fs.Usage()
+		return false
+	}
+
+	return true
+}
+
+// statusCommand prints the status of the push server
+func statusCommand(c *ctl, args []string) int {
+
+	if !parseArgs(commandFlags("status", ""), args, 0) {
+		return 2
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	resp, err := c.client.Status(ctx, &amsPb.StatusRequest{})
+	if err != nil {
+		return fail(err)
+	}
+
+	return c.print(resp, func(t *table) {
+		printStatus(t, resp)
+	})
+}
+
+// subStatusCommand prints the status of the worker of a subscription
+func subStatusCommand(c *ctl, args []string) int {
+
+	fs := commandFlags("sub-status", "<full_name>")
+	if !parseArgs(fs, args, 1) {
+		return 2
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	resp, err := c.client.SubscriptionStatus(ctx, &amsPb.SubscriptionStatusRequest{FullName: fs.Arg(0)})
+	if err != nil {
+		return fail(err)
+	}
+
+	return c.print(resp, func(t *table) {
+		printSubscriptionStatus(t, fs.Arg(0), resp)
+	})
+}
+
+// listCommand prints the active subscriptions of the push server
+func listCommand(c *ctl, args []string) int {
+
+	if !parseArgs(commandFlags("list", ""), args, 0) {
+		return 2
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	resp, err := c.client.ListSubscriptions(ctx, &amsPb.ListSubscriptionsRequest{})
+	if err != nil {
+		return fail(err)
+	}
+
+	return c.print(resp, func(t *table) {
+		printSubscriptions(t, resp)
+	})
+}
+
+// activateCommand activates a subscription, described either in a file or by its configuration in ams
+func activateCommand(c *ctl, args []string) int {
+
+	fs := commandFlags("activate", "-file <path> | -ams <full_name>")
+	file := fs.String("file", "", "Path of the subscription to activate, in json or yaml, - for the standard input.")
+	amsName := fs.String("ams", "", "Full name of a subscription to activate with its push configuration in ams, e.g. /projects/p1/subscriptions/s1.")
+	amsHost := fs.String("ams-host", "", "Host of ams, required with -ams.")
+	amsPort := fs.Int("ams-port", 443, "Port of ams.")
+	amsToken := fs.String("ams-token", "", "Token of an ams user that can read the subscription, it can also be a secret reference(file:,env:).")
+	amsCADir := fs.String("ams-ca-dir", "", "Directory of the .pem CAs that the certificate of ams is verified against, defaults to the system CAs.")
+	verifySSL := fs.Bool("verify-ssl", true, "Verify the certificate of ams.")
+
+	if !parseArgs(fs, args, 0) {
+		return 2
+	}
+
+	if (*file == "") == (*amsName == "") || (*amsName != "" && *amsHost == "") {
+		fs.Usage()
+		return 2
+	}
+
+	var sub *amsPb.Subscription
+	var err error
+
+	if *file != "" {
+		sub, err = readSubscription(*file)
+	} else {
+		sub, err = c.amsSubscription(*amsName, *amsHost, *amsPort, *amsToken, *amsCADir, *verifySSL)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	resp, err := c.client.ActivateSubscription(ctx, &amsPb.ActivateSubscriptionRequest{Subscription: sub})
+	if err != nil {
+		return fail(err)
+	}
+
+	return c.print(resp, func(t *table) {
+		t.row(resp.Message)
+	})
+}
+
+// deactivateCommand deactivates a subscription
+func deactivateCommand(c *ctl, args []string) int {
+
+	fs := commandFlags("deactivate", "<full_name>")
+	if !parseArgs(fs, args, 1) {
+		return 2
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	resp, err := c.client.DeactivateSubscription(ctx, &amsPb.DeactivateSubscriptionRequest{FullName: fs.Arg(0)})
+	if err != nil {
+		return fail(err)
+	}
+
+	return c.print(resp, func(t *table) {
+		t.row(resp.Message)
+	})
+}
+
+// watchCommand runs one of the status, sub-status or list commands every interval, until it gets interrupted.
+// Tables are redrawn on every run, while json is printed one response per line
+func watchCommand(c *ctl, args []string) int {
+
+	fs := commandFlags("watch", "<status|sub-status|list> [args]")
+	interval := fs.Duration("interval", 2*time.Second, "How often the command runs.")
+
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	watchable := map[string]func(c *ctl, args []string) int{
+		"status":     statusCommand,
+		"sub-status": subStatusCommand,
+		"list":       listCommand,
+	}
+
+	command, found := watchable[fs.Arg(0)]
+	if !found || *interval <= 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c.compact = true
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+
+		if c.output == TableOutput {
+			// clear the screen and move to its top
+			fmt.Fprint(c.out, "\033[H\033[2J")
+			fmt.Fprintf(c.out, "Every %v: %v\t%v\n\n", interval.String(), strings.Join(fs.Args(), " "), time.Now().Format(time.RFC3339))
+		}
+
+		// a failed run is reported and the next one is attempted, unless the arguments are wrong
+		if command(c, fs.Args()[1:]) == 2 {
+			return 2
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
+
+// readSubscription reads a subscription from a json or yaml file, or from the standard input if the path is -.
+// The fields are named as in the grpc api, e.g. full_name or fullName, push_config.type HTTP_ENDPOINT or MATTERMOST
+func readSubscription(path string) (*amsPb.Subscription, error) {
+
+	var from io.Reader = os.Stdin
+
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, errors.Errorf("Could not read subscription %v, %v", path, err.Error())
+		}
+		defer f.Close()
+		from = f
+	}
+
+	// json is valid yaml, so both of them are decoded the same way
+	var values interface{}
+	err := yaml.NewDecoder(from).Decode(&values)
+	if err != nil {
+		return nil, errors.Errorf("Could not read subscription %v, %v", path, err.Error())
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil, errors.Errorf("Could not read subscription %v, %v", path, err.Error())
+	}
+
+	sub := new(amsPb.Subscription)
+	err = jsonpb.UnmarshalString(string(b), sub)
+	if err != nil {
+		return nil, errors.Errorf("Invalid subscription %v, %v", path, err.Error())
+	}
+
+	return sub, nil
+}
+
+// amsSubscription retrieves a subscription from ams and maps it to the subscription the push server activates
+func (c *ctl) amsSubscription(name string, host string, port int, token string, casDir string, verifySSL bool) (*amsPb.Subscription, error) {
+
+	token, err := config.ResolveSecret(token)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := config.NewClientTLSConfig("", "", casDir)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.InsecureSkipVerify = !verifySSL
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+			Proxy:           http.ProxyFromEnvironment,
+		},
+		Timeout: c.timeout,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	sub, err := ams.NewClient("https", host, token, port, client).GetSubscription(ctx, name)
+	if err != nil {
+		return nil, errors.Errorf("Could not retrieve subscription %v from ams, %v", name, err.Error())
+	}
+
+	if !sub.IsPushEnabled() {
+		return nil, errors.Errorf("Subscription %v is not push enabled", name)
+	}
+
+	return amsgRPC.ToSubscription(sub), nil
+}
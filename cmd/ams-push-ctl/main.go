@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/config"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	TableOutput = "table"
+	JSONOutput  = "json"
+)
+
+// commands are the subcommands of the client, each one returns the exit code of the client
+var commands = map[string]func(c *ctl, args []string) int{
+	"status":     statusCommand,
+	"sub-status": subStatusCommand,
+	"list":       listCommand,
+	"activate":   activateCommand,
+	"deactivate": deactivateCommand,
+	"watch":      watchCommand,
+}
+
+// ctl holds the connection to the grpc api of a push server and how the responses are printed
+type ctl struct {
+	client  amsPb.PushServiceClient
+	token   string
+	timeout time.Duration
+	output  string
+	// whether json is printed on a single line
+	compact bool
+	out     io.Writer
+}
+
+// context returns the context of a call, that carries the bearer token, if any, and expires after the timeout
+func (c *ctl) context() (context.Context, context.CancelFunc) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+
+	if c.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+	}
+
+	return ctx, cancel
+}
+
+// fail prints the error of a command and returns the exit code of a failed command
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err.Error())
+	return 1
+}
+
+func usage(fs *flag.FlagSet) func() {
+	return func() {
+
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintf(fs.Output(), "Usage: ams-push-ctl [flags] <%v> [args]\n\nFlags:\n", strings.Join(names, "|"))
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nRun ams-push-ctl <command> -h for the arguments of a command.\n")
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run parses the flags, connects to the push server and runs the requested command
+func run(args []string) int {
+
+	fs := flag.NewFlagSet("ams-push-ctl", flag.ContinueOnError)
+	fs.Usage = usage(fs)
+
+	address := fs.String("address", "localhost:9000", "Address(host:port) of the grpc api of the push server.")
+	plaintext := fs.Bool("plaintext", false, "Connect without tls, when the push server runs with tls_enabled false.")
+	certificate := fs.String("cert", "", "Path of the client certificate to present to the push server.")
+	key := fs.String("key", "", "Path of the key of the client certificate.")
+	casDir := fs.String("ca-dir", "", "Directory of the .pem CAs that the certificate of the push server is verified against, defaults to the system CAs.")
+	serverName := fs.String("server-name", "", "Name to verify the certificate of the push server against, defaults to the host of the address.")
+	token := fs.String("token", "", "Bearer token to present to the push server, it can also be a secret reference(file:,env:).")
+	timeout := fs.Duration("timeout", 10*time.Second, "How long a call to the push server can take.")
+	output := fs.String("output", TableOutput, "Output format, table or json.")
+
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	command, found := commands[fs.Arg(0)]
+	if !found {
+		fmt.Fprintf(os.Stderr, "Unknown command %v\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	if *output != TableOutput && *output != JSONOutput {
+		fmt.Fprintf(os.Stderr, "Invalid output %v, it should be table or json\n", *output)
+		return 2
+	}
+
+	// the config helpers log their progress, only their failures matter here
+	log.SetLevel(log.WarnLevel)
+
+	creds := insecure.NewCredentials()
+	if !*plaintext {
+		tlsConfig, err := config.NewClientTLSConfig(*certificate, *key, *casDir)
+		if err != nil {
+			return fail(err)
+		}
+		tlsConfig.ServerName = *serverName
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	bearer, err := config.ResolveSecret(*token)
+	if err != nil {
+		return fail(err)
+	}
+
+	conn, err := grpc.NewClient(*address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fail(err)
+	}
+	defer conn.Close()
+
+	return command(&ctl{
+		client:  amsPb.NewPushServiceClient(conn),
+		token:   bearer,
+		timeout: *timeout,
+		output:  *output,
+		out:     os.Stdout,
+	}, fs.Args()[1:])
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// table aligns the columns of the rows written to it
+type table struct {
+	w *tabwriter.Writer
+}
+
+func newTable(out io.Writer) *table {
+	return &table{w: tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)}
+}
+
+// row writes a row out of its columns
+func (t *table) row(columns ...string) {
+	fmt.Fprintln(t.w, strings.Join(columns, "\t"))
+}
+
+// print prints a response as json, with the field names of the grpc api, or as the table that the provided function fills
+func (c *ctl) print(m proto.Message, fill func(t *table)) int {
+
+	if c.output == JSONOutput {
+
+		marshaler := jsonpb.Marshaler{OrigName: true, EmitDefaults: true}
+		s, err := marshaler.MarshalToString(m)
+		if err != nil {
+			return fail(err)
+		}
+
+		buf := new(bytes.Buffer)
+		if c.compact {
+			err = json.Compact(buf, []byte(s))
+		} else {
+			err = json.Indent(buf, []byte(s), "", "  ")
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		fmt.Fprintln(c.out, buf.String())
+		return 0
+	}
+
+	t := newTable(c.out)
+	fill(t)
+
+	err := t.w.Flush()
+	if err != nil {
+		return fail(err)
+	}
+
+	return 0
+}
+
+// orNone returns the values joined, or - if there are none
+func orNone(values ...string) string {
+
+	joined := strings.Join(values, ",")
+	if joined == "" {
+		return "-"
+	}
+
+	return joined
+}
+
+// printStatus fills the table with the status of the push server, one row for every part of it that is reported
+func printStatus(t *table, resp *amsPb.StatusResponse) {
+
+	if resp.CertificateExpiry != "" {
+		t.row("CERTIFICATE EXPIRY", resp.CertificateExpiry)
+	}
+
+	if l := resp.Load; l != nil {
+		t.row("LOAD", fmt.Sprintf("loaded %v/%v, retrying %v, failed %v, ready %v, done %v",
+			l.Loaded, l.Total, l.Retrying, l.Failed, l.Ready, l.Done))
+	}
+
+	if r := resp.Reconcile; r != nil {
+		t.row("RECONCILE", fmt.Sprintf("last run %v, runs %v, started %v, stopped %v, updated %v, failed %v",
+			orNone(r.LastRun), r.Runs, r.Started, r.Stopped, r.Updated, r.Failed))
+	}
+
+	if cl := resp.Cluster; cl != nil {
+		t.row("CLUSTER", fmt.Sprintf("self %v, live %v, down %v",
+			cl.Self, orNone(cl.LivePeers...), orNone(cl.DownPeers...)))
+	}
+
+	if e := resp.Election; e != nil {
+		t.row("ELECTION", fmt.Sprintf("role %v, identity %v, holder %v", e.Role, e.Identity, orNone(e.Holder)))
+	}
+}
+
+// printSubscriptionStatus fills the table with the status of a worker, followed by the deliveries to each of its destinations
+func printSubscriptionStatus(t *table, name string, resp *amsPb.SubscriptionStatusResponse) {
+
+	t.row("SUBSCRIPTION", name)
+	t.row("STATUS", resp.Status)
+
+	if len(resp.Destinations) == 0 {
+		return
+	}
+
+	t.row()
+	t.row("DESTINATION", "DELIVERED", "FAILED", "LAST ERROR")
+	for _, d := range resp.Destinations {
+		t.row(d.Destination, fmt.Sprint(d.DeliveredMessages), fmt.Sprint(d.FailedDeliveries), orNone(d.LastError))
+	}
+}
+
+// printSubscriptions fills the table with the active subscriptions
+func printSubscriptions(t *table, resp *amsPb.ListSubscriptionsResponse) {
+
+	t.row("SUBSCRIPTION", "TOPIC", "STATUS")
+	for _, s := range resp.Subscriptions {
+		t.row(s.FullName, s.FullTopic, s.Status)
+	}
+}
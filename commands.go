@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	amsgRPC "github.com/ARGOeu/ams-push-server/api/v1/grpc"
+	"github.com/ARGOeu/ams-push-server/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// build information, set at build time e.g.
+// go build -ldflags "-X main.version=1.3.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = "devel"
+	commit    = ""
+	buildDate = ""
+)
+
+// commands are the subcommands of the service, each one returns the exit code of the service.
+// Without a subcommand the service starts serving
+var commands = map[string]func(args []string) int{
+	"validate":     validateCommand,
+	"print-config": printConfigCommand,
+	"version":      versionCommand,
+}
+
+// overrideFlags collects the key=value pairs of every -set flag
+type overrideFlags []string
+
+func (o *overrideFlags) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *overrideFlags) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// configFlags registers the flags that locate and override the configuration and returns the source they describe
+func configFlags(fs *flag.FlagSet) func() config.Source {
+
+	cfgPath := fs.String("config", "/etc/ams-push-server/conf.d/ams-push-server-config.json", "Path for the required configuration file(.json,.yaml,.yml,.toml).")
+	overrides := new(overrideFlags)
+	fs.Var(overrides, "set", "Override a configuration field, in key=value form e.g. -set ams_host=ams.example.com, can be repeated.")
+
+	// the file is overridden by the AMS_PUSH_* environment variables and those by the -set flags
+	return func() config.Source {
+		return config.Source{
+			Path:      *cfgPath,
+			Environ:   os.Environ(),
+			Overrides: *overrides,
+		}
+	}
+}
+
+// loadConfig builds the configuration out of its source, loading and checking everything the service needs to start
+func loadConfig(source config.Source) (*config.Config, error) {
+
+	cfgReader, err := source.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := new(config.Config)
+	err = cfg.LoadFromJson(cfgReader)
+	if err != nil {
+		return nil, err
+	}
+
+	// the keys of the JWKS file are loaded by the auth providers
+	_, err = amsgRPC.NewAuthProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// validateCommand checks the configuration, including its certificate, key and CA directory, without starting the service
+func validateCommand(args []string) int {
+
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	source := configFlags(fs)
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	// report only the problems of the configuration
+	log.SetLevel(log.WarnLevel)
+
+	_, err := loadConfig(source())
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "error_log",
+				"path":  source().Path,
+				"error": err.Error(),
+			},
+		).Error("Invalid configuration")
+		return 1
+	}
+
+	fmt.Println("Configuration is valid")
+
+	return 0
+}
+
+// printConfigCommand prints the effective configuration, after all of its layers are merged, with its secrets masked
+func printConfigCommand(args []string) int {
+
+	fs := flag.NewFlagSet("print-config", flag.ContinueOnError)
+	source := configFlags(fs)
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	log.SetLevel(log.WarnLevel)
+
+	cfg, err := loadConfig(source())
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "error_log",
+				"path":  source().Path,
+				"error": err.Error(),
+			},
+		).Error("Invalid configuration")
+		return 1
+	}
+
+	b, err := json.MarshalIndent(cfg.Masked(), "", "  ")
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "error_log",
+				"error": err.Error(),
+			},
+		).Error("Could not print configuration")
+		return 1
+	}
+
+	fmt.Println(string(b))
+
+	return 0
+}
+
+// versionCommand prints the build information of the service
+func versionCommand(args []string) int {
+
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	rev, built := commit, buildDate
+
+	// fall back to the version control information that go embeds in the binary
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && rev == "":
+				rev = setting.Value
+			case setting.Key == "vcs.time" && built == "":
+				built = setting.Value
+			}
+		}
+	}
+
+	fmt.Printf("ams-push-server %v\n", version)
+	fmt.Printf("commit: %v\n", rev)
+	fmt.Printf("built: %v\n", built)
+	fmt.Printf("go: %v %v/%v\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	return 0
+}
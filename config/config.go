@@ -4,15 +4,26 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"fmt"
+	"github.com/ARGOeu/ams-push-server/acl"
+	"github.com/ARGOeu/ams-push-server/election"
+	"github.com/ARGOeu/ams-push-server/logging"
+	"github.com/ARGOeu/ams-push-server/netpolicy"
+	"github.com/ARGOeu/ams-push-server/redact"
+	"github.com/ARGOeu/ams-push-server/verifiers"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	lSyslog "github.com/sirupsen/logrus/hooks/syslog"
 	"io"
 	"log/syslog"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Config contains all the needed information for the server to function properly
@@ -22,13 +33,13 @@ type Config struct {
 	// Which port to bind to
 	BindPort int `json:"bind_port" required:"true"`
 	// Certificate file in order to enable TLS
-	Certificate string `json:"certificate" required:"true"`
+	Certificate string `json:"certificate" required:"true" reload:"live"`
 	// Certificate's private key
-	CertificateKey string `json:"certificate_key" required:"true"`
+	CertificateKey string `json:"certificate_key" required:"true" reload:"live"`
 	// Directory containing all the appropriate files to load the CA
-	CertificateAuthoritiesDir string `json:"certificate_authorities_dir" required:"true"`
+	CertificateAuthoritiesDir string `json:"certificate_authorities_dir" required:"true" reload:"live"`
 	// token for ams interaction
-	AmsToken string `json:"ams_token" required:"true"`
+	AmsToken string `json:"ams_token" required:"true" secret:"true"`
 	// Ams endpoint
 	AmsHost string `json:"ams_host" required:"true"`
 	// Ams port
@@ -38,19 +49,255 @@ type Config struct {
 	// whether the service will start with tls enabled
 	TLSEnabled bool `json:"tls_enabled"`
 	// Trust incoming certificates signed from unknown CAs
-	TrustUnknownCAs bool `json:"trust_unknown_cas"`
+	TrustUnknownCAs bool `json:"trust_unknown_cas" reload:"live"`
 	// log level(DEBUG,INFO,WARNING,ERROR)
-	LogLevel string `json:"log_level" required:"true"`
+	LogLevel string `json:"log_level" required:"true" reload:"live"`
+	// log levels of specific components(grpc,worker,sender,ams_client), any other component logs at log_level
+	LogLevels map[string]string `json:"log_levels" reload:"live"`
+	// format of the logs(text,json,logfmt)
+	LogFormat string `json:"log_format"`
+	// file where the logs are written and rotated, instead of the standard error
+	LogFile LogFile `json:"log_file"`
 	// whether or not it should try to load any push enabled subscriptions, upon starting up
 	SkipSubsLoad bool `json:"skip_subs_load"`
 	// tls configuration to be used by the grpc server
 	tlsConfig *tls.Config
-	// list of certificate DNs that should be allowed to access the service
-	ACL []string `json:"acl"`
+	// list of certificate identities that should be allowed full access to the service
+	ACL []string `json:"acl" reload:"live"`
+	// list of certificate identities that should only be allowed to query the status of the service and its subscriptions
+	ACLReadOnly []string `json:"acl_read_only" reload:"live"`
 	// Enable direct logging of the service to the syslog facility
 	SyslogEnabled bool `json:"syslog_enabled"`
+	// How push endpoints should be verified before a subscription gets activated(none,challenge,well_known)
+	EndpointVerification string `json:"endpoint_verification"`
+	// For how many seconds a successful endpoint verification is trusted
+	EndpointVerificationTTL int `json:"endpoint_verification_ttl"`
+	// Restrictions on the destinations that subscriptions are allowed to push to
+	DestinationPolicy DestinationPolicy `json:"destination_policy"`
+	// How often, in seconds, the certificate, its key and the CA directory are checked for changes
+	CertificateWatchInterval int `json:"certificate_watch_interval"`
+	// How many days before its expiry the service starts warning about the certificate
+	CertificateExpiryWarningDays int `json:"certificate_expiry_warning_days"`
+	// How much of the pushed messages should be logged(none,metadata,full)
+	PayloadLogging string `json:"payload_logging" reload:"live"`
+	// Which providers authenticate the callers of the api, tried in order(mtls,token,jwt).
+	// When empty, callers are authenticated through their certificates if tls is enabled
+	AuthProviders []string `json:"auth_providers"`
+	// Static bearer tokens that grant a role to their holders
+	AuthTokens []AuthToken `json:"auth_tokens" secret:"true" reload:"live"`
+	// How jwt bearer tokens are validated
+	JWTAuth JWTAuth `json:"jwt_auth"`
+	// Where the audit log of administrative calls is written, syslog or the path of a file, when empty there is no audit log
+	AuditLog string `json:"audit_log"`
+	// How the client that consumes from ams connects
+	AmsClient HTTPClient `json:"ams_client"`
+	// How the client that pushes to the destinations of the subscriptions connects
+	PushClient HTTPClient `json:"push_client"`
+	// How long, in seconds, the push workers are given to complete their current push cycle when the service shuts down
+	DrainTimeout int `json:"drain_timeout"`
+	// How often, in seconds, the active subscriptions are reconciled against ams, a negative value disables the reconciliation
+	ReconcileInterval int `json:"reconcile_interval"`
+	// How the subscriptions of the push worker user are loaded at startup
+	SubscriptionLoading SubscriptionLoading `json:"subscription_loading"`
+	// Path of the file where the state of the push workers is persisted across restarts, when empty it is kept in memory
+	StateFile string `json:"state_file"`
+	// How often, in seconds, the state of the push workers is persisted
+	StateCheckpointInterval int `json:"state_checkpoint_interval"`
+	// Which push server instances share the subscriptions of the push worker user, when empty the instance owns all of them
+	Cluster Cluster `json:"cluster" reload:"live"`
+	// Bearer token the instance presents when it calls the other instances of the cluster
+	ClusterToken string `json:"cluster_token" secret:"true" reload:"live"`
+	// How the instance competes with its standby instances for the right to push, when empty it always pushes
+	Election Election `json:"election"`
+	// references(file:,env:) of the secret fields, keyed by the json name of the field
+	secretRefs map[string]string
+	// guards the fields that can change through a reload
+	mutex sync.RWMutex
+	// certificate and CA pool currently served by the grpc server
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	// summary of the certificate files the served certificate and CA pool were loaded from
+	certificatesState string
+	// functions to be called after every successful reload
+	reloadHooks []func(cfg *Config)
+	// resolves the hosts of the push destinations, the system resolver is used when it's nil
+	resolver netpolicy.Resolver
 }
 
+// DestinationPolicy restricts the destinations that subscriptions are allowed to push to
+type DestinationPolicy struct {
+	// networks that destinations can't resolve to, when omitted loopback, private and link-local networks are denied
+	DenyCIDRs []string `json:"deny_cidrs"`
+	// hosts that destinations are allowed to use, entries like *.example.com match any subdomain, when empty any host is allowed
+	AllowHosts []string `json:"allow_hosts"`
+	// url schemes that destinations are allowed to use, when empty http and https are allowed
+	AllowedSchemes []string `json:"allowed_schemes"`
+}
+
+// LogFile describes the file where the logs are written and when it is rotated
+type LogFile struct {
+	// path of the log file, when empty the logs are written to the standard error
+	Path string `json:"path"`
+	// size in megabytes that the file can reach before it is rotated
+	MaxSizeMB int `json:"max_size_mb"`
+	// number of rotated files that are kept
+	MaxBackups int `json:"max_backups"`
+}
+
+// HTTPClient describes the timeouts, the connection pool and the proxy of an http client,
+// timeouts are in seconds and any field left unset keeps its default
+type HTTPClient struct {
+	// how long establishing a connection can take, defaults to 30
+	DialTimeout int `json:"dial_timeout"`
+	// how long the tls handshake can take, defaults to 10
+	TLSHandshakeTimeout int `json:"tls_handshake_timeout"`
+	// how long the response headers can take after the request has been written, defaults to no limit
+	ResponseHeaderTimeout int `json:"response_header_timeout"`
+	// how long a whole request can take, including reading the response body, defaults to 30
+	Timeout int `json:"timeout"`
+	// how many idle connections are kept open to every host, defaults to 2
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+	// whether http/2 is attempted with the hosts that support it
+	HTTP2 bool `json:"http2"`
+	// the proxy that requests go through
+	Proxy Proxy `json:"proxy"`
+}
+
+// Proxy describes the proxies that an http client goes through
+type Proxy struct {
+	// proxy of http requests, an http, https or socks5 url, its user info is masked
+	HTTPProxy string `json:"http_proxy" secret:"url"`
+	// proxy of https requests, an http, https or socks5 url, its user info is masked
+	HTTPSProxy string `json:"https_proxy" secret:"url"`
+	// hosts, domains(.example.com), ips and cidrs that are reached directly
+	NoProxy []string `json:"no_proxy"`
+}
+
+// SubscriptionLoading describes how the subscriptions of the push worker user are loaded at startup
+type SubscriptionLoading struct {
+	// how many subscriptions are retrieved from ams at the same time, defaults to 8
+	Concurrency int `json:"concurrency"`
+	// how many times, after the first attempt, a subscription that couldn't be retrieved is retried, defaults to 5
+	Retries int `json:"retries"`
+	// how long, in seconds, to wait before the first retry, the wait doubles with every retry, defaults to 1
+	InitialBackoff int `json:"initial_backoff"`
+	// the longest, in seconds, to wait between retries, defaults to 60
+	MaxBackoff int `json:"max_backoff"`
+	// fraction of the subscriptions that should be loaded before the service reports itself as ok, defaults to 1
+	ReadyFraction float64 `json:"ready_fraction"`
+}
+
+// Cluster describes the push server instances that share the subscriptions of the push worker user,
+// every subscription is owned by one of the live instances, chosen by consistent hashing of its full name
+type Cluster struct {
+	// name of the current instance, it should be one of the peers
+	Self string `json:"self"`
+	// every instance of the cluster, including the current one
+	Peers []Peer `json:"peers"`
+	// how many points every instance gets on the hash ring, defaults to 128
+	VirtualNodes int `json:"virtual_nodes"`
+	// how often, in seconds, the other instances are probed, defaults to 5
+	HealthInterval int `json:"health_interval"`
+}
+
+// Peer is an instance of the cluster
+type Peer struct {
+	// name that identifies the instance, it should be the same in the configuration of every instance
+	Name string `json:"name"`
+	// host:port where the grpc api of the instance listens
+	Address string `json:"address"`
+}
+
+// default settings of the cluster
+const (
+	DefaultClusterVirtualNodes   = 128
+	DefaultClusterHealthInterval = 5 * time.Second
+)
+
+// Election describes the lease that an active and its standby instances compete for,
+// only the instance that holds the lease pushes the subscriptions of the push worker user
+type Election struct {
+	// where the lease is kept(file), when empty leader election is disabled
+	Backend string `json:"backend"`
+	// path of the lease file, on a filesystem shared by the instances
+	LeaseFile string `json:"lease_file"`
+	// name of the instance, defaults to its hostname
+	Identity string `json:"identity"`
+	// how long, in seconds, the lease lasts unless it is renewed, defaults to 15
+	LeaseDuration int `json:"lease_duration"`
+	// how often, in seconds, the leader renews the lease and the standby instances try to acquire it, defaults to 5
+	RenewInterval int `json:"renew_interval"`
+}
+
+// default settings of the leader election
+const (
+	DefaultElectionLeaseDuration = 15 * time.Second
+	DefaultElectionRenewInterval = 5 * time.Second
+)
+
+// default settings of the subscription loading
+const (
+	DefaultLoadingConcurrency    = 8
+	DefaultLoadingRetries        = 5
+	DefaultLoadingInitialBackoff = time.Second
+	DefaultLoadingMaxBackoff     = time.Minute
+	DefaultLoadingReadyFraction  = 1.0
+)
+
+// default settings of the http clients
+const (
+	DefaultHTTPDialTimeout         = 30 * time.Second
+	DefaultHTTPTLSHandshakeTimeout = 10 * time.Second
+	DefaultHTTPTimeout             = 30 * time.Second
+	DefaultHTTPMaxIdleConnsPerHost = 2
+)
+
+// AuthToken is a static bearer token that grants a role to its holder
+type AuthToken struct {
+	// name that identifies the holder of the token
+	Name string `json:"name"`
+	// the token itself, it can also be a secret reference
+	Token string `json:"token" secret:"true"`
+	// role granted by the token(admin,read_only)
+	Role string `json:"role"`
+}
+
+// JWTAuth describes how jwt bearer tokens are validated
+type JWTAuth struct {
+	// file holding the JSON Web Key Set with the keys that tokens should be signed with
+	JWKSFile string `json:"jwks_file"`
+	// expected issuer(iss) of the tokens
+	Issuer string `json:"issuer"`
+	// expected audience(aud) of the tokens
+	Audience string `json:"audience"`
+	// claim holding the role of the caller(admin,read_only), defaults to role
+	RoleClaim string `json:"role_claim"`
+}
+
+// names of the supported auth providers
+const (
+	MTLSAuthProvider  = "mtls"
+	TokenAuthProvider = "token"
+	JWTAuthProvider   = "jwt"
+)
+
+// DefaultDrainTimeout is how long the push workers are given to drain when no drain timeout is configured
+const DefaultDrainTimeout = 30 * time.Second
+
+// DefaultReconcileInterval is how often the active subscriptions are reconciled when no interval is configured
+const DefaultReconcileInterval = 5 * time.Minute
+
+// DefaultStateCheckpointInterval is how often the state of the push workers is persisted when no interval is configured
+const DefaultStateCheckpointInterval = 10 * time.Second
+
+// DefaultJWTRoleClaim is the claim holding the role of the caller when none is configured
+const DefaultJWTRoleClaim = "role"
+
+const (
+	fileSecretPrefix = "file:"
+	envSecretPrefix  = "env:"
+)
+
 var logLevels = map[string]log.Level{
 	"DEBUG":   log.DebugLevel,
 	"INFO":    log.InfoLevel,
@@ -67,6 +314,9 @@ func (cfg *Config) GetTLSConfig() *tls.Config {
 // if it can't map it, it will return log.LevelInfo
 func (cfg *Config) GetLogLevel() log.Level {
 
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
 	logLevel, ok := logLevels[strings.ToUpper(cfg.LogLevel)]
 
 	if !ok {
@@ -76,29 +326,450 @@ func (cfg *Config) GetLogLevel() log.Level {
 	return logLevel
 }
 
-// LoadFromJson fills the config struct with the contents of the reader
-func (cfg *Config) LoadFromJson(from io.Reader) error {
+// GetComponentLogLevels maps the log levels of the components inside the config to log.Levels
+func (cfg *Config) GetComponentLogLevels() map[string]log.Level {
 
-	// load the configuration into the struct
-	err := json.NewDecoder(from).Decode(cfg)
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	levels := make(map[string]log.Level)
+
+	for component, level := range cfg.LogLevels {
+		if l, ok := logLevels[strings.ToUpper(level)]; ok {
+			levels[component] = l
+		}
+	}
+
+	return levels
+}
+
+// GetACL returns the list of certificate identities that are currently allowed full access to the service
+func (cfg *Config) GetACL() []string {
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	return cfg.ACL
+}
+
+// GetACLList returns the acl entries of every role that are currently in use
+func (cfg *Config) GetACLList() acl.List {
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	return acl.List{
+		Admin:    cfg.ACL,
+		ReadOnly: cfg.ACLReadOnly,
+	}
+}
+
+// GetAuthProviders returns the providers that should authenticate the callers of the api, in the order they are tried.
+// When none are configured, callers are authenticated through their certificates if tls is enabled
+func (cfg *Config) GetAuthProviders() []string {
+
+	if len(cfg.AuthProviders) > 0 {
+		return cfg.AuthProviders
+	}
+
+	if cfg.TLSEnabled {
+		return []string{MTLSAuthProvider}
+	}
+
+	return nil
+}
+
+// GetAuthTokens returns the static bearer tokens that are currently accepted
+func (cfg *Config) GetAuthTokens() []AuthToken {
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	return cfg.AuthTokens
+}
+
+// GetJWTRoleClaim returns the claim that holds the role of the caller in jwt bearer tokens
+func (cfg *Config) GetJWTRoleClaim() string {
+
+	if cfg.JWTAuth.RoleClaim == "" {
+		return DefaultJWTRoleClaim
+	}
+
+	return cfg.JWTAuth.RoleClaim
+}
+
+// GetDestinationPolicy builds the policy that push destinations have to comply with
+func (cfg *Config) GetDestinationPolicy() (*netpolicy.Policy, error) {
+
+	policy, err := netpolicy.New(cfg.DestinationPolicy.DenyCIDRs, cfg.DestinationPolicy.AllowHosts, cfg.DestinationPolicy.AllowedSchemes)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// check if all required fields are set
-	err = cfg.validateRequired()
+	if cfg.resolver != nil {
+		policy.SetResolver(cfg.resolver)
+	}
+
+	return policy, nil
+}
+
+// SetResolver sets the resolver that the destination policy resolves the hosts of the push destinations with
+func (cfg *Config) SetResolver(r netpolicy.Resolver) {
+	cfg.resolver = r
+}
+
+// GetDrainTimeout returns how long the push workers are given to complete their current push cycle on shutdown
+func (cfg *Config) GetDrainTimeout() time.Duration {
+	return secondsOrDefault(cfg.DrainTimeout, DefaultDrainTimeout)
+}
+
+// GetReconcileInterval returns how often the active subscriptions are reconciled against ams, 0 if they are not
+func (cfg *Config) GetReconcileInterval() time.Duration {
+
+	if cfg.ReconcileInterval < 0 {
+		return 0
+	}
+
+	return secondsOrDefault(cfg.ReconcileInterval, DefaultReconcileInterval)
+}
+
+// GetStateCheckpointInterval returns how often the state of the push workers is persisted
+func (cfg *Config) GetStateCheckpointInterval() time.Duration {
+	return secondsOrDefault(cfg.StateCheckpointInterval, DefaultStateCheckpointInterval)
+}
+
+// GetCluster returns the instances that currently make up the cluster
+func (cfg *Config) GetCluster() Cluster {
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	return cfg.Cluster
+}
+
+// GetClusterToken returns the bearer token presented to the other instances of the cluster
+func (cfg *Config) GetClusterToken() string {
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	return cfg.ClusterToken
+}
+
+// Enabled returns whether or not the instance is part of a cluster
+func (c Cluster) Enabled() bool {
+	return len(c.Peers) > 0
+}
+
+// GetVirtualNodes returns how many points every instance gets on the hash ring
+func (c Cluster) GetVirtualNodes() int {
+
+	if c.VirtualNodes <= 0 {
+		return DefaultClusterVirtualNodes
+	}
+
+	return c.VirtualNodes
+}
+
+// GetHealthInterval returns how often the other instances are probed
+func (c Cluster) GetHealthInterval() time.Duration {
+	return secondsOrDefault(c.HealthInterval, DefaultClusterHealthInterval)
+}
+
+// validate checks that the current instance is one of the peers and that every peer has a unique name and a valid address
+func (c Cluster) validate() error {
+
+	if !c.Enabled() {
+		return nil
+	}
+
+	if c.VirtualNodes < 0 {
+		return errors.Errorf("Invalid value %v for field cluster.virtual_nodes", c.VirtualNodes)
+	}
+
+	if c.HealthInterval < 0 {
+		return errors.Errorf("Invalid value %v for field cluster.health_interval", c.HealthInterval)
+	}
+
+	names := make(map[string]bool)
+
+	for _, p := range c.Peers {
+
+		if p.Name == "" {
+			return errors.Errorf("Invalid cluster peer %v, empty name", p.Address)
+		}
+
+		if names[p.Name] {
+			return errors.Errorf("Invalid cluster peer %v, duplicate name", p.Name)
+		}
+		names[p.Name] = true
+
+		host, port, err := net.SplitHostPort(p.Address)
+		if err != nil || host == "" || port == "" {
+			return errors.Errorf("Invalid cluster peer %v, address %v should be host:port", p.Name, p.Address)
+		}
+	}
+
+	if !names[c.Self] {
+		return errors.Errorf("Invalid value %v for field cluster.self, it should be one of the peers", c.Self)
+	}
+
+	return nil
+}
+
+// Enabled returns whether or not the instance competes for a lease
+func (e Election) Enabled() bool {
+	return e.Backend != ""
+}
+
+// GetIdentity returns the name under which the instance competes for the lease
+func (e Election) GetIdentity() string {
+
+	if e.Identity != "" {
+		return e.Identity
+	}
+
+	hostname, _ := os.Hostname()
+
+	return hostname
+}
+
+// GetLeaseDuration returns how long the lease lasts unless it is renewed
+func (e Election) GetLeaseDuration() time.Duration {
+	return secondsOrDefault(e.LeaseDuration, DefaultElectionLeaseDuration)
+}
+
+// GetRenewInterval returns how often the lease is renewed or acquired
+func (e Election) GetRenewInterval() time.Duration {
+	return secondsOrDefault(e.RenewInterval, DefaultElectionRenewInterval)
+}
+
+// validate checks that the lease backend is supported and that the lease is renewed well before it expires
+func (e Election) validate() error {
+
+	if !e.Enabled() {
+		return nil
+	}
+
+	if !election.IsValidBackend(e.Backend) {
+		return errors.Errorf("Invalid election backend %v", e.Backend)
+	}
+
+	if e.Backend == election.FileBackend && e.LeaseFile == "" {
+		return errors.Errorf("Empty value for field election.lease_file")
+	}
+
+	values := []struct {
+		field string
+		value int
+	}{
+		{"lease_duration", e.LeaseDuration},
+		{"renew_interval", e.RenewInterval},
+	}
+
+	for _, v := range values {
+		if v.value < 0 {
+			return errors.Errorf("Invalid value %v for field election.%v", v.value, v.field)
+		}
+	}
+
+	if e.GetRenewInterval() >= e.GetLeaseDuration() {
+		return errors.Errorf("Invalid value %v for field election.renew_interval, it should be shorter than the lease_duration", e.RenewInterval)
+	}
+
+	return nil
+}
+
+// GetConcurrency returns how many subscriptions are retrieved from ams at the same time
+func (l SubscriptionLoading) GetConcurrency() int {
+
+	if l.Concurrency <= 0 {
+		return DefaultLoadingConcurrency
+	}
+
+	return l.Concurrency
+}
+
+// GetRetries returns how many times a subscription that couldn't be retrieved is retried
+func (l SubscriptionLoading) GetRetries() int {
+
+	if l.Retries <= 0 {
+		return DefaultLoadingRetries
+	}
+
+	return l.Retries
+}
+
+// GetInitialBackoff returns how long to wait before the first retry
+func (l SubscriptionLoading) GetInitialBackoff() time.Duration {
+	return secondsOrDefault(l.InitialBackoff, DefaultLoadingInitialBackoff)
+}
+
+// GetMaxBackoff returns the longest to wait between retries
+func (l SubscriptionLoading) GetMaxBackoff() time.Duration {
+	return secondsOrDefault(l.MaxBackoff, DefaultLoadingMaxBackoff)
+}
+
+// GetReadyFraction returns the fraction of the subscriptions that should be loaded before the service is ok
+func (l SubscriptionLoading) GetReadyFraction() float64 {
+
+	if l.ReadyFraction <= 0 {
+		return DefaultLoadingReadyFraction
+	}
+
+	return l.ReadyFraction
+}
+
+// validate checks that the subscription loading settings are within their bounds
+func (l SubscriptionLoading) validate() error {
+
+	values := []struct {
+		field string
+		value int
+	}{
+		{"concurrency", l.Concurrency},
+		{"retries", l.Retries},
+		{"initial_backoff", l.InitialBackoff},
+		{"max_backoff", l.MaxBackoff},
+	}
+
+	for _, v := range values {
+		if v.value < 0 {
+			return errors.Errorf("Invalid value %v for field subscription_loading.%v", v.value, v.field)
+		}
+	}
+
+	if l.ReadyFraction < 0 || l.ReadyFraction > 1 {
+		return errors.Errorf("Invalid value %v for field subscription_loading.ready_fraction", l.ReadyFraction)
+	}
+
+	return nil
+}
+
+// GetDialTimeout returns how long establishing a connection can take
+func (c HTTPClient) GetDialTimeout() time.Duration {
+	return secondsOrDefault(c.DialTimeout, DefaultHTTPDialTimeout)
+}
+
+// GetTLSHandshakeTimeout returns how long the tls handshake can take
+func (c HTTPClient) GetTLSHandshakeTimeout() time.Duration {
+	return secondsOrDefault(c.TLSHandshakeTimeout, DefaultHTTPTLSHandshakeTimeout)
+}
+
+// GetResponseHeaderTimeout returns how long the response headers can take, 0 means no limit
+func (c HTTPClient) GetResponseHeaderTimeout() time.Duration {
+	return secondsOrDefault(c.ResponseHeaderTimeout, 0)
+}
+
+// GetTimeout returns how long a whole request can take
+func (c HTTPClient) GetTimeout() time.Duration {
+	return secondsOrDefault(c.Timeout, DefaultHTTPTimeout)
+}
+
+// GetMaxIdleConnsPerHost returns how many idle connections are kept open to every host
+func (c HTTPClient) GetMaxIdleConnsPerHost() int {
+
+	if c.MaxIdleConnsPerHost <= 0 {
+		return DefaultHTTPMaxIdleConnsPerHost
+	}
+
+	return c.MaxIdleConnsPerHost
+}
+
+// validate checks that the timeouts aren't negative and that the proxies are valid urls
+func (c HTTPClient) validate(name string) error {
+
+	values := []struct {
+		field string
+		value int
+	}{
+		{"dial_timeout", c.DialTimeout},
+		{"tls_handshake_timeout", c.TLSHandshakeTimeout},
+		{"response_header_timeout", c.ResponseHeaderTimeout},
+		{"timeout", c.Timeout},
+		{"max_idle_conns_per_host", c.MaxIdleConnsPerHost},
+	}
+
+	for _, v := range values {
+		if v.value < 0 {
+			return errors.Errorf("Invalid value %v for field %v.%v", v.value, name, v.field)
+		}
+	}
+
+	proxies := []struct {
+		field string
+		proxy string
+	}{
+		{"http_proxy", c.Proxy.HTTPProxy},
+		{"https_proxy", c.Proxy.HTTPSProxy},
+	}
+
+	for _, p := range proxies {
+
+		field, proxy := p.field, p.proxy
+		if proxy == "" {
+			continue
+		}
+
+		u, err := url.Parse(proxy)
+		if err != nil {
+			// the error quotes the whole url, along with any credentials it carries
+			var uErr *url.Error
+			if errors.As(err, &uErr) {
+				err = uErr.Err
+			}
+			return errors.Errorf("Invalid %v.proxy.%v, %v", name, field, err.Error())
+		}
+
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return errors.Errorf("Invalid %v.proxy.%v, unsupported scheme %v", name, field, u.Scheme)
+		}
+
+		if u.Host == "" {
+			return errors.Errorf("Invalid %v.proxy.%v, empty host", name, field)
+		}
+	}
+
+	return nil
+}
+
+// secondsOrDefault converts the seconds to a duration, or returns the default if they are not set
+func secondsOrDefault(seconds int, def time.Duration) time.Duration {
+
+	if seconds <= 0 {
+		return def
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// GetEndpointVerificationTTL returns the period for which a successful endpoint verification is trusted
+func (cfg *Config) GetEndpointVerificationTTL() time.Duration {
+	return time.Duration(cfg.EndpointVerificationTTL) * time.Second
+}
+
+// LoadFromJson fills the config struct with the contents of the reader
+func (cfg *Config) LoadFromJson(from io.Reader) error {
+
+	err := cfg.load(from)
 	if err != nil {
 		return err
 	}
 
-	// check if the given log value is correct
-	_, ok := logLevels[strings.ToUpper(cfg.LogLevel)]
-	if !ok {
-		return errors.Errorf("Invalid log level %v", cfg.LogLevel)
+	// print values in the configured format
+	log.SetFormatter(logging.NewFormatter(cfg.LogFormat))
+
+	// direct logging to syslog, a single hook serves the service and all of its components
+	if cfg.SyslogEnabled {
+		hook, err := lSyslog.NewSyslogHook("", "", syslog.LOG_INFO, "")
+		if err == nil {
+			log.AddHook(hook)
+		}
 	}
 
-	// print values
-	rvc := reflect.ValueOf(*cfg)
+	rvc := reflect.ValueOf(cfg).Elem()
 
 	for i := 0; i < rvc.NumField(); i++ {
 
@@ -114,18 +785,11 @@ func (cfg *Config) LoadFromJson(from io.Reader) error {
 			continue
 		}
 
-		if cfg.SyslogEnabled {
-			hook, err := lSyslog.NewSyslogHook("", "", syslog.LOG_INFO, "")
-			if err == nil {
-				log.AddHook(hook)
-			}
-		}
-
 		log.WithFields(
 			log.Fields{
-				"type":  "service_log",
+				"type":  "system_log",
 				"field": fl.Tag.Get("json"),
-				"value": rvc.Field(i).Interface(),
+				"value": maskedValue(fl, rvc.Field(i)),
 			},
 		).Info("Configuration field has been successfully initialized")
 
@@ -142,10 +806,339 @@ func (cfg *Config) LoadFromJson(from io.Reader) error {
 	return nil
 }
 
+// Masked returns the configuration fields keyed by their json names, with the values of the secret fields masked
+func (cfg *Config) Masked() map[string]interface{} {
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	return maskedFields(reflect.ValueOf(cfg).Elem())
+}
+
+// maskedFields returns the fields of the provided struct keyed by their json names,
+// masking the secret ones, including the ones of any nested struct or list of structs
+func maskedFields(v reflect.Value) map[string]interface{} {
+
+	fields := make(map[string]interface{})
+
+	for i := 0; i < v.NumField(); i++ {
+
+		sf := v.Type().Field(i)
+
+		// skip unexported and non configuration fields
+		if sf.PkgPath != "" || sf.Tag.Get("json") == "" {
+			continue
+		}
+
+		fields[sf.Tag.Get("json")] = maskedValue(sf, v.Field(i))
+	}
+
+	return fields
+}
+
+// maskedValue returns the value of a configuration field with its secrets masked,
+// the value of a struct or a list of structs is returned as its masked fields.
+// Every configuration value that gets logged or printed goes through it
+func maskedValue(sf reflect.StructField, fv reflect.Value) interface{} {
+
+	switch {
+
+	case sf.Type.Kind() == reflect.Struct:
+		return maskedFields(fv)
+
+	case sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() == reflect.Struct:
+		list := make([]map[string]interface{}, fv.Len())
+		for j := 0; j < fv.Len(); j++ {
+			list[j] = maskedFields(fv.Index(j))
+		}
+		return list
+
+	case sf.Tag.Get("secret") == "true" && sf.Type.Kind() == reflect.String:
+		return redact.String(fv.String())
+
+	// urls that might carry credentials, only their secrets are masked
+	case sf.Tag.Get("secret") == "url" && sf.Type.Kind() == reflect.String:
+		return redact.URL(fv.String())
+	}
+
+	return fv.Interface()
+}
+
+// ResolveSecrets replaces the value of every secret field that has been configured as a reference
+// with the secret it points to. A file:<path> reference is replaced by the contents of the file,
+// environment variables in its path are expanded e.g. file:${CREDENTIALS_DIRECTORY}/ams_token,
+// while an env:<name> reference is replaced by the value of the environment variable.
+// References are remembered, so calling it again re-reads the secrets
+func (cfg *Config) ResolveSecrets() error {
+
+	if cfg.secretRefs == nil {
+		cfg.secretRefs = make(map[string]string)
+	}
+
+	return cfg.resolveSecretFields(reflect.ValueOf(cfg).Elem(), "")
+}
+
+// resolveSecretFields resolves the secret fields of the provided struct, including the ones of any list of structs it holds.
+// Nested fields are named after their parents e.g. auth_tokens[0].token
+func (cfg *Config) resolveSecretFields(v reflect.Value, prefix string) error {
+
+	for i := 0; i < v.NumField(); i++ {
+
+		sf := v.Type().Field(i)
+
+		// skip unexported fields
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		name := prefix + sf.Tag.Get("json")
+
+		// resolve the secrets of every element of a list of structs
+		if sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() == reflect.Struct {
+			for j := 0; j < v.Field(i).Len(); j++ {
+				err := cfg.resolveSecretFields(v.Field(i).Index(j), fmt.Sprintf("%v[%v].", name, j))
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		// skip non secret and non string fields
+		if sf.Tag.Get("secret") != "true" || sf.Type.Kind() != reflect.String {
+			continue
+		}
+
+		ref, found := cfg.secretRefs[name]
+		if !found {
+			ref = v.Field(i).String()
+			if !strings.HasPrefix(ref, fileSecretPrefix) && !strings.HasPrefix(ref, envSecretPrefix) {
+				continue
+			}
+			cfg.secretRefs[name] = ref
+		}
+
+		secret, err := resolveSecret(ref)
+		if err != nil {
+			return errors.Errorf("Could not resolve secret %v, %v", name, err.Error())
+		}
+
+		v.Field(i).SetString(secret)
+	}
+
+	return nil
+}
+
+// ResolveSecret returns the secret that the provided value points to, if it is a secret reference(file:,env:),
+// or the value itself
+func ResolveSecret(value string) (string, error) {
+
+	if !strings.HasPrefix(value, fileSecretPrefix) && !strings.HasPrefix(value, envSecretPrefix) {
+		return value, nil
+	}
+
+	return resolveSecret(value)
+}
+
+// resolveSecret returns the secret that the provided reference points to
+func resolveSecret(ref string) (string, error) {
+
+	if path, ok := strings.CutPrefix(ref, fileSecretPrefix); ok {
+
+		b, err := os.ReadFile(os.ExpandEnv(path))
+		if err != nil {
+			return "", err
+		}
+
+		// secret files usually end with a new line
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	name := strings.TrimPrefix(ref, envSecretPrefix)
+
+	secret, found := os.LookupEnv(name)
+	if !found {
+		return "", errors.Errorf("environment variable %v is not set", name)
+	}
+
+	return secret, nil
+}
+
+// load fills the config struct with the contents of the reader and validates it
+func (cfg *Config) load(from io.Reader) error {
+
+	// load the configuration into the struct
+	err := json.NewDecoder(from).Decode(cfg)
+	if err != nil {
+		return err
+	}
+
+	// replace any secret references with the actual secrets
+	cfg.secretRefs = make(map[string]string)
+	err = cfg.ResolveSecrets()
+	if err != nil {
+		return err
+	}
+
+	// check if all required fields are set
+	err = cfg.validateRequired()
+	if err != nil {
+		return err
+	}
+
+	// check if the given log value is correct
+	_, ok := logLevels[strings.ToUpper(cfg.LogLevel)]
+	if !ok {
+		return errors.Errorf("Invalid log level %v", cfg.LogLevel)
+	}
+
+	// check if the component log levels are correct
+	for component, level := range cfg.LogLevels {
+		if !logging.IsValidComponent(component) {
+			return errors.Errorf("Invalid log component %v", component)
+		}
+		_, ok = logLevels[strings.ToUpper(level)]
+		if !ok {
+			return errors.Errorf("Invalid log level %v for component %v", level, component)
+		}
+	}
+
+	// check if the given log format is supported
+	if !logging.IsValidFormat(cfg.LogFormat) {
+		return errors.Errorf("Invalid log format %v", cfg.LogFormat)
+	}
+
+	// check if the acl entries are well formed
+	for _, entry := range append(cfg.ACL, cfg.ACLReadOnly...) {
+		err = acl.Validate(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if the authentication settings are valid
+	err = cfg.validateAuth()
+	if err != nil {
+		return err
+	}
+
+	// check if the given endpoint verification is supported
+	if !verifiers.IsValidType(cfg.EndpointVerification) {
+		return errors.Errorf("Invalid endpoint verification %v", cfg.EndpointVerification)
+	}
+
+	// check if the given payload logging mode is supported
+	if !redact.IsValidPayloadMode(cfg.PayloadLogging) {
+		return errors.Errorf("Invalid payload logging %v", cfg.PayloadLogging)
+	}
+
+	// check if the http client settings are valid
+	err = cfg.AmsClient.validate("ams_client")
+	if err != nil {
+		return err
+	}
+
+	err = cfg.PushClient.validate("push_client")
+	if err != nil {
+		return err
+	}
+
+	// check if the subscription loading settings are valid
+	err = cfg.SubscriptionLoading.validate()
+	if err != nil {
+		return err
+	}
+
+	// check if the cluster settings are valid
+	err = cfg.validateCluster()
+	if err != nil {
+		return err
+	}
+
+	// check if the election settings are valid
+	err = cfg.Election.validate()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Election.Enabled() && cfg.Cluster.Enabled() {
+		return errors.Errorf("Invalid configuration, election and cluster can't be enabled at the same time")
+	}
+
+	// check if the destination policy is valid
+	_, err = cfg.GetDestinationPolicy()
+	if err != nil {
+		return errors.Errorf("Invalid destination policy, %v", err.Error())
+	}
+
+	return nil
+}
+
+// validateAuth checks that the configured auth providers are supported and have everything they need
+func (cfg *Config) validateAuth() error {
+
+	for _, provider := range cfg.AuthProviders {
+
+		switch provider {
+
+		case MTLSAuthProvider:
+
+		case TokenAuthProvider:
+			if len(cfg.AuthTokens) == 0 {
+				return errors.Errorf("Empty value for field auth_tokens")
+			}
+
+		case JWTAuthProvider:
+			if cfg.JWTAuth.JWKSFile == "" {
+				return errors.Errorf("Empty value for field jwt_auth.jwks_file")
+			}
+			if cfg.JWTAuth.Issuer == "" {
+				return errors.Errorf("Empty value for field jwt_auth.issuer")
+			}
+			if cfg.JWTAuth.Audience == "" {
+				return errors.Errorf("Empty value for field jwt_auth.audience")
+			}
+
+		default:
+			return errors.Errorf("Invalid auth provider %v", provider)
+		}
+	}
+
+	for _, token := range cfg.AuthTokens {
+
+		if token.Name == "" || token.Token == "" {
+			return errors.Errorf("Invalid auth token %v, both a name and a token are required", token.Name)
+		}
+
+		_, err := acl.ParseRole(token.Role)
+		if err != nil {
+			return errors.Errorf("Invalid auth token %v, %v", token.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// validateCluster checks the cluster settings and that the instances of the cluster can authenticate each other,
+// either through their certificates or through the cluster token
+func (cfg *Config) validateCluster() error {
+
+	err := cfg.Cluster.validate()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Cluster.Enabled() && !cfg.TLSEnabled && cfg.ClusterToken == "" {
+		return errors.Errorf("Invalid configuration, cluster mode requires either tls_enabled or a cluster_token")
+	}
+
+	return nil
+}
+
 // validateRequired accepts checks whether or not all required fields are set
 func (cfg *Config) validateRequired() error {
 
-	v := reflect.ValueOf(*cfg)
+	v := reflect.ValueOf(cfg).Elem()
 
 	for i := 0; i < v.NumField(); i++ {
 
@@ -173,19 +1166,50 @@ func (cfg *Config) validateRequired() error {
 	return nil
 }
 
-// loadTLSConfig initialises the tls configuration field
+// loadTLSConfig loads the certificate and the CA pool and initialises the tls configuration field.
+// The configuration resolves the certificate and the CA pool on every handshake, so that a reload can replace them
 func (cfg *Config) loadTLSConfig() error {
 
+	// summarise the files before loading them, so that any change while loading is detected later on
+	cfg.certificatesState = cfg.filesState()
+
 	c, err := tls.LoadX509KeyPair(cfg.Certificate, cfg.CertificateKey)
 	if err != nil {
 		return err
 	}
 
-	tlsConfig := &tls.Config{
-		ClientAuth:   cfg.GetClientAuthType(),
-		Certificates: []tls.Certificate{c},
-		MinVersion:   tls.VersionTLS12,
-		ClientCAs:    cfg.loadCAs(),
+	cas, err := cfg.loadCAs()
+	if err != nil {
+		return err
+	}
+
+	cfg.certificate = &c
+	cfg.clientCAs = cas
+
+	tlsConfig := cfg.serverTLSConfig()
+	tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return cfg.serverTLSConfig(), nil
+	}
+
+	cfg.tlsConfig = tlsConfig
+	return nil
+}
+
+// serverTLSConfig builds the tls configuration for a handshake out of the currently loaded certificate and CA pool
+func (cfg *Config) serverTLSConfig() *tls.Config {
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	return &tls.Config{
+		ClientAuth: cfg.GetClientAuthType(),
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cfg.mutex.RLock()
+			defer cfg.mutex.RUnlock()
+			return cfg.certificate, nil
+		},
+		MinVersion: tls.VersionTLS12,
+		ClientCAs:  cfg.clientCAs,
 		CurvePreferences: []tls.CurveID{
 			tls.CurveP256,
 			tls.X25519,
@@ -200,9 +1224,52 @@ func (cfg *Config) loadTLSConfig() error {
 		},
 		NextProtos: []string{"h2"},
 	}
+}
 
-	cfg.tlsConfig = tlsConfig
-	return nil
+// GetClientTLSConfig returns the tls configuration the service uses when it calls the other instances of the cluster,
+// presenting its own certificate and trusting the same CA pool as the grpc server
+func (cfg *Config) GetClientTLSConfig() *tls.Config {
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	return &tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cfg.mutex.RLock()
+			defer cfg.mutex.RUnlock()
+			return cfg.certificate, nil
+		},
+		RootCAs:    cfg.clientCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// NewClientTLSConfig returns the tls configuration of a client of the grpc api, e.g. ams-push-ctl.
+// The client presents the provided certificate, if any, and trusts the .pem CAs of the provided directory,
+// or the system CAs if the directory is empty
+func NewClientTLSConfig(certificate, key, casDir string) (*tls.Config, error) {
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if certificate != "" || key != "" {
+		c, err := tls.LoadX509KeyPair(certificate, key)
+		if err != nil {
+			return nil, errors.Errorf("Could not load certificate %v, %v", certificate, err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{c}
+	}
+
+	if casDir != "" {
+		cas, err := (&Config{CertificateAuthoritiesDir: casDir}).loadCAs()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = cas
+	}
+
+	return tlsConfig, nil
 }
 
 // GetClientAuthType returns which client auth strategy should the server follow when validating a certificate
@@ -214,15 +1281,27 @@ func (cfg *Config) GetClientAuthType() tls.ClientAuthType {
 		authType = tls.RequireAnyClientCert
 	}
 
+	// callers that authenticate through bearer tokens, e.g. behind a tls terminating proxy, might not have a certificate
+	for _, provider := range cfg.GetAuthProviders() {
+		if provider == TokenAuthProvider || provider == JWTAuthProvider {
+			authType = tls.VerifyClientCertIfGiven
+			if cfg.TrustUnknownCAs {
+				authType = tls.RequestClientCert
+			}
+			break
+		}
+	}
+
 	return authType
 }
 
-// loadCAs walks the specified CertificateAuthoritiesDir and uses each .pem file to build the trusted CA pool
-func (cfg *Config) loadCAs() *x509.CertPool {
+// loadCAs walks the specified CertificateAuthoritiesDir and uses each .pem file to build the trusted CA pool,
+// any file that can't be read or parsed fails the whole pool, since a partial pool would reject the callers it misses
+func (cfg *Config) loadCAs() (*x509.CertPool, error) {
 
 	log.WithFields(
 		log.Fields{
-			"type": "service_log",
+			"type": "system_log",
 			"path": cfg.CertificateAuthoritiesDir,
 		},
 	).Info("Trying to load CAs")
@@ -231,22 +1310,16 @@ func (cfg *Config) loadCAs() *x509.CertPool {
 	roots := x509.NewCertPool()
 	err := filepath.Walk(cfg.CertificateAuthoritiesDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.WithFields(
-				log.Fields{
-					"type":  "error_log",
-					"path":  path,
-					"error": err.Error(),
-				},
-			).Error("Prevent panic by handling failure accessing a path")
 			return err
 		}
-		if ok, _ := filepath.Match(pattern, info.Name()); ok {
-			bytes, err := os.ReadFile(filepath.Join(cfg.CertificateAuthoritiesDir, info.Name()))
+		// the CAs of the subdirectories are loaded as well
+		if ok, _ := filepath.Match(pattern, info.Name()); ok && !info.IsDir() {
+			bytes, err := os.ReadFile(path)
 			if err != nil {
 				return err
 			}
 			if ok = roots.AppendCertsFromPEM(bytes); !ok {
-				return errors.New("Something went wrong while parsing certificate: " + filepath.Join(cfg.CertificateAuthoritiesDir, info.Name()))
+				return errors.New("Something went wrong while parsing certificate: " + path)
 			}
 		}
 		return nil
@@ -260,13 +1333,14 @@ func (cfg *Config) loadCAs() *x509.CertPool {
 				"error": err.Error(),
 			},
 		).Error("Could not walk down the path")
-	} else {
-		log.WithFields(
-			log.Fields{
-				"type": "service_log",
-			},
-		).Info("All CAs parsed and loaded successfully")
+		return nil, errors.Errorf("Could not load CAs from %v, %v", cfg.CertificateAuthoritiesDir, err.Error())
 	}
 
-	return roots
+	log.WithFields(
+		log.Fields{
+			"type": "system_log",
+		},
+	).Info("All CAs parsed and loaded successfully")
+
+	return roots, nil
 }
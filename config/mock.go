@@ -1,5 +1,19 @@
 package config
 
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/ARGOeu/ams-push-server/netpolicy"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
 // NewMockConfig returns a config to be used in tests
 func NewMockConfig() *Config {
 	cfg := new(Config)
@@ -13,5 +27,88 @@ func NewMockConfig() *Config {
 	cfg.VerifySSL = true
 	cfg.TrustUnknownCAs = false
 	cfg.SkipSubsLoad = true
+	// the destinations of the tests resolve without any dns lookup
+	cfg.resolver = netpolicy.MockResolver{}
 	return cfg
 }
+
+// WriteMockCertificate generates a self signed certificate with the provided common name and validity period,
+// stores it alongside its key in the directory and returns their paths
+func WriteMockCertificate(dir string, cn string, validity time.Duration) (string, string, error) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		return "", "", err
+	}
+
+	return certPath, keyPath, nil
+}
+
+// NewMockTLSConfig returns a tls enabled config to be used in tests, serving a certificate generated in the directory
+func NewMockTLSConfig(dir string, validity time.Duration) (*Config, error) {
+
+	certPath, keyPath, err := WriteMockCertificate(dir, "localhost", validity)
+	if err != nil {
+		return nil, err
+	}
+
+	// the self signed certificate is also the only trusted CA
+	caDir := filepath.Join(dir, "cas")
+	err = os.Mkdir(caDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.WriteFile(filepath.Join(caDir, "ca.pem"), ca, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := NewMockConfig()
+	cfg.TLSEnabled = true
+	cfg.Certificate = certPath
+	cfg.CertificateKey = keyPath
+	cfg.CertificateAuthoritiesDir = caDir
+
+	err = cfg.loadTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	log "github.com/sirupsen/logrus"
+	"io"
+	"reflect"
+)
+
+// Reload re-reads the configuration from the reader and applies every changed field that is tagged as live,
+// while the service keeps running. The certificate and the CA pool are always reloaded when tls is enabled.
+// If the new configuration is invalid, the current one is kept and the error is returned.
+// It returns the fields that have changed but can't take effect without a restart
+func (cfg *Config) Reload(from io.Reader) ([]string, error) {
+
+	nc := new(Config)
+
+	err := nc.load(from)
+	if err != nil {
+		return nil, err
+	}
+
+	// tls can't be enabled or disabled live, reload the certificates only if tls is already in use
+	if cfg.TLSEnabled {
+		err = nc.loadTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var restart []string
+
+	cv := reflect.ValueOf(cfg).Elem()
+	nv := reflect.ValueOf(nc).Elem()
+
+	cfg.mutex.Lock()
+
+	for i := 0; i < cv.NumField(); i++ {
+
+		fl := cv.Type().Field(i)
+
+		// skip unexported and non configuration fields
+		if fl.PkgPath != "" || fl.Tag.Get("json") == "" {
+			continue
+		}
+
+		if reflect.DeepEqual(cv.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+
+		if fl.Tag.Get("reload") != "live" {
+			restart = append(restart, fl.Tag.Get("json"))
+			continue
+		}
+
+		cv.Field(i).Set(nv.Field(i))
+
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"field": fl.Tag.Get("json"),
+				"value": maskedValue(fl, nv.Field(i)),
+			},
+		).Info("Configuration field has been reloaded")
+	}
+
+	if cfg.TLSEnabled {
+		cfg.certificate = nc.certificate
+		cfg.clientCAs = nc.clientCAs
+		cfg.certificatesState = nc.certificatesState
+	}
+
+	hooks := make([]func(cfg *Config), len(cfg.reloadHooks))
+	copy(hooks, cfg.reloadHooks)
+
+	cfg.mutex.Unlock()
+
+	for _, hook := range hooks {
+		hook(cfg)
+	}
+
+	return restart, nil
+}
+
+// OnReload registers a function to be called after every successful reload
+func (cfg *Config) OnReload(hook func(cfg *Config)) {
+
+	cfg.mutex.Lock()
+	defer cfg.mutex.Unlock()
+
+	cfg.reloadHooks = append(cfg.reloadHooks, hook)
+}
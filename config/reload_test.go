@@ -0,0 +1,96 @@
+package config
+
+import (
+	"crypto/x509"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ReloadTestSuite struct {
+	suite.Suite
+}
+
+const reloadTestCfg = `
+{
+  "bind_port": %v,
+  "certificate": "%v",
+  "certificate_key": "%v",
+  "certificate_authorities_dir": "%v",
+  "ams_token": "sometoken",
+  "ams_host": "localhost",
+  "ams_port": 8080,
+  "tls_enabled": true,
+  "log_level": "%v",
+  "acl": ["%v"]
+}
+`
+
+// writeCertificate generates a self signed certificate with the provided common name, that expires in an hour,
+// and stores it in the directory
+func writeCertificate(dir string, cn string) (string, string, error) {
+	return WriteMockCertificate(dir, cn, time.Hour)
+}
+
+// servedCommonName returns the common name of the certificate the tls configuration currently serves
+func servedCommonName(cfg *Config) string {
+	tlsCfg, _ := cfg.GetTLSConfig().GetConfigForClient(nil)
+	c, _ := tlsCfg.GetCertificate(nil)
+	leaf, _ := x509.ParseCertificate(c.Certificate[0])
+	return leaf.Subject.CommonName
+}
+
+// TestReload tests that live fields are applied while fields that require a restart are reported
+func (suite *ReloadTestSuite) TestReload() {
+
+	dir := suite.T().TempDir()
+	caDir := suite.T().TempDir()
+	certPath, keyPath, err := writeCertificate(dir, "first.example.com")
+	suite.Nil(err)
+
+	cfg := new(Config)
+	e1 := cfg.LoadFromJson(strings.NewReader(fmt.Sprintf(reloadTestCfg, 9000, certPath, keyPath, caDir, "INFO", "acl1")))
+	suite.Nil(e1)
+	suite.Equal("first.example.com", servedCommonName(cfg))
+
+	reloaded := 0
+	cfg.OnReload(func(cfg *Config) {
+		reloaded++
+	})
+
+	// rotate the certificate and change live and restart only fields
+	_, _, err = writeCertificate(dir, "second.example.com")
+	suite.Nil(err)
+
+	restart, e2 := cfg.Reload(strings.NewReader(fmt.Sprintf(reloadTestCfg, 9001, certPath, keyPath, caDir, "DEBUG", "acl2")))
+	suite.Nil(e2)
+	suite.Equal([]string{"bind_port"}, restart)
+	suite.Equal(1, reloaded)
+	suite.Equal(9000, cfg.BindPort)
+	suite.Equal(log.DebugLevel, cfg.GetLogLevel())
+	suite.Equal([]string{"acl2"}, cfg.GetACL())
+	suite.Equal("second.example.com", servedCommonName(cfg))
+
+	// invalid configuration, the current one is kept
+	restart, e3 := cfg.Reload(strings.NewReader(fmt.Sprintf(reloadTestCfg, 9000, certPath, keyPath, caDir, "unknown", "acl3")))
+	suite.Nil(restart)
+	suite.Equal("Invalid log level unknown", e3.Error())
+	suite.Equal(1, reloaded)
+	suite.Equal([]string{"acl2"}, cfg.GetACL())
+
+	// missing certificate, the current one is kept
+	_, e4 := cfg.Reload(strings.NewReader(fmt.Sprintf(reloadTestCfg, 9000, filepath.Join(dir, "missing.pem"), keyPath, caDir, "INFO", "acl3")))
+	suite.NotNil(e4)
+	suite.Equal([]string{"acl2"}, cfg.GetACL())
+	suite.Equal("second.example.com", servedCommonName(cfg))
+}
+
+func TestReloadTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(ReloadTestSuite))
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// supported configuration file formats
+const (
+	JSONFormat = "json"
+	YAMLFormat = "yaml"
+	TOMLFormat = "toml"
+)
+
+// EnvPrefix is the prefix of the environment variables that override configuration fields
+// e.g. AMS_PUSH_AMS_HOST overrides ams_host, while nested fields are separated by a double underscore
+// e.g. AMS_PUSH_LOG_FILE__PATH overrides log_file.path
+const EnvPrefix = "AMS_PUSH_"
+
+// envNestingSeparator separates the names of nested fields in environment variables
+const envNestingSeparator = "__"
+
+// Source describes the layers that the configuration is built from, in order of precedence, from lowest to highest:
+// the built-in defaults of the fields that are left unset, the configuration file,
+// the AMS_PUSH_* environment variables and the key=value overrides of the command line
+type Source struct {
+	// Path of the configuration file, its format is selected by its extension(.json,.yaml,.yml,.toml).
+	// When empty, the configuration is built only from the environment variables and the overrides
+	Path string
+	// Environ holds the environment variables in key=value form, usually os.Environ()
+	Environ []string
+	// Overrides holds key=value pairs where the key is the json name of a field, nested fields are separated by dots
+	// e.g. log_file.path=/var/log/ams-push-server.log
+	Overrides []string
+}
+
+// Read merges the layers of the source and returns the resulting configuration in json,
+// ready to be loaded through LoadFromJson or Reload
+func (s Source) Read() (io.Reader, error) {
+
+	values := make(map[string]interface{})
+
+	if s.Path != "" {
+
+		b, err := os.ReadFile(s.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		values, err = decode(bytes.NewReader(b), FormatFromPath(s.Path))
+		if err != nil {
+			return nil, errors.Errorf("Could not parse configuration file %v, %v", s.Path, err.Error())
+		}
+	}
+
+	for _, env := range s.Environ {
+
+		key, value, _ := strings.Cut(env, "=")
+
+		name, ok := strings.CutPrefix(key, EnvPrefix)
+		if !ok {
+			continue
+		}
+
+		path := strings.Split(strings.ToLower(name), envNestingSeparator)
+
+		// other variables may share the prefix, skip the ones that don't refer to a field
+		if _, err := fieldType(path); err != nil {
+			continue
+		}
+
+		err := setValue(values, path, value)
+		if err != nil {
+			return nil, errors.Errorf("Invalid environment variable %v, %v", key, err.Error())
+		}
+	}
+
+	for _, override := range s.Overrides {
+
+		key, value, found := strings.Cut(override, "=")
+		if !found {
+			return nil, errors.Errorf("Invalid override %v, it should be in key=value form", override)
+		}
+
+		err := setValue(values, strings.Split(key, "."), value)
+		if err != nil {
+			return nil, errors.Errorf("Invalid override %v, %v", key, err.Error())
+		}
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// LoadFromYaml fills the config struct with the yaml contents of the reader
+func (cfg *Config) LoadFromYaml(from io.Reader) error {
+	return cfg.loadFromFormat(from, YAMLFormat)
+}
+
+// LoadFromToml fills the config struct with the toml contents of the reader
+func (cfg *Config) LoadFromToml(from io.Reader) error {
+	return cfg.loadFromFormat(from, TOMLFormat)
+}
+
+// loadFromFormat converts the contents of the reader from the provided format to json and loads them
+func (cfg *Config) loadFromFormat(from io.Reader, format string) error {
+
+	values, err := decode(from, format)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	return cfg.LoadFromJson(bytes.NewReader(b))
+}
+
+// FormatFromPath returns the format of a configuration file based on its extension,
+// any file that isn't yaml or toml is considered json
+func FormatFromPath(path string) string {
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return YAMLFormat
+	case ".toml":
+		return TOMLFormat
+	}
+
+	return JSONFormat
+}
+
+// decode reads the fields of a configuration in the provided format, keyed by their json names
+func decode(from io.Reader, format string) (map[string]interface{}, error) {
+
+	values := make(map[string]interface{})
+
+	switch format {
+
+	case YAMLFormat:
+		err := yaml.NewDecoder(from).Decode(&values)
+		// an empty file holds no fields
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+	case TOMLFormat:
+		_, err := toml.NewDecoder(from).Decode(&values)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		d := json.NewDecoder(from)
+		// keep numbers as they are written
+		d.UseNumber()
+		err := d.Decode(&values)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// setValue converts the textual value to the type of the field at the provided path and stores it in the values
+func setValue(values map[string]interface{}, path []string, value string) error {
+
+	t, err := fieldType(path)
+	if err != nil {
+		return err
+	}
+
+	v, err := parseValue(t, value)
+	if err != nil {
+		return errors.Errorf("invalid value for field %v, %v", strings.Join(path, "."), err.Error())
+	}
+
+	// create any missing parent of the field
+	for _, name := range path[:len(path)-1] {
+		child, ok := values[name].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			values[name] = child
+		}
+		values = child
+	}
+
+	values[path[len(path)-1]] = v
+
+	return nil
+}
+
+// fieldType returns the type of the configuration field at the provided path of json names
+func fieldType(path []string) (reflect.Type, error) {
+
+	t := reflect.TypeOf(Config{})
+
+	for i, name := range path {
+
+		switch t.Kind() {
+
+		case reflect.Struct:
+			sf, found := jsonField(t, name)
+			if !found {
+				return nil, errors.Errorf("unknown field %v", strings.Join(path[:i+1], "."))
+			}
+			t = sf.Type
+
+		case reflect.Map:
+			t = t.Elem()
+
+		default:
+			return nil, errors.Errorf("field %v has no nested fields", strings.Join(path[:i], "."))
+		}
+	}
+
+	return t, nil
+}
+
+// jsonField returns the exported field of the struct type with the provided json name
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath == "" && sf.Tag.Get("json") == name {
+			return sf, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// parseValue converts the textual value of a field to its type.
+// Strings are taken as they are, lists of strings can also be given comma separated
+// while any other value should be written in json e.g. [{"name":"probe","token":"env:PROBE_TOKEN","role":"read_only"}]
+func parseValue(t reflect.Type, value string) (interface{}, error) {
+
+	switch t.Kind() {
+
+	case reflect.String:
+		return value, nil
+
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(value, 10, 64)
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			list := []string{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			return list, nil
+		}
+	}
+
+	var v interface{}
+
+	err := json.Unmarshal([]byte(value), &v)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type SourceTestSuite struct {
+	suite.Suite
+}
+
+const yamlTestCfg = `
+bind_port: 9000
+certificate: /path/cert.pem
+certificate_key: /path/certkey.pem
+certificate_authorities_dir: /path/to/cas
+ams_token: sometoken
+ams_host: localhost
+ams_port: 8080
+log_level: INFO
+acl:
+  - OU=my.local,O=mkcert development certificate
+log_levels:
+  worker: DEBUG
+log_file:
+  path: /var/log/push.log
+  max_backups: 3
+auth_tokens:
+  - name: probe
+    token: probetoken
+    role: read_only
+`
+
+const tomlTestCfg = `
+bind_port = 9000
+certificate = "/path/cert.pem"
+certificate_key = "/path/certkey.pem"
+certificate_authorities_dir = "/path/to/cas"
+ams_token = "sometoken"
+ams_host = "localhost"
+ams_port = 8080
+log_level = "INFO"
+acl = ["OU=my.local,O=mkcert development certificate"]
+
+[log_levels]
+worker = "DEBUG"
+
+[log_file]
+path = "/var/log/push.log"
+max_backups = 3
+
+[[auth_tokens]]
+name = "probe"
+token = "probetoken"
+role = "read_only"
+`
+
+// assertTestCfg checks the fields of the yaml and toml test configurations
+func (suite *SourceTestSuite) assertTestCfg(cfg *Config) {
+	suite.Equal(9000, cfg.BindPort)
+	suite.Equal("localhost", cfg.AmsHost)
+	suite.Equal(8080, cfg.AmsPort)
+	suite.Equal([]string{"OU=my.local,O=mkcert development certificate"}, cfg.ACL)
+	suite.Equal(map[string]string{"worker": "DEBUG"}, cfg.LogLevels)
+	suite.Equal(LogFile{Path: "/var/log/push.log", MaxBackups: 3}, cfg.LogFile)
+	suite.Equal([]AuthToken{{Name: "probe", Token: "probetoken", Role: "read_only"}}, cfg.AuthTokens)
+}
+
+// writeSource stores the configuration in a file of the temporary directory
+func (suite *SourceTestSuite) writeSource(name, contents string) string {
+	path := filepath.Join(suite.T().TempDir(), name)
+	suite.Nil(os.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func (suite *SourceTestSuite) TestLoadFromYaml() {
+
+	cfg := new(Config)
+	suite.Nil(cfg.LoadFromYaml(strings.NewReader(yamlTestCfg)))
+	suite.assertTestCfg(cfg)
+
+	// required fields are still checked
+	cfg2 := new(Config)
+	e2 := cfg2.LoadFromYaml(strings.NewReader("bind_port: 9000"))
+	suite.Equal("Empty value for field certificate", e2.Error())
+
+	cfg3 := new(Config)
+	suite.NotNil(cfg3.LoadFromYaml(strings.NewReader("bind_port: [")))
+}
+
+func (suite *SourceTestSuite) TestLoadFromToml() {
+
+	cfg := new(Config)
+	suite.Nil(cfg.LoadFromToml(strings.NewReader(tomlTestCfg)))
+	suite.assertTestCfg(cfg)
+
+	cfg2 := new(Config)
+	suite.NotNil(cfg2.LoadFromToml(strings.NewReader("bind_port = ")))
+}
+
+func (suite *SourceTestSuite) TestFormatFromPath() {
+	suite.Equal(YAMLFormat, FormatFromPath("/etc/push/config.yaml"))
+	suite.Equal(YAMLFormat, FormatFromPath("/etc/push/config.YML"))
+	suite.Equal(TOMLFormat, FormatFromPath("/etc/push/config.toml"))
+	suite.Equal(JSONFormat, FormatFromPath("/etc/push/config.json"))
+	suite.Equal(JSONFormat, FormatFromPath("/etc/push/config"))
+}
+
+func (suite *SourceTestSuite) TestSourceRead() {
+
+	for _, path := range []string{
+		suite.writeSource("config.yml", yamlTestCfg),
+		suite.writeSource("config.toml", tomlTestCfg),
+	} {
+
+		r, err := Source{Path: path}.Read()
+		suite.Nil(err)
+
+		cfg := new(Config)
+		suite.Nil(cfg.LoadFromJson(r))
+		suite.assertTestCfg(cfg)
+	}
+
+	_, e1 := Source{Path: "/missing/config.json"}.Read()
+	suite.NotNil(e1)
+
+	path := suite.writeSource("invalid.json", "{")
+	_, e2 := Source{Path: path}.Read()
+	suite.Equal("Could not parse configuration file "+path+", unexpected EOF", e2.Error())
+}
+
+// TestSourceLayers tests that environment variables override the file and the overrides override both
+func (suite *SourceTestSuite) TestSourceLayers() {
+
+	path := suite.writeSource("config.yaml", yamlTestCfg)
+
+	source := Source{
+		Path: path,
+		Environ: []string{
+			"HOME=/root",
+			"AMS_PUSH_AMS_HOST=ams.example.com",
+			"AMS_PUSH_AMS_PORT=443",
+			"AMS_PUSH_VERIFY_SSL=true",
+			"AMS_PUSH_LOG_FILE__PATH=/var/log/env.log",
+			"AMS_PUSH_LOG_LEVELS__GRPC=ERROR",
+			"AMS_PUSH_ACL=acl1, acl2",
+			"AMS_PUSH_SERVER_VERSION=1.0.0",
+		},
+		Overrides: []string{
+			"ams_port=8443",
+			"acl_read_only=[\"dns:monitoring.example.com\"]",
+			"auth_tokens=[{\"name\":\"admin\",\"token\":\"admintoken\",\"role\":\"admin\"}]",
+		},
+	}
+
+	r, err := source.Read()
+	suite.Nil(err)
+
+	cfg := new(Config)
+	suite.Nil(cfg.LoadFromJson(r))
+
+	suite.Equal("ams.example.com", cfg.AmsHost)
+	suite.Equal(8443, cfg.AmsPort)
+	suite.True(cfg.VerifySSL)
+	suite.Equal(LogFile{Path: "/var/log/env.log", MaxBackups: 3}, cfg.LogFile)
+	suite.Equal(map[string]string{"worker": "DEBUG", "grpc": "ERROR"}, cfg.LogLevels)
+	suite.Equal([]string{"acl1", "acl2"}, cfg.ACL)
+	suite.Equal([]string{"dns:monitoring.example.com"}, cfg.ACLReadOnly)
+	suite.Equal([]AuthToken{{Name: "admin", Token: "admintoken", Role: "admin"}}, cfg.AuthTokens)
+	// untouched fields keep the values of the file
+	suite.Equal(9000, cfg.BindPort)
+}
+
+// TestSourceWithoutFile tests that the whole configuration can be provided through the environment
+func (suite *SourceTestSuite) TestSourceWithoutFile() {
+
+	source := Source{
+		Environ: []string{
+			"AMS_PUSH_BIND_PORT=9000",
+			"AMS_PUSH_CERTIFICATE=/path/cert.pem",
+			"AMS_PUSH_CERTIFICATE_KEY=/path/certkey.pem",
+			"AMS_PUSH_CERTIFICATE_AUTHORITIES_DIR=/path/to/cas",
+			"AMS_PUSH_AMS_TOKEN=sometoken",
+			"AMS_PUSH_AMS_PORT=8080",
+			"AMS_PUSH_LOG_LEVEL=INFO",
+		},
+	}
+
+	r, err := source.Read()
+	suite.Nil(err)
+
+	// the merged result is still checked for required fields
+	cfg := new(Config)
+	e1 := cfg.LoadFromJson(r)
+	suite.Equal("Empty value for field ams_host", e1.Error())
+
+	source.Overrides = []string{"ams_host=localhost"}
+	r, err = source.Read()
+	suite.Nil(err)
+
+	cfg2 := new(Config)
+	suite.Nil(cfg2.LoadFromJson(r))
+	suite.Equal("localhost", cfg2.AmsHost)
+}
+
+func (suite *SourceTestSuite) TestSourceErrors() {
+
+	_, e1 := Source{Environ: []string{"AMS_PUSH_AMS_PORT=port"}}.Read()
+	suite.Equal("Invalid environment variable AMS_PUSH_AMS_PORT, invalid value for field ams_port, strconv.ParseInt: parsing \"port\": invalid syntax", e1.Error())
+
+	_, e2 := Source{Overrides: []string{"ams_host"}}.Read()
+	suite.Equal("Invalid override ams_host, it should be in key=value form", e2.Error())
+
+	_, e3 := Source{Overrides: []string{"ams_hostname=localhost"}}.Read()
+	suite.Equal("Invalid override ams_hostname, unknown field ams_hostname", e3.Error())
+
+	_, e4 := Source{Overrides: []string{"log_file.size=10"}}.Read()
+	suite.Equal("Invalid override log_file.size, unknown field log_file.size", e4.Error())
+
+	_, e5 := Source{Overrides: []string{"ams_host.name=localhost"}}.Read()
+	suite.Equal("Invalid override ams_host.name, field ams_host has no nested fields", e5.Error())
+
+	_, e6 := Source{Overrides: []string{"verify_ssl=maybe"}}.Read()
+	suite.NotNil(e6)
+}
+
+func (suite *SourceTestSuite) TestParseValue() {
+
+	r, err := Source{Overrides: []string{"acl=", "skip_subs_load=1", "log_levels={\"sender\":\"ERROR\"}"}}.Read()
+	suite.Nil(err)
+
+	values := make(map[string]interface{})
+	suite.Nil(json.NewDecoder(r).Decode(&values))
+	suite.Equal(map[string]interface{}{
+		"acl":            []interface{}{},
+		"skip_subs_load": true,
+		"log_levels":     map[string]interface{}{"sender": "ERROR"},
+	}, values)
+}
+
+func TestSourceTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(SourceTestSuite))
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCertificateWatchInterval is used when no certificate watch interval has been configured
+	DefaultCertificateWatchInterval = time.Minute
+	// DefaultCertificateExpiryWarningDays is used when no certificate expiry warning has been configured
+	DefaultCertificateExpiryWarningDays = 14
+	// certificateExpiryWarningPeriod is how often the expiry warning is repeated
+	certificateExpiryWarningPeriod = 24 * time.Hour
+)
+
+// CertificateExpiry returns the expiration time of the certificate that is currently being served
+func (cfg *Config) CertificateExpiry() (time.Time, error) {
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	if cfg.certificate == nil || len(cfg.certificate.Certificate) == 0 {
+		return time.Time{}, errors.New("no certificate loaded")
+	}
+
+	leaf := cfg.certificate.Leaf
+	if leaf == nil {
+		var err error
+		leaf, err = x509.ParseCertificate(cfg.certificate.Certificate[0])
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return leaf.NotAfter, nil
+}
+
+// WatchCertificates polls the modification times of the certificate, its key and the CA directory
+// and loads them again whenever they change. It also warns, once a day, if the certificate is about to expire.
+// It blocks until the context is canceled
+func (cfg *Config) WatchCertificates(ctx context.Context) {
+
+	interval := time.Duration(cfg.CertificateWatchInterval) * time.Second
+	if interval <= 0 {
+		interval = DefaultCertificateWatchInterval
+	}
+
+	lastWarning := time.Time{}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		if time.Since(lastWarning) >= certificateExpiryWarningPeriod && cfg.warnCertificateExpiry() {
+			lastWarning = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg.mutex.RLock()
+		loaded := cfg.certificatesState
+		cfg.mutex.RUnlock()
+
+		if cfg.filesState() == loaded {
+			continue
+		}
+
+		err := cfg.ReloadCertificates()
+		if err != nil {
+			// keep serving the current certificate, the files might still be in the middle of being replaced
+			log.WithFields(
+				log.Fields{
+					"type":  "error_log",
+					"error": err.Error(),
+				},
+			).Error("Could not reload certificates, keeping the current ones")
+			continue
+		}
+
+		lastWarning = time.Time{}
+	}
+}
+
+// ReloadCertificates loads the certificate, its key and the CA pool from the configured paths
+// and serves them on every new tls handshake, existing connections are not affected
+func (cfg *Config) ReloadCertificates() error {
+
+	cfg.mutex.RLock()
+	nc := &Config{
+		Certificate:               cfg.Certificate,
+		CertificateKey:            cfg.CertificateKey,
+		CertificateAuthoritiesDir: cfg.CertificateAuthoritiesDir,
+		TrustUnknownCAs:           cfg.TrustUnknownCAs,
+	}
+	cfg.mutex.RUnlock()
+
+	err := nc.loadTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg.mutex.Lock()
+	cfg.certificate = nc.certificate
+	cfg.clientCAs = nc.clientCAs
+	cfg.certificatesState = nc.certificatesState
+	cfg.mutex.Unlock()
+
+	expiry, _ := cfg.CertificateExpiry()
+
+	log.WithFields(
+		log.Fields{
+			"type":        "system_log",
+			"certificate": nc.Certificate,
+			"expiry":      expiry.UTC().Format(time.RFC3339),
+		},
+	).Info("Certificates reloaded successfully")
+
+	return nil
+}
+
+// warnCertificateExpiry logs a warning if the certificate expires within the configured warning period,
+// it returns whether or not a warning has been logged
+func (cfg *Config) warnCertificateExpiry() bool {
+
+	expiry, err := cfg.CertificateExpiry()
+	if err != nil {
+		return false
+	}
+
+	days := cfg.CertificateExpiryWarningDays
+	if days <= 0 {
+		days = DefaultCertificateExpiryWarningDays
+	}
+
+	remaining := time.Until(expiry)
+	if remaining > time.Duration(days)*24*time.Hour {
+		return false
+	}
+
+	log.WithFields(
+		log.Fields{
+			"type":      "system_log",
+			"expiry":    expiry.UTC().Format(time.RFC3339),
+			"remaining": remaining.Round(time.Minute).String(),
+		},
+	).Warning("Certificate is about to expire")
+
+	return true
+}
+
+// filesState summarises the size and the modification time of the certificate, its key
+// and every .pem file of the CA directory, any change to them results in a different summary
+func (cfg *Config) filesState() string {
+
+	cfg.mutex.RLock()
+	paths := []string{cfg.Certificate, cfg.CertificateKey}
+	caDir := cfg.CertificateAuthoritiesDir
+	cfg.mutex.RUnlock()
+
+	cas, _ := filepath.Glob(filepath.Join(caDir, "*.pem"))
+	sort.Strings(cas)
+	paths = append(paths, cas...)
+
+	state := make([]string, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			state = append(state, fmt.Sprintf("%v:missing", path))
+			continue
+		}
+		state = append(state, fmt.Sprintf("%v:%v:%v", path, info.Size(), info.ModTime().UnixNano()))
+	}
+
+	return strings.Join(state, ",")
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type WatchTestSuite struct {
+	suite.Suite
+}
+
+// loadTLSTestConfig loads a tls enabled configuration that serves a freshly generated certificate
+func (suite *WatchTestSuite) loadTLSTestConfig(dir string, caDir string) *Config {
+
+	certPath, keyPath, err := writeCertificate(dir, "first.example.com")
+	suite.Nil(err)
+
+	cfg := new(Config)
+	err = cfg.LoadFromJson(strings.NewReader(fmt.Sprintf(reloadTestCfg, 9000, certPath, keyPath, caDir, "INFO", "acl1")))
+	suite.Nil(err)
+
+	return cfg
+}
+
+// TestCertificateExpiry tests the retrieval of the served certificate's expiry
+func (suite *WatchTestSuite) TestCertificateExpiry() {
+
+	_, e1 := new(Config).CertificateExpiry()
+	suite.Equal("no certificate loaded", e1.Error())
+
+	cfg := suite.loadTLSTestConfig(suite.T().TempDir(), suite.T().TempDir())
+
+	expiry, e2 := cfg.CertificateExpiry()
+	suite.Nil(e2)
+	suite.WithinDuration(time.Now().Add(time.Hour), expiry, time.Minute)
+
+	// the generated certificate expires in an hour, within the default warning period
+	suite.True(cfg.warnCertificateExpiry())
+
+	cfg.CertificateExpiryWarningDays = 0
+	suite.True(cfg.warnCertificateExpiry())
+
+	// a certificate that expires after the warning period is not reported
+	_, _, err := WriteMockCertificate(filepath.Dir(cfg.Certificate), "second.example.com", 30*24*time.Hour)
+	suite.Nil(err)
+	suite.Nil(cfg.ReloadCertificates())
+	suite.False(cfg.warnCertificateExpiry())
+}
+
+// TestFilesState tests that any change of the certificates results in a different state
+func (suite *WatchTestSuite) TestFilesState() {
+
+	dir := suite.T().TempDir()
+	caDir := suite.T().TempDir()
+	cfg := suite.loadTLSTestConfig(dir, caDir)
+
+	s1 := cfg.filesState()
+	suite.Equal(s1, cfg.filesState())
+
+	// a new CA
+	ca, err := os.ReadFile(cfg.Certificate)
+	suite.Nil(err)
+	suite.Nil(os.WriteFile(filepath.Join(caDir, "ca.pem"), ca, 0600))
+	s2 := cfg.filesState()
+	suite.NotEqual(s1, s2)
+
+	// a renewed certificate
+	_, _, err = writeCertificate(dir, "second.example.com")
+	suite.Nil(err)
+	suite.NotEqual(s2, cfg.filesState())
+
+	// the state of the loaded files is kept
+	suite.Equal(s1, cfg.certificatesState)
+	suite.Nil(cfg.ReloadCertificates())
+	suite.Equal(cfg.filesState(), cfg.certificatesState)
+}
+
+// TestWatchCertificates tests that a renewed certificate is served without any reload
+func (suite *WatchTestSuite) TestWatchCertificates() {
+
+	dir := suite.T().TempDir()
+	cfg := suite.loadTLSTestConfig(dir, suite.T().TempDir())
+	cfg.CertificateWatchInterval = 1
+
+	suite.Equal("first.example.com", servedCommonName(cfg))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		cfg.WatchCertificates(ctx)
+		close(done)
+	}()
+
+	_, _, err := writeCertificate(dir, "second.example.com")
+	suite.Nil(err)
+
+	suite.Eventually(func() bool {
+		return servedCommonName(cfg) == "second.example.com"
+	}, 5*time.Second, 100*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+// TestReloadCertificates tests that an invalid certificate doesn't replace the served one
+func (suite *WatchTestSuite) TestReloadCertificates() {
+
+	dir := suite.T().TempDir()
+	cfg := suite.loadTLSTestConfig(dir, suite.T().TempDir())
+
+	suite.Nil(os.WriteFile(filepath.Join(dir, "cert.pem"), []byte("invalid"), 0600))
+	suite.NotNil(cfg.ReloadCertificates())
+	suite.Equal("first.example.com", servedCommonName(cfg))
+}
+
+// TestNewClientTLSConfig tests the tls configuration of the clients of the grpc api
+func (suite *WatchTestSuite) TestNewClientTLSConfig() {
+
+	dir := suite.T().TempDir()
+	certPath, keyPath, err := WriteMockCertificate(dir, "client.example.com", time.Hour)
+	suite.Nil(err)
+
+	// the self signed certificate is its own CA
+	caDir := suite.T().TempDir()
+	b, _ := os.ReadFile(certPath)
+	suite.Nil(os.WriteFile(filepath.Join(caDir, "ca.pem"), b, 0600))
+
+	// the client presents its certificate and trusts the CAs of the directory
+	c1, e1 := NewClientTLSConfig(certPath, keyPath, caDir)
+	suite.Nil(e1)
+	suite.Equal(1, len(c1.Certificates))
+	suite.NotNil(c1.RootCAs)
+
+	// without a certificate or a CA directory, e.g. with token auth, it trusts the system CAs
+	c2, e2 := NewClientTLSConfig("", "", "")
+	suite.Nil(e2)
+	suite.Empty(c2.Certificates)
+	suite.Nil(c2.RootCAs)
+
+	_, e3 := NewClientTLSConfig(certPath, "", "")
+	suite.Contains(e3.Error(), "Could not load certificate "+certPath)
+
+	suite.Nil(os.WriteFile(filepath.Join(caDir, "invalid.pem"), []byte("invalid"), 0600))
+	_, e4 := NewClientTLSConfig("", "", caDir)
+	suite.Contains(e4.Error(), "Could not load CAs from "+caDir)
+}
+
+func TestWatchTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(WatchTestSuite))
+}
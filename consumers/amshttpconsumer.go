@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/ARGOeu/ams-push-server/logging"
 	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	"github.com/ARGOeu/ams-push-server/redact"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
@@ -71,10 +73,12 @@ func (ahc *AmsHttpConsumer) ResourceInfo() string {
 // Consume consumes messages from an subscription
 func (ahc *AmsHttpConsumer) Consume(ctx context.Context, numberOfMessages int64) (ams.ReceivedMessagesList, error) {
 
-	log.WithFields(
+	logging.WithFields(
+		logging.AmsClient,
 		log.Fields{
-			"type":     "service_log",
-			"resource": ahc.ResourceInfo(),
+			"type":         "system_log",
+			"subscription": ahc.fullSub,
+			"ams_host":     ahc.amsClient.Host(),
 		},
 	).Debug("Trying to consume message")
 
@@ -89,13 +93,14 @@ func (ahc *AmsHttpConsumer) Consume(ctx context.Context, numberOfMessages int64)
 		return ams.ReceivedMessagesList{}, errors.New("no new messages")
 	}
 
-	log.WithFields(
-		log.Fields{
+	logging.WithFields(
+		logging.AmsClient,
+		withMessages(log.Fields{
 			"type":            "performance_log",
-			"message":         reqList,
-			"resource":        ahc.ResourceInfo(),
+			"subscription":    ahc.fullSub,
+			"ams_host":        ahc.amsClient.Host(),
 			"processing_time": time.Since(t1).String(),
-		},
+		}, reqList),
 	).Info("Message consumed")
 
 	return reqList, nil
@@ -109,14 +114,41 @@ func (ahc *AmsHttpConsumer) Ack(ctx context.Context, ackId string) error {
 		return fmt.Errorf("an error occurred while trying to acknowledge message with ackId %v from %v, %v",
 			ackId, ahc.ResourceInfo(), err.Error())
 	}
-	log.WithFields(
+	logging.WithFields(
+		logging.AmsClient,
 		log.Fields{
-			"type":            "performance",
-			"ackId":           ackId,
-			"resource":        ahc.ResourceInfo(),
+			"type":            "performance_log",
+			"ack_id":          ackId,
+			"subscription":    ahc.fullSub,
+			"ams_host":        ahc.amsClient.Host(),
 			"processing_time": time.Since(t1).String(),
 		},
 	).Debug("Message acknowledged")
 
 	return nil
 }
+
+// withMessages adds the consumed messages to the provided log fields, based on the payload logging mode of the service
+func withMessages(fields log.Fields, rml ams.ReceivedMessagesList) log.Fields {
+
+	switch redact.PayloadMode() {
+
+	case redact.FullPayloads:
+
+		fields["messages"] = rml
+
+	case redact.MetadataPayloads:
+
+		ids := make([]string, 0, len(rml.RecMsgs))
+		size := 0
+		for _, rm := range rml.RecMsgs {
+			ids = append(ids, rm.Msg.ID)
+			size += len(rm.Msg.Data)
+		}
+
+		fields["message_ids"] = ids
+		fields["payload_bytes"] = size
+	}
+
+	return fields
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	"github.com/ARGOeu/ams-push-server/redact"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/suite"
 	"io"
@@ -195,6 +196,34 @@ func (suite *AmsHttpConsumerTestSuite) TestToCancelableError() {
 	suite.Equal(CancelableError{}, ce3)
 }
 
+// TestWithMessages tests that consumed messages are logged based on the payload logging mode
+func (suite *AmsHttpConsumerTestSuite) TestWithMessages() {
+
+	rml := ams.ReceivedMessagesList{
+		RecMsgs: []ams.ReceivedMessage{
+			{AckID: "ack_1", Msg: ams.Message{ID: "id_1", Data: "data-1"}},
+			{AckID: "ack_2", Msg: ams.Message{ID: "id_2", Data: "data"}},
+		},
+	}
+
+	defer redact.SetPayloadMode("")
+
+	suite.Equal(logrus.Fields{
+		"type":          "performance_log",
+		"message_ids":   []string{"id_1", "id_2"},
+		"payload_bytes": 10,
+	}, withMessages(logrus.Fields{"type": "performance_log"}, rml))
+
+	redact.SetPayloadMode(redact.NoPayloads)
+	suite.Equal(logrus.Fields{"type": "performance_log"}, withMessages(logrus.Fields{"type": "performance_log"}, rml))
+
+	redact.SetPayloadMode(redact.FullPayloads)
+	suite.Equal(logrus.Fields{
+		"type":     "performance_log",
+		"messages": rml,
+	}, withMessages(logrus.Fields{"type": "performance_log"}, rml))
+}
+
 func TestAmsHttpConsumerTestSuite(t *testing.T) {
 	logrus.SetOutput(io.Discard)
 	suite.Run(t, new(AmsHttpConsumerTestSuite))
@@ -0,0 +1,35 @@
+package election
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+// FileBackend keeps the lease in a file on a filesystem shared by the instances
+const FileBackend = "file"
+
+// Lease is held by a single instance of the service at a time, for a limited duration unless it is renewed
+type Lease interface {
+	// Acquire acquires the lease for the identity, or renews it if the identity already holds it, for the provided
+	// duration. The lease can't be acquired while another identity holds it and it hasn't expired.
+	// It returns the identity that holds the lease afterwards
+	Acquire(identity string, duration time.Duration) (string, error)
+	// Release gives the lease up, if the identity holds it, so that another identity can acquire it right away
+	Release(identity string) error
+}
+
+// IsValidBackend returns whether or not the provided lease backend is supported
+func IsValidBackend(backend string) bool {
+	return backend == FileBackend
+}
+
+// NewLease acts as a lease factory, the location is the path of the lease file for the file backend
+func NewLease(backend string, location string) (Lease, error) {
+
+	switch backend {
+	case FileBackend:
+		return NewFileLease(location), nil
+	}
+
+	return nil, errors.Errorf("Lease backend %v not yet implemented", backend)
+}
@@ -0,0 +1,158 @@
+package election
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type ElectionTestSuite struct {
+	suite.Suite
+}
+
+// TestFileLease tests acquiring, renewing, releasing and taking over an expired file lease
+func (suite *ElectionTestSuite) TestFileLease() {
+
+	path := filepath.Join(suite.T().TempDir(), "lease")
+
+	l, err := NewLease(FileBackend, path)
+	suite.Nil(err)
+	suite.IsType(&FileLease{}, l)
+
+	holder, err := l.Acquire("push-1", time.Minute)
+	suite.Nil(err)
+	suite.Equal("push-1", holder)
+
+	// the holder renews it, while anyone else finds it held
+	holder, err = l.Acquire("push-1", 50*time.Millisecond)
+	suite.Nil(err)
+	suite.Equal("push-1", holder)
+
+	holder, err = NewFileLease(path).Acquire("push-2", time.Minute)
+	suite.Nil(err)
+	suite.Equal("push-1", holder)
+
+	// an expired lease can be taken over
+	time.Sleep(60 * time.Millisecond)
+
+	holder, err = NewFileLease(path).Acquire("push-2", time.Minute)
+	suite.Nil(err)
+	suite.Equal("push-2", holder)
+
+	// only the holder can release the lease
+	suite.Nil(l.Release("push-1"))
+	holder, _ = l.Acquire("push-1", time.Minute)
+	suite.Equal("push-2", holder)
+
+	suite.Nil(l.Release("push-2"))
+	b, err := os.ReadFile(path)
+	suite.Nil(err)
+	suite.Empty(b)
+
+	holder, _ = l.Acquire("push-1", time.Minute)
+	suite.Equal("push-1", holder)
+
+	// a corrupted lease file is not held by anyone
+	suite.Nil(os.WriteFile(path, []byte("{not json"), 0600))
+	holder, _ = l.Acquire("push-2", time.Minute)
+	suite.Equal("push-2", holder)
+
+	// a lease file that can't be created
+	_, err = NewFileLease(filepath.Join(suite.T().TempDir(), "missing", "lease")).Acquire("push-1", time.Minute)
+	suite.NotNil(err)
+
+	_, err = NewLease("unknown", path)
+	suite.Equal("Lease backend unknown not yet implemented", err.Error())
+	suite.True(IsValidBackend(FileBackend))
+	suite.False(IsValidBackend("unknown"))
+}
+
+// TestElector tests that only one of the electors leads and that the standby takes over once the leader stops
+func (suite *ElectionTestSuite) TestElector() {
+
+	lease := new(MockLease)
+
+	var elected1, demoted1, elected2, demoted2 atomic.Int32
+
+	e1 := NewElector(lease, "push-1", 100*time.Millisecond, 10*time.Millisecond)
+	e1.Start(func() { elected1.Add(1) }, func() { demoted1.Add(1) })
+
+	suite.Eventually(e1.IsLeader, time.Second, time.Millisecond)
+
+	e2 := NewElector(lease, "push-2", 100*time.Millisecond, 10*time.Millisecond)
+	e2.Start(func() { elected2.Add(1) }, func() { demoted2.Add(1) })
+
+	suite.Eventually(func() bool {
+		return e2.Holder() == "push-1"
+	}, time.Second, time.Millisecond)
+
+	suite.Equal(LeaderRole, e1.Role())
+	suite.Equal(StandbyRole, e2.Role())
+	suite.Equal("push-2", e2.Identity())
+
+	// the lease is released once the leader stops, and the standby takes over without waiting for it to expire
+	suite.Nil(e1.Stop())
+	suite.False(e1.IsLeader())
+
+	suite.Eventually(e2.IsLeader, 50*time.Millisecond, time.Millisecond)
+	suite.Equal("push-2", e2.Holder())
+
+	suite.Nil(e2.Stop())
+
+	suite.Equal(int32(1), elected1.Load())
+	suite.Equal(int32(0), demoted1.Load())
+	suite.Equal(int32(1), elected2.Load())
+	suite.Equal(int32(0), demoted2.Load())
+}
+
+// TestElectorLeaseLost tests that the leader steps down when another instance holds the lease
+// and when the lease can't be renewed before it might expire
+func (suite *ElectionTestSuite) TestElectorLeaseLost() {
+
+	lease := new(MockLease)
+
+	var elected, demoted atomic.Int32
+
+	e := NewElector(lease, "push-1", 100*time.Millisecond, 10*time.Millisecond)
+	e.Start(func() { elected.Add(1) }, func() { demoted.Add(1) })
+	defer e.Stop()
+
+	suite.Eventually(e.IsLeader, time.Second, time.Millisecond)
+
+	// the lease can't be renewed, the leader keeps leading until the lease might expire
+	lease.SetErr(ErrMockLease)
+	time.Sleep(30 * time.Millisecond)
+	suite.True(e.IsLeader())
+
+	suite.Eventually(func() bool {
+		return !e.IsLeader()
+	}, time.Second, time.Millisecond)
+	suite.Equal(int32(1), demoted.Load())
+
+	// it leads again once the lease is available
+	lease.SetErr(nil)
+	suite.Eventually(e.IsLeader, time.Second, time.Millisecond)
+	suite.Equal(int32(2), elected.Load())
+
+	// another instance took the lease over, e.g. after the leader was paused for longer than the lease
+	lease.mutex.Lock()
+	lease.holder = "push-2"
+	lease.expires = time.Now().Add(time.Minute)
+	lease.mutex.Unlock()
+
+	suite.Eventually(func() bool {
+		return !e.IsLeader()
+	}, time.Second, time.Millisecond)
+	suite.Equal(int32(2), demoted.Load())
+	suite.Equal("push-2", e.Holder())
+}
+
+func TestElectionTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(ElectionTestSuite))
+}
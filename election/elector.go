@@ -0,0 +1,212 @@
+package election
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// Leader and standby are the roles of an instance that takes part in an election
+const (
+	LeaderRole  = "leader"
+	StandbyRole = "standby"
+)
+
+// Elector competes for a lease on behalf of an instance of the service. The instance that holds the lease leads,
+// while the rest stand by and try to acquire it every renew interval. The leader renews the lease every renew interval
+// and steps down as soon as it finds out that it no longer holds it, or once it couldn't be renewed for long enough
+// that it might have expired
+type Elector struct {
+	lease         Lease
+	identity      string
+	duration      time.Duration
+	renewInterval time.Duration
+	mutex         sync.RWMutex
+	leading       bool
+	holder        string
+	// when the lease was last acquired or renewed by the instance
+	renewed time.Time
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewElector returns an elector that competes for the lease under the provided identity
+func NewElector(lease Lease, identity string, duration time.Duration, renewInterval time.Duration) *Elector {
+	return &Elector{
+		lease:         lease,
+		identity:      identity,
+		duration:      duration,
+		renewInterval: renewInterval,
+	}
+}
+
+// Start starts competing for the lease. The onElected function is called when the instance acquires the lease
+// and the onDemoted function when it loses it, both of them from the goroutine of the elector
+func (e *Elector) Start(onElected func(), onDemoted func()) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.done = make(chan struct{})
+
+	go func() {
+
+		defer close(e.done)
+
+		for {
+
+			e.campaign(onElected, onDemoted)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(e.renewInterval):
+			}
+		}
+	}()
+}
+
+// Stop stops competing for the lease and releases it, if the instance holds it, without calling onDemoted
+func (e *Elector) Stop() error {
+
+	if e.cancel == nil {
+		return nil
+	}
+
+	e.cancel()
+	<-e.done
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.leading {
+		return nil
+	}
+
+	e.leading = false
+	e.holder = ""
+
+	return e.lease.Release(e.identity)
+}
+
+// campaign acquires or renews the lease once
+func (e *Elector) campaign(onElected func(), onDemoted func()) {
+
+	// the lease lasts from before it was requested, so that the instance never considers it longer than it is
+	t1 := time.Now()
+
+	holder, err := e.lease.Acquire(e.identity, e.duration)
+	if err != nil {
+
+		log.WithFields(
+			log.Fields{
+				"type":     "system_log",
+				"identity": e.identity,
+				"error":    err.Error(),
+			},
+		).Error("Could not acquire the lease")
+
+		// another instance can acquire the lease once it expires, step down before the next attempt would be too late
+		if e.IsLeader() && time.Since(e.lastRenewed())+e.renewInterval >= e.duration {
+			e.demote("", onDemoted)
+		}
+		return
+	}
+
+	if holder != e.identity {
+		if e.IsLeader() {
+			e.demote(holder, onDemoted)
+			return
+		}
+		e.setHolder(holder)
+		return
+	}
+
+	e.mutex.Lock()
+	elected := !e.leading
+	e.leading = true
+	e.holder = holder
+	e.renewed = t1
+	e.mutex.Unlock()
+
+	if elected {
+		log.WithFields(
+			log.Fields{
+				"type":     "system_log",
+				"identity": e.identity,
+			},
+		).Info("Acquired the lease, leading")
+		onElected()
+	}
+}
+
+// demote steps down from the leadership
+func (e *Elector) demote(holder string, onDemoted func()) {
+
+	e.mutex.Lock()
+	e.leading = false
+	e.holder = holder
+	e.mutex.Unlock()
+
+	log.WithFields(
+		log.Fields{
+			"type":     "system_log",
+			"identity": e.identity,
+			"holder":   holder,
+		},
+	).Warning("Lost the lease, standing by")
+
+	onDemoted()
+}
+
+// setHolder records the identity that currently holds the lease
+func (e *Elector) setHolder(holder string) {
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.holder = holder
+}
+
+// lastRenewed returns when the lease was last acquired or renewed by the instance
+func (e *Elector) lastRenewed() time.Time {
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.renewed
+}
+
+// IsLeader returns whether or not the instance currently holds the lease
+func (e *Elector) IsLeader() bool {
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.leading
+}
+
+// Role returns the role of the instance, leader or standby
+func (e *Elector) Role() string {
+
+	if e.IsLeader() {
+		return LeaderRole
+	}
+
+	return StandbyRole
+}
+
+// Holder returns the identity that held the lease the last time the instance tried to acquire it,
+// empty if it isn't known
+func (e *Elector) Holder() string {
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.holder
+}
+
+// Identity returns the identity under which the instance competes for the lease
+func (e *Elector) Identity() string {
+	return e.identity
+}
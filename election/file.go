@@ -0,0 +1,118 @@
+package election
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// FileLease keeps the lease in a file on a filesystem shared by the instances, e.g. over nfs.
+// The file holds the identity of the holder and the expiry of the lease, and it is locked while it is read and written.
+// Since the expiry is compared against the local clock, the clocks of the instances should be synchronised
+type FileLease struct {
+	path string
+}
+
+// leaseRecord is the content of the lease file
+type leaseRecord struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewFileLease returns a lease kept in the file of the provided path, the file is created on the first acquisition
+func NewFileLease(path string) *FileLease {
+	return &FileLease{
+		path: path,
+	}
+}
+
+// Acquire acquires or renews the lease for the identity, unless another identity holds it and it hasn't expired
+func (l *FileLease) Acquire(identity string, duration time.Duration) (string, error) {
+
+	holder := ""
+
+	err := l.update(func(r *leaseRecord) bool {
+
+		if r.Holder != "" && r.Holder != identity && time.Now().Before(r.Expires) {
+			holder = r.Holder
+			return false
+		}
+
+		r.Holder = identity
+		r.Expires = time.Now().Add(duration)
+		holder = identity
+
+		return true
+	})
+
+	return holder, err
+}
+
+// Release empties the lease file, if the identity holds the lease
+func (l *FileLease) Release(identity string) error {
+	return l.update(func(r *leaseRecord) bool {
+
+		if r.Holder != identity {
+			return false
+		}
+
+		*r = leaseRecord{}
+
+		return true
+	})
+}
+
+// update locks the lease file and passes its content to the provided function,
+// the content is written back if the function returns true
+func (l *FileLease) update(f func(r *leaseRecord) bool) error {
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	r := leaseRecord{}
+
+	// an empty or unreadable file means that nobody holds the lease
+	if len(b) > 0 && json.Unmarshal(b, &r) != nil {
+		r = leaseRecord{}
+	}
+
+	if !f(&r) {
+		return nil
+	}
+
+	b = nil
+	if r.Holder != "" {
+		b, err = json.Marshal(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteAt(b, 0)
+	if err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
@@ -0,0 +1,65 @@
+package election
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// MockLease is a lease kept in memory, it can be shared by the electors of a test
+type MockLease struct {
+	mutex   sync.Mutex
+	holder  string
+	expires time.Time
+	// when set, every call fails with it
+	err error
+}
+
+// Acquire acquires or renews the lease for the identity, unless another identity holds it and it hasn't expired
+func (m *MockLease) Acquire(identity string, duration time.Duration) (string, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.err != nil {
+		return "", m.err
+	}
+
+	if m.holder != "" && m.holder != identity && time.Now().Before(m.expires) {
+		return m.holder, nil
+	}
+
+	m.holder = identity
+	m.expires = time.Now().Add(duration)
+
+	return identity, nil
+}
+
+// Release gives the lease up, if the identity holds it
+func (m *MockLease) Release(identity string) error {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+
+	if m.holder == identity {
+		m.holder = ""
+	}
+
+	return nil
+}
+
+// SetErr makes every following call fail with the provided error, or succeed again if it is nil
+func (m *MockLease) SetErr(err error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.err = err
+}
+
+// ErrMockLease is an error that a mock lease can fail with
+var ErrMockLease = errors.New("lease is unavailable")
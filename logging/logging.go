@@ -0,0 +1,190 @@
+package logging
+
+import (
+	log "github.com/sirupsen/logrus"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// TextFormat is the default human readable format
+	TextFormat = "text"
+	// JSONFormat writes every entry as a json object
+	JSONFormat = "json"
+	// LogfmtFormat writes every entry as key=value pairs, quoting any value that needs it
+	LogfmtFormat = "logfmt"
+)
+
+const (
+	// Service is the component of the entries that are not logged by any specific component
+	Service = "service"
+	// GRPC logs the calls to the grpc api
+	GRPC = "grpc"
+	// Worker logs the push cycles of the subscriptions
+	Worker = "worker"
+	// Sender logs the deliveries to the push destinations
+	Sender = "sender"
+	// AmsClient logs the interaction with ams
+	AmsClient = "ams_client"
+)
+
+// ComponentField is the field that holds the component that logged an entry
+const ComponentField = "component"
+
+// Components are the components whose level can be set on their own
+var Components = []string{GRPC, Worker, Sender, AmsClient}
+
+var (
+	mutex sync.RWMutex
+	// loggers holds the logger of each component
+	loggers = make(map[string]*log.Logger)
+	// levels holds the level of each component that doesn't follow the level of the standard logger
+	levels = make(map[string]log.Level)
+	// hookOnce makes sure that the component hook is added only once to the standard logger
+	hookOnce sync.Once
+)
+
+// IsValidFormat checks whether or not the provided log format is supported
+func IsValidFormat(format string) bool {
+	switch format {
+	case "", TextFormat, JSONFormat, LogfmtFormat:
+		return true
+	}
+	return false
+}
+
+// IsValidComponent checks whether or not the provided component's level can be set on its own
+func IsValidComponent(component string) bool {
+	for _, c := range Components {
+		if c == component {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFormatter returns the formatter of the provided log format, an unknown format falls back to text.
+// Every format uses the time, level and msg keys and RFC3339 timestamps
+func NewFormatter(format string) log.Formatter {
+
+	switch format {
+
+	case JSONFormat:
+		return &log.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		}
+
+	case LogfmtFormat:
+		return &log.TextFormatter{
+			DisableColors:    true,
+			FullTimestamp:    true,
+			TimestampFormat:  time.RFC3339Nano,
+			QuoteEmptyFields: true,
+		}
+	}
+
+	return &log.TextFormatter{FullTimestamp: true, DisableColors: true}
+}
+
+// Setup configures the format, the output and the level of the standard logger and of every component logger.
+// Components without a level of their own follow the level of the standard logger
+func Setup(format string, out io.Writer, level log.Level, componentLevels map[string]log.Level) {
+
+	hookOnce.Do(func() {
+		log.AddHook(new(componentHook))
+	})
+
+	log.SetFormatter(NewFormatter(format))
+	log.SetOutput(out)
+
+	SetLevels(level, componentLevels)
+}
+
+// SetLevels sets the level of the standard logger and of every component logger
+func SetLevels(level log.Level, componentLevels map[string]log.Level) {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	log.SetLevel(level)
+
+	levels = make(map[string]log.Level)
+	for c, l := range componentLevels {
+		levels[c] = l
+	}
+
+	std := log.StandardLogger()
+
+	for c, l := range loggers {
+		l.SetOutput(std.Out)
+		l.SetFormatter(std.Formatter)
+		l.SetLevel(componentLevel(c))
+	}
+}
+
+// componentLevel returns the level of the component, mutex should be held by the caller
+func componentLevel(component string) log.Level {
+
+	if l, ok := levels[component]; ok {
+		return l
+	}
+
+	return log.GetLevel()
+}
+
+// Logger returns the logger of the component. It writes to the same output, in the same format
+// and fires the same hooks as the standard logger, but has a level of its own
+func Logger(component string) *log.Logger {
+
+	mutex.RLock()
+	l, ok := loggers[component]
+	mutex.RUnlock()
+
+	if ok {
+		return l
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if l, ok := loggers[component]; ok {
+		return l
+	}
+
+	std := log.StandardLogger()
+
+	l = log.New()
+	l.SetOutput(std.Out)
+	l.SetFormatter(std.Formatter)
+	l.SetLevel(componentLevel(component))
+	// share the hooks, so that hooks added later on to the standard logger, e.g. syslog, also apply to the component
+	l.Hooks = std.Hooks
+
+	loggers[component] = l
+
+	return l
+}
+
+// WithFields returns an entry of the component's logger that carries the provided fields and the component
+func WithFields(component string, fields log.Fields) *log.Entry {
+	return Logger(component).WithFields(fields).WithField(ComponentField, component)
+}
+
+// componentHook marks the entries that don't belong to any specific component as service entries
+type componentHook struct{}
+
+// Levels returns all levels, since every entry should carry its component
+func (h *componentHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire sets the component of the entry, unless it already has one
+func (h *componentHook) Fire(entry *log.Entry) error {
+
+	if _, ok := entry.Data[ComponentField]; !ok {
+		entry.Data[ComponentField] = Service
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"strings"
+	"testing"
+)
+
+type LoggingTestSuite struct {
+	suite.Suite
+}
+
+func (suite *LoggingTestSuite) TearDownTest() {
+	Setup(TextFormat, io.Discard, log.InfoLevel, nil)
+}
+
+// TestIsValidFormat tests the IsValidFormat functionality
+func (suite *LoggingTestSuite) TestIsValidFormat() {
+	suite.True(IsValidFormat(""))
+	suite.True(IsValidFormat("text"))
+	suite.True(IsValidFormat("json"))
+	suite.True(IsValidFormat("logfmt"))
+	suite.False(IsValidFormat("xml"))
+}
+
+// TestIsValidComponent tests the IsValidComponent functionality
+func (suite *LoggingTestSuite) TestIsValidComponent() {
+	suite.True(IsValidComponent("grpc"))
+	suite.True(IsValidComponent("worker"))
+	suite.True(IsValidComponent("sender"))
+	suite.True(IsValidComponent("ams_client"))
+	suite.False(IsValidComponent("service"))
+	suite.False(IsValidComponent("unknown"))
+}
+
+// TestNewFormatter tests that each format produces the expected output
+func (suite *LoggingTestSuite) TestNewFormatter() {
+
+	entry := log.NewEntry(log.New()).WithFields(log.Fields{"type": "system_log", "empty": ""})
+	entry.Message = "some message"
+	entry.Level = log.InfoLevel
+
+	b1, _ := NewFormatter(JSONFormat).Format(entry)
+	m := make(map[string]interface{})
+	suite.Nil(json.Unmarshal(b1, &m))
+	suite.Equal("some message", m["msg"])
+	suite.Equal("info", m["level"])
+	suite.Equal("system_log", m["type"])
+	suite.Contains(m, "time")
+
+	b2, _ := NewFormatter(LogfmtFormat).Format(entry)
+	suite.Contains(string(b2), `level=info msg="some message" empty="" type=system_log`)
+
+	b3, _ := NewFormatter(TextFormat).Format(entry)
+	suite.Contains(string(b3), `level=info msg="some message" empty= type=system_log`)
+
+	suite.Equal(NewFormatter(TextFormat), NewFormatter("unknown"))
+}
+
+// TestComponents tests that component loggers share the output and format of the standard logger,
+// while having levels of their own
+func (suite *LoggingTestSuite) TestComponents() {
+
+	buf := new(bytes.Buffer)
+
+	Setup(JSONFormat, buf, log.InfoLevel, map[string]log.Level{Worker: log.DebugLevel, Sender: log.ErrorLevel})
+
+	WithFields(Worker, log.Fields{"type": "system_log"}).Debug("worker debug")
+	WithFields(Sender, log.Fields{"type": "system_log"}).Warning("sender warning")
+	WithFields(AmsClient, log.Fields{"type": "system_log"}).Info("ams client info")
+	WithFields(AmsClient, log.Fields{"type": "system_log"}).Debug("ams client debug")
+	log.WithFields(log.Fields{"type": "system_log"}).Info("service info")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	suite.Equal(3, len(lines))
+
+	entries := make([]map[string]interface{}, len(lines))
+	for idx, line := range lines {
+		suite.Nil(json.Unmarshal([]byte(line), &entries[idx]))
+	}
+
+	suite.Equal("worker debug", entries[0]["msg"])
+	suite.Equal("worker", entries[0]["component"])
+	suite.Equal("ams client info", entries[1]["msg"])
+	suite.Equal("ams_client", entries[1]["component"])
+	suite.Equal("service info", entries[2]["msg"])
+	suite.Equal("service", entries[2]["component"])
+
+	// the levels change
+	buf.Reset()
+	SetLevels(log.WarnLevel, map[string]log.Level{Sender: log.DebugLevel})
+
+	WithFields(Worker, log.Fields{}).Info("worker info")
+	WithFields(Sender, log.Fields{}).Debug("sender debug")
+	log.Info("service info")
+
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	suite.Equal(1, len(lines))
+	suite.Contains(lines[0], `"msg":"sender debug"`)
+}
+
+func TestLoggingTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggingTestSuite))
+}
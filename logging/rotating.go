@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	// DefaultMaxSizeMB is the size in megabytes that a log file can reach before it is rotated
+	DefaultMaxSizeMB = 100
+	// DefaultMaxBackups is the number of rotated log files that are kept
+	DefaultMaxBackups = 5
+)
+
+// RotatingFile is a log file that is rotated whenever it reaches its maximum size.
+// Rotated files are named after the file with a numeric suffix, e.g. push.log.1 is the most recent one
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	mutex      sync.Mutex
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingFile opens the log file for appending, creating it if it doesn't exist.
+// A non positive size or number of backups falls back to the defaults
+func NewRotatingFile(path string, maxSizeMB int, maxBackups int) (*RotatingFile, error) {
+
+	if maxSizeMB <= 0 {
+		maxSizeMB = DefaultMaxSizeMB
+	}
+
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+
+	rf := &RotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+
+	err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+// Write writes to the log file, rotating it first if the data would make it exceed its maximum size
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		err := rf.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+
+	return n, err
+}
+
+// Close closes the log file
+func (rf *RotatingFile) Close() error {
+
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	return rf.file.Close()
+}
+
+// open opens the log file for appending and picks up its current size
+func (rf *RotatingFile) open() error {
+
+	f, err := os.OpenFile(rf.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rf.file = f
+	rf.size = info.Size()
+
+	return nil
+}
+
+// rotate shifts every rotated file by one, dropping the oldest one, moves the log file to the first backup
+// and opens a new log file
+func (rf *RotatingFile) rotate() error {
+
+	err := rf.file.Close()
+	if err != nil {
+		return err
+	}
+
+	os.Remove(rf.backup(rf.maxBackups))
+
+	for i := rf.maxBackups - 1; i > 0; i-- {
+		os.Rename(rf.backup(i), rf.backup(i+1))
+	}
+
+	// keep writing to the same file if it can't be moved
+	os.Rename(rf.path, rf.backup(1))
+
+	return rf.open()
+}
+
+// backup returns the path of the rotated file with the provided index
+func (rf *RotatingFile) backup(idx int) string {
+	return fmt.Sprintf("%v.%v", rf.path, idx)
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type RotatingFileTestSuite struct {
+	suite.Suite
+}
+
+// TestNewRotatingFile tests the proper initialisation of a rotating file
+func (suite *RotatingFileTestSuite) TestNewRotatingFile() {
+
+	path := filepath.Join(suite.T().TempDir(), "push.log")
+	suite.Nil(os.WriteFile(path, []byte("existing\n"), 0640))
+
+	rf, err := NewRotatingFile(path, 0, 0)
+	suite.Nil(err)
+	defer rf.Close()
+
+	suite.Equal(int64(DefaultMaxSizeMB*1024*1024), rf.maxSize)
+	suite.Equal(DefaultMaxBackups, rf.maxBackups)
+	// the size of the existing file is picked up
+	suite.Equal(int64(9), rf.size)
+
+	_, err = NewRotatingFile(filepath.Join(suite.T().TempDir(), "missing", "push.log"), 1, 1)
+	suite.NotNil(err)
+}
+
+// TestWrite tests that the file is rotated once it reaches its maximum size
+func (suite *RotatingFileTestSuite) TestWrite() {
+
+	path := filepath.Join(suite.T().TempDir(), "push.log")
+
+	rf, err := NewRotatingFile(path, 1, 2)
+	suite.Nil(err)
+	defer rf.Close()
+
+	rf.maxSize = 10
+
+	for _, line := range []string{"line-1\n", "line-2\n", "line-3\n", "line-4\n"} {
+		n, err := rf.Write([]byte(line))
+		suite.Nil(err)
+		suite.Equal(len(line), n)
+	}
+
+	b, _ := os.ReadFile(path)
+	suite.Equal("line-4\n", string(b))
+
+	b1, _ := os.ReadFile(path + ".1")
+	suite.Equal("line-3\n", string(b1))
+
+	b2, _ := os.ReadFile(path + ".2")
+	suite.Equal("line-2\n", string(b2))
+
+	// only the configured number of backups is kept
+	_, err = os.Stat(path + ".3")
+	suite.True(os.IsNotExist(err))
+
+	// a write larger than the maximum size still goes through
+	_, err = rf.Write([]byte("a very long line\n"))
+	suite.Nil(err)
+	b, _ = os.ReadFile(path)
+	suite.Equal("a very long line\n", string(b))
+}
+
+func TestRotatingFileTestSuite(t *testing.T) {
+	suite.Run(t, new(RotatingFileTestSuite))
+}
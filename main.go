@@ -1,14 +1,22 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	amsgRPC "github.com/ARGOeu/ams-push-server/api/v1/grpc"
 	"github.com/ARGOeu/ams-push-server/config"
+	"github.com/ARGOeu/ams-push-server/logging"
+	"github.com/ARGOeu/ams-push-server/redact"
+	"github.com/ARGOeu/ams-push-server/sdnotify"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"io"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func init() {
@@ -18,16 +26,24 @@ func init() {
 
 func main() {
 
-	// Retrieve configuration file location from a cli argument
-	cfgPath := flag.String("config", "/etc/ams-push-server/conf.d/ams-push-server-config.json", "Path for the required configuration file.")
+	// run any subcommand instead of serving
+	if len(os.Args) > 1 {
+		if command, found := commands[os.Args[1]]; found {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
+	// Retrieve configuration file location and its overrides from the cli arguments
+	cfgSource := configFlags(flag.CommandLine)
 	flag.Parse()
+	source := cfgSource()
 
-	bCfg, err := os.ReadFile(*cfgPath)
+	cfgReader, err := source.Read()
 	if err != nil {
 		log.WithFields(
 			log.Fields{
 				"type":  "error_log",
-				"path":  *cfgPath,
+				"path":  source.Path,
 				"error": err.Error(),
 			},
 		).Fatal("Could not read configuration file")
@@ -35,7 +51,7 @@ func main() {
 
 	// initialize the config
 	cfg := new(config.Config)
-	err = cfg.LoadFromJson(bytes.NewReader(bCfg))
+	err = cfg.LoadFromJson(cfgReader)
 	if err != nil {
 		log.WithFields(
 			log.Fields{
@@ -45,7 +61,44 @@ func main() {
 		).Fatal("Could not load configuration file")
 	}
 
-	log.SetLevel(cfg.GetLogLevel())
+	// write the logs to the standard error or to a rotating file
+	var logOutput io.Writer = os.Stderr
+	if cfg.LogFile.Path != "" {
+		rf, err := logging.NewRotatingFile(cfg.LogFile.Path, cfg.LogFile.MaxSizeMB, cfg.LogFile.MaxBackups)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":  "error_log",
+					"path":  cfg.LogFile.Path,
+					"error": err.Error(),
+				},
+			).Fatal("Could not open log file")
+		}
+		defer rf.Close()
+		logOutput = rf
+	}
+
+	logging.Setup(cfg.LogFormat, logOutput, cfg.GetLogLevel(), cfg.GetComponentLogLevels())
+	redact.SetPayloadMode(cfg.PayloadLogging)
+
+	cfg.OnReload(func(cfg *config.Config) {
+		logging.SetLevels(cfg.GetLogLevel(), cfg.GetComponentLogLevels())
+		redact.SetPayloadMode(cfg.PayloadLogging)
+	})
+
+	// serve renewed certificates without a restart
+	if cfg.TLSEnabled {
+		go cfg.WatchCertificates(context.Background())
+	}
+
+	// reload the configuration file whenever a SIGHUP is received
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			reloadConfig(cfg, source)
+		}
+	}()
 
 	listener, err := net.Listen("tcp", fmt.Sprintf("%v:%v", cfg.BindIp, cfg.BindPort))
 	if err != nil {
@@ -57,26 +110,165 @@ func main() {
 		).Fatal("Could not listen")
 	}
 
+	ps := amsgRPC.NewPushService(cfg)
+	srv := amsgRPC.NewGRPCServer(cfg, ps)
+
+	// drain the push workers and stop the grpc server whenever a SIGINT or a SIGTERM is received
+	stopped := make(chan struct{})
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigterm
+		shutdown(srv, ps, cfg.GetDrainTimeout(), sig)
+		close(stopped)
+	}()
+
 	log.WithFields(
 		log.Fields{
-			"type": "service_log",
+			"type": "system_log",
 		},
 	).Info("API is ready to start serving")
 
-	srv := amsgRPC.NewGRPCServer(cfg)
+	notifySystemd(sdnotify.Ready)
+
+	err = srv.Serve(listener)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "error_log",
+				"error": err.Error(),
+			},
+		).Fatal("Could not serve")
+	}
+
+	// serve returns as soon as the server stops listening, wait for the shutdown to complete
+	<-stopped
+
+	log.WithFields(
+		log.Fields{
+			"type": "system_log",
+		},
+	).Info("Push server stopped")
+}
+
+// shutdown stops accepting activations, lets the push workers complete their current push cycle
+// and then stops the grpc server, all within the drain timeout
+func shutdown(srv *grpc.Server, ps *amsgRPC.PushService, drainTimeout time.Duration, sig os.Signal) {
+
+	log.WithFields(
+		log.Fields{
+			"type":          "system_log",
+			"signal":        sig.String(),
+			"drain_timeout": drainTimeout.String(),
+		},
+	).Info("Shutting down")
+
+	notifySystemd(sdnotify.Stopping)
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err := ps.Shutdown(ctx)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"error": err.Error(),
+			},
+		).Warning("Push workers were not fully drained")
+	}
+
+	log.WithFields(
+		log.Fields{
+			"type": "system_log",
+		},
+	).Info("Stopping the grpc server")
 
-	defer func() {
-		listener.Close()
+	// let the in-flight calls complete within whatever is left of the drain timeout
+	grpcStopped := make(chan struct{})
+	go func() {
 		srv.GracefulStop()
+		close(grpcStopped)
 	}()
 
-	err = srv.Serve(listener)
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		log.WithFields(
+			log.Fields{
+				"type": "system_log",
+			},
+		).Warning("The in-flight grpc calls did not complete in time, closing their connections")
+		srv.Stop()
+		<-grpcStopped
+	}
+}
+
+// notifySystemd reports the state of the service to systemd, when the service is managed by it
+func notifySystemd(state string) {
+
+	_, err := sdnotify.Notify(state)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"state": state,
+				"error": err.Error(),
+			},
+		).Warning("Could not notify systemd")
+	}
+}
+
+// reloadConfig re-reads the configuration file, along with its overrides,
+// and applies the fields that can change while the service is running
+func reloadConfig(cfg *config.Config, source config.Source) {
+
+	cfgPath := source.Path
+
+	log.WithFields(
+		log.Fields{
+			"type": "system_log",
+			"path": cfgPath,
+		},
+	).Info("Reloading configuration")
+
+	cfgReader, err := source.Read()
 	if err != nil {
 		log.WithFields(
 			log.Fields{
 				"type":  "error_log",
+				"path":  cfgPath,
 				"error": err.Error(),
 			},
-		).Fatal("Could not serve")
+		).Error("Could not read configuration file, keeping the current configuration")
+		return
 	}
+
+	restart, err := cfg.Reload(cfgReader)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "error_log",
+				"path":  cfgPath,
+				"error": err.Error(),
+			},
+		).Error("Could not reload configuration file, keeping the current configuration")
+		return
+	}
+
+	for _, field := range restart {
+		log.WithFields(
+			log.Fields{
+				"type":  "system_log",
+				"field": field,
+			},
+		).Warning("Configuration field has changed but requires restart")
+	}
+
+	log.WithFields(
+		log.Fields{
+			"type": "system_log",
+			"path": cfgPath,
+		},
+	).Info("Configuration reloaded successfully")
 }
@@ -0,0 +1,33 @@
+package netpolicy
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net"
+)
+
+// MockResolverAddress is the address that the mock resolver resolves any host it doesn't know of to
+const MockResolverAddress = "203.0.113.1"
+
+// MockResolver resolves the hosts found in it to their addresses, without any dns lookup.
+// A host mapped to no addresses can't be resolved, while any other host resolves to MockResolverAddress
+type MockResolver map[string][]string
+
+func (m MockResolver) LookupIP(ctx context.Context, network string, host string) ([]net.IP, error) {
+
+	addresses, found := m[host]
+	if !found {
+		return []net.IP{net.ParseIP(MockResolverAddress)}, nil
+	}
+
+	if len(addresses) == 0 {
+		return nil, errors.Errorf("lookup %v: no such host", host)
+	}
+
+	ips := make([]net.IP, 0, len(addresses))
+	for _, address := range addresses {
+		ips = append(ips, net.ParseIP(address))
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,201 @@
+package netpolicy
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultDenyCIDRs are the networks that push destinations can't reach when no deny list has been configured,
+// loopback, private, link-local, shared and unspecified addresses for both ipv4 and ipv6
+var DefaultDenyCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// DefaultAllowedSchemes are the url schemes that push destinations can use when no schemes have been configured
+var DefaultAllowedSchemes = []string{"http", "https"}
+
+// Resolver resolves the hosts of the destinations to their addresses, net.Resolver is the one in use by default
+type Resolver interface {
+	LookupIP(ctx context.Context, network string, host string) ([]net.IP, error)
+}
+
+// Policy decides which destinations the service is allowed to push to
+type Policy struct {
+	deny           []*net.IPNet
+	allowHosts     []string
+	allowedSchemes []string
+	resolver       Resolver
+}
+
+// New initialises and returns a new destination policy.
+// A nil deny list falls back to DefaultDenyCIDRs and an empty scheme list falls back to DefaultAllowedSchemes,
+// while an empty host allow-list allows every host
+func New(denyCIDRs []string, allowHosts []string, allowedSchemes []string) (*Policy, error) {
+
+	p := new(Policy)
+
+	if denyCIDRs == nil {
+		denyCIDRs = DefaultDenyCIDRs
+	}
+
+	for _, cidr := range denyCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Errorf("Invalid cidr %v", cidr)
+		}
+		p.deny = append(p.deny, ipNet)
+	}
+
+	for _, host := range allowHosts {
+		p.allowHosts = append(p.allowHosts, strings.ToLower(host))
+	}
+
+	if len(allowedSchemes) == 0 {
+		allowedSchemes = DefaultAllowedSchemes
+	}
+
+	for _, scheme := range allowedSchemes {
+		p.allowedSchemes = append(p.allowedSchemes, strings.ToLower(scheme))
+	}
+
+	p.resolver = net.DefaultResolver
+
+	return p, nil
+}
+
+// SetResolver sets the resolver that the hosts of the destinations are resolved with
+func (p *Policy) SetResolver(r Resolver) {
+	p.resolver = r
+}
+
+// CheckURL checks the scheme, the host and the addresses the host resolves to, of the provided destination.
+// Hosts that can't be resolved are rejected. Control remains the point where the policy is enforced,
+// since a host can resolve to a different address by the time it gets connected to
+func (p *Policy) CheckURL(ctx context.Context, rawURL string) error {
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if !p.isSchemeAllowed(u.Scheme) {
+		return errors.Errorf("scheme %v is not allowed", u.Scheme)
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("missing host")
+	}
+
+	if !p.isHostAllowed(host) {
+		return errors.Errorf("host %v is not allowed", host)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return p.CheckIP(ip)
+	}
+
+	ips, err := p.resolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return errors.Errorf("host %v could not be resolved, %v", host, err.Error())
+	}
+
+	for _, ip := range ips {
+		if err := p.CheckIP(ip); err != nil {
+			return errors.Errorf("host %v resolves to %v", host, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// CheckIP checks whether or not the provided address belongs to any of the denied networks
+func (p *Policy) CheckIP(ip net.IP) error {
+
+	for _, ipNet := range p.deny {
+		if ipNet.Contains(ip) {
+			return errors.Errorf("address %v is not allowed", ip.String())
+		}
+	}
+
+	return nil
+}
+
+// Control is meant to be used as the control function of a net.Dialer.
+// It runs after the destination's host has been resolved and right before connecting,
+// so a host that resolves to a different address than the one checked during activation is still blocked
+func (p *Policy) Control(network string, address string, c syscall.RawConn) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Errorf("address %v is not an ip", host)
+	}
+
+	return p.CheckIP(ip)
+}
+
+// Dialer returns a net.Dialer that refuses to connect to any of the denied networks
+func (p *Policy) Dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.Control,
+	}
+}
+
+// isSchemeAllowed checks the provided scheme against the allowed schemes
+func (p *Policy) isSchemeAllowed(scheme string) bool {
+
+	for _, s := range p.allowedSchemes {
+		if strings.ToLower(scheme) == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHostAllowed checks the provided host against the host allow-list.
+// Entries starting with "*." match any subdomain of the respective domain
+func (p *Policy) isHostAllowed(host string) bool {
+
+	if len(p.allowHosts) == 0 {
+		return true
+	}
+
+	host = strings.ToLower(host)
+
+	for _, h := range p.allowHosts {
+		if strings.HasPrefix(h, "*.") {
+			if strings.HasSuffix(host, h[1:]) {
+				return true
+			}
+			continue
+		}
+		if host == h {
+			return true
+		}
+	}
+
+	return false
+}
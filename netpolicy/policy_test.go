@@ -0,0 +1,87 @@
+package netpolicy
+
+import (
+	"context"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type PolicyTestSuite struct {
+	suite.Suite
+}
+
+// TestNew tests the proper initialisation of a policy
+func (suite *PolicyTestSuite) TestNew() {
+
+	// defaults
+	p1, e1 := New(nil, nil, nil)
+	suite.Equal(len(DefaultDenyCIDRs), len(p1.deny))
+	suite.Equal(DefaultAllowedSchemes, p1.allowedSchemes)
+	suite.Nil(p1.allowHosts)
+	suite.Nil(e1)
+
+	// an empty deny list disables the default one
+	p2, e2 := New([]string{}, []string{"Example.com"}, []string{"HTTPS"})
+	suite.Nil(p2.deny)
+	suite.Equal([]string{"example.com"}, p2.allowHosts)
+	suite.Equal([]string{"https"}, p2.allowedSchemes)
+	suite.Nil(e2)
+
+	// invalid cidr
+	p3, e3 := New([]string{"10.0.0.0"}, nil, nil)
+	suite.Nil(p3)
+	suite.Equal("Invalid cidr 10.0.0.0", e3.Error())
+}
+
+// TestCheckURL tests the check of a destination url against the policy
+func (suite *PolicyTestSuite) TestCheckURL() {
+
+	p, _ := New(nil, []string{"example.com", "*.example.org", "internal.local", "unknown.example.com"}, []string{"https"})
+	p.SetResolver(MockResolver{
+		"example.com":         {"93.184.216.34"},
+		"api.example.org":     {"93.184.216.34"},
+		"internal.local":      {"93.184.216.34", "10.0.0.5"},
+		"unknown.example.com": {},
+	})
+
+	suite.Nil(p.CheckURL(context.Background(), "https://example.com/receive_here"))
+	suite.Nil(p.CheckURL(context.Background(), "https://api.example.org:8443/receive_here"))
+
+	// unresolvable hosts are rejected
+	suite.Equal("host unknown.example.com could not be resolved, lookup unknown.example.com: no such host",
+		p.CheckURL(context.Background(), "https://unknown.example.com").Error())
+
+	suite.Equal("scheme http is not allowed", p.CheckURL(context.Background(), "http://example.com").Error())
+	suite.Equal("scheme example.com is not allowed", p.CheckURL(context.Background(), "example.com:9999").Error())
+	suite.Equal("host example.net is not allowed", p.CheckURL(context.Background(), "https://example.net").Error())
+	suite.Equal("host example.org is not allowed", p.CheckURL(context.Background(), "https://example.org").Error())
+	suite.Equal("host internal.local resolves to address 10.0.0.5 is not allowed",
+		p.CheckURL(context.Background(), "https://internal.local").Error())
+
+	// ip destinations
+	p2, _ := New(nil, nil, nil)
+	suite.Nil(p2.CheckURL(context.Background(), "http://93.184.216.34:8080"))
+	suite.Equal("address 127.0.0.1 is not allowed", p2.CheckURL(context.Background(), "http://127.0.0.1:8080/").Error())
+	suite.Equal("address 169.254.169.254 is not allowed", p2.CheckURL(context.Background(), "http://169.254.169.254/latest").Error())
+	suite.Equal("address ::1 is not allowed", p2.CheckURL(context.Background(), "http://[::1]:8080/").Error())
+	suite.Equal("address 192.168.1.1 is not allowed", p2.CheckURL(context.Background(), "http://[::ffff:192.168.1.1]/").Error())
+	suite.Equal("missing host", p2.CheckURL(context.Background(), "https:///path").Error())
+}
+
+// TestControl tests that the dialer refuses to connect to denied addresses
+func (suite *PolicyTestSuite) TestControl() {
+
+	p, _ := New(nil, nil, nil)
+
+	suite.Nil(p.Control("tcp4", "93.184.216.34:443", nil))
+	suite.Equal("address 10.1.2.3 is not allowed", p.Control("tcp4", "10.1.2.3:443", nil).Error())
+	suite.Equal("address fe80::1 is not allowed", p.Control("tcp6", "[fe80::1]:443", nil).Error())
+
+	// the dialer never reaches the denied address
+	_, err := p.Dialer().Dial("tcp", "127.0.0.1:1")
+	suite.Contains(err.Error(), "address 127.0.0.1 is not allowed")
+}
+
+func TestPolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(PolicyTestSuite))
+}
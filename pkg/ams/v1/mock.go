@@ -64,7 +64,7 @@ func (m *MockAmsRoundTripper) RoundTrip(r *http.Request) (*http.Response, error)
 		}
 
 		pc := PushConfig{
-			Pend:                "example.com:9999",
+			Pend:                "https://example.com:9999",
 			Type:                HttpEndpointPushConfig,
 			AuthorizationHeader: authz,
 			RetPol:              rp,
@@ -99,7 +99,7 @@ func (m *MockAmsRoundTripper) RoundTrip(r *http.Request) (*http.Response, error)
 			RetPol:             rp,
 			MattermostChannel:  "channel",
 			MattermostUsername: "mattermost",
-			MattermostUrl:      "webhook.com",
+			MattermostUrl:      "https://webhook.com",
 			Base64Decode:       false,
 		}
 
@@ -155,7 +155,7 @@ func (m *MockAmsRoundTripper) RoundTrip(r *http.Request) (*http.Response, error)
 
 		pc := PushConfig{
 			Type:   HttpEndpointPushConfig,
-			Pend:   "example.com:9999",
+			Pend:   "https://example.com:9999",
 			RetPol: rp,
 		}
 
@@ -14,6 +14,9 @@ const (
 	getSubscriptionPath    = "/v1%s"
 	HttpEndpointPushConfig = "http_endpoint"
 	MattermostPushConfig   = "mattermost"
+	AllMustSucceedPolicy   = "all_must_succeed"
+	AnySucceedsPolicy      = "any_succeeds"
+	BestEffortPolicy       = "best_effort"
 )
 
 type Subscription struct {
@@ -24,15 +27,32 @@ type Subscription struct {
 
 // PushConfig holds optional configuration for push operations
 type PushConfig struct {
+	Type                 string              `json:"type"`
+	Pend                 string              `json:"pushEndpoint"`
+	AuthorizationHeader  AuthorizationHeader `json:"authorizationHeader"`
+	MaxMessages          int64               `json:"maxMessages"`
+	RetPol               RetryPolicy         `json:"retryPolicy"`
+	MattermostUrl        string              `json:"mattermostUrl"`
+	MattermostUsername   string              `json:"mattermostUsername"`
+	MattermostChannel    string              `json:"mattermostChannel"`
+	Base64Decode         bool                `json:"base64Decode"`
+	MessageFormat        string              `json:"messageFormat,omitempty"`
+	Compression          string              `json:"compression,omitempty"`
+	CompressionThreshold int64               `json:"compressionThreshold,omitempty"`
+	MaxBatchBytes        int64               `json:"maxBatchBytes,omitempty"`
+	Destinations         []Destination       `json:"destinations,omitempty"`
+	DeliveryPolicy       string              `json:"deliveryPolicy,omitempty"`
+	TimeoutMs            int64               `json:"timeoutMs,omitempty"`
+}
+
+// Destination holds information on an additional destination that the messages are pushed to
+type Destination struct {
 	Type                string              `json:"type"`
 	Pend                string              `json:"pushEndpoint"`
 	AuthorizationHeader AuthorizationHeader `json:"authorizationHeader"`
-	MaxMessages         int64               `json:"maxMessages"`
-	RetPol              RetryPolicy         `json:"retryPolicy"`
 	MattermostUrl       string              `json:"mattermostUrl"`
 	MattermostUsername  string              `json:"mattermostUsername"`
 	MattermostChannel   string              `json:"mattermostChannel"`
-	Base64Decode        bool                `json:"base64Decode"`
 }
 
 // AuthorizationHeader holds an optional value to be supplied as an Authorization header to push requests
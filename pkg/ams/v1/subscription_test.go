@@ -63,7 +63,7 @@ func (suite *SubscriptionTestSuite) TestGetSubscription() {
 
 	pc := PushConfig{
 		Type:                HttpEndpointPushConfig,
-		Pend:                "example.com:9999",
+		Pend:                "https://example.com:9999",
 		AuthorizationHeader: authz,
 		RetPol:              rp,
 		Base64Decode:        true,
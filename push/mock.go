@@ -1,8 +1,11 @@
 package push
 
 import (
+	"context"
 	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
 	"github.com/ARGOeu/ams-push-server/consumers"
+	"github.com/ARGOeu/ams-push-server/senders"
+	"github.com/ARGOeu/ams-push-server/state"
 )
 
 // MockWorker is to be used as a dummy worker when we want the push actual worker functionality
@@ -10,6 +13,9 @@ type MockWorker struct {
 	Sub       amsPb.Subscription
 	SubStatus string
 	status    string
+	MSender   senders.Sender
+	// the state the worker was restored from
+	Restored *state.WorkerState
 }
 
 func (w *MockWorker) Status() string {
@@ -20,12 +26,36 @@ func (w *MockWorker) Consumer() consumers.Consumer {
 	return new(consumers.MockConsumer)
 }
 
+func (w *MockWorker) Sender() senders.Sender {
+	if w.MSender == nil {
+		return new(senders.MockSender)
+	}
+	return w.MSender
+}
+
 func (w *MockWorker) Subscription() *amsPb.Subscription {
-	return new(amsPb.Subscription)
+	return &w.Sub
+}
+
+func (w *MockWorker) State() state.WorkerState {
+	return state.WorkerState{
+		Subscription: &w.Sub,
+		LastError:    w.SubStatus,
+	}
+}
+
+func (w *MockWorker) Restore(s state.WorkerState) {
+	w.Restored = &s
 }
 
 func (w *MockWorker) Start() {}
 
+func (w *MockWorker) Drain(ctx context.Context) error {
+	w.status = "drained"
+	w.SubStatus = "drained"
+	return nil
+}
+
 func (w *MockWorker) Stop() {
 	w.status = "stopped"
 	w.SubStatus = "stopped"
@@ -0,0 +1,117 @@
+package push
+
+import (
+	"sort"
+	"sync"
+)
+
+// Registry holds the active workers keyed by the full name of their subscription,
+// it is safe to be used by multiple goroutines at the same time
+type Registry struct {
+	mutex   sync.RWMutex
+	workers map[string]Worker
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		workers: make(map[string]Worker),
+	}
+}
+
+// Get returns the worker of the provided subscription and whether or not there is one
+func (r *Registry) Get(name string) (Worker, bool) {
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	w, found := r.workers[name]
+
+	return w, found
+}
+
+// Has returns whether or not the provided subscription has a worker
+func (r *Registry) Has(name string) bool {
+
+	_, found := r.Get(name)
+
+	return found
+}
+
+// Add registers the worker of the provided subscription, unless the subscription already has one.
+// It returns whether or not the worker was registered, only a registered worker should be started
+func (r *Registry) Add(name string, w Worker) bool {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, found := r.workers[name]; found {
+		return false
+	}
+
+	r.workers[name] = w
+
+	return true
+}
+
+// Remove unregisters and returns the worker of the provided subscription, the caller is the one to stop it
+func (r *Registry) Remove(name string) (Worker, bool) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	w, found := r.workers[name]
+	if found {
+		delete(r.workers, name)
+	}
+
+	return w, found
+}
+
+// Len returns how many workers are registered
+func (r *Registry) Len() int {
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.workers)
+}
+
+// Names returns the sorted names of the subscriptions that have a worker
+func (r *Registry) Names() []string {
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.workers))
+	for name := range r.workers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Range calls f for every registered worker, in the order of their subscriptions' names.
+// It iterates over a snapshot of the registry, so f is free to add or remove workers
+func (r *Registry) Range(f func(name string, w Worker)) {
+
+	r.mutex.RLock()
+	snapshot := make(map[string]Worker, len(r.workers))
+	for name, w := range r.workers {
+		snapshot[name] = w
+	}
+	r.mutex.RUnlock()
+
+	names := make([]string, 0, len(snapshot))
+	for name := range snapshot {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		f(name, snapshot[name])
+	}
+}
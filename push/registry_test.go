@@ -0,0 +1,123 @@
+package push
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type RegistryTestSuite struct {
+	suite.Suite
+}
+
+// TestRegistry tests the lookup, insertion, removal and iteration of the workers
+func (suite *RegistryTestSuite) TestRegistry() {
+
+	r := NewRegistry()
+	suite.Equal(0, r.Len())
+
+	w1 := new(MockWorker)
+	w2 := new(MockWorker)
+
+	suite.True(r.Add("sub2", w2))
+	suite.True(r.Add("sub1", w1))
+
+	// a subscription can't have a second worker
+	suite.False(r.Add("sub1", new(MockWorker)))
+
+	w, found := r.Get("sub1")
+	suite.True(found)
+	suite.Same(w1, w)
+	suite.True(r.Has("sub2"))
+	suite.False(r.Has("sub3"))
+	suite.Equal(2, r.Len())
+	suite.Equal([]string{"sub1", "sub2"}, r.Names())
+
+	// the iteration is sorted and can remove workers
+	names := make([]string, 0)
+	r.Range(func(name string, w Worker) {
+		names = append(names, name)
+		r.Remove(name)
+	})
+	suite.Equal([]string{"sub1", "sub2"}, names)
+	suite.Equal(0, r.Len())
+
+	_, found = r.Remove("sub1")
+	suite.False(found)
+}
+
+// TestRegistryConcurrency adds, removes, looks up and iterates the workers from multiple goroutines,
+// run it with -race to detect unsynchronized access
+func (suite *RegistryTestSuite) TestRegistryConcurrency() {
+
+	r := NewRegistry()
+
+	const subs = 50
+	const callers = 10
+
+	var added, removed atomic.Int32
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < subs; i++ {
+
+		name := fmt.Sprintf("sub%v", i)
+
+		for j := 0; j < callers; j++ {
+
+			wg.Add(2)
+
+			go func() {
+				defer wg.Done()
+				if r.Add(name, new(MockWorker)) {
+					added.Add(1)
+				}
+			}()
+
+			go func() {
+				defer wg.Done()
+				r.Get(name)
+				r.Len()
+				r.Names()
+				r.Range(func(name string, w Worker) {})
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	// every subscription got exactly one worker
+	suite.Equal(int32(subs), added.Load())
+	suite.Equal(subs, r.Len())
+
+	for i := 0; i < subs; i++ {
+
+		name := fmt.Sprintf("sub%v", i)
+
+		for j := 0; j < callers; j++ {
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				if _, found := r.Remove(name); found {
+					removed.Add(1)
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	// every worker was removed exactly once
+	suite.Equal(int32(subs), removed.Load())
+	suite.Equal(0, r.Len())
+}
+
+func TestRegistryTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(RegistryTestSuite))
+}
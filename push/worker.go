@@ -3,13 +3,19 @@ package push
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
 	"github.com/ARGOeu/ams-push-server/consumers"
+	"github.com/ARGOeu/ams-push-server/logging"
 	v1 "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
 	"github.com/ARGOeu/ams-push-server/retrypolicies"
 	"github.com/ARGOeu/ams-push-server/senders"
+	"github.com/ARGOeu/ams-push-server/state"
 	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,12 +32,21 @@ type Worker interface {
 	Start()
 	// Stop cancels the push functionality
 	Stop()
+	// Drain stops the push functionality once the current push cycle completes,
+	// the cycle is canceled if it doesn't complete before the context is done
+	Drain(ctx context.Context) error
 	// Subscription returns the currently active subscription that is being handled by the worker
 	Subscription() *amsPb.Subscription
 	// Consumer returns the consumer that the worker is using
 	Consumer() consumers.Consumer
+	// Sender returns the sender that the worker is using
+	Sender() senders.Sender
 	// Status returns the status of the worker
 	Status() string
+	// State returns the state of the worker that is carried over a restart of the service
+	State() state.WorkerState
+	// Restore continues from a previous state of the worker, it is called before the worker starts
+	Restore(s state.WorkerState)
 }
 
 // New acts as a worker factory, creates and returns a new worker based on the provided type
@@ -55,6 +70,8 @@ func New(sub *amsPb.Subscription, c consumers.Consumer, s senders.Sender, ch cha
 	w.ctx = ctx
 	w.cancel = cancel
 	w.deactivationChan = ch
+	w.batchBytes = sub.PushConfig.MaxBatchBytes
+	w.publishState()
 
 	return w, nil
 
@@ -69,7 +86,29 @@ type worker struct {
 	ctx              context.Context
 	retryPolicy      retrypolicies.RetryPolicy
 	deactivationChan chan<- consumers.CancelableError
-	pushErr          string
+	// error of the last push cycle, empty if it completed successfully, it is read concurrently through the status
+	pushErr atomic.Value
+	// maximum size in bytes of a pushed batch, it shrinks whenever a receiver rejects a batch as too large
+	batchBytes int64
+	// draining is closed to stop the worker once its current push cycle completes, done is closed once it has stopped
+	draining  chan struct{}
+	done      chan struct{}
+	initOnce  sync.Once
+	drainOnce sync.Once
+	// whether the worker has started or has been drained, a worker drained before it starts never pushes
+	lifecycle sync.Mutex
+	started   bool
+	drained   bool
+	// the retry interval and the batch size as they were after the last push cycle, they are read concurrently
+	// through the state of the worker
+	cycle atomic.Value
+}
+
+// cycleState holds what a push cycle changes in the state of the worker
+type cycleState struct {
+	retryInterval time.Duration
+	failing       bool
+	batchBytes    int64
 }
 
 // Consumer returns the currently in use consumer
@@ -77,13 +116,39 @@ func (w *worker) Consumer() consumers.Consumer {
 	return w.consumer
 }
 
+// Sender returns the currently in use sender
+func (w *worker) Sender() senders.Sender {
+	return w.sender
+}
+
 // Status returns whether or not the worker is experiencing any error handling its assigned subscription
 func (w *worker) Status() string {
-	if w.pushErr == "" {
-		return fmt.Sprintf("Subscription %v is currently active", w.sub.FullName)
+
+	status := w.lastPushErr()
+	if status == "" {
+		status = fmt.Sprintf("Subscription %v is currently active", w.sub.FullName)
+	}
+
+	if n, ok := w.sender.(senders.Noter); ok {
+		if notes := n.Notes(); len(notes) > 0 {
+			status = fmt.Sprintf("%v (%v)", status, strings.Join(notes, "; "))
+		}
 	}
 
-	return w.pushErr
+	return status
+}
+
+// setPushErr records the error of the last push cycle
+func (w *worker) setPushErr(err string) {
+	w.pushErr.Store(err)
+}
+
+// lastPushErr returns the error of the last push cycle, empty if there was none
+func (w *worker) lastPushErr() string {
+
+	err, _ := w.pushErr.Load().(string)
+
+	return err
 }
 
 // Subscription returns the currently active subscription inside the worker
@@ -94,22 +159,130 @@ func (w *worker) Subscription() *amsPb.Subscription {
 // Start starts the push functionality for the worker
 func (w *worker) Start() {
 
+	w.init()
+
+	w.lifecycle.Lock()
+	if w.drained {
+		w.lifecycle.Unlock()
+		return
+	}
+	w.started = true
+	w.lifecycle.Unlock()
+
+	defer close(w.done)
+
 Loop:
 	for {
+		// a requested drain takes precedence over a cycle that is due
+		select {
+		case <-w.draining:
+			w.stopTimer()
+			break Loop
+		default:
+		}
+
 		select {
 		case <-w.retryPolicy.Timer().C:
 			w.push()
 		case <-w.ctx.Done():
-			canceled := w.retryPolicy.Timer().Stop()
+			w.stopTimer()
+			break Loop
+		case <-w.draining:
+			w.stopTimer()
+			break Loop
+		}
 
-			if !canceled {
-				<-w.retryPolicy.Timer().C
-			}
+		w.retryPolicy.Reset(w.lastPushErr())
+		w.publishState()
+	}
+}
 
-			break Loop
+// publishState makes the outcome of the last push cycle available to the state of the worker
+func (w *worker) publishState() {
+
+	cs := cycleState{
+		batchBytes: w.batchBytes,
+	}
+
+	if r, ok := w.retryPolicy.(retrypolicies.Resumable); ok {
+		cs.retryInterval, cs.failing = r.Interval()
+	}
+
+	w.cycle.Store(cs)
+}
+
+// State returns the subscription of the worker along with the outcome of its last push cycle
+func (w *worker) State() state.WorkerState {
+
+	cs, _ := w.cycle.Load().(cycleState)
+
+	s := state.WorkerState{
+		Subscription:  w.sub,
+		RetryInterval: cs.retryInterval,
+		Failing:       cs.failing,
+		LastError:     w.lastPushErr(),
+		BatchBytes:    cs.batchBytes,
+	}
+
+	if sr, ok := w.sender.(senders.StatsReporter); ok {
+		for _, st := range sr.Stats() {
+			s.Destinations = append(s.Destinations, state.DestinationState{
+				Destination:       st.Destination,
+				DeliveredMessages: st.DeliveredMessages,
+				FailedDeliveries:  st.FailedDeliveries,
+				LastError:         st.LastError,
+			})
+		}
+	}
+
+	return s
+}
+
+// Restore continues from the retry interval, the error, the batch size and the delivery information
+// of a previous state. A batch size that exceeds the one of the subscription is ignored
+func (w *worker) Restore(s state.WorkerState) {
+
+	w.setPushErr(s.LastError)
+
+	if s.BatchBytes > 0 && (w.batchBytes <= 0 || s.BatchBytes < w.batchBytes) {
+		w.batchBytes = s.BatchBytes
+	}
+
+	if r, ok := w.retryPolicy.(retrypolicies.Resumable); ok && s.RetryInterval > 0 {
+		r.Resume(s.RetryInterval, s.Failing)
+	}
+
+	if sr, ok := w.sender.(senders.StatsRestorer); ok {
+		stats := make([]senders.DestinationStats, 0, len(s.Destinations))
+		for _, d := range s.Destinations {
+			stats = append(stats, senders.DestinationStats{
+				Destination:       d.Destination,
+				DeliveredMessages: d.DeliveredMessages,
+				FailedDeliveries:  d.FailedDeliveries,
+				LastError:         d.LastError,
+			})
 		}
+		sr.RestoreStats(stats)
+	}
 
-		w.retryPolicy.Reset(w.pushErr)
+	w.publishState()
+}
+
+// init initialises the channels that signal the draining of the worker
+func (w *worker) init() {
+	w.initOnce.Do(func() {
+		w.draining = make(chan struct{})
+		w.done = make(chan struct{})
+	})
+}
+
+// stopTimer stops the timer of the retry policy, draining its channel if it has already fired
+func (w *worker) stopTimer() {
+
+	canceled := w.retryPolicy.Timer().Stop()
+
+	if !canceled {
+		<-w.retryPolicy.Timer().C
 	}
 }
 
@@ -126,34 +299,39 @@ func (w *worker) push() {
 		}
 
 		if err.Error() == "no new messages" {
-			log.WithFields(
+			logging.WithFields(
+				logging.Worker,
 				log.Fields{
-					"type":     "service_log",
-					"resource": w.consumer.ResourceInfo(),
+					"type":         "system_log",
+					"subscription": w.sub.FullName,
 				},
 			).Debug("No new messages")
 			return
 		}
 
-		log.WithFields(
+		logging.WithFields(
+			logging.Worker,
 			log.Fields{
-				"type":     "service_log",
-				"resource": w.consumer.ResourceInfo(),
-				"error":    err.Error(),
+				"type":         "system_log",
+				"subscription": w.sub.FullName,
+				"error":        err.Error(),
 			},
 		).Error("Could not consume message")
 
-		w.pushErr = fmt.Sprintf(
+		w.setPushErr(fmt.Sprintf(
 			"%v - %v, %v",
 			time.Now().UTC().Format("2006-01-02T15:04:05"),
 			"Could not consume message",
 			err.Error(),
-		)
+		))
 
 		return
 	}
 
-	pms := senders.PushMsgs{}
+	pms := senders.PushMsgs{
+		Subscription: w.sub.FullName,
+		Topic:        w.sub.FullTopic,
+	}
 
 	for _, rm := range rml.RecMsgs {
 
@@ -163,9 +341,10 @@ func (w *worker) push() {
 		if w.sub.PushConfig.Base_64Decode {
 			decodedMessageBytes, err := base64.StdEncoding.DecodeString(rm.Msg.Data)
 			if err != nil {
-				log.WithFields(
+				logging.WithFields(
+					logging.Worker,
 					log.Fields{
-						"type":         "service_log",
+						"type":         "system_log",
 						"subscription": w.sub.FullName,
 						"message_id":   rm.Msg.ID,
 						"error":        err.Error(),
@@ -192,51 +371,152 @@ func (w *worker) push() {
 		pms.Messages = append(pms.Messages, msg)
 	}
 
-	err = w.sender.Send(w.ctx, pms, senders.DetermineMessageFormat(w.sub.PushConfig.MaxMessages))
-	if err != nil {
-		log.WithFields(
-			log.Fields{
-				"type":     "service_log",
-				"endpoint": w.sender.Destination(),
-				"error":    err.Error(),
-			},
-		).Error("Could not send message")
+	format := senders.DetermineMessageFormat(w.sub.PushConfig.MaxMessages, w.sub.PushConfig.MessageFormat)
 
-		w.pushErr = fmt.Sprintf(
-			"%v - %v, %v",
-			time.Now().UTC().Format("2006-01-02T15:04:05"),
-			"Could not send message",
-			err.Error(),
-		)
+	// deliver the messages in batches that respect the batch size limit,
+	// each delivered batch is acknowledged before moving on to the next one
+	for len(pms.Messages) > 0 {
 
-		return
-	}
+		n := w.batchLen(pms.Messages)
 
-	err = w.consumer.Ack(w.ctx, rml.Last().AckID)
-	if err != nil {
+		batch := pms
+		batch.Messages = pms.Messages[:n]
 
-		log.WithFields(
-			log.Fields{
-				"type":  "service_log",
-				"error": err.Error(),
-			},
-		).Error("Could not acknowledge message")
+		err = w.sender.Send(w.ctx, batch, format)
 
-		w.pushErr = fmt.Sprintf(
-			"%v - %v, %v",
-			time.Now().UTC().Format("2006-01-02T15:04:05"),
-			"Could not acknowledge message",
-			err.Error(),
-		)
+		// the receiver rejected the batch because of its size, shrink the batch and retry
+		if senders.IsPayloadTooLarge(err) && n > 1 {
 
-		return
+			w.batchBytes = batchSize(batch.Messages) / 2
+
+			logging.WithFields(
+				logging.Worker,
+				log.Fields{
+					"type":            "system_log",
+					"destination":     w.sender.Destination(),
+					"max_batch_bytes": w.batchBytes,
+				},
+			).Warning("Batch too large for the endpoint, shrinking the batch size")
+
+			continue
+		}
+
+		if err != nil {
+			logging.WithFields(
+				logging.Worker,
+				log.Fields{
+					"type":        "system_log",
+					"destination": w.sender.Destination(),
+					"error":       err.Error(),
+				},
+			).Error("Could not send message")
+
+			w.setPushErr(fmt.Sprintf(
+				"%v - %v, %v",
+				time.Now().UTC().Format("2006-01-02T15:04:05"),
+				"Could not send message",
+				err.Error(),
+			))
+
+			return
+		}
+
+		// acknowledging the last message of the batch, acknowledges the whole batch
+		err = w.consumer.Ack(w.ctx, rml.RecMsgs[n-1].AckID)
+		if err != nil {
+
+			logging.WithFields(
+				logging.Worker,
+				log.Fields{
+					"type":  "system_log",
+					"error": err.Error(),
+				},
+			).Error("Could not acknowledge message")
+
+			w.setPushErr(fmt.Sprintf(
+				"%v - %v, %v",
+				time.Now().UTC().Format("2006-01-02T15:04:05"),
+				"Could not acknowledge message",
+				err.Error(),
+			))
+
+			return
+		}
+
+		pms.Messages = pms.Messages[n:]
+		rml.RecMsgs = rml.RecMsgs[n:]
 	}
 
 	// if no errors occurred during the push cycle make sure that there is no error registered
-	w.pushErr = ""
+	w.setPushErr("")
+}
+
+// batchLen returns how many of the provided messages fit in a single batch without exceeding the batch size limit.
+// A batch always contains at least one message, even if that message exceeds the limit on its own
+func (w *worker) batchLen(msgs []senders.PushMsg) int {
+
+	if w.batchBytes <= 0 {
+		return len(msgs)
+	}
+
+	size := int64(0)
+	for idx, msg := range msgs {
+		size += msgSize(msg)
+		if size > w.batchBytes && idx > 0 {
+			return idx
+		}
+	}
+
+	return len(msgs)
+}
+
+// batchSize returns the approximate size in bytes of the payload of a batch of messages
+func batchSize(msgs []senders.PushMsg) int64 {
+
+	size := int64(0)
+	for _, msg := range msgs {
+		size += msgSize(msg)
+	}
+
+	return size
+}
+
+// msgSize returns the approximate size in bytes that a message occupies in a push payload
+func msgSize(msg senders.PushMsg) int64 {
+	b, _ := json.Marshal(msg)
+	return int64(len(b))
 }
 
 // Stop stops the push worker's functionality
 func (w *worker) Stop() {
 	w.cancel()
 }
+
+// Drain lets the current push cycle, if any, consume, send and acknowledge its messages and then stops the worker.
+// If the cycle doesn't complete before the context is done, it is canceled and the context's error is returned
+func (w *worker) Drain(ctx context.Context) error {
+
+	w.init()
+	w.drainOnce.Do(func() {
+		close(w.draining)
+	})
+
+	// a worker that hasn't started has no push cycle to complete
+	w.lifecycle.Lock()
+	w.drained = true
+	started := w.started
+	w.lifecycle.Unlock()
+
+	if !started {
+		w.cancel()
+		return nil
+	}
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		return ctx.Err()
+	}
+}
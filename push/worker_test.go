@@ -8,6 +8,7 @@ import (
 	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
 	"github.com/ARGOeu/ams-push-server/retrypolicies"
 	"github.com/ARGOeu/ams-push-server/senders"
+	"github.com/ARGOeu/ams-push-server/state"
 	"github.com/stretchr/testify/suite"
 	"net/http"
 	"testing"
@@ -268,7 +269,7 @@ func (suite *WorkerTestSuite) TestPush() {
 	suite.Equal(0, len(c.AckMessages))
 	suite.Equal(1, len(c.GeneratedMessages))
 	suite.Equal(0, len(s.PushMessages))
-	pushErr1stCycle := lw.pushErr
+	pushErr1stCycle := lw.lastPushErr()
 	suite.Regexp("[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2} - Could not send message, error while sending", pushErr1stCycle)
 	suite.Regexp("[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2} - Could not send message, error while sending", lw.Status())
 
@@ -305,6 +306,80 @@ func (suite *WorkerTestSuite) TestPush() {
 	}, <-cancelCh2)
 }
 
+// TestPushMaxBatchBytes tests the splitting of the consumed messages into batches
+func (suite *WorkerTestSuite) TestPushMaxBatchBytes() {
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	sub := &amsPb.Subscription{
+		FullName: "sub1",
+		PushConfig: &amsPb.PushConfig{
+			Type:        amsPb.PushType_HTTP_ENDPOINT,
+			MaxMessages: 3,
+			RetryPolicy: &amsPb.RetryPolicy{
+				Period: 300,
+				Type:   retrypolicies.LinearRetryPolicy,
+			},
+		},
+	}
+
+	rp, _ := retrypolicies.New(sub.PushConfig.RetryPolicy)
+
+	c := new(consumers.MockConsumer)
+	c.SubStatus = "normal_sub"
+	c.AckStatus = "normal_ack"
+	s := new(senders.MockSender)
+
+	lw := worker{
+		sub:         sub,
+		consumer:    c,
+		sender:      s,
+		retryPolicy: rp,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	msgSize := msgSize(senders.PushMsg{
+		Sub: "mock-consumer",
+		Msg: ams.Message{
+			ID:      "id_0",
+			Data:    "c29tZSBkYXRh",
+			PubTime: time.Now().UTC().Format(time.StampNano),
+		},
+	})
+
+	// a limit that fits two messages, splits the three consumed messages in two batches
+	lw.batchBytes = msgSize*2 + 1
+	lw.push()
+	suite.Equal([]int{2, 1}, s.Batches)
+	suite.Equal(2, len(c.AckMessages))
+	suite.Equal(3, len(s.PushMessages))
+	suite.Equal("Subscription sub1 is currently active", lw.Status())
+
+	// no limit, the receiver rejects batches with more than one message
+	c.GeneratedMessages = nil
+	c.AckMessages = nil
+	s2 := &senders.MockSender{MaxBatchMessages: 1}
+	lw.sender = s2
+	lw.batchBytes = 0
+	lw.push()
+	suite.Equal([]int{1, 1, 1}, s2.Batches)
+	suite.Equal(3, len(c.AckMessages))
+	suite.True(lw.batchBytes > 0)
+	suite.True(lw.batchBytes < msgSize*2)
+	suite.Equal("Subscription sub1 is currently active", lw.Status())
+
+	// a failed batch stops the delivery, only the delivered batches are acknowledged
+	c.GeneratedMessages = nil
+	c.AckMessages = nil
+	s3 := &senders.MockSender{MaxBatchMessages: 1}
+	lw.sender = s3
+	lw.sub.PushConfig.MaxMessages = 1
+	lw.batchBytes = 0
+	lw.push()
+	suite.Equal([]int{1}, s3.Batches)
+	suite.Equal(1, len(c.AckMessages))
+}
+
 func (suite *WorkerTestSuite) TestConsumer() {
 
 	mc := new(consumers.MockConsumer)
@@ -323,12 +398,150 @@ func (suite *WorkerTestSuite) TestStatus() {
 		},
 	}
 
-	lw.pushErr = "error1"
+	lw.setPushErr("error1")
 
 	suite.Equal("error1", lw.Status())
 
-	lw.pushErr = ""
+	lw.setPushErr("")
 	suite.Equal("Subscription sub1 is currently active", lw.Status())
+
+	// notes reported by the sender accompany the status
+	lw.sender = &senders.MockSender{SenderNotes: []string{"note1", "note2"}}
+	suite.Equal("Subscription sub1 is currently active (note1; note2)", lw.Status())
+}
+
+// TestStateRestore tests that a new worker continues from the state of a previous one
+func (suite *WorkerTestSuite) TestStateRestore() {
+
+	sub := &amsPb.Subscription{
+		FullName: "/projects/p1/subscriptions/sub1",
+		PushConfig: &amsPb.PushConfig{
+			Type:          amsPb.PushType_HTTP_ENDPOINT,
+			MaxMessages:   1,
+			MaxBatchBytes: 4096,
+			RetryPolicy: &amsPb.RetryPolicy{
+				Type: retrypolicies.SlowStartRetryPolicy,
+			},
+		},
+	}
+
+	ms := senders.NewMultiSender([]senders.Sender{new(senders.MockSender)}, amsPb.DeliveryPolicy_ALL_MUST_SUCCEED)
+
+	w, err := New(sub, new(consumers.MockConsumer), ms, make(chan consumers.CancelableError))
+	suite.Nil(err)
+
+	// a new worker starts from the initial interval of its retry policy
+	s1 := w.State()
+	suite.Equal(sub, s1.Subscription)
+	suite.Equal(retrypolicies.SlowStartInitialInterval, s1.RetryInterval)
+	suite.False(s1.Failing)
+	suite.Equal("", s1.LastError)
+	suite.Equal(int64(4096), s1.BatchBytes)
+	suite.Equal([]state.DestinationState{{Destination: "mock destination"}}, s1.Destinations)
+
+	w.Restore(state.WorkerState{
+		Subscription:  sub,
+		RetryInterval: 8 * time.Second,
+		Failing:       true,
+		LastError:     "Could not send message",
+		BatchBytes:    1024,
+		Destinations: []state.DestinationState{
+			{Destination: "mock destination", DeliveredMessages: 3, FailedDeliveries: 1, LastError: "error"},
+		},
+	})
+
+	s2 := w.State()
+	suite.Equal(8*time.Second, s2.RetryInterval)
+	suite.True(s2.Failing)
+	suite.Equal("Could not send message", s2.LastError)
+	suite.Equal("Could not send message", w.Status())
+	suite.Equal(int64(1024), s2.BatchBytes)
+	suite.Equal(uint64(3), s2.Destinations[0].DeliveredMessages)
+
+	// a batch size larger than the one of the subscription is ignored
+	w.Restore(state.WorkerState{Subscription: sub, BatchBytes: 8192})
+	suite.Equal(int64(1024), w.State().BatchBytes)
+}
+
+// TestDrain tests that draining lets the current push cycle complete, unless the drain timeout expires first
+func (suite *WorkerTestSuite) TestDrain() {
+
+	newWorker := func(delay time.Duration) (*worker, *consumers.MockConsumer) {
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := &amsPb.Subscription{
+			PushConfig: &amsPb.PushConfig{
+				Type:        amsPb.PushType_HTTP_ENDPOINT,
+				MaxMessages: 1,
+				RetryPolicy: &amsPb.RetryPolicy{
+					Period: 10,
+					Type:   retrypolicies.LinearRetryPolicy,
+				},
+			},
+		}
+
+		rp, _ := retrypolicies.New(sub.PushConfig.RetryPolicy)
+
+		c := new(consumers.MockConsumer)
+		c.SubStatus = "normal_sub"
+		c.AckStatus = "normal_ack"
+
+		return &worker{
+			sub:         sub,
+			consumer:    c,
+			sender:      &senders.MockSender{SendDelay: delay},
+			retryPolicy: rp,
+			ctx:         ctx,
+			cancel:      cancel,
+		}, c
+	}
+
+	// the in-flight cycle completes and gets acknowledged
+	w1, c1 := newWorker(300 * time.Millisecond)
+	go w1.Start()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx1, cancel1 := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel1()
+	suite.Nil(w1.Drain(ctx1))
+	suite.Equal([]string{"ackid_0"}, c1.AckMessages)
+	suite.Nil(w1.ctx.Err())
+
+	// draining again returns immediately
+	suite.Nil(w1.Drain(ctx1))
+
+	// the in-flight cycle doesn't complete in time and gets canceled without being acknowledged
+	w2, c2 := newWorker(5 * time.Second)
+	go w2.Start()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel2()
+	suite.Equal(context.DeadlineExceeded, w2.Drain(ctx2))
+	<-w2.done
+	suite.Empty(c2.AckMessages)
+
+	// a worker that never started drains right away, instead of waiting for the drain timeout
+	w3, c3 := newWorker(0)
+	ctx3, cancel3 := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel3()
+	t3 := time.Now()
+	suite.Nil(w3.Drain(ctx3))
+	suite.Less(time.Since(t3), time.Second)
+	suite.NotNil(w3.ctx.Err())
+
+	// and if it gets started afterwards, it never pushes
+	started := make(chan struct{})
+	go func() {
+		w3.Start()
+		close(started)
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		suite.Fail("the drained worker should not have started")
+	}
+	suite.Empty(c3.AckMessages)
 }
 
 func TestWorkerTestSuite(t *testing.T) {
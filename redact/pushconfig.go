@@ -0,0 +1,34 @@
+package redact
+
+import (
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+)
+
+// PushConfig returns a copy of the provided push configuration
+// with its authorization headers and the secrets of its urls masked
+func PushConfig(pc *amsPb.PushConfig) *amsPb.PushConfig {
+
+	if pc == nil {
+		return nil
+	}
+
+	rpc := *pc
+	rpc.PushEndpoint = URL(pc.PushEndpoint)
+	rpc.AuthorizationHeader = String(pc.AuthorizationHeader)
+	rpc.MattermostUrl = URL(pc.MattermostUrl)
+
+	rpc.Destinations = nil
+	for _, d := range pc.Destinations {
+		if d == nil {
+			rpc.Destinations = append(rpc.Destinations, nil)
+			continue
+		}
+		rd := *d
+		rd.PushEndpoint = URL(d.PushEndpoint)
+		rd.AuthorizationHeader = String(d.AuthorizationHeader)
+		rd.MattermostUrl = URL(d.MattermostUrl)
+		rpc.Destinations = append(rpc.Destinations, &rd)
+	}
+
+	return &rpc
+}
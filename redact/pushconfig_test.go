@@ -0,0 +1,53 @@
+package redact
+
+import (
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type PushConfigTestSuite struct {
+	suite.Suite
+}
+
+// TestPushConfig tests the masking of the secrets of a push configuration
+func (suite *PushConfigTestSuite) TestPushConfig() {
+
+	pc := &amsPb.PushConfig{
+		Type:                amsPb.PushType_HTTP_ENDPOINT,
+		PushEndpoint:        "https://example.com/receive_here?token=abc",
+		AuthorizationHeader: "auth-header-1",
+		MaxMessages:         3,
+		RetryPolicy:         &amsPb.RetryPolicy{Type: "linear", Period: 300},
+		Destinations: []*amsPb.Destination{
+			{
+				Type:          amsPb.PushType_MATTERMOST,
+				MattermostUrl: "https://mattermost.example.com/hooks/xn7ujq5ot3rd8",
+			},
+		},
+	}
+
+	suite.Equal(&amsPb.PushConfig{
+		Type:                amsPb.PushType_HTTP_ENDPOINT,
+		PushEndpoint:        "https://example.com/receive_here?token=REDACTED",
+		AuthorizationHeader: "REDACTED",
+		MaxMessages:         3,
+		RetryPolicy:         &amsPb.RetryPolicy{Type: "linear", Period: 300},
+		Destinations: []*amsPb.Destination{
+			{
+				Type:          amsPb.PushType_MATTERMOST,
+				MattermostUrl: "https://mattermost.example.com/hooks/REDACTED",
+			},
+		},
+	}, PushConfig(pc))
+
+	// the original configuration is left untouched
+	suite.Equal("auth-header-1", pc.AuthorizationHeader)
+	suite.Equal("https://mattermost.example.com/hooks/xn7ujq5ot3rd8", pc.Destinations[0].MattermostUrl)
+
+	suite.Nil(PushConfig(nil))
+}
+
+func TestPushConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(PushConfigTestSuite))
+}
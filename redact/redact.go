@@ -0,0 +1,125 @@
+package redact
+
+import (
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// Mask replaces any secret value that would otherwise be logged or returned
+const Mask = "REDACTED"
+
+const (
+	// NoPayloads keeps message payloads out of the logs entirely
+	NoPayloads = "none"
+	// MetadataPayloads logs only information about the messages, e.g. their ids and sizes
+	MetadataPayloads = "metadata"
+	// FullPayloads logs the messages as they are delivered
+	FullPayloads = "full"
+)
+
+// payloadMode holds how message payloads should be logged, the zero value falls back to MetadataPayloads
+var payloadMode atomic.Value
+
+// sensitiveQueryKeys are the query parameters, or parts of them, whose values are considered secrets
+var sensitiveQueryKeys = []string{"token", "key", "secret", "sig", "pass", "auth", "code"}
+
+// IsValidPayloadMode checks whether or not the provided payload logging mode is supported
+func IsValidPayloadMode(mode string) bool {
+	switch mode {
+	case "", NoPayloads, MetadataPayloads, FullPayloads:
+		return true
+	}
+	return false
+}
+
+// SetPayloadMode sets how message payloads should be logged across the service
+func SetPayloadMode(mode string) {
+	payloadMode.Store(mode)
+}
+
+// PayloadMode returns how message payloads should be logged
+func PayloadMode() string {
+
+	mode, _ := payloadMode.Load().(string)
+	if mode == "" {
+		return MetadataPayloads
+	}
+
+	return mode
+}
+
+// String masks the provided secret, an empty secret stays empty so that it is still obvious that it is not set
+func String(secret string) string {
+
+	if secret == "" {
+		return ""
+	}
+
+	return Mask
+}
+
+// URL masks the secrets that the provided url might carry, the password of its user info,
+// the token of a webhook path(/hooks/<token>) and the values of sensitive query parameters.
+// Urls that carry no secrets are returned untouched
+func URL(rawURL string) string {
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Opaque != "" {
+		return rawURL
+	}
+
+	changed := false
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Mask)
+			changed = true
+		}
+	}
+
+	segments := strings.Split(u.Path, "/")
+	for idx := 0; idx < len(segments)-1; idx++ {
+		if segments[idx] == "hooks" && segments[idx+1] != "" {
+			segments[idx+1] = Mask
+			changed = true
+		}
+	}
+
+	if changed {
+		u.Path = strings.Join(segments, "/")
+		u.RawPath = ""
+	}
+
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for idx, param := range params {
+			key, _, found := strings.Cut(param, "=")
+			if found && isSensitiveKey(key) {
+				params[idx] = key + "=" + Mask
+				changed = true
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+
+	if !changed {
+		return rawURL
+	}
+
+	return u.String()
+}
+
+// isSensitiveKey checks whether or not the value of a query parameter should be considered a secret
+func isSensitiveKey(key string) bool {
+
+	key = strings.ToLower(key)
+
+	for _, k := range sensitiveQueryKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -23,6 +23,15 @@ type RetryPolicy interface {
 	Timer() *time.Timer
 }
 
+// Resumable is implemented by the retry policies whose interval adapts to the outcome of the push cycles,
+// so that the interval can be carried over a restart of the service
+type Resumable interface {
+	// Interval returns the current interval and whether the last push cycle failed
+	Interval() (time.Duration, bool)
+	// Resume continues from a previous interval, the next time event takes place after it
+	Resume(interval time.Duration, failing bool)
+}
+
 // New transforms the registered retry policy of a subscription
 // to an the internal corresponding one that will be used by the respective worker
 func New(rp *amsPb.RetryPolicy) (RetryPolicy, error) {
@@ -55,6 +55,27 @@ func (s *Slowstart) Reset(err string) {
 
 }
 
+// Interval returns the current interval and whether the last push cycle failed
+func (s *Slowstart) Interval() (time.Duration, bool) {
+	return s.previousRestartInterval, s.previousError
+}
+
+// Resume continues from a previous interval, kept within the bounds of the policy
+func (s *Slowstart) Resume(interval time.Duration, failing bool) {
+
+	if interval < SlowStartLowerTimeBound {
+		interval = SlowStartLowerTimeBound
+	}
+
+	if interval > SlowStartUpperTimeBound {
+		interval = SlowStartUpperTimeBound
+	}
+
+	s.previousRestartInterval = interval
+	s.previousError = failing
+	s.timer.Reset(interval)
+}
+
 // Timer returns the in use timer of the policy
 func (s *Slowstart) Timer() *time.Timer {
 	return s.timer
@@ -80,6 +80,33 @@ func (suite *SlowStartTestSuite) TestTimer() {
 
 }
 
+// TestResume tests that the policy continues from a previous interval, within its bounds
+func (suite *SlowStartTestSuite) TestResume() {
+
+	lr := Slowstart{
+		timer:                   time.NewTimer(SlowStartInitialInterval),
+		previousRestartInterval: SlowStartInitialInterval,
+	}
+
+	lr.Resume(8*time.Second, true)
+	interval, failing := lr.Interval()
+	suite.Equal(8*time.Second, interval)
+	suite.True(failing)
+
+	// the next error doubles the resumed interval
+	lr.Reset("error")
+	suite.Equal(16*time.Second, lr.previousRestartInterval)
+
+	lr.Resume(time.Millisecond, false)
+	interval, failing = lr.Interval()
+	suite.Equal(SlowStartLowerTimeBound, interval)
+	suite.False(failing)
+
+	lr.Resume(48*time.Hour, true)
+	interval, _ = lr.Interval()
+	suite.Equal(SlowStartUpperTimeBound, interval)
+}
+
 func TestSlowStartTestSuite(t *testing.T) {
 	suite.Run(t, new(SlowStartTestSuite))
 }
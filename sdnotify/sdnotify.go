@@ -0,0 +1,50 @@
+package sdnotify
+
+import (
+	"net"
+	"os"
+)
+
+// states that the service reports to systemd
+const (
+	// Ready tells systemd that the service has started up and is serving
+	Ready = "READY=1"
+	// Stopping tells systemd that the service has started shutting down
+	Stopping = "STOPPING=1"
+)
+
+// SocketEnv is the environment variable through which systemd passes the path of its notification socket
+const SocketEnv = "NOTIFY_SOCKET"
+
+// Notify sends the state to the notification socket of systemd, for services of Type=notify.
+// It returns false, without an error, if the service hasn't been started by systemd with notifications enabled
+func Notify(state string) (bool, error) {
+
+	socket := os.Getenv(SocketEnv)
+	if socket == "" {
+		return false, nil
+	}
+
+	// a leading @ denotes a socket in the abstract namespace
+	addr := &net.UnixAddr{
+		Name: socket,
+		Net:  "unixgram",
+	}
+
+	if socket[0] == '@' {
+		addr.Name = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix(addr.Net, nil, addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
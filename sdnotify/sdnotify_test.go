@@ -0,0 +1,53 @@
+package sdnotify
+
+import (
+	"github.com/stretchr/testify/suite"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+type SDNotifyTestSuite struct {
+	suite.Suite
+}
+
+// TestNotify tests that the state is sent to the notification socket
+func (suite *SDNotifyTestSuite) TestNotify() {
+
+	path := filepath.Join(suite.T().TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	suite.Nil(err)
+	defer conn.Close()
+
+	suite.T().Setenv(SocketEnv, path)
+
+	sent, err := Notify(Ready)
+	suite.True(sent)
+	suite.Nil(err)
+
+	b := make([]byte, 64)
+	n, err := conn.Read(b)
+	suite.Nil(err)
+	suite.Equal(Ready, string(b[:n]))
+
+	// a socket that doesn't exist
+	suite.T().Setenv(SocketEnv, filepath.Join(suite.T().TempDir(), "missing.sock"))
+	sent, err = Notify(Stopping)
+	suite.False(sent)
+	suite.NotNil(err)
+}
+
+// TestNotifyWithoutSystemd tests that nothing is sent when the service isn't started by systemd
+func (suite *SDNotifyTestSuite) TestNotifyWithoutSystemd() {
+
+	suite.T().Setenv(SocketEnv, "")
+
+	sent, err := Notify(Ready)
+	suite.False(sent)
+	suite.Nil(err)
+}
+
+func TestSDNotifyTestSuite(t *testing.T) {
+	suite.Run(t, new(SDNotifyTestSuite))
+}
@@ -0,0 +1,165 @@
+package senders
+
+import (
+	"encoding/base64"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const (
+	CloudEventsSpecVersion      = "1.0"
+	CloudEventsType             = "argo.ams.message"
+	ApplicationCloudEvents      = "application/cloudevents+json"
+	ApplicationCloudEventsBatch = "application/cloudevents-batch+json"
+	ApplicationOctetStream      = "application/octet-stream"
+	TextPlain                   = "text/plain"
+)
+
+// cloudEventsExtensionName describes the allowed names for cloudevents extension attributes
+var cloudEventsExtensionName = regexp.MustCompile("^[a-z0-9]{1,20}$")
+
+// cloudEventsContextAttributes holds the attribute names that are reserved by the cloudevents specification
+var cloudEventsContextAttributes = map[string]bool{
+	"id":              true,
+	"source":          true,
+	"specversion":     true,
+	"type":            true,
+	"datacontenttype": true,
+	"dataschema":      true,
+	"subject":         true,
+	"time":            true,
+	"data":            true,
+	"data_base64":     true,
+}
+
+// CloudEvent holds the context attributes and the data of a cloudevents 1.0 event
+type CloudEvent struct {
+	SpecVersion     string
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            string
+	DataContentType string
+	Data            *string
+	DataBase64      string
+	Extensions      map[string]string
+}
+
+// NewCloudEvent maps a push message to a cloudevent.
+// The topic is used as the source of the event and the subscription as its subject,
+// while any message attribute that is a valid extension name is carried as an extension attribute.
+// base64Payload indicates whether or not the message data is still base64 encoded.
+func NewCloudEvent(msg PushMsg, subscription, topic string, base64Payload bool) CloudEvent {
+
+	ce := CloudEvent{
+		SpecVersion: CloudEventsSpecVersion,
+		ID:          msg.Msg.ID,
+		Source:      topic,
+		Type:        CloudEventsType,
+		Subject:     subscription,
+		Extensions:  make(map[string]string),
+	}
+
+	if ce.Source == "" {
+		ce.Source = subscription
+	}
+
+	// publish times that cannot be parsed are left out since the attribute is optional
+	if t, err := time.Parse(time.RFC3339Nano, msg.Msg.PubTime); err == nil {
+		ce.Time = t.UTC().Format(time.RFC3339Nano)
+	}
+
+	if base64Payload {
+		ce.DataContentType = ApplicationOctetStream
+		ce.DataBase64 = msg.Msg.Data
+	} else {
+		data := msg.Msg.Data
+		ce.DataContentType = TextPlain
+		ce.Data = &data
+	}
+
+	for k, v := range msg.Msg.Attr {
+		if !cloudEventsExtensionName.MatchString(k) || cloudEventsContextAttributes[k] {
+			continue
+		}
+		ce.Extensions[k] = v
+	}
+
+	return ce
+}
+
+// structured returns the representation of the event in the structured content mode,
+// where the extension attributes live next to the context attributes
+func (ce CloudEvent) structured() map[string]interface{} {
+
+	m := map[string]interface{}{
+		"specversion": ce.SpecVersion,
+		"id":          ce.ID,
+		"source":      ce.Source,
+		"type":        ce.Type,
+	}
+
+	if ce.Subject != "" {
+		m["subject"] = ce.Subject
+	}
+
+	if ce.Time != "" {
+		m["time"] = ce.Time
+	}
+
+	if ce.DataContentType != "" {
+		m["datacontenttype"] = ce.DataContentType
+	}
+
+	if ce.Data != nil {
+		m["data"] = *ce.Data
+	}
+
+	if ce.DataBase64 != "" {
+		m["data_base64"] = ce.DataBase64
+	}
+
+	for k, v := range ce.Extensions {
+		m[k] = v
+	}
+
+	return m
+}
+
+// binary fills the provided headers with the ce-* attributes of the event
+// and returns the body of the request in the binary content mode
+func (ce CloudEvent) binary(h http.Header) []byte {
+
+	h.Set("ce-specversion", ce.SpecVersion)
+	h.Set("ce-id", ce.ID)
+	h.Set("ce-source", ce.Source)
+	h.Set("ce-type", ce.Type)
+
+	if ce.Subject != "" {
+		h.Set("ce-subject", ce.Subject)
+	}
+
+	if ce.Time != "" {
+		h.Set("ce-time", ce.Time)
+	}
+
+	for k, v := range ce.Extensions {
+		h.Set("ce-"+k, v)
+	}
+
+	h.Set("Content-Type", ce.DataContentType)
+
+	if ce.Data != nil {
+		return []byte(*ce.Data)
+	}
+
+	// binary mode carries the raw payload, fallback to the encoded one if it can't be decoded
+	b, err := base64.StdEncoding.DecodeString(ce.DataBase64)
+	if err != nil {
+		return []byte(ce.DataBase64)
+	}
+
+	return b
+}
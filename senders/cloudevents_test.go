@@ -0,0 +1,83 @@
+package senders
+
+import (
+	v1 "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+)
+
+type CloudEventsTestSuite struct {
+	suite.Suite
+}
+
+// TestNewCloudEvent tests the mapping of a push message to a cloudevent
+func (suite *CloudEventsTestSuite) TestNewCloudEvent() {
+
+	msg := PushMsg{
+		Msg: v1.Message{
+			ID:      "id-1",
+			Data:    "some data",
+			PubTime: "2024-01-02T10:00:00Z",
+			Attr: map[string]string{
+				"env":         "prod",
+				"Invalid-Key": "skipped",
+				"id":          "reserved",
+			},
+		},
+	}
+
+	ce := NewCloudEvent(msg, "/projects/p1/subscriptions/s1", "/projects/p1/topics/t1", false)
+
+	suite.Equal("1.0", ce.SpecVersion)
+	suite.Equal("id-1", ce.ID)
+	suite.Equal("/projects/p1/topics/t1", ce.Source)
+	suite.Equal("/projects/p1/subscriptions/s1", ce.Subject)
+	suite.Equal(CloudEventsType, ce.Type)
+	suite.Equal("2024-01-02T10:00:00Z", ce.Time)
+	suite.Equal(TextPlain, ce.DataContentType)
+	suite.Equal("some data", *ce.Data)
+	suite.Equal("", ce.DataBase64)
+	suite.Equal(map[string]string{"env": "prod"}, ce.Extensions)
+
+	// unparseable publish time and encoded payload
+	msg.Msg.PubTime = "unknown"
+	msg.Msg.Data = "c29tZSBkYXRh"
+	ce2 := NewCloudEvent(msg, "/projects/p1/subscriptions/s1", "", true)
+	suite.Equal("", ce2.Time)
+	suite.Equal("/projects/p1/subscriptions/s1", ce2.Source)
+	suite.Nil(ce2.Data)
+	suite.Equal("c29tZSBkYXRh", ce2.DataBase64)
+	suite.Equal(ApplicationOctetStream, ce2.DataContentType)
+}
+
+// TestBinary tests the binary content mode representation of a cloudevent
+func (suite *CloudEventsTestSuite) TestBinary() {
+
+	data := "some data"
+	ce := CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              "id-1",
+		Source:          "/source",
+		Type:            CloudEventsType,
+		DataContentType: TextPlain,
+		Data:            &data,
+		Extensions:      map[string]string{"env": "prod"},
+	}
+
+	h := make(http.Header)
+	b := ce.binary(h)
+	suite.Equal("some data", string(b))
+	suite.Equal("id-1", h.Get("ce-id"))
+	suite.Equal("prod", h.Get("ce-env"))
+	suite.Equal("", h.Get("ce-subject"))
+	suite.Equal(TextPlain, h.Get("Content-Type"))
+
+	// payload that is not valid base64 is sent as is
+	ce2 := CloudEvent{DataBase64: "not-base64!", DataContentType: ApplicationOctetStream}
+	suite.Equal("not-base64!", string(ce2.binary(make(http.Header))))
+}
+
+func TestCloudEventsTestSuite(t *testing.T) {
+	suite.Run(t, new(CloudEventsTestSuite))
+}
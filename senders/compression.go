@@ -0,0 +1,80 @@
+package senders
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+const (
+	NoCompression   = "none"
+	GzipCompression = "gzip"
+	ZstdCompression = "zstd"
+)
+
+// the zstd encoder is shared among all senders, EncodeAll is safe for concurrent use.
+// It gets built the first time a payload is compressed with zstd
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdErr     error
+)
+
+// newZstdEncoder returns the shared zstd encoder, or the error that building it failed with
+func newZstdEncoder() (*zstd.Encoder, error) {
+
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			zstdErr = errors.Errorf("Could not initialise the zstd encoder, %v", zstdErr.Error())
+		}
+	})
+
+	return zstdEncoder, zstdErr
+}
+
+// IsValidCompression checks whether or not the provided compression of a push configuration is supported
+func IsValidCompression(compression string) bool {
+	switch compression {
+	case "", NoCompression, GzipCompression, ZstdCompression:
+		return true
+	}
+	return false
+}
+
+// compress encodes the payload with the provided compression
+// and returns the encoded payload alongside the respective Content-Encoding value
+func compress(payload []byte, compression string) ([]byte, string, error) {
+
+	switch compression {
+
+	case GzipCompression:
+
+		buf := bytes.Buffer{}
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write(payload)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = w.Close()
+		if err != nil {
+			return nil, "", err
+		}
+
+		return buf.Bytes(), GzipCompression, nil
+
+	case ZstdCompression:
+
+		encoder, err := newZstdEncoder()
+		if err != nil {
+			return nil, "", err
+		}
+
+		return encoder.EncodeAll(payload, nil), ZstdCompression, nil
+	}
+
+	return payload, "", nil
+}
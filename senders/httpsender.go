@@ -4,19 +4,39 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"github.com/pkg/errors"
+	"fmt"
+	"github.com/ARGOeu/ams-push-server/logging"
+	"github.com/ARGOeu/ams-push-server/redact"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 const ApplicationJson = "application/json"
 
+// HttpError represents an unsuccessful response from a remote http endpoint
+type HttpError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HttpError) Error() string {
+	return e.Body
+}
+
 // HttpSender delivers data to any http endpoint
 type HttpSender struct {
 	client      *http.Client
 	endpoint    string
 	authZHeader string
+	// whether or not the pushed payload is still base64 encoded
+	base64Payload bool
+	// compression applied to payloads that reach the compression threshold
+	compression          string
+	compressionThreshold int64
+	// set when the endpoint has rejected a compressed payload
+	compressionDisabled atomic.Bool
 }
 
 // NewHttpSender initialises and returns a new http sender
@@ -34,37 +54,114 @@ func (s *HttpSender) Send(ctx context.Context, msgs PushMsgs, format pushMessage
 	var msgB []byte
 	var err error
 
-	if format == SingleMessageFormat {
+	header := make(http.Header)
+	header.Set("Content-Type", ApplicationJson)
+
+	switch format {
+	case SingleMessageFormat:
 		msgB, err = json.Marshal(msgs.Messages[0])
 		if err != nil {
 			return err
 		}
-	} else if format == MultipleMessageFormat {
+	case MultipleMessageFormat:
 		msgB, err = json.Marshal(msgs)
 		if err != nil {
 			return err
 		}
+	case CloudEventsBinaryFormat:
+		ce := NewCloudEvent(msgs.Messages[0], msgs.Subscription, msgs.Topic, s.base64Payload)
+		msgB = ce.binary(header)
+	case CloudEventsStructuredFormat:
+		ce := NewCloudEvent(msgs.Messages[0], msgs.Subscription, msgs.Topic, s.base64Payload)
+		msgB, err = json.Marshal(ce.structured())
+		if err != nil {
+			return err
+		}
+		header.Set("Content-Type", ApplicationCloudEvents)
+	case CloudEventsBatchFormat:
+		batch := make([]map[string]interface{}, 0, len(msgs.Messages))
+		for _, msg := range msgs.Messages {
+			batch = append(batch, NewCloudEvent(msg, msgs.Subscription, msgs.Topic, s.base64Payload).structured())
+		}
+		msgB, err = json.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		header.Set("Content-Type", ApplicationCloudEventsBatch)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewBuffer(msgB))
-	if err != nil {
-		return err
+	if s.authZHeader != "" {
+		header.Set("Authorization", s.authZHeader)
 	}
 
-	req.Header.Set("Content-Type", ApplicationJson)
-	if s.authZHeader != "" {
-		req.Header.Set("Authorization", s.authZHeader)
+	body := msgB
+	encoding := ""
+	if s.shouldCompress(msgB) {
+		body, encoding, err = compress(msgB, s.compression)
+		if err != nil {
+			return err
+		}
 	}
 
-	log.WithFields(
-		log.Fields{
-			"type":        "service_log",
-			"message(s)":  msgs,
-			"destination": s.endpoint,
-		},
+	logging.WithFields(
+		logging.Sender,
+		withPayload(log.Fields{
+			"type":        "system_log",
+			"destination": s.Destination(),
+			"encoding":    encoding,
+		}, msgs),
 	).Debug("Trying to send")
 
 	t1 := time.Now()
+	err = s.post(ctx, body, encoding, header)
+
+	// the endpoint doesn't accept the compressed payload,
+	// stop compressing for this endpoint and retry uncompressed
+	if herr, ok := err.(*HttpError); ok && herr.StatusCode == http.StatusUnsupportedMediaType && encoding != "" {
+
+		s.compressionDisabled.Store(true)
+
+		logging.WithFields(
+			logging.Sender,
+			log.Fields{
+				"type":        "system_log",
+				"destination": s.Destination(),
+				"encoding":    encoding,
+			},
+		).Warning("Endpoint does not accept compressed payloads, compression has been disabled")
+
+		err = s.post(ctx, msgB, "", header)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logging.WithFields(
+		logging.Sender,
+		withPayload(log.Fields{
+			"type":            "performance_log",
+			"destination":     s.Destination(),
+			"processing_time": time.Since(t1).String(),
+		}, msgs),
+	).Info("Delivered successfully")
+
+	return nil
+}
+
+// post executes a POST request with the provided payload and headers against the sender's endpoint
+func (s *HttpSender) post(ctx context.Context, payload []byte, encoding string, header http.Header) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header = header.Clone()
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
 	resp, err := s.client.Do(req)
 	if err != nil {
 		return err
@@ -77,22 +174,37 @@ func (s *HttpSender) Send(ctx context.Context, msgs PushMsgs, format pushMessage
 		resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusProcessing {
 		buf := bytes.Buffer{}
 		buf.ReadFrom(resp.Body)
-		return errors.New(buf.String())
+		return &HttpError{
+			StatusCode: resp.StatusCode,
+			Body:       buf.String(),
+		}
 	}
 
-	log.WithFields(
-		log.Fields{
-			"type":            "performance_log",
-			"message(s)":      msgs,
-			"endpoint":        s.endpoint,
-			"processing_time": time.Since(t1).String(),
-		},
-	).Info("Delivered successfully")
+	return nil
+}
+
+// shouldCompress decides whether or not the payload should be compressed before being sent
+func (s *HttpSender) shouldCompress(payload []byte) bool {
+
+	if s.compression == "" || s.compression == NoCompression || s.compressionDisabled.Load() {
+		return false
+	}
+
+	return int64(len(payload)) >= s.compressionThreshold
+}
+
+// Notes reports whether or not the configured compression had to be disabled
+func (s *HttpSender) Notes() []string {
+
+	if s.compression != "" && s.compression != NoCompression && s.compressionDisabled.Load() {
+		return []string{fmt.Sprintf("%v compression disabled, endpoint responded with %v",
+			s.compression, http.StatusUnsupportedMediaType)}
+	}
 
 	return nil
 }
 
-// Destination returns the http endpoint where data is being sent
+// Destination returns the http endpoint where data is being sent, with any secrets it might carry masked
 func (s *HttpSender) Destination() string {
-	return s.endpoint
+	return redact.URL(s.endpoint)
 }
@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"github.com/ARGOeu/ams-push-server/logging"
+	"github.com/ARGOeu/ams-push-server/redact"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"net/http"
@@ -75,12 +77,12 @@ func (s *MattermostSender) Send(ctx context.Context, msgs PushMsgs, format pushM
 
 	req.Header.Set("Content-Type", ApplicationJson)
 
-	log.WithFields(
-		log.Fields{
-			"type":        "service_log",
-			"text":        msgs,
-			"destination": s.webhookUrl,
-		},
+	logging.WithFields(
+		logging.Sender,
+		withPayload(log.Fields{
+			"type":        "system_log",
+			"destination": s.Destination(),
+		}, msgs),
 	).Debug("Trying to send")
 
 	t1 := time.Now()
@@ -104,10 +106,11 @@ func (s *MattermostSender) Send(ctx context.Context, msgs PushMsgs, format pushM
 			if err != nil {
 				return errors.New(string(errorB))
 			} else {
-				log.WithFields(
+				logging.WithFields(
+					logging.Sender,
 					log.Fields{
-						"type":           "service_log",
-						"endpoint":       s.webhookUrl,
+						"type":           "system_log",
+						"destination":    s.Destination(),
 						"id":             mattermostError.Id,
 						"message":        mattermostError.Message,
 						"detailed_error": mattermostError.DetailedError,
@@ -120,19 +123,19 @@ func (s *MattermostSender) Send(ctx context.Context, msgs PushMsgs, format pushM
 		}
 	}
 
-	log.WithFields(
-		log.Fields{
+	logging.WithFields(
+		logging.Sender,
+		withPayload(log.Fields{
 			"type":            "performance_log",
-			"message(s)":      msgs,
-			"endpoint":        s.webhookUrl,
+			"destination":     s.Destination(),
 			"processing_time": time.Since(t1).String(),
-		},
+		}, msgs),
 	).Info("Delivered successfully")
 
 	return nil
 }
 
-// Destination returns the http webhook where data is being sent
+// Destination returns the http webhook where data is being sent, with its token masked
 func (s *MattermostSender) Destination() string {
-	return s.webhookUrl
+	return redact.URL(s.webhookUrl)
 }
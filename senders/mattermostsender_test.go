@@ -74,6 +74,10 @@ func (suite *MattermostSenderTestSuite) TestMattermostError() {
 func (suite *MattermostSenderTestSuite) TestDestination() {
 	m := NewMattermostSender("https://example.com/webhook", "mattermost", "ops", nil)
 	suite.Equal("https://example.com/webhook", m.Destination())
+
+	// the webhook token is masked
+	m2 := NewMattermostSender("https://example.com/hooks/xn7ujq5ot3rd8", "mattermost", "ops", nil)
+	suite.Equal("https://example.com/hooks/REDACTED", m2.Destination())
 }
 
 func TestMattermostSenderTestSuite(t *testing.T) {
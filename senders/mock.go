@@ -7,11 +7,23 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type MockSender struct {
 	SendStatus   string
 	PushMessages []PushMsg
+	SenderNotes  []string
+	// when set, batches with more messages are rejected as too large
+	MaxBatchMessages int
+	// the number of messages of each accepted batch
+	Batches []int
+	// how long every send takes, unless its context is done first
+	SendDelay time.Duration
+}
+
+func (s *MockSender) Notes() []string {
+	return s.SenderNotes
 }
 
 func (s *MockSender) Destination() string {
@@ -20,12 +32,26 @@ func (s *MockSender) Destination() string {
 
 func (s *MockSender) Send(ctx context.Context, msgs PushMsgs, format pushMessageFormat) error {
 
+	if s.SendDelay > 0 {
+		select {
+		case <-time.After(s.SendDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
 	switch s.SendStatus {
 
 	case "error_send":
 		return errors.New("error while sending")
 	}
 
+	if s.MaxBatchMessages > 0 && len(msgs.Messages) > s.MaxBatchMessages {
+		return &HttpError{StatusCode: http.StatusRequestEntityTooLarge, Body: "payload too large"}
+	}
+
+	s.Batches = append(s.Batches, len(msgs.Messages))
+
 	if format == SingleMessageFormat {
 		s.PushMessages = append(s.PushMessages, msgs.Messages[0])
 	} else if format == MultipleMessageFormat {
@@ -39,6 +65,7 @@ func (s *MockSender) Send(ctx context.Context, msgs PushMsgs, format pushMessage
 
 type MockSenderRoundTripper struct {
 	RequestBodyBytes []byte
+	RequestHeaders   http.Header
 }
 
 func (m *MockSenderRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -49,6 +76,7 @@ func (m *MockSenderRoundTripper) RoundTrip(r *http.Request) (*http.Response, err
 	header.Set("Content-type", ApplicationJson)
 
 	m.RequestBodyBytes, _ = io.ReadAll(r.Body)
+	m.RequestHeaders = r.Header
 
 	switch r.URL.Path {
 
@@ -92,6 +120,24 @@ func (m *MockSenderRoundTripper) RoundTrip(r *http.Request) (*http.Response, err
 				Header: header,
 			}
 		}
+	case "/receive_here_no_encoding":
+		if r.Header.Get("Content-Encoding") != "" {
+			resp = &http.Response{
+				StatusCode: 415,
+				// Send response to be tested
+				Body: io.NopCloser(strings.NewReader("unsupported encoding")),
+				// Must be set to non-nil value or it panics
+				Header: header,
+			}
+		} else {
+			resp = &http.Response{
+				StatusCode: 200,
+				// Send response to be tested
+				Body: io.NopCloser(strings.NewReader("")),
+				// Must be set to non-nil value or it panics
+				Header: header,
+			}
+		}
 	case "/receive_here_error":
 
 		err := `{
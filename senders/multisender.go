@@ -0,0 +1,272 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/ARGOeu/ams-push-server/logging"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+)
+
+// DestinationStats holds delivery information for a single destination of a multi sender
+type DestinationStats struct {
+	Destination       string
+	DeliveredMessages uint64
+	FailedDeliveries  uint64
+	LastError         string
+}
+
+// StatsReporter is implemented by senders that keep delivery information for each of their destinations
+type StatsReporter interface {
+	// Stats returns the delivery information of each destination
+	Stats() []DestinationStats
+}
+
+// StatsRestorer is implemented by senders whose delivery information can be carried over a restart of the service
+type StatsRestorer interface {
+	// RestoreStats continues from previous delivery information, matched to the destinations by their name
+	RestoreStats(stats []DestinationStats)
+}
+
+// maxTrackedMessages is the most messages whose deliveries a multi sender keeps track of, until they are delivered
+// to every destination. Past it the tracking starts over, e.g. if the messages of failed batches never come back
+const maxTrackedMessages = 10000
+
+// MultiSender delivers data to multiple destinations and decides
+// based on its delivery policy, whether or not the data has been delivered
+type MultiSender struct {
+	senders []Sender
+	policy  amsPb.DeliveryPolicy
+	mutex   sync.Mutex
+	stats   []DestinationStats
+	// the destinations that already received each message of a batch that failed as a whole,
+	// keyed by the message id, so that the retries of the batch skip them
+	delivered map[string][]bool
+}
+
+// NewMultiSender initialises and returns a new multi sender
+func NewMultiSender(senders []Sender, policy amsPb.DeliveryPolicy) *MultiSender {
+	s := new(MultiSender)
+	s.senders = senders
+	s.policy = policy
+	s.stats = make([]DestinationStats, len(senders))
+	s.delivered = make(map[string][]bool)
+	for idx, sender := range senders {
+		s.stats[idx].Destination = sender.Destination()
+	}
+	return s
+}
+
+// Send delivers the messages to all destinations concurrently.
+// With the all-must-succeed policy an error is returned if any of the deliveries failed,
+// with the any-succeeds policy only if all of them failed, while the best-effort policy never returns an error.
+// When a batch fails, the messages that some destinations did receive are not sent to them again when it is retried
+func (s *MultiSender) Send(ctx context.Context, msgs PushMsgs, format pushMessageFormat) error {
+
+	errs := make([]error, len(s.senders))
+	pending := s.pending(msgs)
+
+	wg := sync.WaitGroup{}
+	for idx, sender := range s.senders {
+		// the destination already received the whole batch
+		if len(pending[idx].Messages) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(idx int, sender Sender) {
+			defer wg.Done()
+			errs[idx] = sender.Send(ctx, pending[idx], format)
+		}(idx, sender)
+	}
+	wg.Wait()
+
+	var failed []error
+	delivered := 0
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for idx, err := range errs {
+		// a destination that already received the whole batch counts as delivered to
+		if len(pending[idx].Messages) == 0 {
+			delivered++
+			continue
+		}
+		if err != nil {
+			s.stats[idx].FailedDeliveries++
+			s.stats[idx].LastError = err.Error()
+			failed = append(failed, err)
+
+			logging.WithFields(
+				logging.Sender,
+				log.Fields{
+					"type":        "system_log",
+					"destination": s.stats[idx].Destination,
+					"error":       err.Error(),
+				},
+			).Error("Could not deliver to destination")
+
+			continue
+		}
+		s.stats[idx].DeliveredMessages += uint64(len(pending[idx].Messages))
+		s.markDelivered(idx, pending[idx])
+		delivered++
+	}
+
+	err := s.result(failed, delivered)
+	if err == nil {
+		s.forget(msgs)
+	}
+
+	return err
+}
+
+// pending returns the messages of the batch that each destination hasn't received yet
+func (s *MultiSender) pending(msgs PushMsgs) []PushMsgs {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pending := make([]PushMsgs, len(s.senders))
+
+	for idx := range s.senders {
+
+		pending[idx] = msgs
+		pending[idx].Messages = make([]PushMsg, 0, len(msgs.Messages))
+
+		for _, m := range msgs.Messages {
+			if d, found := s.delivered[m.Msg.ID]; found && d[idx] {
+				continue
+			}
+			pending[idx].Messages = append(pending[idx].Messages, m)
+		}
+	}
+
+	return pending
+}
+
+// markDelivered records that the destination received the messages, messages without an id can't be tracked
+func (s *MultiSender) markDelivered(idx int, msgs PushMsgs) {
+
+	if len(s.delivered) > maxTrackedMessages {
+		s.delivered = make(map[string][]bool)
+	}
+
+	for _, m := range msgs.Messages {
+
+		if m.Msg.ID == "" {
+			continue
+		}
+
+		if _, found := s.delivered[m.Msg.ID]; !found {
+			s.delivered[m.Msg.ID] = make([]bool, len(s.senders))
+		}
+
+		s.delivered[m.Msg.ID][idx] = true
+	}
+}
+
+// forget stops tracking the messages of a batch that has been delivered
+func (s *MultiSender) forget(msgs PushMsgs) {
+	for _, m := range msgs.Messages {
+		delete(s.delivered, m.Msg.ID)
+	}
+}
+
+// result decides, based on the delivery policy, whether or not the batch has been delivered
+func (s *MultiSender) result(failed []error, delivered int) error {
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	switch s.policy {
+	case amsPb.DeliveryPolicy_ANY_SUCCEEDS:
+		if delivered > 0 {
+			return nil
+		}
+	case amsPb.DeliveryPolicy_BEST_EFFORT:
+		return nil
+	}
+
+	// prefer reporting a payload size rejection, so the batch can be shrunk
+	for _, err := range failed {
+		if IsPayloadTooLarge(err) {
+			return err
+		}
+	}
+
+	if len(failed) == 1 {
+		return failed[0]
+	}
+
+	errMsgs := make([]string, 0, len(failed))
+	for _, err := range failed {
+		errMsgs = append(errMsgs, err.Error())
+	}
+
+	return fmt.Errorf("%v of %v destinations failed, %v", len(failed), len(s.senders), strings.Join(errMsgs, "; "))
+}
+
+// Destination returns all the destinations where data is being sent
+func (s *MultiSender) Destination() string {
+
+	destinations := make([]string, 0, len(s.senders))
+	for _, sender := range s.senders {
+		destinations = append(destinations, sender.Destination())
+	}
+
+	return strings.Join(destinations, ", ")
+}
+
+// Stats returns the delivery information of each destination
+func (s *MultiSender) Stats() []DestinationStats {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := make([]DestinationStats, len(s.stats))
+	copy(stats, s.stats)
+
+	return stats
+}
+
+// RestoreStats continues from previous delivery information, destinations that are no longer part
+// of the sender are ignored
+func (s *MultiSender) RestoreStats(stats []DestinationStats) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	restored := make([]bool, len(s.stats))
+
+	for _, st := range stats {
+		for idx := range s.stats {
+			if !restored[idx] && s.stats[idx].Destination == st.Destination {
+				s.stats[idx] = st
+				restored[idx] = true
+				break
+			}
+		}
+	}
+}
+
+// Notes returns the notes of each destination prefixed with the destination they refer to
+func (s *MultiSender) Notes() []string {
+
+	var notes []string
+
+	for _, sender := range s.senders {
+		n, ok := sender.(Noter)
+		if !ok {
+			continue
+		}
+		for _, note := range n.Notes() {
+			notes = append(notes, fmt.Sprintf("%v: %v", sender.Destination(), note))
+		}
+	}
+
+	return notes
+}
@@ -0,0 +1,176 @@
+package senders
+
+import (
+	"context"
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"testing"
+)
+
+type MultiSenderTestSuite struct {
+	suite.Suite
+}
+
+// TestNewMultiSender tests the proper initialisation of a multi sender
+func (suite *MultiSenderTestSuite) TestNewMultiSender() {
+
+	s1 := new(MockSender)
+	s2 := new(MockSender)
+
+	m := NewMultiSender([]Sender{s1, s2}, amsPb.DeliveryPolicy_ANY_SUCCEEDS)
+
+	suite.Equal([]Sender{s1, s2}, m.senders)
+	suite.Equal(amsPb.DeliveryPolicy_ANY_SUCCEEDS, m.policy)
+	suite.Equal([]DestinationStats{
+		{Destination: "mock destination"},
+		{Destination: "mock destination"},
+	}, m.Stats())
+}
+
+// TestSend tests the delivery policies of the multi sender
+func (suite *MultiSenderTestSuite) TestSend() {
+
+	msgs := PushMsgs{Messages: []PushMsg{{Sub: "sub"}, {Sub: "sub"}}}
+
+	// all must succeed
+	ok := new(MockSender)
+	failing := &MockSender{SendStatus: "error_send"}
+	m1 := NewMultiSender([]Sender{ok, failing}, amsPb.DeliveryPolicy_ALL_MUST_SUCCEED)
+	e1 := m1.Send(context.Background(), msgs, MultipleMessageFormat)
+	suite.Equal("error while sending", e1.Error())
+	suite.Equal(2, len(ok.PushMessages))
+	suite.Equal([]DestinationStats{
+		{Destination: "mock destination", DeliveredMessages: 2},
+		{Destination: "mock destination", FailedDeliveries: 1, LastError: "error while sending"},
+	}, m1.Stats())
+
+	// any succeeds
+	m2 := NewMultiSender([]Sender{new(MockSender), &MockSender{SendStatus: "error_send"}}, amsPb.DeliveryPolicy_ANY_SUCCEEDS)
+	suite.Nil(m2.Send(context.Background(), msgs, MultipleMessageFormat))
+
+	m3 := NewMultiSender([]Sender{&MockSender{SendStatus: "error_send"}, &MockSender{SendStatus: "error_send"}},
+		amsPb.DeliveryPolicy_ANY_SUCCEEDS)
+	e3 := m3.Send(context.Background(), msgs, MultipleMessageFormat)
+	suite.Equal("2 of 2 destinations failed, error while sending; error while sending", e3.Error())
+
+	// best effort
+	m4 := NewMultiSender([]Sender{&MockSender{SendStatus: "error_send"}, &MockSender{SendStatus: "error_send"}},
+		amsPb.DeliveryPolicy_BEST_EFFORT)
+	suite.Nil(m4.Send(context.Background(), msgs, MultipleMessageFormat))
+	suite.Equal(uint64(1), m4.Stats()[0].FailedDeliveries)
+
+	// payload size rejections are preferred so that the batch can be shrunk
+	m5 := NewMultiSender([]Sender{&MockSender{SendStatus: "error_send"}, &MockSender{MaxBatchMessages: 1}},
+		amsPb.DeliveryPolicy_ALL_MUST_SUCCEED)
+	suite.True(IsPayloadTooLarge(m5.Send(context.Background(), msgs, MultipleMessageFormat)))
+}
+
+// TestSendRetry tests that the retries of a failed batch are only sent to the destinations that didn't receive it
+func (suite *MultiSenderTestSuite) TestSendRetry() {
+
+	msg := func(id string) PushMsg {
+		return PushMsg{Sub: "sub", Msg: ams.Message{ID: id}}
+	}
+
+	ok := new(MockSender)
+	flaky := &MockSender{SendStatus: "error_send"}
+	m := NewMultiSender([]Sender{ok, flaky}, amsPb.DeliveryPolicy_ALL_MUST_SUCCEED)
+
+	batch := PushMsgs{Messages: []PushMsg{msg("1"), msg("2")}}
+
+	suite.NotNil(m.Send(context.Background(), batch, MultipleMessageFormat))
+	suite.NotNil(m.Send(context.Background(), batch, MultipleMessageFormat))
+	suite.Equal([]int{2}, ok.Batches)
+
+	// once the failing destination recovers, only it receives the batch
+	flaky.SendStatus = ""
+	suite.Nil(m.Send(context.Background(), batch, MultipleMessageFormat))
+	suite.Equal([]int{2}, ok.Batches)
+	suite.Equal([]int{2}, flaky.Batches)
+	suite.Equal(uint64(2), m.Stats()[0].DeliveredMessages)
+	suite.Equal(uint64(2), m.Stats()[1].DeliveredMessages)
+	suite.Empty(m.delivered)
+
+	// a batch that comes again after it was delivered, e.g. because it couldn't be acknowledged, goes to everyone
+	suite.Nil(m.Send(context.Background(), batch, MultipleMessageFormat))
+	suite.Equal([]int{2, 2}, ok.Batches)
+	suite.Equal([]int{2, 2}, flaky.Batches)
+
+	// a shrunk batch only carries the messages that each destination hasn't received
+	flaky.SendStatus = "error_send"
+	suite.NotNil(m.Send(context.Background(), PushMsgs{Messages: []PushMsg{msg("3"), msg("4")}}, MultipleMessageFormat))
+	flaky.SendStatus = ""
+	suite.Nil(m.Send(context.Background(), PushMsgs{Messages: []PushMsg{msg("3"), msg("5")}}, MultipleMessageFormat))
+	suite.Equal([]int{2, 2, 2, 1}, ok.Batches)
+	suite.Equal([]int{2, 2, 2}, flaky.Batches)
+}
+
+// TestSendRetrySkipped tests that the destinations that already received the whole batch count as delivered to
+func (suite *MultiSenderTestSuite) TestSendRetrySkipped() {
+
+	batch := PushMsgs{Messages: []PushMsg{{Sub: "sub", Msg: ams.Message{ID: "1"}}, {Sub: "sub", Msg: ams.Message{ID: "2"}}}}
+
+	// the first destination got the batch on a previous attempt, while the second one keeps failing
+	ok := new(MockSender)
+	failing := &MockSender{SendStatus: "error_send"}
+	m := NewMultiSender([]Sender{ok, failing}, amsPb.DeliveryPolicy_ANY_SUCCEEDS)
+	m.markDelivered(0, batch)
+
+	suite.Nil(m.Send(context.Background(), batch, MultipleMessageFormat))
+	suite.Empty(ok.Batches)
+	suite.Empty(m.delivered)
+
+	// every destination still has to succeed with the all-must-succeed policy
+	m2 := NewMultiSender([]Sender{ok, failing}, amsPb.DeliveryPolicy_ALL_MUST_SUCCEED)
+	m2.markDelivered(0, batch)
+
+	suite.NotNil(m2.Send(context.Background(), batch, MultipleMessageFormat))
+	suite.Empty(ok.Batches)
+}
+
+// TestNotes tests that the notes of the destinations are reported
+func (suite *MultiSenderTestSuite) TestNotes() {
+
+	m := NewMultiSender([]Sender{
+		new(MockSender),
+		&MockSender{SenderNotes: []string{"note1"}},
+		NewHttpSender("https://example.com", "", nil),
+	}, amsPb.DeliveryPolicy_ALL_MUST_SUCCEED)
+
+	suite.Equal([]string{"mock destination: note1"}, m.Notes())
+}
+
+// TestRestoreStats tests that previous delivery information is matched to the destinations by their name
+func (suite *MultiSenderTestSuite) TestRestoreStats() {
+
+	m := NewMultiSender([]Sender{
+		new(MockSender),
+		NewHttpSender("https://example.com", "", nil),
+	}, amsPb.DeliveryPolicy_ALL_MUST_SUCCEED)
+
+	m.RestoreStats([]DestinationStats{
+		{Destination: "https://example.com", DeliveredMessages: 5, FailedDeliveries: 1, LastError: "error"},
+		{Destination: "https://removed.example.com", DeliveredMessages: 3},
+	})
+
+	suite.Equal([]DestinationStats{
+		{Destination: "mock destination"},
+		{Destination: "https://example.com", DeliveredMessages: 5, FailedDeliveries: 1, LastError: "error"},
+	}, m.Stats())
+}
+
+func (suite *MultiSenderTestSuite) TestDestination() {
+	m := NewMultiSender([]Sender{
+		new(MockSender),
+		NewHttpSender("https://example.com", "", nil),
+	}, amsPb.DeliveryPolicy_ALL_MUST_SUCCEED)
+	suite.Equal("mock destination, https://example.com", m.Destination())
+}
+
+func TestMultiSenderTestSuite(t *testing.T) {
+	logrus.SetOutput(io.Discard)
+	suite.Run(t, new(MultiSenderTestSuite))
+}
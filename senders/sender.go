@@ -5,7 +5,10 @@ import (
 	"fmt"
 	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
 	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	"github.com/ARGOeu/ams-push-server/redact"
+	log "github.com/sirupsen/logrus"
 	"net/http"
+	"time"
 )
 
 type senderType string
@@ -13,10 +16,13 @@ type senderType string
 type pushMessageFormat string
 
 const (
-	HttpSenderType        senderType        = "http-sender"
-	MattermostSenderType  senderType        = "mattermost"
-	SingleMessageFormat   pushMessageFormat = "single"
-	MultipleMessageFormat pushMessageFormat = "multi"
+	HttpSenderType              senderType        = "http-sender"
+	MattermostSenderType        senderType        = "mattermost"
+	SingleMessageFormat         pushMessageFormat = "single"
+	MultipleMessageFormat       pushMessageFormat = "multi"
+	CloudEventsBinaryFormat     pushMessageFormat = "cloudevents-binary"
+	CloudEventsStructuredFormat pushMessageFormat = "cloudevents-structured"
+	CloudEventsBatchFormat      pushMessageFormat = "cloudevents-batch"
 )
 
 // Sender is responsible for delivering data to remote destinations
@@ -27,12 +33,66 @@ type Sender interface {
 	Destination() string
 }
 
-// New acts as a sender factory, creates and returns a new sender based on the provided type
+// Noter is implemented by senders that have additional information to report regarding their delivery behaviour
+type Noter interface {
+	// Notes returns human readable notes that should accompany the status of the worker using the sender
+	Notes() []string
+}
+
+// New acts as a sender factory, creates and returns a new sender based on the provided type.
+// If the configuration declares additional destinations, a multi sender covering all of them is returned
 func New(cfg amsPb.PushConfig, client *http.Client) (Sender, error) {
 
+	// the subscription's own timeout overrides the one of the shared client, which still provides the connections
+	if cfg.TimeoutMs > 0 && client != nil {
+		c := *client
+		c.Timeout = time.Duration(cfg.TimeoutMs) * time.Millisecond
+		client = &c
+	}
+
+	s, err := newSender(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Destinations) == 0 {
+		return s, nil
+	}
+
+	ss := []Sender{s}
+
+	for _, d := range cfg.Destinations {
+
+		// destinations share the rest of the push configuration, e.g. the message format and the compression
+		dCfg := cfg
+		dCfg.Type = d.Type
+		dCfg.PushEndpoint = d.PushEndpoint
+		dCfg.AuthorizationHeader = d.AuthorizationHeader
+		dCfg.MattermostUrl = d.MattermostUrl
+		dCfg.MattermostUsername = d.MattermostUsername
+		dCfg.MattermostChannel = d.MattermostChannel
+
+		ds, err := newSender(dCfg, client)
+		if err != nil {
+			return nil, err
+		}
+
+		ss = append(ss, ds)
+	}
+
+	return NewMultiSender(ss, cfg.DeliveryPolicy), nil
+}
+
+// newSender creates and returns a new sender for a single destination
+func newSender(cfg amsPb.PushConfig, client *http.Client) (Sender, error) {
+
 	switch cfg.Type {
 	case amsPb.PushType_HTTP_ENDPOINT:
-		return NewHttpSender(cfg.PushEndpoint, cfg.AuthorizationHeader, client), nil
+		s := NewHttpSender(cfg.PushEndpoint, cfg.AuthorizationHeader, client)
+		s.base64Payload = !cfg.Base_64Decode
+		s.compression = cfg.Compression
+		s.compressionThreshold = cfg.CompressionThreshold
+		return s, nil
 	case amsPb.PushType_MATTERMOST:
 		return NewMattermostSender(cfg.MattermostUrl, cfg.MattermostUsername, cfg.MattermostChannel, client), nil
 	}
@@ -52,18 +112,74 @@ type PushMsg struct {
 type PushMsgs struct {
 	// the actual messages
 	Messages []PushMsg `json:"messages"`
+	// the full name of the subscription the messages were consumed from
+	Subscription string `json:"-"`
+	// the full name of the topic the messages were published to
+	Topic string `json:"-"`
 }
 
 // DetermineMessageFormat decides what message format should be used depending on the number of messages
-func DetermineMessageFormat(numberOfMessages int64) pushMessageFormat {
+// and the message format that has been requested by the push configuration
+func DetermineMessageFormat(numberOfMessages int64, messageFormat string) pushMessageFormat {
 
 	var f pushMessageFormat
 
-	if numberOfMessages == 1 {
-		f = SingleMessageFormat
-	} else {
-		f = MultipleMessageFormat
+	switch pushMessageFormat(messageFormat) {
+	case CloudEventsBinaryFormat, CloudEventsStructuredFormat:
+		// cloudevents binary mode can only carry a single event per request,
+		// so any multi message push falls back to the batched mode
+		if numberOfMessages == 1 {
+			f = pushMessageFormat(messageFormat)
+		} else {
+			f = CloudEventsBatchFormat
+		}
+	default:
+		if numberOfMessages == 1 {
+			f = SingleMessageFormat
+		} else {
+			f = MultipleMessageFormat
+		}
 	}
 
 	return f
 }
+
+// IsPayloadTooLarge checks whether or not the error indicates that the remote destination rejected the payload due to its size
+func IsPayloadTooLarge(err error) bool {
+	herr, ok := err.(*HttpError)
+	return ok && herr.StatusCode == http.StatusRequestEntityTooLarge
+}
+
+// IsValidMessageFormat checks whether or not the provided message format of a push configuration is supported
+func IsValidMessageFormat(messageFormat string) bool {
+	switch pushMessageFormat(messageFormat) {
+	case "", CloudEventsBinaryFormat, CloudEventsStructuredFormat:
+		return true
+	}
+	return false
+}
+
+// withPayload adds the pushed messages to the provided log fields, based on the payload logging mode of the service
+func withPayload(fields log.Fields, msgs PushMsgs) log.Fields {
+
+	switch redact.PayloadMode() {
+
+	case redact.FullPayloads:
+
+		fields["messages"] = msgs
+
+	case redact.MetadataPayloads:
+
+		ids := make([]string, 0, len(msgs.Messages))
+		size := 0
+		for _, msg := range msgs.Messages {
+			ids = append(ids, msg.Msg.ID)
+			size += len(msg.Msg.Data)
+		}
+
+		fields["message_ids"] = ids
+		fields["payload_bytes"] = size
+	}
+
+	return fields
+}
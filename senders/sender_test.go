@@ -2,9 +2,13 @@ package senders
 
 import (
 	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	ams "github.com/ARGOeu/ams-push-server/pkg/ams/v1"
+	"github.com/ARGOeu/ams-push-server/redact"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/suite"
 	"net/http"
 	"testing"
+	"time"
 )
 
 type SenderTestSuite struct {
@@ -33,12 +37,120 @@ func (suite *SenderTestSuite) TestNew() {
 	s2, e2 := New(pushCFG2, &http.Client{})
 	suite.IsType(&MattermostSender{}, s2)
 	suite.Nil(e2)
+
+	// compression settings are passed to http senders
+	pushCFG3 := amsPb.PushConfig{
+		Type:                 amsPb.PushType_HTTP_ENDPOINT,
+		PushEndpoint:         "example.com",
+		Compression:          "gzip",
+		CompressionThreshold: 1024,
+	}
+	s3, e3 := New(pushCFG3, &http.Client{})
+	suite.Equal("gzip", s3.(*HttpSender).compression)
+	suite.Equal(int64(1024), s3.(*HttpSender).compressionThreshold)
+	suite.True(s3.(*HttpSender).base64Payload)
+	suite.Nil(e3)
+
+	// additional destinations produce a multi sender
+	pushCFG4 := amsPb.PushConfig{
+		Type:           amsPb.PushType_HTTP_ENDPOINT,
+		PushEndpoint:   "https://example.com",
+		Compression:    "gzip",
+		DeliveryPolicy: amsPb.DeliveryPolicy_BEST_EFFORT,
+		Destinations: []*amsPb.Destination{
+			{
+				Type:              amsPb.PushType_MATTERMOST,
+				MattermostUrl:     "https://mattermost.example.com/hooks/1",
+				MattermostChannel: "ops",
+			},
+			{
+				Type:         amsPb.PushType_HTTP_ENDPOINT,
+				PushEndpoint: "https://other.example.com",
+			},
+		},
+	}
+	s4, e4 := New(pushCFG4, &http.Client{})
+	m4 := s4.(*MultiSender)
+	suite.Equal(3, len(m4.senders))
+	suite.Equal(amsPb.DeliveryPolicy_BEST_EFFORT, m4.policy)
+	suite.Equal("https://example.com", m4.senders[0].Destination())
+	suite.Equal("ops", m4.senders[1].(*MattermostSender).channel)
+	suite.Equal("https://other.example.com", m4.senders[2].Destination())
+	suite.Equal("gzip", m4.senders[2].(*HttpSender).compression)
+	suite.Nil(e4)
+
+	// the timeout of the subscription overrides the one of the shared client, for all of its destinations
+	client := &http.Client{Timeout: 30 * time.Second}
+	pushCFG5 := pushCFG4
+	pushCFG5.TimeoutMs = 2500
+	s5, e5 := New(pushCFG5, client)
+	m5 := s5.(*MultiSender)
+	suite.Equal(2500*time.Millisecond, m5.senders[0].(*HttpSender).client.Timeout)
+	suite.Equal(2500*time.Millisecond, m5.senders[1].(*MattermostSender).client.Timeout)
+	suite.Equal(30*time.Second, client.Timeout)
+	suite.Nil(e5)
+
+	s6, _ := New(pushCFG3, client)
+	suite.Equal(client, s6.(*HttpSender).client)
+}
+
+// TestIsValidCompression tests the IsValidCompression functionality
+func (suite *SenderTestSuite) TestIsValidCompression() {
+	suite.True(IsValidCompression(""))
+	suite.True(IsValidCompression("none"))
+	suite.True(IsValidCompression("gzip"))
+	suite.True(IsValidCompression("zstd"))
+	suite.False(IsValidCompression("br"))
 }
 
 // TestDetermineMessageFormat tests the DetermineMessageFormat functionality
 func (suite *SenderTestSuite) TestDetermineMessageFormat() {
-	suite.Equal(SingleMessageFormat, DetermineMessageFormat(1))
-	suite.Equal(MultipleMessageFormat, DetermineMessageFormat(30))
+	suite.Equal(SingleMessageFormat, DetermineMessageFormat(1, ""))
+	suite.Equal(MultipleMessageFormat, DetermineMessageFormat(30, ""))
+
+	// cloudevents formats
+	suite.Equal(CloudEventsBinaryFormat, DetermineMessageFormat(1, "cloudevents-binary"))
+	suite.Equal(CloudEventsStructuredFormat, DetermineMessageFormat(1, "cloudevents-structured"))
+	suite.Equal(CloudEventsBatchFormat, DetermineMessageFormat(30, "cloudevents-binary"))
+	suite.Equal(CloudEventsBatchFormat, DetermineMessageFormat(30, "cloudevents-structured"))
+}
+
+// TestIsValidMessageFormat tests the IsValidMessageFormat functionality
+func (suite *SenderTestSuite) TestIsValidMessageFormat() {
+	suite.True(IsValidMessageFormat(""))
+	suite.True(IsValidMessageFormat("cloudevents-binary"))
+	suite.True(IsValidMessageFormat("cloudevents-structured"))
+	suite.False(IsValidMessageFormat("cloudevents-batch"))
+	suite.False(IsValidMessageFormat("unknown"))
+}
+
+// TestWithPayload tests that messages are logged based on the payload logging mode
+func (suite *SenderTestSuite) TestWithPayload() {
+
+	msgs := PushMsgs{
+		Messages: []PushMsg{
+			{Sub: "sub", Msg: ams.Message{ID: "id_1", Data: "data-1"}},
+			{Sub: "sub", Msg: ams.Message{ID: "id_2", Data: "data"}},
+		},
+	}
+
+	defer redact.SetPayloadMode("")
+
+	// metadata is the default mode
+	suite.Equal(log.Fields{
+		"type":          "system_log",
+		"message_ids":   []string{"id_1", "id_2"},
+		"payload_bytes": 10,
+	}, withPayload(log.Fields{"type": "system_log"}, msgs))
+
+	redact.SetPayloadMode(redact.NoPayloads)
+	suite.Equal(log.Fields{"type": "system_log"}, withPayload(log.Fields{"type": "system_log"}, msgs))
+
+	redact.SetPayloadMode(redact.FullPayloads)
+	suite.Equal(log.Fields{
+		"type":     "system_log",
+		"messages": msgs,
+	}, withPayload(log.Fields{"type": "system_log"}, msgs))
 }
 
 func TestSenderTestSuite(t *testing.T) {
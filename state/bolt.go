@@ -0,0 +1,119 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"sync"
+	"time"
+)
+
+// workersBucket is the bucket that holds the state of the push workers, keyed by the name of their subscription
+var workersBucket = []byte("workers")
+
+// BoltStore persists the state of the push workers in a bolt database file
+type BoltStore struct {
+	db *bolt.DB
+	// the last state that was written for every subscription, so that unchanged states aren't written again
+	mutex   sync.Mutex
+	written map[string][]byte
+}
+
+// NewBoltStore opens, or creates, the bolt database at the provided path.
+// The file is locked for as long as the store is open, so a second instance of the service can't use it
+func NewBoltStore(path string) (*BoltStore, error) {
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Errorf("Could not open state store %v, %v", path, err.Error())
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(workersBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Errorf("Could not initialise state store %v, %v", path, err.Error())
+	}
+
+	return &BoltStore{
+		db:      db,
+		written: make(map[string][]byte),
+	}, nil
+}
+
+// Put stores the state of a worker, unless it is the same as the one last stored
+func (b *BoltStore) Put(s WorkerState) error {
+
+	v, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if bytes.Equal(b.written[s.Name()], v) {
+		return nil
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(workersBucket).Put([]byte(s.Name()), v)
+	})
+	if err != nil {
+		return err
+	}
+
+	b.written[s.Name()] = v
+
+	return nil
+}
+
+// Delete removes the state of the worker of the provided subscription
+func (b *BoltStore) Delete(name string) error {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(workersBucket).Delete([]byte(name))
+	})
+	if err != nil {
+		return err
+	}
+
+	delete(b.written, name)
+
+	return nil
+}
+
+// List returns the state of every worker, sorted by the name of its subscription.
+// States that can't be decoded are skipped
+func (b *BoltStore) List() ([]WorkerState, error) {
+
+	states := make([]WorkerState, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		// bolt iterates the keys in byte order
+		return tx.Bucket(workersBucket).ForEach(func(k, v []byte) error {
+			s := WorkerState{}
+			if json.Unmarshal(v, &s) != nil || s.Name() != string(k) {
+				return nil
+			}
+			states = append(states, s)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// Close closes the database and releases its lock
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
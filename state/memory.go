@@ -0,0 +1,64 @@
+package state
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps the state of the push workers in memory
+type MemoryStore struct {
+	mutex  sync.Mutex
+	states map[string]WorkerState
+}
+
+// NewMemoryStore returns an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states: make(map[string]WorkerState),
+	}
+}
+
+// Put stores the state of a worker
+func (m *MemoryStore) Put(s WorkerState) error {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.states[s.Name()] = s
+
+	return nil
+}
+
+// Delete removes the state of the worker of the provided subscription
+func (m *MemoryStore) Delete(name string) error {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.states, name)
+
+	return nil
+}
+
+// List returns the state of every worker, sorted by the name of its subscription
+func (m *MemoryStore) List() ([]WorkerState, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	states := make([]WorkerState, 0, len(m.states))
+	for _, s := range m.states {
+		states = append(states, s)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name() < states[j].Name()
+	})
+
+	return states, nil
+}
+
+// Close does nothing, the state is gone along with the store
+func (m *MemoryStore) Close() error {
+	return nil
+}
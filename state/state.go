@@ -0,0 +1,61 @@
+package state
+
+import (
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"time"
+)
+
+// NoPath keeps the state of the push workers in memory, it doesn't outlive a restart
+const NoPath = ""
+
+// WorkerState is the state of a push worker that is carried over a restart of the service
+type WorkerState struct {
+	// the subscription that the worker handles, along with its push configuration
+	Subscription *amsPb.Subscription `json:"subscription"`
+	// the interval of the retry policy, for the policies that adapt it to the outcome of the push cycles
+	RetryInterval time.Duration `json:"retry_interval,omitempty"`
+	// whether the last push cycle failed, as far as the retry policy is concerned
+	Failing bool `json:"failing,omitempty"`
+	// the error of the last push cycle, empty if it completed successfully
+	LastError string `json:"last_error,omitempty"`
+	// the maximum size in bytes of a pushed batch, as it has shrunk after the receivers' rejections
+	BatchBytes int64 `json:"batch_bytes,omitempty"`
+	// delivery information of each destination of a subscription with multiple destinations
+	Destinations []DestinationState `json:"destinations,omitempty"`
+}
+
+// Name returns the full name of the subscription that the state refers to
+func (s WorkerState) Name() string {
+	return s.Subscription.GetFullName()
+}
+
+// DestinationState holds the delivery information of a single destination
+type DestinationState struct {
+	Destination       string `json:"destination"`
+	DeliveredMessages uint64 `json:"delivered_messages"`
+	FailedDeliveries  uint64 `json:"failed_deliveries"`
+	LastError         string `json:"last_error,omitempty"`
+}
+
+// Store persists the state of the push workers
+type Store interface {
+	// Put stores the state of a worker, replacing any previous state of the same subscription
+	Put(s WorkerState) error
+	// Delete removes the state of the worker of the provided subscription
+	Delete(name string) error
+	// List returns the state of every worker, sorted by the name of its subscription
+	List() ([]WorkerState, error)
+	// Close releases the resources of the store
+	Close() error
+}
+
+// New acts as a store factory, the path can be empty to keep the state in memory
+// or the path of the file where the state is persisted
+func New(path string) (Store, error) {
+
+	if path == NoPath {
+		return NewMemoryStore(), nil
+	}
+
+	return NewBoltStore(path)
+}
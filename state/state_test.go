@@ -0,0 +1,121 @@
+package state
+
+import (
+	amsPb "github.com/ARGOeu/ams-push-server/api/v1/grpc/proto"
+	"github.com/golang/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type StateTestSuite struct {
+	suite.Suite
+}
+
+func workerState(name string) WorkerState {
+	return WorkerState{
+		Subscription: &amsPb.Subscription{
+			FullName:  name,
+			FullTopic: "/projects/p1/topics/t1",
+			PushConfig: &amsPb.PushConfig{
+				Type:         amsPb.PushType_HTTP_ENDPOINT,
+				PushEndpoint: "https://example.com/receive_here",
+				RetryPolicy:  &amsPb.RetryPolicy{Type: "slowstart"},
+			},
+		},
+		RetryInterval: 4 * time.Second,
+		Failing:       true,
+		LastError:     "Could not send message",
+		BatchBytes:    1024,
+		Destinations: []DestinationState{
+			{Destination: "https://example.com/receive_here", DeliveredMessages: 10, FailedDeliveries: 2},
+		},
+	}
+}
+
+// testStore tests the storing, listing and removal of states against any store
+func (suite *StateTestSuite) testStore(s Store) {
+
+	states, err := s.List()
+	suite.Nil(err)
+	suite.Empty(states)
+
+	suite.Nil(s.Put(workerState("/projects/p1/subscriptions/sub2")))
+	suite.Nil(s.Put(workerState("/projects/p1/subscriptions/sub1")))
+
+	// a state replaces the previous state of the same subscription
+	ws := workerState("/projects/p1/subscriptions/sub1")
+	ws.LastError = ""
+	ws.Failing = false
+	suite.Nil(s.Put(ws))
+
+	states, err = s.List()
+	suite.Nil(err)
+	suite.Equal(2, len(states))
+	suite.Equal("/projects/p1/subscriptions/sub1", states[0].Name())
+	suite.Equal("", states[0].LastError)
+	suite.False(states[0].Failing)
+	suite.Equal("/projects/p1/subscriptions/sub2", states[1].Name())
+	suite.True(proto.Equal(workerState("x").Subscription.PushConfig, states[1].Subscription.PushConfig))
+	suite.Equal(4*time.Second, states[1].RetryInterval)
+	suite.Equal(int64(1024), states[1].BatchBytes)
+	suite.Equal(uint64(10), states[1].Destinations[0].DeliveredMessages)
+
+	suite.Nil(s.Delete("/projects/p1/subscriptions/sub2"))
+	// deleting a state that doesn't exist is not an error
+	suite.Nil(s.Delete("/projects/p1/subscriptions/unknown"))
+
+	states, err = s.List()
+	suite.Nil(err)
+	suite.Equal(1, len(states))
+}
+
+// TestMemoryStore tests the memory store
+func (suite *StateTestSuite) TestMemoryStore() {
+
+	s, err := New(NoPath)
+	suite.Nil(err)
+	suite.IsType(&MemoryStore{}, s)
+
+	suite.testStore(s)
+	suite.Nil(s.Close())
+}
+
+// TestBoltStore tests the bolt store and that the states outlive the store
+func (suite *StateTestSuite) TestBoltStore() {
+
+	path := filepath.Join(suite.T().TempDir(), "state.db")
+
+	s, err := New(path)
+	suite.Nil(err)
+	suite.IsType(&BoltStore{}, s)
+
+	suite.testStore(s)
+
+	// the file is locked while the store is open
+	_, err = NewBoltStore(path)
+	suite.NotNil(err)
+
+	suite.Nil(s.Close())
+
+	s, err = New(path)
+	suite.Nil(err)
+
+	states, err := s.List()
+	suite.Nil(err)
+	suite.Equal(1, len(states))
+	suite.Equal("/projects/p1/subscriptions/sub1", states[0].Name())
+	suite.Nil(s.Close())
+
+	// a path that can't be opened
+	_, err = New(filepath.Join(suite.T().TempDir(), "missing", "state.db"))
+	suite.NotNil(err)
+}
+
+func TestStateTestSuite(t *testing.T) {
+	log.SetOutput(io.Discard)
+	suite.Run(t, new(StateTestSuite))
+}
@@ -0,0 +1,72 @@
+package verifiers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CachedVerifier wraps a verifier and remembers its successful verifications for a limited period.
+// Failed verifications are not cached, so that an endpoint can be verified again as soon as it has been fixed
+type CachedVerifier struct {
+	verifier Verifier
+	ttl      time.Duration
+	mutex    sync.Mutex
+	// expiration time of each verified subscription and endpoint pair
+	verified map[string]time.Time
+	now      func() time.Time
+}
+
+// NewCachedVerifier initialises and returns a new cached verifier
+func NewCachedVerifier(verifier Verifier, ttl time.Duration) *CachedVerifier {
+	v := new(CachedVerifier)
+	v.verifier = verifier
+	v.ttl = ttl
+	v.verified = make(map[string]time.Time)
+	v.now = time.Now
+	return v
+}
+
+// Verify verifies the endpoint using the underlying verifier, unless it has been verified recently
+func (v *CachedVerifier) Verify(ctx context.Context, subscription string, endpoint string) error {
+
+	key := subscription + " " + endpoint
+
+	v.mutex.Lock()
+	expires, found := v.verified[key]
+	if found && !v.now().Before(expires) {
+		delete(v.verified, key)
+		found = false
+	}
+	v.mutex.Unlock()
+
+	if found {
+		return nil
+	}
+
+	err := v.verifier.Verify(ctx, subscription, endpoint)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if err != nil {
+		delete(v.verified, key)
+		return err
+	}
+
+	v.evictExpired()
+	v.verified[key] = v.now().Add(v.ttl)
+
+	return nil
+}
+
+// evictExpired removes the expired verifications of the pairs that are no longer looked up.
+// The caller must hold the mutex
+func (v *CachedVerifier) evictExpired() {
+	now := v.now()
+	for key, expires := range v.verified {
+		if !now.Before(expires) {
+			delete(v.verified, key)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package verifiers
+
+import (
+	"context"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type CachedVerifierTestSuite struct {
+	suite.Suite
+}
+
+// TestVerify tests that successful verifications are cached until they expire
+func (suite *CachedVerifierTestSuite) TestVerify() {
+
+	rt := new(MockVerifierRoundTripper)
+	v := NewCachedVerifier(NewChallengeVerifier(&http.Client{Transport: rt}), time.Hour)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	v.now = func() time.Time { return now }
+
+	// first verification reaches the endpoint
+	suite.Nil(v.Verify(context.Background(), "sub", "https://echo.example.com/receive_here"))
+	suite.Equal(1, rt.Requests)
+
+	// cached verification
+	suite.Nil(v.Verify(context.Background(), "sub", "https://echo.example.com/receive_here"))
+	suite.Equal(1, rt.Requests)
+
+	// a different subscription on the same endpoint is verified separately
+	suite.Nil(v.Verify(context.Background(), "sub2", "https://echo.example.com/receive_here"))
+	suite.Equal(2, rt.Requests)
+
+	// expired verification
+	now = now.Add(2 * time.Hour)
+	suite.Nil(v.Verify(context.Background(), "sub", "https://echo.example.com/receive_here"))
+	suite.Equal(3, rt.Requests)
+
+	// failures are not cached
+	suite.NotNil(v.Verify(context.Background(), "sub", "https://wrong.example.com/receive_here"))
+	suite.NotNil(v.Verify(context.Background(), "sub", "https://wrong.example.com/receive_here"))
+	suite.Equal(5, rt.Requests)
+}
+
+// TestVerifyEviction tests that expired verifications are removed from the cache
+func (suite *CachedVerifierTestSuite) TestVerifyEviction() {
+
+	mv := new(MockVerifier)
+	v := NewCachedVerifier(mv, time.Hour)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	v.now = func() time.Time { return now }
+
+	suite.Nil(v.Verify(context.Background(), "sub", "https://one.example.com"))
+	suite.Nil(v.Verify(context.Background(), "sub", "https://two.example.com"))
+	suite.Len(v.verified, 2)
+
+	// an expired verification is removed on lookup
+	now = now.Add(2 * time.Hour)
+	mv.Unverified = []string{"https://one.example.com"}
+	suite.NotNil(v.Verify(context.Background(), "sub", "https://one.example.com"))
+	suite.NotContains(v.verified, "sub https://one.example.com")
+	suite.Len(v.verified, 1)
+
+	// storing a new verification sweeps the expired pairs that are not looked up again
+	suite.Nil(v.Verify(context.Background(), "sub", "https://three.example.com"))
+	suite.Equal(map[string]time.Time{"sub https://three.example.com": now.Add(time.Hour)}, v.verified)
+}
+
+func TestCachedVerifierTestSuite(t *testing.T) {
+	suite.Run(t, new(CachedVerifierTestSuite))
+}
@@ -0,0 +1,86 @@
+package verifiers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ChallengeQueryParam is the query parameter that carries the challenge token
+const ChallengeQueryParam = "challenge"
+
+// ChallengeVerifier verifies an endpoint by sending it a random token that the endpoint has to echo back
+type ChallengeVerifier struct {
+	client *http.Client
+}
+
+// NewChallengeVerifier initialises and returns a new challenge verifier
+func NewChallengeVerifier(client *http.Client) *ChallengeVerifier {
+	v := new(ChallengeVerifier)
+	v.client = client
+	return v
+}
+
+// Verify sends a GET request to the endpoint containing a random challenge token
+// and expects a successful response whose body is the token itself
+func (v *ChallengeVerifier) Verify(ctx context.Context, subscription string, endpoint string) error {
+
+	token, err := challengeToken()
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	q := u.Query()
+	q.Set(ChallengeQueryParam, token)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("endpoint responded to the verification challenge with %v", resp.StatusCode)
+	}
+
+	// the token is short, don't read more than needed from the endpoint
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+
+	if string(bytes.TrimSpace(body)) != token {
+		return errors.New("endpoint did not echo the verification challenge")
+	}
+
+	return nil
+}
+
+// challengeToken generates a random hex encoded token
+func challengeToken() (string, error) {
+
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,35 @@
+package verifiers
+
+import (
+	"context"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+)
+
+type ChallengeVerifierTestSuite struct {
+	suite.Suite
+}
+
+// TestVerify tests the challenge verification of an endpoint
+func (suite *ChallengeVerifierTestSuite) TestVerify() {
+
+	client := &http.Client{Transport: new(MockVerifierRoundTripper)}
+	v := NewChallengeVerifier(client)
+
+	// the endpoint echoes the token
+	e1 := v.Verify(context.Background(), "sub", "https://echo.example.com/receive_here?key=value")
+	suite.Nil(e1)
+
+	// the endpoint responds with a different token
+	e2 := v.Verify(context.Background(), "sub", "https://wrong.example.com/receive_here")
+	suite.Equal("endpoint did not echo the verification challenge", e2.Error())
+
+	// the endpoint doesn't handle the challenge
+	e3 := v.Verify(context.Background(), "sub", "https://other.example.com/receive_here")
+	suite.Equal("endpoint responded to the verification challenge with 404", e3.Error())
+}
+
+func TestChallengeVerifierTestSuite(t *testing.T) {
+	suite.Run(t, new(ChallengeVerifierTestSuite))
+}
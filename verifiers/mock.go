@@ -0,0 +1,89 @@
+package verifiers
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type MockVerifier struct {
+	// endpoints that fail verification
+	Unverified []string
+	// endpoints that have been checked
+	Verified []string
+}
+
+func (v *MockVerifier) Verify(ctx context.Context, subscription string, endpoint string) error {
+
+	for _, e := range v.Unverified {
+		if e == endpoint {
+			return errors.New("endpoint not verified")
+		}
+	}
+
+	v.Verified = append(v.Verified, endpoint)
+
+	return nil
+}
+
+type MockVerifierRoundTripper struct {
+	Requests int
+}
+
+func (m *MockVerifierRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	var resp *http.Response
+
+	m.Requests++
+
+	header := make(http.Header)
+	header.Set("Content-type", "text/plain")
+
+	switch r.URL.Host + r.URL.Path {
+
+	case "echo.example.com/receive_here":
+		resp = &http.Response{
+			StatusCode: 200,
+			// Send response to be tested
+			Body: io.NopCloser(strings.NewReader(r.URL.Query().Get(ChallengeQueryParam) + "\n")),
+			// Must be set to non-nil value or it panics
+			Header: header,
+		}
+
+	case "wrong.example.com/receive_here":
+		resp = &http.Response{
+			StatusCode: 200,
+			// Send response to be tested
+			Body: io.NopCloser(strings.NewReader("wrong token")),
+			// Must be set to non-nil value or it panics
+			Header: header,
+		}
+
+	case "verified.example.com" + WellKnownPath:
+		body := strings.Join([]string{
+			VerificationHash("/projects/p1/subscriptions/other"),
+			VerificationHash("/projects/p1/subscriptions/sub1"),
+		}, "\n")
+
+		resp = &http.Response{
+			StatusCode: 200,
+			// Send response to be tested
+			Body: io.NopCloser(strings.NewReader(body)),
+			// Must be set to non-nil value or it panics
+			Header: header,
+		}
+
+	default:
+		resp = &http.Response{
+			StatusCode: 404,
+			// Send response to be tested
+			Body: io.NopCloser(strings.NewReader("not found")),
+			// Must be set to non-nil value or it panics
+			Header: header,
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,64 @@
+package verifiers
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+const (
+	NoVerification        = "none"
+	ChallengeVerification = "challenge"
+	WellKnownVerification = "well_known"
+)
+
+// DefaultCacheTTL is the period for which a successful verification is trusted when no ttl has been configured
+const DefaultCacheTTL = 24 * time.Hour
+
+// Verifier checks whether or not a push endpoint has agreed to receive the messages of a subscription
+type Verifier interface {
+	// Verify returns an error if the provided endpoint couldn't be verified for the provided subscription
+	Verify(ctx context.Context, subscription string, endpoint string) error
+}
+
+// IsValidType checks whether or not the provided endpoint verification type is supported
+func IsValidType(verifierType string) bool {
+	switch verifierType {
+	case "", NoVerification, ChallengeVerification, WellKnownVerification:
+		return true
+	}
+	return false
+}
+
+// New acts as a verifier factory, creates and returns a new verifier based on the provided type.
+// Successful verifications are cached for the provided ttl
+func New(verifierType string, client *http.Client, ttl time.Duration) (Verifier, error) {
+
+	var v Verifier
+
+	switch verifierType {
+	case "", NoVerification:
+		return new(noVerifier), nil
+	case ChallengeVerification:
+		v = NewChallengeVerifier(client)
+	case WellKnownVerification:
+		v = NewWellKnownVerifier(client)
+	default:
+		return nil, errors.Errorf("verifier %v not yet implemented", verifierType)
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	return NewCachedVerifier(v, ttl), nil
+}
+
+// noVerifier accepts every endpoint
+type noVerifier struct{}
+
+// Verify always succeeds
+func (v *noVerifier) Verify(ctx context.Context, subscription string, endpoint string) error {
+	return nil
+}
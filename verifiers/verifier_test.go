@@ -0,0 +1,49 @@
+package verifiers
+
+import (
+	"context"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type VerifierTestSuite struct {
+	suite.Suite
+}
+
+// TestNew tests that the verifier factory behaves properly
+func (suite *VerifierTestSuite) TestNew() {
+
+	v1, e1 := New("", &http.Client{}, 0)
+	suite.IsType(&noVerifier{}, v1)
+	suite.Nil(v1.Verify(context.Background(), "sub", "https://example.com"))
+	suite.Nil(e1)
+
+	v2, e2 := New(ChallengeVerification, &http.Client{}, 0)
+	suite.IsType(&ChallengeVerifier{}, v2.(*CachedVerifier).verifier)
+	suite.Equal(DefaultCacheTTL, v2.(*CachedVerifier).ttl)
+	suite.Nil(e2)
+
+	v3, e3 := New(WellKnownVerification, &http.Client{}, time.Hour)
+	suite.IsType(&WellKnownVerifier{}, v3.(*CachedVerifier).verifier)
+	suite.Equal(time.Hour, v3.(*CachedVerifier).ttl)
+	suite.Nil(e3)
+
+	v4, e4 := New("unknown", &http.Client{}, 0)
+	suite.Nil(v4)
+	suite.Equal("verifier unknown not yet implemented", e4.Error())
+}
+
+// TestIsValidType tests the IsValidType functionality
+func (suite *VerifierTestSuite) TestIsValidType() {
+	suite.True(IsValidType(""))
+	suite.True(IsValidType("none"))
+	suite.True(IsValidType("challenge"))
+	suite.True(IsValidType("well_known"))
+	suite.False(IsValidType("dns"))
+}
+
+func TestVerifierTestSuite(t *testing.T) {
+	suite.Run(t, new(VerifierTestSuite))
+}
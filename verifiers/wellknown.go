@@ -0,0 +1,84 @@
+package verifiers
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WellKnownPath is the path, relative to the endpoint's host, of the file that lists the verified subscriptions
+const WellKnownPath = "/.well-known/ams-push-verification"
+
+// WellKnownVerifier verifies an endpoint by looking up the subscription's verification hash
+// in a well known file served by the endpoint's host
+type WellKnownVerifier struct {
+	client *http.Client
+}
+
+// NewWellKnownVerifier initialises and returns a new well known verifier
+func NewWellKnownVerifier(client *http.Client) *WellKnownVerifier {
+	v := new(WellKnownVerifier)
+	v.client = client
+	return v
+}
+
+// VerificationHash returns the hash that has to be present in the well known file of an endpoint
+// in order for it to receive the messages of the provided subscription
+func VerificationHash(subscription string) string {
+	h := sha256.Sum256([]byte(subscription))
+	return hex.EncodeToString(h[:])
+}
+
+// Verify retrieves the well known file of the endpoint's host
+// and expects one of its lines to contain the verification hash of the subscription
+func (v *WellKnownVerifier) Verify(ctx context.Context, subscription string, endpoint string) error {
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	wk := url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   WellKnownPath,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wk.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%v responded with %v", wk.String(), resp.StatusCode)
+	}
+
+	hash := VerificationHash(subscription)
+
+	// the file may list the hashes of several subscriptions, one per line
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 1<<20))
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == hash {
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return errors.Errorf("verification hash %v not found in %v", hash, wk.String())
+}
@@ -0,0 +1,41 @@
+package verifiers
+
+import (
+	"context"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+)
+
+type WellKnownVerifierTestSuite struct {
+	suite.Suite
+}
+
+// TestVerificationHash tests the generation of the verification hash of a subscription
+func (suite *WellKnownVerifierTestSuite) TestVerificationHash() {
+	suite.Equal("ddc6e2b224d0fd821669202258386936fc9ce2899e215eec6322b95f8dd96d6a", VerificationHash("sub"))
+}
+
+// TestVerify tests the well known file verification of an endpoint
+func (suite *WellKnownVerifierTestSuite) TestVerify() {
+
+	client := &http.Client{Transport: new(MockVerifierRoundTripper)}
+	v := NewWellKnownVerifier(client)
+
+	// the well known file contains the subscription's hash
+	e1 := v.Verify(context.Background(), "/projects/p1/subscriptions/sub1", "https://verified.example.com/receive_here")
+	suite.Nil(e1)
+
+	// the well known file doesn't contain the subscription's hash
+	e2 := v.Verify(context.Background(), "/projects/p1/subscriptions/sub2", "https://verified.example.com/receive_here")
+	suite.Equal("verification hash "+VerificationHash("/projects/p1/subscriptions/sub2")+
+		" not found in https://verified.example.com/.well-known/ams-push-verification", e2.Error())
+
+	// the host doesn't serve a well known file
+	e3 := v.Verify(context.Background(), "/projects/p1/subscriptions/sub1", "https://other.example.com/receive_here")
+	suite.Equal("https://other.example.com/.well-known/ams-push-verification responded with 404", e3.Error())
+}
+
+func TestWellKnownVerifierTestSuite(t *testing.T) {
+	suite.Run(t, new(WellKnownVerifierTestSuite))
+}